@@ -6,24 +6,48 @@ package database
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/zeebo/errs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+//secretPattern matches the bcrypt password hashes and sha256 token hashes we store so they are
+//kept out of the statement log
+var secretPattern = regexp.MustCompile(`\$2[aby]?\$\d\d\$[./A-Za-z0-9]{53}|\b[0-9a-f]{64}\b`)
+
 func init() {
 	WrapErr = func(e *Error) error {
 		return errs.Wrap(e)
 	}
+
+	//statements are only logged at debug level. building the message is skipped otherwise since
+	//every query goes through here
 	Logger = func(format string, args ...interface{}) {
-		fmt.Printf(format+"\n", args...)
+		if logrus.GetLevel() < logrus.DebugLevel {
+			return
+		}
+		stmt := format
+		if len(args) > 0 {
+			stmt = fmt.Sprintf(format, args...)
+		}
+		stmt = strings.TrimSpace(stmt)
+		logrus.WithField("sql", secretPattern.ReplaceAllString(stmt, "[redacted]")).Debug("query")
 	}
 }
 
 func (db *DB) WithTx(ctx context.Context,
 	fn func(context.Context, *Tx) error) (err error) {
 
-	tx, err := db.Open(ctx)
+	ctx, span := tracer.Start(ctx, "database.WithTx")
+	defer span.End()
+
+	start := time.Now()
+	tx, err := db.begin(ctx)
 	if err != nil {
 		return err
 	}
@@ -31,13 +55,39 @@ func (db *DB) WithTx(ctx context.Context,
 		if err == nil {
 			err = tx.Commit()
 		} else {
-			logrus.Error(err)
-			tx.Rollback()
+			logrus.Errorf("rolling back: %v", err)
+			rollback_err := tx.Rollback()
+			if rollback_err != nil {
+				logrus.Errorf("rollback failed: %v", rollback_err)
+			}
+		}
+
+		outcome := "commit"
+		if err != nil {
+			outcome = "rollback"
+			txRollbacks.Inc()
+			span.SetStatus(codes.Error, err.Error())
 		}
+		span.SetAttributes(attribute.String("db.outcome", outcome))
+		txDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
 	}()
 	return fn(ctx, tx)
 }
 
+//begin is Open except the driver sees ctx, which is how statements find the transaction's span.
+//cancellation is left out since transactions never used to be rolled back by it
+func (db *DB) begin(ctx context.Context) (*Tx, error) {
+	tx, err := db.DB.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return nil, db.makeErr(err)
+	}
+
+	return &Tx{
+		Tx:        tx,
+		txMethods: db.wrapTx(tx),
+	}, nil
+}
+
 func IsConstraintViolationError(err error) bool {
 	if e, ok := err.(*Error); ok {
 		return e.Code == ErrorCode_ConstraintViolation
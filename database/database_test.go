@@ -57,6 +57,7 @@ func TestDeathlyHand(t *testing.T) {
 		Buyer_Id("ID"),
 		Buyer_FirstName("FIRST"),
 		Buyer_LastName("LAST"),
+		Buyer_SaltedHash("HASH"),
 	)
 	require.NoError(err)
 
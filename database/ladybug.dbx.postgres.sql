@@ -4,15 +4,34 @@ CREATE TABLE addresses (
 	pk bigserial NOT NULL,
 	buyer_pk bigint NOT NULL,
 	created_at timestamp with time zone NOT NULL,
-	street_address text NOT NULL,
+	country_code text NOT NULL,
+	line1 text NOT NULL,
+	line2 text NOT NULL,
 	city text NOT NULL,
-	state text NOT NULL,
-	zip integer NOT NULL,
+	region text NOT NULL,
+	postal_code text NOT NULL,
 	is_billing boolean NOT NULL,
+	is_default_billing boolean NOT NULL,
+	is_default_shipping boolean NOT NULL,
 	id text NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE audit_events (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	actor_kind text NOT NULL,
+	actor_id text NOT NULL,
+	action text NOT NULL,
+	target_kind text NOT NULL,
+	target_id text NOT NULL,
+	diff text NOT NULL,
+	ip text NOT NULL,
+	user_agent text NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
 CREATE TABLE buyers (
 	pk bigserial NOT NULL,
 	created_at timestamp with time zone NOT NULL,
@@ -20,16 +39,27 @@ CREATE TABLE buyers (
 	id text NOT NULL,
 	first_name text NOT NULL,
 	last_name text NOT NULL,
+	salted_hash text NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE buyer_deletions (
+	pk bigserial NOT NULL,
+	buyer_pk bigint NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	delete_after timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( buyer_pk )
+);
 CREATE TABLE buyer_emails (
 	pk bigserial NOT NULL,
 	buyer_pk bigint NOT NULL,
 	created_at timestamp with time zone NOT NULL,
 	address text NOT NULL,
-	salted_hash text NOT NULL,
 	id text NOT NULL,
+	is_primary boolean NOT NULL,
+	verified boolean NOT NULL,
+	verification_hash text NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id ),
 	UNIQUE ( address )
@@ -42,6 +72,23 @@ CREATE TABLE buyer_sessions (
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE catalog_imports (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	format text NOT NULL,
+	status text NOT NULL,
+	total_rows bigint NOT NULL,
+	processed_rows bigint NOT NULL,
+	created_rows bigint NOT NULL,
+	updated_rows bigint NOT NULL,
+	failed_rows bigint NOT NULL,
+	errors text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
 CREATE TABLE conversations (
 	pk bigserial NOT NULL,
 	vendor_pk bigint NOT NULL,
@@ -49,8 +96,26 @@ CREATE TABLE conversations (
 	buyer_unread boolean NOT NULL,
 	vendor_unread boolean NOT NULL,
 	message_count bigint NOT NULL,
+	buyer_last_read bigint NOT NULL,
+	vendor_last_read bigint NOT NULL,
+	blocked_by_buyer boolean NOT NULL,
+	blocked_by_vendor boolean NOT NULL,
+	id text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE TABLE conversation_reports (
+	pk bigserial NOT NULL,
 	id text NOT NULL,
+	conversation_pk bigint NOT NULL,
+	reporter text NOT NULL,
+	reason text NOT NULL,
+	transcript text NOT NULL,
+	status text NOT NULL,
+	resolution text NOT NULL,
 	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
@@ -60,6 +125,7 @@ CREATE TABLE executive_contacts (
 	vendor_pk bigint NOT NULL,
 	first_name text NOT NULL,
 	last_name text NOT NULL,
+	role text NOT NULL,
 	created_at timestamp with time zone NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
@@ -70,11 +136,32 @@ CREATE TABLE messages (
 	created_at timestamp with time zone NOT NULL,
 	buyer_sent boolean NOT NULL,
 	description text NOT NULL,
+	search_text text NOT NULL,
 	conversation_pk bigint NOT NULL,
 	conversation_number bigint NOT NULL,
 	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( conversation_pk, conversation_number )
+);
+CREATE TABLE message_attachments (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	message_pk bigint NOT NULL,
+	blob_key text NOT NULL,
+	filename text NOT NULL,
+	content_type text NOT NULL,
+	size bigint NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE message_references (
+	pk bigserial NOT NULL,
+	message_pk bigint NOT NULL,
+	kind text NOT NULL,
+	ref_pk bigint NOT NULL,
+	PRIMARY KEY ( pk )
+);
 CREATE TABLE products (
 	pk bigserial NOT NULL,
 	id text NOT NULL,
@@ -90,8 +177,10 @@ CREATE TABLE products (
 	num_in_stock integer NOT NULL,
 	description text NOT NULL,
 	rating real NOT NULL,
+	attributes text NOT NULL,
 	PRIMARY KEY ( pk ),
-	UNIQUE ( id )
+	UNIQUE ( id ),
+	UNIQUE ( vendor_pk, sku )
 );
 CREATE TABLE product_reviews (
 	pk bigserial NOT NULL,
@@ -103,26 +192,53 @@ CREATE TABLE product_reviews (
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE product_variants (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	product_pk bigint NOT NULL,
+	vendor_pk bigint NOT NULL,
+	sku text NOT NULL,
+	attributes text NOT NULL,
+	price real NOT NULL,
+	discount real NOT NULL,
+	discount_active boolean NOT NULL,
+	num_in_stock integer NOT NULL,
+	google_bucket_id text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( vendor_pk, sku )
+);
 CREATE TABLE purchased_products (
 	pk bigserial NOT NULL,
 	id text NOT NULL,
 	vendor_pk bigint NOT NULL,
 	buyer_pk bigint NOT NULL,
 	product_pk bigint NOT NULL,
+	variant_pk bigint NOT NULL,
 	purchase_price real NOT NULL,
 	created_at timestamp with time zone NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE rate_limit_buckets (
+	name text NOT NULL,
+	tokens double precision NOT NULL,
+	allowed boolean NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( name )
+);
 CREATE TABLE trial_products (
 	pk bigserial NOT NULL,
 	id text NOT NULL,
 	vendor_pk bigint NOT NULL,
 	buyer_pk bigint NOT NULL,
 	product_pk bigint NOT NULL,
+	variant_pk bigint NOT NULL,
 	created_at timestamp with time zone NOT NULL,
 	trial_price real NOT NULL,
 	is_returned boolean NOT NULL,
+	shipping_address_pk bigint NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
@@ -138,15 +254,40 @@ CREATE TABLE vendor_addresses (
 	pk bigserial NOT NULL,
 	vendor_pk bigint NOT NULL,
 	created_at timestamp with time zone NOT NULL,
-	street_address text NOT NULL,
+	country_code text NOT NULL,
+	line1 text NOT NULL,
+	line2 text NOT NULL,
 	city text NOT NULL,
-	state text NOT NULL,
-	zip integer NOT NULL,
+	region text NOT NULL,
+	postal_code text NOT NULL,
 	is_billing boolean NOT NULL,
 	id text NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
+CREATE TABLE vendor_api_keys (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	created_by_pk bigint NOT NULL,
+	name text NOT NULL,
+	scopes text NOT NULL,
+	prefix text NOT NULL,
+	key_hash text NOT NULL,
+	last_used_at timestamp with time zone NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( key_hash )
+);
+CREATE TABLE vendor_deletions (
+	pk bigserial NOT NULL,
+	vendor_pk bigint NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	delete_after timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( vendor_pk )
+);
 CREATE TABLE vendor_emails (
 	pk bigserial NOT NULL,
 	id text NOT NULL,
@@ -158,6 +299,19 @@ CREATE TABLE vendor_emails (
 	UNIQUE ( id ),
 	UNIQUE ( address )
 );
+CREATE TABLE vendor_invites (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	invited_by_pk bigint NOT NULL,
+	email text NOT NULL,
+	role text NOT NULL,
+	token_hash text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( token_hash )
+);
 CREATE TABLE vendor_phones (
 	pk bigserial NOT NULL,
 	id text NOT NULL,
@@ -169,11 +323,60 @@ CREATE TABLE vendor_phones (
 	UNIQUE ( id ),
 	UNIQUE ( phone_number )
 );
+CREATE TABLE vendor_profiles (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	display_name text NOT NULL,
+	slug text NOT NULL,
+	logo_url text NOT NULL,
+	description text NOT NULL,
+	return_policy text NOT NULL,
+	support_hours text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( vendor_pk ),
+	UNIQUE ( slug )
+);
 CREATE TABLE vendor_sessions (
 	pk bigserial NOT NULL,
 	vendor_pk bigint NOT NULL,
+	executive_contact_pk bigint NOT NULL,
+	id text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE TABLE webhook_deliveries (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	endpoint_pk bigint NOT NULL,
+	event_id text NOT NULL,
+	event text NOT NULL,
+	payload text NOT NULL,
+	status text NOT NULL,
+	attempts bigint NOT NULL,
+	next_attempt_at timestamp with time zone NOT NULL,
+	response_status bigint NOT NULL,
+	error text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE TABLE webhook_endpoints (
+	pk bigserial NOT NULL,
 	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	url text NOT NULL,
+	secret text NOT NULL,
+	events text NOT NULL,
+	enabled boolean NOT NULL,
+	failing_since timestamp with time zone NOT NULL,
 	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
 	PRIMARY KEY ( pk ),
 	UNIQUE ( id )
 );
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+)
+
+//LockBuyer holds the buyer's row until the transaction ends so that a check followed by a write,
+//like counting recent messages before posting one, cannot interleave with another transaction
+//doing the same for the buyer. sqlite, which is only used in tests, already runs one writer at a
+//time so there is nothing to do there
+func (tx *Tx) LockBuyer(ctx context.Context, buyer_pk int64) error {
+	return tx.lockRow(ctx, "buyers", buyer_pk)
+}
+
+//LockVendor is LockBuyer for a vendor
+func (tx *Tx) LockVendor(ctx context.Context, vendor_pk int64) error {
+	return tx.lockRow(ctx, "vendors", vendor_pk)
+}
+
+//LockConversation is LockBuyer for a conversation
+func (tx *Tx) LockConversation(ctx context.Context, conversation_pk int64) error {
+	return tx.lockRow(ctx, "conversations", conversation_pk)
+}
+
+func (tx *Tx) lockRow(ctx context.Context, table string, pk int64) error {
+	impl, ok := tx.txMethods.(*postgresTx)
+	if !ok {
+		return nil
+	}
+
+	stmt := "SELECT pk FROM " + table + " WHERE pk = $1 FOR UPDATE"
+	impl.logStmt(stmt, pk)
+
+	var locked int64
+	err := impl.driver.QueryRow(stmt, pk).Scan(&locked)
+	if err != nil {
+		return impl.makeErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	txDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "ladybug_db_transaction_duration_seconds",
+		Help: "How long WithTx transactions took from open to commit or rollback.",
+	}, []string{"outcome"})
+
+	txRollbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ladybug_db_transaction_rollbacks_total",
+		Help: "Transactions rolled back because the work in them failed.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(txDuration, txRollbacks)
+}
+
+var (
+	poolOpenDesc = prometheus.NewDesc("ladybug_db_pool_open_connections",
+		"Connections open to the database, in use or idle.", nil, nil)
+	poolInUseDesc = prometheus.NewDesc("ladybug_db_pool_in_use_connections",
+		"Connections currently running queries.", nil, nil)
+	poolIdleDesc = prometheus.NewDesc("ladybug_db_pool_idle_connections",
+		"Connections waiting in the pool.", nil, nil)
+	poolWaitsDesc = prometheus.NewDesc("ladybug_db_pool_waits_total",
+		"Times a query had to wait for a connection.", nil, nil)
+	poolWaitDesc = prometheus.NewDesc("ladybug_db_pool_wait_seconds_total",
+		"Time spent waiting for connections.", nil, nil)
+)
+
+//PoolCollector reports the connection pool of a DB. it is registered by whoever opened the DB
+//since the package does not know which DB is the one serving traffic
+type PoolCollector struct {
+	db *DB
+}
+
+func NewPoolCollector(db *DB) *PoolCollector {
+	return &PoolCollector{db: db}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolOpenDesc
+	ch <- poolInUseDesc
+	ch <- poolIdleDesc
+	ch <- poolWaitsDesc
+	ch <- poolWaitDesc
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(poolOpenDesc, prometheus.GaugeValue,
+		float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue,
+		float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue,
+		float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(poolWaitsDesc, prometheus.CounterValue,
+		float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(poolWaitDesc, prometheus.CounterValue,
+		stats.WaitDuration.Seconds())
+}
@@ -0,0 +1,20 @@
+-- adds how far each side of a conversation has read. a side with nothing unread has read every
+-- message and one with unread messages has read at least up to the last message it sent
+
+BEGIN;
+
+ALTER TABLE conversations ADD COLUMN buyer_last_read bigint NOT NULL DEFAULT 0;
+ALTER TABLE conversations ADD COLUMN vendor_last_read bigint NOT NULL DEFAULT 0;
+UPDATE conversations SET
+	buyer_last_read = CASE WHEN buyer_unread THEN COALESCE((
+		SELECT max(messages.conversation_number) FROM messages
+		WHERE messages.conversation_pk = conversations.pk AND messages.buyer_sent
+	), 0) ELSE message_count END,
+	vendor_last_read = CASE WHEN vendor_unread THEN COALESCE((
+		SELECT max(messages.conversation_number) FROM messages
+		WHERE messages.conversation_pk = conversations.pk AND NOT messages.buyer_sent
+	), 0) ELSE message_count END;
+ALTER TABLE conversations ALTER COLUMN buyer_last_read DROP DEFAULT;
+ALTER TABLE conversations ALTER COLUMN vendor_last_read DROP DEFAULT;
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- adds the files and references attached to messages
+
+BEGIN;
+
+CREATE TABLE message_attachments (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	message_pk bigint NOT NULL,
+	blob_key text NOT NULL,
+	filename text NOT NULL,
+	content_type text NOT NULL,
+	size bigint NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE INDEX message_attachments_message_pk ON message_attachments ( message_pk );
+
+CREATE TABLE message_references (
+	pk bigserial NOT NULL,
+	message_pk bigint NOT NULL,
+	kind text NOT NULL,
+	ref_pk bigint NOT NULL,
+	PRIMARY KEY ( pk )
+);
+CREATE INDEX message_references_message_pk ON message_references ( message_pk );
+
+COMMIT;
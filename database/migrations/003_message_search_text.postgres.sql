@@ -0,0 +1,37 @@
+-- adds the text message search matches against, backfilled for existing messages, and the full
+-- text index search uses. the index expression has to match the one in database/search.go
+--
+-- it also makes message numbers unique within a conversation since paging and read pointers go
+-- by them. a buyer and a vendor posting at the same time could be given the same number before,
+-- so conversations where that happened are renumbered in the order their messages were posted
+
+BEGIN;
+
+ALTER TABLE messages ADD COLUMN search_text text NOT NULL DEFAULT '';
+UPDATE messages SET search_text = lower(description);
+ALTER TABLE messages ALTER COLUMN search_text DROP DEFAULT;
+CREATE INDEX messages_search_text ON messages USING gin (to_tsvector('english', search_text));
+
+UPDATE messages SET conversation_number = numbered.number
+FROM (
+	SELECT pk, row_number() OVER (PARTITION BY conversation_pk
+		ORDER BY conversation_number, created_at, pk) AS number
+	FROM messages
+	WHERE conversation_pk IN (
+		SELECT conversation_pk FROM messages
+		GROUP BY conversation_pk, conversation_number
+		HAVING count(*) > 1
+	)
+) AS numbered
+WHERE messages.pk = numbered.pk AND messages.conversation_number <> numbered.number;
+
+UPDATE conversations SET message_count = counted.messages
+FROM (
+	SELECT conversation_pk, count(*) AS messages FROM messages GROUP BY conversation_pk
+) AS counted
+WHERE conversations.pk = counted.conversation_pk AND conversations.message_count < counted.messages;
+
+ALTER TABLE messages ADD CONSTRAINT messages_conversation_pk_conversation_number_key
+	UNIQUE ( conversation_pk, conversation_number );
+
+COMMIT;
@@ -0,0 +1,26 @@
+-- adds blocking to conversations, which nobody has done yet, and the reports raised against them
+
+BEGIN;
+
+ALTER TABLE conversations ADD COLUMN blocked_by_buyer boolean NOT NULL DEFAULT false;
+ALTER TABLE conversations ADD COLUMN blocked_by_vendor boolean NOT NULL DEFAULT false;
+ALTER TABLE conversations ALTER COLUMN blocked_by_buyer DROP DEFAULT;
+ALTER TABLE conversations ALTER COLUMN blocked_by_vendor DROP DEFAULT;
+
+CREATE TABLE conversation_reports (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	conversation_pk bigint NOT NULL,
+	reporter text NOT NULL,
+	reason text NOT NULL,
+	transcript text NOT NULL,
+	status text NOT NULL,
+	resolution text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE INDEX conversation_reports_status_created_at ON conversation_reports ( status, created_at );
+
+COMMIT;
@@ -0,0 +1,29 @@
+-- adds roles to executive contacts and the invites that add new ones. every existing contact
+-- signed their vendor up, so they are all owners. sessions now belong to a contact rather than a
+-- vendor and there is no telling which contact an existing session was started by, so they are
+-- deleted and everyone signs in again
+
+BEGIN;
+
+ALTER TABLE executive_contacts ADD COLUMN role text NOT NULL DEFAULT 'owner';
+ALTER TABLE executive_contacts ALTER COLUMN role DROP DEFAULT;
+
+DELETE FROM vendor_sessions;
+ALTER TABLE vendor_sessions ADD COLUMN executive_contact_pk bigint NOT NULL;
+
+CREATE TABLE vendor_invites (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	invited_by_pk bigint NOT NULL,
+	email text NOT NULL,
+	role text NOT NULL,
+	token_hash text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( token_hash )
+);
+CREATE INDEX vendor_invites_vendor_pk ON vendor_invites ( vendor_pk );
+
+COMMIT;
@@ -0,0 +1,23 @@
+-- adds the storefront profiles vendors present themselves with. vendors start without one
+
+BEGIN;
+
+CREATE TABLE vendor_profiles (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	display_name text NOT NULL,
+	slug text NOT NULL,
+	logo_url text NOT NULL,
+	description text NOT NULL,
+	return_policy text NOT NULL,
+	support_hours text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( vendor_pk ),
+	UNIQUE ( slug )
+);
+
+COMMIT;
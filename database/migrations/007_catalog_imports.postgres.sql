@@ -0,0 +1,24 @@
+-- adds the catalog imports that run in the background
+
+BEGIN;
+
+CREATE TABLE catalog_imports (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	format text NOT NULL,
+	status text NOT NULL,
+	total_rows bigint NOT NULL,
+	processed_rows bigint NOT NULL,
+	created_rows bigint NOT NULL,
+	updated_rows bigint NOT NULL,
+	failed_rows bigint NOT NULL,
+	errors text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE INDEX catalog_imports_vendor_pk ON catalog_imports ( vendor_pk );
+
+COMMIT;
@@ -0,0 +1,42 @@
+-- adds product variants. existing products have no attributes and existing trials and purchases
+-- are of no variant. skus become unique per vendor, so any a vendor has used more than once keep
+-- their sku on the oldest product and get the product's pk appended everywhere else
+
+BEGIN;
+
+ALTER TABLE products ADD COLUMN attributes text NOT NULL DEFAULT '[]';
+ALTER TABLE products ALTER COLUMN attributes DROP DEFAULT;
+
+UPDATE products SET sku = products.sku || '-' || products.pk
+WHERE EXISTS (
+	SELECT 1 FROM products AS older
+	WHERE older.vendor_pk = products.vendor_pk AND older.sku = products.sku
+	AND older.pk < products.pk
+);
+ALTER TABLE products ADD CONSTRAINT products_vendor_pk_sku_key UNIQUE ( vendor_pk, sku );
+
+CREATE TABLE product_variants (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	product_pk bigint NOT NULL,
+	vendor_pk bigint NOT NULL,
+	sku text NOT NULL,
+	attributes text NOT NULL,
+	price real NOT NULL,
+	discount real NOT NULL,
+	discount_active boolean NOT NULL,
+	num_in_stock integer NOT NULL,
+	google_bucket_id text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( vendor_pk, sku )
+);
+CREATE INDEX product_variants_product_pk ON product_variants ( product_pk );
+
+ALTER TABLE trial_products ADD COLUMN variant_pk bigint NOT NULL DEFAULT 0;
+ALTER TABLE trial_products ALTER COLUMN variant_pk DROP DEFAULT;
+ALTER TABLE purchased_products ADD COLUMN variant_pk bigint NOT NULL DEFAULT 0;
+ALTER TABLE purchased_products ALTER COLUMN variant_pk DROP DEFAULT;
+
+COMMIT;
@@ -0,0 +1,26 @@
+-- adds default billing and shipping addresses and the address a trial ships to. each buyer's
+-- oldest billing address becomes their default billing address and their oldest other address
+-- their default shipping address, falling back to their oldest address when they only have one
+-- kind. existing trials are taken to have shipped to the default shipping address
+
+BEGIN;
+
+ALTER TABLE addresses ADD COLUMN is_default_billing boolean NOT NULL DEFAULT false;
+ALTER TABLE addresses ADD COLUMN is_default_shipping boolean NOT NULL DEFAULT false;
+UPDATE addresses SET is_default_billing = true WHERE pk IN (
+	SELECT DISTINCT ON (buyer_pk) pk FROM addresses ORDER BY buyer_pk, is_billing DESC, pk
+);
+UPDATE addresses SET is_default_shipping = true WHERE pk IN (
+	SELECT DISTINCT ON (buyer_pk) pk FROM addresses ORDER BY buyer_pk, is_billing ASC, pk
+);
+ALTER TABLE addresses ALTER COLUMN is_default_billing DROP DEFAULT;
+ALTER TABLE addresses ALTER COLUMN is_default_shipping DROP DEFAULT;
+
+ALTER TABLE trial_products ADD COLUMN shipping_address_pk bigint NOT NULL DEFAULT 0;
+UPDATE trial_products SET shipping_address_pk = COALESCE((
+	SELECT addresses.pk FROM addresses
+	WHERE addresses.buyer_pk = trial_products.buyer_pk AND addresses.is_default_shipping
+), 0);
+ALTER TABLE trial_products ALTER COLUMN shipping_address_pk DROP DEFAULT;
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- moves addresses and vendor_addresses from street_address, state and an integer zip to the
+-- international columns in ladybug.dbx. every address stored before this was a US one, so zips
+-- shorter than five digits are padded back to the zeros the integer column dropped (2110 becomes
+-- 02110). longer ones are kept as they are
+
+BEGIN;
+
+ALTER TABLE addresses RENAME COLUMN street_address TO line1;
+ALTER TABLE addresses RENAME COLUMN state TO region;
+ALTER TABLE addresses ALTER COLUMN zip TYPE text USING
+	CASE WHEN length(zip::text) < 5 THEN lpad(zip::text, 5, '0') ELSE zip::text END;
+ALTER TABLE addresses RENAME COLUMN zip TO postal_code;
+ALTER TABLE addresses ADD COLUMN country_code text NOT NULL DEFAULT 'US';
+ALTER TABLE addresses ADD COLUMN line2 text NOT NULL DEFAULT '';
+ALTER TABLE addresses ALTER COLUMN country_code DROP DEFAULT;
+ALTER TABLE addresses ALTER COLUMN line2 DROP DEFAULT;
+
+ALTER TABLE vendor_addresses RENAME COLUMN street_address TO line1;
+ALTER TABLE vendor_addresses RENAME COLUMN state TO region;
+ALTER TABLE vendor_addresses ALTER COLUMN zip TYPE text USING
+	CASE WHEN length(zip::text) < 5 THEN lpad(zip::text, 5, '0') ELSE zip::text END;
+ALTER TABLE vendor_addresses RENAME COLUMN zip TO postal_code;
+ALTER TABLE vendor_addresses ADD COLUMN country_code text NOT NULL DEFAULT 'US';
+ALTER TABLE vendor_addresses ADD COLUMN line2 text NOT NULL DEFAULT '';
+ALTER TABLE vendor_addresses ALTER COLUMN country_code DROP DEFAULT;
+ALTER TABLE vendor_addresses ALTER COLUMN line2 DROP DEFAULT;
+
+COMMIT;
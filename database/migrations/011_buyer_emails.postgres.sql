@@ -0,0 +1,27 @@
+-- moves the buyer's password from buyer_emails onto buyers so that every email of a buyer logs in
+-- with the same password. each buyer's oldest email becomes their primary one and emails stored
+-- before this were all verified at sign up. a buyer without an email has no password and cannot
+-- log in until they add one
+
+BEGIN;
+
+ALTER TABLE buyers ADD COLUMN salted_hash text NOT NULL DEFAULT '';
+UPDATE buyers SET salted_hash = COALESCE((
+	SELECT buyer_emails.salted_hash FROM buyer_emails
+	WHERE buyer_emails.buyer_pk = buyers.pk
+	ORDER BY buyer_emails.pk LIMIT 1
+), '');
+ALTER TABLE buyers ALTER COLUMN salted_hash DROP DEFAULT;
+
+ALTER TABLE buyer_emails ADD COLUMN is_primary boolean NOT NULL DEFAULT false;
+ALTER TABLE buyer_emails ADD COLUMN verified boolean NOT NULL DEFAULT true;
+ALTER TABLE buyer_emails ADD COLUMN verification_hash text NOT NULL DEFAULT '';
+UPDATE buyer_emails SET is_primary = true WHERE pk IN (
+	SELECT min(pk) FROM buyer_emails GROUP BY buyer_pk
+);
+ALTER TABLE buyer_emails ALTER COLUMN is_primary DROP DEFAULT;
+ALTER TABLE buyer_emails ALTER COLUMN verified DROP DEFAULT;
+ALTER TABLE buyer_emails ALTER COLUMN verification_hash DROP DEFAULT;
+ALTER TABLE buyer_emails DROP COLUMN salted_hash;
+
+COMMIT;
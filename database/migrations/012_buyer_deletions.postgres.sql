@@ -0,0 +1,14 @@
+-- adds the table that holds buyers' account deletion requests while their grace period runs
+
+BEGIN;
+
+CREATE TABLE buyer_deletions (
+	pk bigserial NOT NULL,
+	buyer_pk bigint NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	delete_after timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( buyer_pk )
+);
+
+COMMIT;
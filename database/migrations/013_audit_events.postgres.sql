@@ -0,0 +1,23 @@
+-- adds the append-only audit log. the application never updates or deletes its rows
+
+BEGIN;
+
+CREATE TABLE audit_events (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	actor_kind text NOT NULL,
+	actor_id text NOT NULL,
+	action text NOT NULL,
+	target_kind text NOT NULL,
+	target_id text NOT NULL,
+	diff text NOT NULL,
+	ip text NOT NULL,
+	user_agent text NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE INDEX audit_events_actor_id_created_at ON audit_events ( actor_id, created_at );
+CREATE INDEX audit_events_target_id_created_at ON audit_events ( target_id, created_at );
+
+COMMIT;
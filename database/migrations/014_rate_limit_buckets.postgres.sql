@@ -0,0 +1,14 @@
+-- adds the token buckets shared by instances using the postgres rate limit store
+
+BEGIN;
+
+CREATE TABLE rate_limit_buckets (
+	name text NOT NULL,
+	tokens double precision NOT NULL,
+	allowed boolean NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( name )
+);
+CREATE INDEX rate_limit_buckets_updated_at ON rate_limit_buckets ( updated_at );
+
+COMMIT;
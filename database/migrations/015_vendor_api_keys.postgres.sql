@@ -0,0 +1,23 @@
+-- adds the api keys vendors use for server-to-server integrations
+
+BEGIN;
+
+CREATE TABLE vendor_api_keys (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	created_by_pk bigint NOT NULL,
+	name text NOT NULL,
+	scopes text NOT NULL,
+	prefix text NOT NULL,
+	key_hash text NOT NULL,
+	last_used_at timestamp with time zone NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id ),
+	UNIQUE ( key_hash )
+);
+CREATE INDEX vendor_api_keys_vendor_pk ON vendor_api_keys ( vendor_pk );
+CREATE INDEX vendor_api_keys_created_by_pk ON vendor_api_keys ( created_by_pk );
+
+COMMIT;
@@ -0,0 +1,41 @@
+-- adds vendor webhook endpoints and the queue their deliveries go through
+
+BEGIN;
+
+CREATE TABLE webhook_endpoints (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	vendor_pk bigint NOT NULL,
+	url text NOT NULL,
+	secret text NOT NULL,
+	events text NOT NULL,
+	enabled boolean NOT NULL,
+	failing_since timestamp with time zone NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE INDEX webhook_endpoints_vendor_pk ON webhook_endpoints ( vendor_pk );
+
+CREATE TABLE webhook_deliveries (
+	pk bigserial NOT NULL,
+	id text NOT NULL,
+	endpoint_pk bigint NOT NULL,
+	event_id text NOT NULL,
+	event text NOT NULL,
+	payload text NOT NULL,
+	status text NOT NULL,
+	attempts bigint NOT NULL,
+	next_attempt_at timestamp with time zone NOT NULL,
+	response_status bigint NOT NULL,
+	error text NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( id )
+);
+CREATE INDEX webhook_deliveries_endpoint_pk_created_at ON webhook_deliveries ( endpoint_pk, created_at );
+CREATE INDEX webhook_deliveries_status_next_attempt_at ON webhook_deliveries ( status, next_attempt_at );
+
+COMMIT;
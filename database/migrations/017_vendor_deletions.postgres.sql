@@ -0,0 +1,16 @@
+-- adds the table that holds vendors' account deletion requests while their grace period runs.
+-- erasing buyers clears buyer_pk and shipping_address_pk on trials and purchases, which needs no
+-- schema change
+
+BEGIN;
+
+CREATE TABLE vendor_deletions (
+	pk bigserial NOT NULL,
+	vendor_pk bigint NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	delete_after timestamp with time zone NOT NULL,
+	PRIMARY KEY ( pk ),
+	UNIQUE ( vendor_pk )
+);
+
+COMMIT;
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"strings"
+)
+
+//messageSearchStmt is full text search over a party's messages on postgres. the tsvector
+//expression has to match the one in the messages_search_text index for the index to be used
+const messageSearchStmt = `SELECT messages.pk, messages.id, messages.created_at,
+	messages.buyer_sent, messages.description, messages.search_text, messages.conversation_pk,
+	messages.conversation_number
+	FROM conversations JOIN messages ON conversations.pk = messages.conversation_pk
+	WHERE conversations.%s = $1
+	AND to_tsvector('english', messages.search_text) @@ plainto_tsquery('english', $2)
+	ORDER BY messages.created_at DESC LIMIT $3 OFFSET $4`
+
+//SearchBuyerMessages returns the buyer's messages matching every word, newest first. postgres
+//uses full text search and sqlite, which is only used in tests, matches the words in order with
+//LIKE
+func (tx *Tx) SearchBuyerMessages(ctx context.Context, buyer_pk int64, words []string,
+	limit int, offset int64) ([]*Message, error) {
+
+	if impl, ok := tx.txMethods.(*postgresTx); ok {
+		return impl.searchMessages(ctx, "buyer_pk", buyer_pk, words, limit, offset)
+	}
+
+	return tx.Limited_Message_By_Conversation_BuyerPk_And_Message_SearchText_Like_OrderBy_Desc_Message_CreatedAt(
+		ctx, Conversation_BuyerPk(buyer_pk), Message_SearchText(likePattern(words)), limit, offset)
+}
+
+//SearchVendorMessages is SearchBuyerMessages for the vendor's side of its conversations
+func (tx *Tx) SearchVendorMessages(ctx context.Context, vendor_pk int64, words []string,
+	limit int, offset int64) ([]*Message, error) {
+
+	if impl, ok := tx.txMethods.(*postgresTx); ok {
+		return impl.searchMessages(ctx, "vendor_pk", vendor_pk, words, limit, offset)
+	}
+
+	return tx.Limited_Message_By_Conversation_VendorPk_And_Message_SearchText_Like_OrderBy_Desc_Message_CreatedAt(
+		ctx, Conversation_VendorPk(vendor_pk), Message_SearchText(likePattern(words)), limit,
+		offset)
+}
+
+func (obj *postgresImpl) searchMessages(ctx context.Context, column string, pk int64,
+	words []string, limit int, offset int64) (rows []*Message, err error) {
+
+	stmt := strings.Replace(messageSearchStmt, "%s", column, 1)
+	values := []interface{}{pk, strings.Join(words, " "), limit, offset}
+	obj.logStmt(stmt, values...)
+
+	__rows, err := obj.driver.Query(stmt, values...)
+	if err != nil {
+		return nil, obj.makeErr(err)
+	}
+	defer __rows.Close()
+
+	for __rows.Next() {
+		message := &Message{}
+		err = __rows.Scan(&message.Pk, &message.Id, &message.CreatedAt, &message.BuyerSent,
+			&message.Description, &message.SearchText, &message.ConversationPk,
+			&message.ConversationNumber)
+		if err != nil {
+			return nil, obj.makeErr(err)
+		}
+		rows = append(rows, message)
+	}
+	if err := __rows.Err(); err != nil {
+		return nil, obj.makeErr(err)
+	}
+	return rows, nil
+}
+
+//likePattern matches text containing each word in order. the words must not contain LIKE
+//wildcards
+func likePattern(words []string) string {
+	return "%" + strings.Join(words, "%") + "%"
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//tracer comes from the global provider, which does nothing until main installs an exporter
+var tracer = otel.Tracer("ladybug/database")
+
+//OpenTraced is Open with a span for every statement run inside WithTx or on a DB from
+//WithContext. the generated code does not hand contexts to the driver, so the driver is wrapped
+//and each connection remembers the context of the transaction running on it
+func OpenTraced(driver_name, source string) (db *DB, err error) {
+	var d sqldriver.Driver
+	var system string
+	switch driver_name {
+	case "postgres":
+		d, system = &pq.Driver{}, "postgresql"
+	case "sqlite3":
+		d, system = &sqlite3.SQLiteDriver{ConnectHook: sqlite3SetupConn}, "sqlite"
+	default:
+		return nil, unsupportedDriver(driver_name)
+	}
+
+	sql_db := sql.OpenDB(&tracedConnector{driver: d, source: source, system: system})
+	defer func() {
+		if err != nil {
+			sql_db.Close()
+		}
+	}()
+
+	if err := sql_db.Ping(); err != nil {
+		return nil, makeErr(err)
+	}
+
+	//the rest mirrors Open
+	db = &DB{
+		DB: sql_db,
+	}
+	db.Hooks.Now = time.Now
+
+	switch driver_name {
+	case "postgres":
+		db.dbMethods = newpostgres(db)
+	case "sqlite3":
+		db.dbMethods = newsqlite3(db)
+	}
+
+	return db, nil
+}
+
+type tracedConnector struct {
+	driver sqldriver.Driver
+	source string
+	system string
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (sqldriver.Conn, error) {
+	conn, err := c.driver.Open(c.source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, system: c.system}, nil
+}
+
+func (c *tracedConnector) Driver() sqldriver.Driver {
+	return c.driver
+}
+
+//tracedConn starts a span for each statement. database/sql only uses a connection from one
+//goroutine at a time so txCtx needs no locking
+type tracedConn struct {
+	sqldriver.Conn
+	system string
+
+	//txCtx is the context of the transaction running on the connection, if any
+	txCtx context.Context
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	var tx sqldriver.Tx
+	var err error
+	if begin, ok := c.Conn.(sqldriver.ConnBeginTx); ok {
+		tx, err = begin.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.txCtx = ctx
+	return &tracedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string,
+	args []sqldriver.NamedValue) (sqldriver.Result, error) {
+
+	execer, ok := c.Conn.(sqldriver.ExecerContext)
+	if !ok {
+		return nil, sqldriver.ErrSkip
+	}
+
+	end := c.startSpan(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	end(err)
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string,
+	args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+
+	queryer, ok := c.Conn.(sqldriver.QueryerContext)
+	if !ok {
+		return nil, sqldriver.ErrSkip
+	}
+
+	end := c.startSpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	end(err)
+	return rows, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (sqldriver.Stmt, error) {
+	if preparer, ok := c.Conn.(sqldriver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+
+	return c.Conn.Prepare(query)
+}
+
+//ResetSession lets the pool reset the connection before reuse. the transaction context is
+//dropped too in case a transaction was abandoned without committing or rolling back
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	c.txCtx = nil
+	if resetter, ok := c.Conn.(sqldriver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(sqldriver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(sqldriver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) CheckNamedValue(value *sqldriver.NamedValue) error {
+	if checker, ok := c.Conn.(sqldriver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return sqldriver.ErrSkip
+}
+
+//startSpan starts the span for query. statements with no span in their context, either their own
+//or their transaction's, have nothing to hang off and are not traced
+func (c *tracedConn) startSpan(ctx context.Context, query string) func(error) {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		if c.txCtx == nil {
+			return func(error) {}
+		}
+		ctx = c.txCtx
+	}
+
+	verb := query
+	if i := strings.IndexAny(verb, " \n\t"); i > 0 {
+		verb = verb[:i]
+	}
+
+	_, span := tracer.Start(ctx, "sql "+strings.ToUpper(verb),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", c.system),
+			attribute.String("db.statement", query)))
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+type tracedTx struct {
+	sqldriver.Tx
+	conn *tracedConn
+}
+
+func (t *tracedTx) Commit() error {
+	t.conn.txCtx = nil
+	return t.Tx.Commit()
+}
+
+func (t *tracedTx) Rollback() error {
+	t.conn.txCtx = nil
+	return t.Tx.Rollback()
+}
+
+//WithContext returns a DB whose statements outside of transactions run with ctx so they are traced
+//under its span. like transactions they are not cancelled with ctx
+func (db *DB) WithContext(ctx context.Context) *DB {
+	bound := &DB{DB: db.DB}
+	bound.Hooks = db.Hooks
+
+	driver := &contextDriver{db: db.DB, ctx: context.WithoutCancel(ctx)}
+	switch db.dbMethods.(type) {
+	case *postgresDB:
+		methods := newpostgres(bound)
+		methods.driver = driver
+		bound.dbMethods = methods
+	case *sqlite3DB:
+		methods := newsqlite3(bound)
+		methods.driver = driver
+		bound.dbMethods = methods
+	default:
+		return db
+	}
+
+	return bound
+}
+
+//contextDriver runs the generated code's statements with a context
+type contextDriver struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (d *contextDriver) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.ExecContext(d.ctx, query, args...)
+}
+
+func (d *contextDriver) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.QueryContext(d.ctx, query, args...)
+}
+
+func (d *contextDriver) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRowContext(d.ctx, query, args...)
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+//LeaseWebhookDelivery moves a delivery's next attempt out to until so no other dispatcher sends it
+//in the meantime. it only takes a delivery that is still pending and due at now, and returns false
+//when another dispatcher leased or finished it first
+func (tx *Tx) LeaseWebhookDelivery(ctx context.Context, delivery_pk int64, status string, now,
+	until time.Time) (bool, error) {
+
+	stmt := tx.Rebind(`UPDATE webhook_deliveries SET next_attempt_at = ?, updated_at = ?
+		WHERE pk = ? AND status = ? AND next_attempt_at <= ?`)
+	values := []interface{}{until.UTC(), now.UTC(), delivery_pk, status, now.UTC()}
+
+	result, err := tx.Tx.ExecContext(ctx, stmt, values...)
+	if err != nil {
+		return false, tx.makeErr(err)
+	}
+
+	leased, err := result.RowsAffected()
+	if err != nil {
+		return false, tx.makeErr(err)
+	}
+	return leased == 1, nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (u *buyerHandler) listAddresses(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.ListAddresses(req.Context(), &server.ListAddressesReq{
+		BuyerPk: GetBuyerPk(req.Context()),
+	})
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) addAddress(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var address_req server.AddAddressReq
+	err := decoder.Decode(&address_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	address_req.BuyerPk = GetBuyerPk(req.Context())
+
+	resp, err := u.buyerServer.AddAddress(req.Context(), &address_req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) updateAddress(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var address_req server.UpdateAddressReq
+	err := decoder.Decode(&address_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	address_req.BuyerPk = GetBuyerPk(req.Context())
+	address_req.AddressId = chi.URLParam(req, "addressId")
+
+	resp, err := u.buyerServer.UpdateAddress(req.Context(), &address_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) setDefaultAddress(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var default_req server.SetDefaultAddressReq
+	err := decoder.Decode(&default_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	default_req.BuyerPk = GetBuyerPk(req.Context())
+	default_req.AddressId = chi.URLParam(req, "addressId")
+
+	resp, err := u.buyerServer.SetDefaultAddress(req.Context(), &default_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) deleteAddress(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.DeleteAddress(req.Context(), &server.DeleteAddressReq{
+		BuyerPk:   GetBuyerPk(req.Context()),
+		AddressId: chi.URLParam(req, "addressId"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/zeebo/errs"
+
+	"ladybug/server"
+)
+
+type adminHandler struct {
+	adminServer *server.AdminServer
+	token       string
+}
+
+func newAdminHandler(server *server.AdminServer, token string) *adminHandler {
+	return &adminHandler{adminServer: server, token: token}
+}
+
+//CheckAdminToken only lets through requests carrying the configured admin token as a bearer token
+func (a *adminHandler) CheckAdminToken(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		expected := "Bearer " + a.token
+		got := req.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, setPrincipal(req, "admin"))
+	})
+}
+
+func (a *adminHandler) pagedOpenConversationReports(w http.ResponseWriter, req *http.Request) {
+	var offset int64
+	if v := req.URL.Query().Get("offset"); v != "" {
+		var err error
+		offset, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := a.adminServer.PagedOpenConversationReports(req.Context(),
+		&server.PagedOpenConversationReportsReq{Offset: offset})
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (a *adminHandler) resolveConversationReport(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var resolve_req server.ResolveConversationReportReq
+	err := decoder.Decode(&resolve_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	resolve_req.ReportId = chi.URLParam(req, "reportId")
+
+	resp, err := a.adminServer.ResolveConversationReport(req.Context(), &resolve_req)
+	if server.NotFound.Has(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+//queryInt64 reads a non-negative integer query parameter. it is 0 when the parameter is missing
+func queryInt64(req *http.Request, name string) (int64, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errs.New("invalid %s", name)
+	}
+
+	return n, nil
+}
+
+//pagedAuditEvents filters the audit log by the actor, target, from and to query parameters.
+//from and to are unix times
+func (a *adminHandler) pagedAuditEvents(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	audit_req := &server.PagedAuditEventsReq{
+		ActorId:  query.Get("actor"),
+		TargetId: query.Get("target"),
+	}
+
+	for name, dest := range map[string]*int64{
+		"from":   &audit_req.From,
+		"to":     &audit_req.To,
+		"offset": &audit_req.Offset,
+	} {
+		n, err := queryInt64(req, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*dest = n
+	}
+
+	resp, err := a.adminServer.PagedAuditEvents(req.Context(), audit_req)
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
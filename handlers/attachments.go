@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+
+	"ladybug/server"
+)
+
+//maxMessageBytes bounds the json of a posted message. attachments are base64 encoded in it, which
+//makes the most they can add up to about a third larger
+const maxMessageBytes = 32 << 20
+
+//writeAttachment sends attachment contents as a download. the content type was sniffed when the
+//file was uploaded and the browser is told not to guess a different one
+func writeAttachment(w http.ResponseWriter, attachment *server.MessageAttachmentResp) {
+	h := w.Header()
+	h.Set("Content-Type", attachment.ContentType)
+	h.Set("Content-Length", fmt.Sprint(len(attachment.Data)))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": attachment.Filename}))
+	w.Write(attachment.Data)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"ladybug/server"
+)
+
+//exportBuyerData builds the whole archive before answering so a failed export is a 500 rather
+//than a truncated zip
+func (u *buyerHandler) exportBuyerData(w http.ResponseWriter, req *http.Request) {
+	var archive bytes.Buffer
+	err := u.buyerServer.ExportBuyerData(req.Context(), &server.ExportBuyerDataReq{
+		BuyerPk: GetBuyerPk(req.Context()),
+	}, &archive)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		server.Logger(req.Context()).Errorf("exporting buyer data failed: %+v", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/zip")
+	h.Set("Content-Disposition", `attachment; filename="ladybug-data.zip"`)
+	archive.WriteTo(w)
+}
+
+func (u *buyerHandler) getBuyerDeletion(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.GetBuyerDeletion(req.Context(), &server.GetBuyerDeletionReq{
+		BuyerPk: GetBuyerPk(req.Context()),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) requestBuyerDeletion(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var deletion_req server.RequestBuyerDeletionReq
+	err := decoder.Decode(&deletion_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	deletion_req.BuyerPk = GetBuyerPk(req.Context())
+
+	resp, err := u.buyerServer.RequestBuyerDeletion(req.Context(), &deletion_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		server.Logger(req.Context()).Errorf("requesting buyer deletion failed: %+v", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) cancelBuyerDeletion(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.CancelBuyerDeletion(req.Context(), &server.CancelBuyerDeletionReq{
+		BuyerPk: GetBuyerPk(req.Context()),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuyerDeletionRoutes(t *testing.T) {
+	h := newHandlerTest(t, "buyer_account")
+	_, session := h.signUpBuyer("ada@example.com")
+
+	resp := h.serveBuyer(session, "GET", "/api/buyer/deletion", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	//a wrong password is the client's mistake
+	resp = h.serveBuyer(session, "POST", "/api/buyer/deletion", `{"password": "wrong"}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "password does not match")
+
+	resp = h.serveBuyer(session, "POST", "/api/buyer/deletion", `{"password": "Password1!"}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	//and so is asking twice
+	resp = h.serveBuyer(session, "POST", "/api/buyer/deletion", `{"password": "Password1!"}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = h.serveBuyer(session, "DELETE", "/api/buyer/deletion", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = h.serveBuyer(session, "DELETE", "/api/buyer/deletion", "")
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
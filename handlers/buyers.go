@@ -4,9 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
+	"strconv"
 
-	"github.com/sirupsen/logrus"
+	"github.com/go-chi/chi"
+	"github.com/zeebo/errs"
 
 	"ladybug/database"
 	"ladybug/server"
@@ -21,10 +22,17 @@ const (
 
 type buyerHandler struct {
 	buyerServer *server.BuyerServer
+
+	//draining is closed when the server starts shutting down
+	draining <-chan struct{}
+
+	cookies cookies
 }
 
-func newBuyerHandler(server *server.BuyerServer) *buyerHandler {
-	return &buyerHandler{buyerServer: server}
+func newBuyerHandler(server *server.BuyerServer, draining <-chan struct{},
+	cookies cookies) *buyerHandler {
+
+	return &buyerHandler{buyerServer: server, draining: draining, cookies: cookies}
 }
 
 func WithBuyer(ctx context.Context, buyer *database.Buyer) context.Context {
@@ -45,7 +53,6 @@ func GetBuyer(ctx context.Context) *database.Buyer {
 	return buyer
 }
 
-//TODO(mac): write put for this function
 func (u *buyerHandler) buyer(w http.ResponseWriter, req *http.Request) {
 
 	ctx := req.Context()
@@ -74,24 +81,26 @@ func (u *buyerHandler) buyer(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if req.Method == "PUT" {
-		buyer_pk := GetBuyerPk(req.Context())
-
-		buyer_response, err := u.buyerServer.GetBuyer(ctx,
-			&server.GetBuyerRequest{BuyerPk: buyer_pk})
+		decoder := json.NewDecoder(req.Body)
+		var update_req server.UpdateBuyerRequest
+		err := decoder.Decode(&update_req)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, "unable to parse json", http.StatusBadRequest)
 			return
 		}
 
-		b, err := json.Marshal(buyer_response)
+		update_req.BuyerPk = GetBuyerPk(req.Context())
+
+		buyer_response, err := u.buyerServer.UpdateBuyer(ctx, &update_req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
-			http.Error(w, "server error", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		h := w.Header()
-		h.Set("Content-Type", "application/json")
-		w.Write(b)
+		writeJSON(w, buyer_response)
 
 		return
 
@@ -106,7 +115,7 @@ func (u *buyerHandler) buyerSignUp(w http.ResponseWriter, req *http.Request) {
 	var sign_up_req server.SignUpRequest
 	err := decoder.Decode(&sign_up_req)
 	if err != nil {
-		logrus.Errorf("%+v", err)
+		server.Logger(req.Context()).Errorf("%+v", err)
 		http.Error(w, "unable to parse json", http.StatusInternalServerError)
 		return
 	}
@@ -114,12 +123,12 @@ func (u *buyerHandler) buyerSignUp(w http.ResponseWriter, req *http.Request) {
 	sign_up_resp, err := u.buyerServer.BuyerSignUp(ctx, &sign_up_req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logrus.Errorf("%+v", err)
+		server.Logger(req.Context()).Errorf("%+v", err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{Name: "session", Value: sign_up_resp.Session.Id,
-		Expires: sign_up_resp.Session.CreatedAt.Add(730 * time.Hour)})
+	u.cookies.setSession(w, buyerSessionCookie, sign_up_resp.Session.Id,
+		sign_up_resp.Session.CreatedAt)
 }
 
 func (u *buyerHandler) buyerLogin(w http.ResponseWriter, req *http.Request) {
@@ -139,8 +148,7 @@ func (u *buyerHandler) buyerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{Name: "session", Value: session.Id,
-		Expires: session.CreatedAt.Add(730 * time.Hour)})
+	u.cookies.setSession(w, buyerSessionCookie, session.Id, session.CreatedAt)
 }
 
 func (u *buyerHandler) buyerProducts(w http.ResponseWriter, req *http.Request) {
@@ -244,58 +252,79 @@ func (u *buyerHandler) getBuyerConversationsUnread(w http.ResponseWriter, req *h
 	return
 }
 
-func (u *buyerHandler) pagedBuyerMessagesByConversationId(w http.ResponseWriter, req *http.Request) {
-	ctx := req.Context()
-
-	if req.Method == "GET" {
+//messagePageParams reads the "before" and "after" cursors and the "markRead" flag of a request
+//for a page of messages
+func messagePageParams(req *http.Request) (before, after int64, mark_read bool, err error) {
+	values := req.URL.Query()
 
-		decoder := json.NewDecoder(req.Body)
-		var conversation_req server.PagedBuyerMessagesByConversationIdReq
-		err := decoder.Decode(&conversation_req)
-		if err != nil {
-			http.Error(w, "unable to parse json", http.StatusInternalServerError)
-			return
+	for name, dest := range map[string]*int64{"before": &before, "after": &after} {
+		v := values.Get(name)
+		if v == "" {
+			continue
 		}
 
-		messages, err := u.buyerServer.PagedBuyerMessagesByConversationId(ctx, &conversation_req)
-		if err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
+		*dest, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || *dest < 0 {
+			return 0, 0, false, errs.New("invalid %s %q", name, v)
 		}
+	}
 
-		b, err := json.Marshal(messages)
+	if v := values.Get("markRead"); v != "" {
+		mark_read, err = strconv.ParseBool(v)
 		if err != nil {
-			http.Error(w, "server error", http.StatusInternalServerError)
-			return
+			return 0, 0, false, errs.New("invalid markRead %q", v)
 		}
+	}
 
-		h := w.Header()
-		h.Set("Content-Type", "application/json")
-		w.Write(b)
+	return before, after, mark_read, nil
+}
+
+func (u *buyerHandler) pagedBuyerMessagesByConversationId(w http.ResponseWriter,
+	req *http.Request) {
 
+	before, after, mark_read, err := messagePageParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
+	}
 
+	messages, err := u.buyerServer.PagedBuyerMessagesByConversationId(req.Context(),
+		&server.PagedBuyerMessagesByConversationIdReq{
+			BuyerPk:        GetBuyerPk(req.Context()),
+			ConversationId: chi.URLParam(req, "conversationId"),
+			Before:         before,
+			After:          after,
+			MarkRead:       mark_read,
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	http.Error(w, "method not allowed", http.StatusBadRequest)
-	return
+	writeJSON(w, messages)
 }
 
 func (u *buyerHandler) postBuyerMessageToConversation(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
 	if req.Method == "POST" {
-		decoder := json.NewDecoder(req.Body)
+		decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxMessageBytes))
 		var conversation_req server.PostBuyerMessageToConversationReq
 		err := decoder.Decode(&conversation_req)
 		if err != nil {
-			http.Error(w, "unable to parse json", http.StatusInternalServerError)
+			http.Error(w, "unable to parse json", http.StatusBadRequest)
 			return
 		}
 
 		conversation_req.BuyerPk = GetBuyerPk(req.Context())
 
 		messages, err := u.buyerServer.PostBuyerMessageToConversation(ctx, &conversation_req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
@@ -326,15 +355,19 @@ func (u *buyerHandler) buyerProductTrial(w http.ResponseWriter, req *http.Reques
 		var trial_req server.StartProductTrialReq
 		err := decoder.Decode(&trial_req)
 		if err != nil {
-			http.Error(w, "unable to parse json", http.StatusInternalServerError)
+			http.Error(w, "unable to parse json", http.StatusBadRequest)
 			return
 		}
 
 		trial_req.BuyerPk = GetBuyerPk(req.Context())
 
 		resp, err := u.buyerServer.StartProductTrial(ctx, &trial_req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
-			http.Error(w, "unable to parse json", http.StatusInternalServerError)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			server.Logger(ctx).Errorf("%+v", err)
 			return
 		}
 
@@ -422,3 +455,94 @@ func (u *buyerHandler) buyerProductReview(w http.ResponseWriter, req *http.Reque
 	http.Error(w, "method not allowed", http.StatusBadRequest)
 	return
 }
+
+func (u *buyerHandler) markBuyerConversationRead(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if req.Method == "POST" {
+		decoder := json.NewDecoder(req.Body)
+		var read_req server.MarkBuyerConversationReadReq
+		err := decoder.Decode(&read_req)
+		if err != nil {
+			http.Error(w, "unable to parse json", http.StatusInternalServerError)
+			return
+		}
+
+		read_req.BuyerPk = GetBuyerPk(req.Context())
+
+		read_resp, err := u.buyerServer.MarkBuyerConversationRead(ctx, &read_req)
+		if server.NotFound.Has(err) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		b, err := json.Marshal(read_resp)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "application/json")
+		w.Write(b)
+
+		return
+	}
+
+	http.Error(w, "method not allowed", http.StatusBadRequest)
+	return
+}
+
+func (u *buyerHandler) getBuyerUnreadCounts(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if req.Method == "GET" {
+		counts_req := &server.BuyerUnreadCountsReq{BuyerPk: GetBuyerPk(req.Context())}
+
+		counts, err := u.buyerServer.GetBuyerUnreadCounts(ctx, counts_req)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		b, err := json.Marshal(counts)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "application/json")
+		w.Write(b)
+
+		return
+	}
+
+	http.Error(w, "method not allowed", http.StatusBadRequest)
+	return
+}
+
+func (u *buyerHandler) buyerMessageAttachment(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	attachment_req := &server.BuyerMessageAttachmentReq{
+		BuyerPk:      GetBuyerPk(req.Context()),
+		AttachmentId: chi.URLParam(req, "attachmentId"),
+	}
+
+	attachment, err := u.buyerServer.GetBuyerMessageAttachment(ctx, attachment_req)
+	if server.NotFound.Has(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeAttachment(w, attachment)
+}
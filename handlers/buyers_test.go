@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/server"
+)
+
+func TestUpdateBuyerRoute(t *testing.T) {
+	h := newHandlerTest(t, "buyers")
+	_, session := h.signUpBuyer("ada@example.com")
+
+	//names and passwords are changed here
+	resp := h.serveBuyer(session, "PUT", "/api/buyer", `{"firstName": "Augusta",
+		"currentEmail": "ada@example.com", "currentPassword": "Password1!"}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var updated server.UpdateBuyerResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	require.Equal(t, "Augusta", updated.Buyer.FirstName)
+
+	//but clients still changing emails here are sent to the email endpoints
+	resp = h.serveBuyer(session, "PUT", "/api/buyer", `{"newEmail": "augusta@example.com",
+		"currentEmail": "ada@example.com", "currentPassword": "Password1!"}`)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "/api/buyer/emails")
+
+	resp = h.serveBuyer(session, "GET", "/api/buyer", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Contains(t, resp.Body.String(), "ada@example.com")
+	require.NotContains(t, resp.Body.String(), "augusta@example.com")
+}
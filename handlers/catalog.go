@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+const (
+	maxImportBytes = 10 << 20
+)
+
+//catalogFormat reads the "format" query parameter. csv is the default
+func catalogFormat(req *http.Request) string {
+	if format := req.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	return server.CSVFormat
+}
+
+//importCatalog takes the file as the raw request body
+func (v *vendorHandler) importCatalog(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.ImportCatalog(req.Context(), &server.ImportCatalogReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		Format:             catalogFormat(req),
+		File:               http.MaxBytesReader(w, req.Body, maxImportBytes),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) getCatalogImport(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.GetCatalogImport(req.Context(), &server.GetCatalogImportReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		ImportId:           chi.URLParam(req, "importId"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) exportCatalog(w http.ResponseWriter, req *http.Request) {
+	format := catalogFormat(req)
+
+	h := w.Header()
+	if format == server.JSONLFormat {
+		h.Set("Content-Type", "application/x-ndjson")
+	} else {
+		h.Set("Content-Type", "text/csv")
+	}
+	h.Set("Content-Disposition", `attachment; filename="catalog.`+format+`"`)
+
+	err := v.vendorServer.ExportCatalog(req.Context(), &server.ExportCatalogReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		Format:             format,
+	}, w)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	buyerSessionCookie  = "buyer_session"
+	vendorSessionCookie = "vendor_session"
+
+	//csrfCookie holds the session's csrf token for the page to copy into csrfHeader. it is the
+	//only cookie scripts can read
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+
+	sessionLifetime = 730 * time.Hour
+)
+
+//cookies sets the attributes every cookie ladybug hands out shares
+type cookies struct {
+	//insecure lets cookies go over plain http for local development
+	insecure bool
+}
+
+//setSession hands out a session cookie along with the csrf token that has to accompany requests
+//made with it. the token is also sent in csrfHeader for clients that cannot read cookies
+func (c cookies) setSession(w http.ResponseWriter, name, session_id string, created_at time.Time) {
+	expires := created_at.Add(sessionLifetime)
+	token := csrfToken(session_id)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    session_id,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   !c.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   !c.insecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set(csrfHeader, token)
+}
+
+//csrfToken derives the token for a session from its id. another site can plant a csrf cookie but
+//cannot know the session id, so it cannot come up with a token the session accepts
+func csrfToken(session_id string) string {
+	sum := sha256.Sum256([]byte("ladybug csrf\x00" + session_id))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type csrfExemptKey struct{}
+
+//exemptCSRF marks a request as authenticated by something other than a cookie, such as a token in
+//a header. browsers do not attach those to forged requests so they need no csrf checks
+func exemptCSRF(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), csrfExemptKey{}, true))
+}
+
+func isCSRFExempt(req *http.Request) bool {
+	exempt, _ := req.Context().Value(csrfExemptKey{}).(bool)
+	return exempt
+}
+
+//csrfGuard turns away requests that change something and might have been forged by another site
+type csrfGuard struct {
+	//origins are the origins besides the api's own that may make requests
+	origins map[string]bool
+}
+
+func newCSRFGuard(allowed_origins []string) *csrfGuard {
+	origins := map[string]bool{}
+	for _, origin := range allowed_origins {
+		origins[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+
+	return &csrfGuard{origins: origins}
+}
+
+//Protect checks the origin of unsafe requests and, when session_cookie is not empty, that they
+//carry the csrf token of the session they are made with. it goes after the auth middleware so
+//requests authenticated with tokens can be exempted
+func (g *csrfGuard) Protect(session_cookie string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if isSafeMethod(req.Method) || isCSRFExempt(req) {
+				handler.ServeHTTP(w, req)
+				return
+			}
+
+			if !g.allowedOrigin(req) {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+
+			if session_cookie != "" {
+				cookie, err := req.Cookie(session_cookie)
+				if err != nil || !validCSRFToken(req.Header.Get(csrfHeader), cookie.Value) {
+					http.Error(w, "missing or invalid csrf token", http.StatusForbidden)
+					return
+				}
+			}
+
+			handler.ServeHTTP(w, req)
+		})
+	}
+}
+
+//allowedOrigin checks the Origin header, or the Referer when a browser left it out. requests with
+//neither are refused since there is no telling where they came from. clients that are not
+//browsers can send their own Origin or use an api key
+func (g *csrfGuard) allowedOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		referer, err := url.Parse(req.Header.Get("Referer"))
+		if err != nil || referer.Host == "" {
+			return false
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	if g.origins[strings.ToLower(origin)] {
+		return true
+	}
+
+	//sandboxed pages and redirects send an origin of null, which is never the api's own
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, req.Host)
+}
+
+func validCSRFToken(token, session_id string) bool {
+	if token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(csrfToken(session_id))) == 1
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRFGuard(t *testing.T) {
+	guard := newCSRFGuard([]string{"https://shop.example.com"})
+	handler := guard.Protect(buyerSessionCookie)(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {}))
+
+	serve := func(method, origin, token string, edit func(*http.Request)) int {
+		req := httptest.NewRequest(method, "http://api.example.com/api/buyer", nil)
+		req.AddCookie(&http.Cookie{Name: buyerSessionCookie, Value: "session"})
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if token != "" {
+			req.Header.Set(csrfHeader, token)
+		}
+		if edit != nil {
+			edit(req)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	token := csrfToken("session")
+
+	//changes need the session's token
+	require.Equal(t, serve("POST", "https://shop.example.com", "", nil), http.StatusForbidden)
+	require.Equal(t, serve("POST", "https://shop.example.com", csrfToken("other"), nil),
+		http.StatusForbidden)
+	require.Equal(t, serve("POST", "https://shop.example.com", token, nil), http.StatusOK)
+
+	//and have to come from the api itself or an allowed origin
+	require.Equal(t, serve("POST", "https://evil.example.com", token, nil), http.StatusForbidden)
+	require.Equal(t, serve("POST", "null", token, nil), http.StatusForbidden)
+	require.Equal(t, serve("POST", "http://api.example.com", token, nil), http.StatusOK)
+
+	//the referer stands in for a missing origin, but one of them has to be sent
+	require.Equal(t, serve("POST", "", token, func(req *http.Request) {
+		req.Header.Set("Referer", "https://shop.example.com/cart")
+	}), http.StatusOK)
+	require.Equal(t, serve("POST", "", token, func(req *http.Request) {
+		req.Header.Set("Referer", "https://evil.example.com/cart")
+	}), http.StatusForbidden)
+	require.Equal(t, serve("POST", "", token, nil), http.StatusForbidden)
+
+	//reads are never checked
+	require.Equal(t, serve("GET", "https://evil.example.com", "", nil), http.StatusOK)
+	require.Equal(t, serve("GET", "", "", nil), http.StatusOK)
+
+	//nor are requests authenticated without cookies
+	require.Equal(t, serve("POST", "", "", func(req *http.Request) {
+		*req = *exemptCSRF(req)
+	}), http.StatusOK)
+}
+
+func TestCORSCredentials(t *testing.T) {
+	preflight := func(config Config) http.Header {
+		req := httptest.NewRequest("OPTIONS", "/api/buyer", nil)
+		req.Header.Set("Origin", "https://shop.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+
+		recorder := httptest.NewRecorder()
+		NewHandler(nil, config).ServeHTTP(recorder, req)
+		return recorder.Header()
+	}
+
+	//any site may read responses but not with the visitor's cookies
+	h := preflight(Config{})
+	require.Equal(t, h.Get("Access-Control-Allow-Origin"), "https://shop.example.com")
+	require.Empty(t, h.Get("Access-Control-Allow-Credentials"))
+
+	h = preflight(Config{AllowedOrigins: []string{"*"}})
+	require.Empty(t, h.Get("Access-Control-Allow-Credentials"))
+
+	//origins named explicitly get cookies
+	h = preflight(Config{AllowedOrigins: []string{"https://shop.example.com"}})
+	require.Equal(t, h.Get("Access-Control-Allow-Origin"), "https://shop.example.com")
+	require.Equal(t, h.Get("Access-Control-Allow-Credentials"), "true")
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (u *buyerHandler) addEmail(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var email_req server.AddBuyerEmailReq
+	err := decoder.Decode(&email_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	email_req.BuyerPk = GetBuyerPk(req.Context())
+
+	resp, err := u.buyerServer.AddBuyerEmail(req.Context(), &email_req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) verifyEmail(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var verify_req server.VerifyBuyerEmailReq
+	err := decoder.Decode(&verify_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	verify_req.BuyerPk = GetBuyerPk(req.Context())
+
+	resp, err := u.buyerServer.VerifyBuyerEmail(req.Context(), &verify_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) setPrimaryEmail(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.SetPrimaryBuyerEmail(req.Context(),
+		&server.SetPrimaryBuyerEmailReq{
+			BuyerPk: GetBuyerPk(req.Context()),
+			EmailId: chi.URLParam(req, "emailId"),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) removeEmail(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.RemoveBuyerEmail(req.Context(), &server.RemoveBuyerEmailReq{
+		BuyerPk: GetBuyerPk(req.Context()),
+		EmailId: chi.URLParam(req, "emailId"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
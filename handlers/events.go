@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/server"
+)
+
+const (
+	eventsHeartbeat = 30 * time.Second
+)
+
+//resumePoint is the last message a client saw in a conversation before it disconnected
+type resumePoint struct {
+	ConversationId     string
+	ConversationNumber int64
+}
+
+//resumePoints reads the Last-Event-ID header set by EventSource on reconnect along with any
+//"resume" query parameters. both use the <conversation id>:<conversation number> format of
+//message event ids
+func resumePoints(req *http.Request) (points []resumePoint, err error) {
+	values := req.URL.Query()["resume"]
+	if last := req.Header.Get("Last-Event-ID"); last != "" {
+		values = append(values, last)
+	}
+
+	for _, v := range values {
+		i := strings.LastIndex(v, ":")
+		if i <= 0 {
+			return nil, errs.New("invalid resume point %q", v)
+		}
+
+		number, err := strconv.ParseInt(v[i+1:], 10, 64)
+		if err != nil {
+			return nil, errs.New("invalid resume point %q", v)
+		}
+
+		points = append(points, resumePoint{
+			ConversationId:     v[:i],
+			ConversationNumber: number,
+		})
+	}
+
+	return points, nil
+}
+
+//resumeUnread adds a resume point at the read pointer of each unread conversation that does not
+//have one yet. Last-Event-ID only names the conversation of the last event the client saw, so the
+//others pick up from what was read
+func resumeUnread(points []resumePoint, unread []*server.ConversationUnread) []resumePoint {
+	resuming := map[string]bool{}
+	for _, p := range points {
+		resuming[p.ConversationId] = true
+	}
+
+	for _, c := range unread {
+		if !resuming[c.Id] {
+			points = append(points, resumePoint{
+				ConversationId:     c.Id,
+				ConversationNumber: c.LastRead,
+			})
+		}
+	}
+
+	return points
+}
+
+func writeEvent(w http.ResponseWriter, event *server.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if event.Type == server.MessageEvent {
+		_, err = fmt.Fprintf(w, "id: %s:%d\n", event.ConversationId, event.MessageNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, b)
+	return err
+}
+
+//eventSource is what an event stream needs from the buyer or vendor server
+type eventSource struct {
+	sub *server.Subscription
+
+	//listUnread lists the conversations with unread messages along with their read pointers
+	listUnread func() ([]*server.ConversationUnread, error)
+
+	//catchUp returns the messages posted in a conversation after a resume point
+	catchUp func(p resumePoint) ([]*server.Message, error)
+}
+
+//streamEvents writes a server-sent event stream. messages missed since each resume point are
+//written first, then events from the subscription until the client goes away or the server starts
+//draining. clients reconnect to another instance with their resume points
+func streamEvents(w http.ResponseWriter, req *http.Request, source *eventSource,
+	draining <-chan struct{}) {
+
+	defer source.sub.Close()
+
+	//streams outlive the server's write timeout. the heartbeat notices clients that went away
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		server.Logger(req.Context()).Debugf("event stream keeps write deadline: %+v", err)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	points, err := resumePoints(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Header.Get("Last-Event-ID") != "" {
+		unread, err := source.listUnread()
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		points = resumeUnread(points, unread)
+	}
+
+	//the subscription is open before catching up so nothing posted in between is lost. the client
+	//may see a message twice and should dedupe on the event id
+	var missed []*server.Event
+	for _, p := range points {
+		messages, err := source.catchUp(p)
+		if server.NotFound.Has(err) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		for _, m := range messages {
+			missed = append(missed, &server.Event{
+				Type:           server.MessageEvent,
+				ConversationId: p.ConversationId,
+				MessageNumber:  m.MessageNumber,
+				Message:        m,
+			})
+		}
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		err = writeEvent(w, event)
+		if err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := req.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-draining:
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-source.sub.Events:
+			if !ok {
+				return
+			}
+			err = writeEvent(w, event)
+		}
+		if err != nil {
+			server.Logger(req.Context()).Debugf("event stream closed: %+v", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (u *buyerHandler) buyerEvents(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	buyer_pk := GetBuyerPk(ctx)
+
+	streamEvents(w, req, &eventSource{
+		sub: u.buyerServer.SubscribeBuyerEvents(buyer_pk),
+		listUnread: func() ([]*server.ConversationUnread, error) {
+			resp, err := u.buyerServer.GetBuyerUnreadCounts(ctx,
+				&server.BuyerUnreadCountsReq{BuyerPk: buyer_pk})
+			if err != nil {
+				return nil, err
+			}
+
+			return resp.Conversations, nil
+		},
+		catchUp: func(p resumePoint) ([]*server.Message, error) {
+			resp, err := u.buyerServer.BuyerMessagesSince(ctx, &server.BuyerMessagesSinceReq{
+				BuyerPk:            buyer_pk,
+				ConversationId:     p.ConversationId,
+				ConversationNumber: p.ConversationNumber,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return resp.Messages, nil
+		},
+	}, u.draining)
+}
+
+func (v *vendorHandler) vendorEvents(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	vendor_pk := GetVendorPk(ctx)
+	contact_pk := GetExecutiveContactPk(ctx)
+
+	sub, err := v.vendorServer.SubscribeVendorEvents(ctx, &server.SubscribeVendorEventsReq{
+		VendorPk:           vendor_pk,
+		ExecutiveContactPk: contact_pk,
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	streamEvents(w, req, &eventSource{
+		sub: sub,
+		listUnread: func() ([]*server.ConversationUnread, error) {
+			resp, err := v.vendorServer.GetVendorUnreadCounts(ctx, &server.VendorUnreadCountsReq{
+				VendorPk:           vendor_pk,
+				ExecutiveContactPk: contact_pk,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return resp.Conversations, nil
+		},
+		catchUp: func(p resumePoint) ([]*server.Message, error) {
+			resp, err := v.vendorServer.VendorMessagesSince(ctx, &server.VendorMessagesSinceReq{
+				VendorPk:           vendor_pk,
+				ExecutiveContactPk: contact_pk,
+				ConversationId:     p.ConversationId,
+				ConversationNumber: p.ConversationNumber,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return resp.Messages, nil
+		},
+	}, v.draining)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/server"
+)
+
+func TestBuyerEventsResume(t *testing.T) {
+	h := newHandlerTest(t, "events")
+	buyer_id, buyer_session := h.signUpBuyer("ada@example.com")
+	_, first_session := h.createVendor()
+	_, second_session := h.createVendor()
+
+	post := func(vendor_session, description string) {
+		resp := h.serveVendor(vendor_session, "POST", "/api/vendor/conversation/message",
+			fmt.Sprintf(`{"buyerId": %q, "messageDescription": %q}`, buyer_id, description))
+		require.Equal(t, http.StatusOK, resp.Code)
+	}
+	post(first_session, "first")
+	post(second_session, "second")
+
+	resp := h.serveBuyer(buyer_session, "GET", "/api/buyer/conversations/unread-counts", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var counts server.BuyerUnreadCountsResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &counts))
+	require.Len(t, counts.Conversations, 2)
+
+	//the stream ends once it has caught up since the request is already canceled
+	eventIds := func(query, last_event_id string) ([]string, int) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req := httptest.NewRequest("GET", "/api/buyer/events"+query, nil).WithContext(ctx)
+		req.AddCookie(&http.Cookie{Name: buyerSessionCookie, Value: buyer_session})
+		if last_event_id != "" {
+			req.Header.Set("Last-Event-ID", last_event_id)
+		}
+
+		resp := httptest.NewRecorder()
+		h.handler.ServeHTTP(resp, req)
+
+		var ids []string
+		for _, line := range strings.Split(resp.Body.String(), "\n") {
+			if strings.HasPrefix(line, "id: ") {
+				ids = append(ids, strings.TrimPrefix(line, "id: "))
+			}
+		}
+		return ids, resp.Code
+	}
+
+	seen, unseen := counts.Conversations[0].Id, counts.Conversations[1].Id
+	post(first_session, "third")
+	post(second_session, "fourth")
+
+	//a client that saw the first message of one conversation before it dropped gets the rest of
+	//that one and everything unread in the others
+	ids, code := eventIds("", seen+":1")
+	require.Equal(t, http.StatusOK, code)
+	require.ElementsMatch(t, ids, []string{seen + ":2", unseen + ":1", unseen + ":2"})
+
+	//resume points it sends are kept
+	ids, code = eventIds("?resume="+unseen+":1", seen+":1")
+	require.Equal(t, http.StatusOK, code)
+	require.ElementsMatch(t, ids, []string{seen + ":2", unseen + ":2"})
+
+	//without Last-Event-ID only the resume points are caught up
+	ids, code = eventIds("?resume="+unseen+":1", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, ids, []string{unseen + ":2"})
+
+	//conversations that are not the buyer's are not found, and malformed ones are refused
+	_, code = eventIds("?resume=nope:1", "")
+	require.Equal(t, http.StatusNotFound, code)
+	_, code = eventIds("?resume=nope", "")
+	require.Equal(t, http.StatusBadRequest, code)
+}
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
@@ -12,37 +17,282 @@ import (
 
 type Handler struct {
 	http.Handler
+
+	vendors   *server.VendorServer
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+//Drain fails readiness and ends event streams so the server can shut down. it is meant for
+//http.Server.RegisterOnShutdown
+func (h *Handler) Drain() {
+	h.drainOnce.Do(func() { close(h.draining) })
+}
+
+//Wait blocks until catalog imports and other work that requests left running in the background are
+//done, or until ctx is
+func (h *Handler) Wait(ctx context.Context) error {
+	return h.vendors.WaitForImports(ctx)
+}
+
+//Config holds what NewHandler needs besides the database
+type Config struct {
+	Hub   server.Hub
+	Blobs server.BlobStore
+
+	//Filter is consulted for every message posted. it may be nil
+	Filter server.ContentFilter
+
+	//Mailer sends vendor team invites and buyer email verifications
+	Mailer server.Mailer
+
+	//DeletionGracePeriod is how long buyers and vendors have to cancel deleting their account. the
+	//server default is used when it is zero
+	DeletionGracePeriod time.Duration
+
+	//RateLimits overrides DefaultRateLimits for the route groups it names. a group with no requests
+	//allowed is not limited
+	RateLimits map[string]server.RateLimit
+
+	//RateLimitStore keeps the rate limit buckets. they are kept in memory when it is nil
+	RateLimitStore server.RateLimitStore
+
+	//AllowedOrigins are the origins of the sites that use the api with their visitors' cookies.
+	//when it is empty any site may read responses without cookies and only the api's own origin
+	//may change things
+	AllowedOrigins []string
+
+	//InsecureCookies lets session cookies go over plain http. it is only for local development
+	InsecureCookies bool
+
+	//ClientIPHeader is the header the proxy in front of the api sets to the client's address, such
+	//as X-Forwarded-For. requests are attributed to their connection's address when it is empty,
+	//which must be the case when there is no proxy since clients can set any header
+	ClientIPHeader string
+
+	//AdminToken is the bearer token for the /api/admin endpoints. they are not served when it is
+	//empty
+	AdminToken string
 }
 
-func NewHandler(db *database.DB) *Handler {
+func NewHandler(db *database.DB, config Config) *Handler {
 
 	r := chi.NewRouter()
 
+	//requests get their id and access log line before anything can turn them away
+	r.Use(withRequestMeta(config.ClientIPHeader))
+	r.Use(traceRequests)
+	r.Use(logRequests)
+	r.Use(measureRequests)
+
 	// Basic CORS
 	// for more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
+	//only origins named explicitly may send cookies. with none, or a wildcard, every site may read
+	//responses but none get to use the visitor's session
+	allowed_origins := config.AllowedOrigins
+	if len(allowed_origins) == 0 {
+		allowed_origins = []string{"*"}
+	}
+	allow_credentials := true
+	for _, origin := range allowed_origins {
+		if strings.Contains(origin, "*") {
+			allow_credentials = false
+		}
+	}
 	cors := cors.New(cors.Options{
-		// AllowedOrigins: []string{"https://foo.com"}, // Use this to allow specific origin
-		// hosts
-		AllowedOrigins: []string{"*"}, //TODO(mac): before this roles out to prod this needs to include a config option which includes where this is publicly hosted
-		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true
-		// },
+		AllowedOrigins:   allowed_origins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", csrfHeader},
+		ExposedHeaders:   []string{"Link", csrfHeader},
+		AllowCredentials: allow_credentials,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	})
 	r.Use(cors.Handler)
 
-	//a := &authMiddleware{db: db}
-	bs := server.NewBuyerServer(db)
-	u := newBuyerHandler(bs)
+	draining := make(chan struct{})
+
+	bs := server.NewBuyerServer(db, config.Hub, config.Blobs, config.Filter, config.Mailer,
+		config.DeletionGracePeriod)
+	cookies := cookies{insecure: config.InsecureCookies}
+	u := newBuyerHandler(bs, draining, cookies)
+
+	vs := server.NewVendorServer(db, config.Hub, config.Blobs, config.Filter, config.Mailer,
+		config.DeletionGracePeriod)
+	v := newVendorHandler(vs, draining, cookies)
+
+	a := &authMiddleware{db: db, vendors: vs}
+
+	//probed by the load balancer
+	health := &healthHandler{db: db, draining: draining}
+	r.Get("/healthz", http.HandlerFunc(health.healthz))
+	r.Get("/readyz", http.HandlerFunc(health.readyz))
+
+	//each group of api routes is rate limited on its own. changes made with a session cookie have
+	//to come from an allowed origin with the session's csrf token
+	limits := newRateLimiter(config.RateLimitStore, config.RateLimits)
+	csrf := newCSRFGuard(config.AllowedOrigins)
+	auth := r.With(csrf.Protect(""), limits.Limit(AuthRateLimit))
+	public := r.With(limits.Limit(PublicRateLimit))
+	buyer := r.With(a.CheckBuyerSessionCookie, csrf.Protect(buyerSessionCookie),
+		limits.Limit(BuyerRateLimit))
+	vendor := r.With(a.CheckVendorCredentials, csrf.Protect(vendorSessionCookie),
+		limits.Limit(VendorRateLimit))
+
+	//vendor api keys only reach the routes their scopes cover
+	readCatalog := vendor.With(requireScope(server.ReadCatalogScope))
+	writeCatalog := vendor.With(requireScope(server.WriteCatalogScope))
+	readOrders := vendor.With(requireScope(server.ReadOrdersScope))
+	messaging := vendor.With(requireScope(server.MessagingScope))
+	vendorSession := vendor.With(requireSession)
+
+	//describes every route under /api
+	public.Get("/api/openapi.json", http.HandlerFunc((&openAPIHandler{}).openAPI))
 
-	//vs := server.NewVendorServer(db)
-	//v := newVendorHandler(vs)
+	auth.Post("/api/buyer/sign-up", http.HandlerFunc(u.buyerSignUp))
+	auth.Post("/api/buyer/login", http.HandlerFunc(u.buyerLogin))
 
-	r.Post("/api/buyer/sign-up", http.HandlerFunc(u.buyerSignUp))
-	r.Post("/api/buyer/login", http.HandlerFunc(u.buyerLogin))
+	//push new messages and unread counts to the session as server-sent events
+	buyer.Get("/api/buyer/events", http.HandlerFunc(u.buyerEvents))
+	messaging.Get("/api/vendor/events", http.HandlerFunc(v.vendorEvents))
+
+	buyer.Post("/api/buyer/conversation/read",
+		http.HandlerFunc(u.markBuyerConversationRead))
+	buyer.Get("/api/buyer/conversations/unread-counts",
+		http.HandlerFunc(u.getBuyerUnreadCounts))
+	messaging.Post("/api/vendor/conversation/read",
+		http.HandlerFunc(v.markVendorConversationRead))
+	messaging.Get("/api/vendor/conversations/unread-counts",
+		http.HandlerFunc(v.getVendorUnreadCounts))
+
+	buyer.Get("/api/buyer/conversations/{conversationId}/messages",
+		http.HandlerFunc(u.pagedBuyerMessagesByConversationId))
+	messaging.Get("/api/vendor/conversations/{conversationId}/messages",
+		http.HandlerFunc(v.pagedVendorMessagesByConversationId))
+	buyer.Post("/api/buyer/conversation/message",
+		http.HandlerFunc(u.postBuyerMessageToConversation))
+	messaging.Post("/api/vendor/conversation/message",
+		http.HandlerFunc(v.postVendorMessageToConversation))
+
+	buyer.Get("/api/buyer/attachments/{attachmentId}",
+		http.HandlerFunc(u.buyerMessageAttachment))
+	messaging.Get("/api/vendor/attachments/{attachmentId}",
+		http.HandlerFunc(v.vendorMessageAttachment))
+
+	buyer.Get("/api/buyer/messages/search",
+		http.HandlerFunc(u.searchBuyerMessages))
+	messaging.Get("/api/vendor/messages/search",
+		http.HandlerFunc(v.searchVendorMessages))
+
+	buyer.Post("/api/buyer/conversation/block",
+		http.HandlerFunc(u.blockBuyerConversation))
+	buyer.Post("/api/buyer/conversation/report",
+		http.HandlerFunc(u.reportBuyerConversation))
+	messaging.Post("/api/vendor/conversation/block",
+		http.HandlerFunc(v.blockVendorConversation))
+	messaging.Post("/api/vendor/conversation/report",
+		http.HandlerFunc(v.reportVendorConversation))
+
+	buyer.Get("/api/buyer", http.HandlerFunc(u.buyer))
+	buyer.Put("/api/buyer", http.HandlerFunc(u.buyer))
+
+	buyer.Get("/api/buyer/addresses",
+		http.HandlerFunc(u.listAddresses))
+	buyer.Post("/api/buyer/addresses",
+		http.HandlerFunc(u.addAddress))
+	buyer.Post("/api/buyer/addresses/{addressId}",
+		http.HandlerFunc(u.updateAddress))
+	buyer.Delete("/api/buyer/addresses/{addressId}",
+		http.HandlerFunc(u.deleteAddress))
+	buyer.Post("/api/buyer/addresses/{addressId}/default",
+		http.HandlerFunc(u.setDefaultAddress))
+	buyer.Post("/api/buyer/emails",
+		http.HandlerFunc(u.addEmail))
+	buyer.Post("/api/buyer/emails/verify",
+		http.HandlerFunc(u.verifyEmail))
+	buyer.Post("/api/buyer/emails/{emailId}/primary",
+		http.HandlerFunc(u.setPrimaryEmail))
+	buyer.Delete("/api/buyer/emails/{emailId}",
+		http.HandlerFunc(u.removeEmail))
+
+	//buyers can download everything kept about them and leave
+	buyer.Get("/api/buyer/export",
+		http.HandlerFunc(u.exportBuyerData))
+	buyer.Get("/api/buyer/deletion",
+		http.HandlerFunc(u.getBuyerDeletion))
+	buyer.Post("/api/buyer/deletion",
+		http.HandlerFunc(u.requestBuyerDeletion))
+	buyer.Delete("/api/buyer/deletion",
+		http.HandlerFunc(u.cancelBuyerDeletion))
+
+	public.Get("/api/storefront/{slug}", http.HandlerFunc(u.getStorefront))
+	public.Get("/api/storefront/{slug}/products", http.HandlerFunc(u.storefrontProducts))
+	readCatalog.Get("/api/vendor/profile",
+		http.HandlerFunc(v.getVendorProfile))
+	writeCatalog.Post("/api/vendor/profile",
+		http.HandlerFunc(v.updateVendorProfile))
+
+	public.Get("/api/products/{productId}/variants", http.HandlerFunc(u.productVariants))
+	buyer.Post("/api/buyer/product/trial", http.HandlerFunc(u.buyerProductTrial))
+	writeCatalog.Post("/api/vendor/products/{productId}/variants",
+		http.HandlerFunc(v.addProductVariant))
+	writeCatalog.Post("/api/vendor/variants/{variantId}",
+		http.HandlerFunc(v.updateProductVariant))
+
+	readOrders.Get("/api/vendor/reports/sales",
+		http.HandlerFunc(v.vendorSalesReport))
+	readOrders.Get("/api/vendor/reports/sales.csv",
+		http.HandlerFunc(v.exportVendorSales))
+	readCatalog.Get("/api/vendor/reports/low-stock",
+		http.HandlerFunc(v.lowStock))
+
+	writeCatalog.Post("/api/vendor/catalog/import",
+		http.HandlerFunc(v.importCatalog))
+	readCatalog.Get("/api/vendor/catalog/import/{importId}",
+		http.HandlerFunc(v.getCatalogImport))
+	readCatalog.Get("/api/vendor/catalog/export",
+		http.HandlerFunc(v.exportCatalog))
+
+	auth.Post("/api/vendor/team/accept", http.HandlerFunc(v.acceptVendorInvite))
+	vendorSession.Get("/api/vendor/team", http.HandlerFunc(v.getVendorTeam))
+	vendorSession.Post("/api/vendor/team/invite",
+		http.HandlerFunc(v.inviteExecutiveContact))
+	vendorSession.Delete("/api/vendor/team/{contactId}",
+		http.HandlerFunc(v.removeExecutiveContact))
+	vendorSession.Post("/api/vendor/team/{contactId}/role",
+		http.HandlerFunc(v.setExecutiveContactRole))
+
+	//api keys can only be managed from a session
+	vendorSession.Get("/api/vendor/api-keys", http.HandlerFunc(v.listVendorApiKeys))
+	vendorSession.Post("/api/vendor/api-keys", http.HandlerFunc(v.createVendorApiKey))
+	vendorSession.Delete("/api/vendor/api-keys/{apiKeyId}",
+		http.HandlerFunc(v.revokeVendorApiKey))
+
+	vendorSession.Get("/api/vendor/webhooks", http.HandlerFunc(v.listWebhookEndpoints))
+	vendorSession.Post("/api/vendor/webhooks", http.HandlerFunc(v.createWebhookEndpoint))
+	vendorSession.Post("/api/vendor/webhooks/{endpointId}",
+		http.HandlerFunc(v.updateWebhookEndpoint))
+	vendorSession.Delete("/api/vendor/webhooks/{endpointId}",
+		http.HandlerFunc(v.deleteWebhookEndpoint))
+	vendorSession.Get("/api/vendor/webhooks/{endpointId}/deliveries",
+		http.HandlerFunc(v.pagedWebhookDeliveries))
+	vendorSession.Post("/api/vendor/webhooks/deliveries/{deliveryId}/replay",
+		http.HandlerFunc(v.replayWebhookDelivery))
+
+	//only owners can close the vendor's account, and only from a session
+	vendorSession.Get("/api/vendor/deletion", http.HandlerFunc(v.getVendorDeletion))
+	vendorSession.Post("/api/vendor/deletion", http.HandlerFunc(v.requestVendorDeletion))
+	vendorSession.Delete("/api/vendor/deletion", http.HandlerFunc(v.cancelVendorDeletion))
+
+	if config.AdminToken != "" {
+		ad := newAdminHandler(server.NewAdminServer(db), config.AdminToken)
+		admin := r.With(ad.CheckAdminToken, limits.Limit(AdminRateLimit))
+		admin.Get("/api/admin/reports",
+			http.HandlerFunc(ad.pagedOpenConversationReports))
+		admin.Post("/api/admin/reports/{reportId}/resolve",
+			http.HandlerFunc(ad.resolveConversationReport))
+		admin.Get("/api/admin/audit-events",
+			http.HandlerFunc(ad.pagedAuditEvents))
+	}
 
 	/*
 		mux := http.NewServeMux()
@@ -59,17 +309,12 @@ func NewHandler(db *database.DB) *Handler {
 			mux.Handle("/products", http.HandlerFunc(u.buyerProducts))
 			//TODO make a /products/category endpoint that lets you search products by category
 			mux.Handle("/buyer/conversations", a.CheckBuyerSessionCookie(http.HandlerFunc(u.getPagedBuyerConversations)))
-			//TODO make this function should mark a conversation as unread
 			mux.Handle("/buyer/conversations/unread", a.CheckBuyerSessionCookie(http.HandlerFunc(u.getBuyerConversationsUnread)))
-			mux.Handle("/buyer/conversation", a.CheckBuyerSessionCookie(http.HandlerFunc(u.pagedBuyerMessagesByConversationId)))
-			mux.Handle("/buyer/conversation/message", a.CheckBuyerSessionCookie(http.HandlerFunc(u.postBuyerMessageToConversation)))
 
 			//Product endpoints
-			//2) get trial product
 			//3) buy a product
 			//1) review a product
 			//4) change product review
-			mux.Handle("/buyer/product/trial", a.CheckBuyerSessionCookie(http.HandlerFunc(u.buyerProductTrial)))
 			mux.Handle("/buyer/product/review", a.CheckBuyerSessionCookie(http.HandlerFunc(u.buyerProductReview)))
 
 			//vendor endpoints
@@ -77,10 +322,49 @@ func NewHandler(db *database.DB) *Handler {
 			mux.Handle("/vendor/product", a.CheckVendorSessionCookie(http.HandlerFunc(v.vendorProduct)))
 			mux.Handle("/vendor/conversations", a.CheckVendorSessionCookie(http.HandlerFunc(v.getPagedVendorConversations)))
 			mux.Handle("/vendor/conversations/unread", a.CheckVendorSessionCookie(http.HandlerFunc(v.getVendorConversationsUnread)))
-			mux.Handle("/vendor/conversations/", a.CheckVendorSessionCookie(http.HandlerFunc(v.pagedVendorMessagesByConversationId)))
-			mux.Handle("/vendor/conversations/message", a.CheckVendorSessionCookie(http.HandlerFunc(v.postVendorMessageToConversation)))
 			//mux.Handle("/vendor/messages", a.CheckVendorSessionCookie(http.HandlerFunc(v.vendorMessage)))
 	*/
 
-	return &Handler{Handler: r}
+	return &Handler{Handler: r, vendors: vs, draining: draining}
+}
+
+//writeJSON writes resp as the json body of a successful response
+func writeJSON(w http.ResponseWriter, resp interface{}) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+//writeClientError writes the response for errors the server returns when a request is refused
+//rather than failing. it returns false if err is not one of them
+func writeClientError(w http.ResponseWriter, err error) bool {
+	switch {
+	case server.NotFound.Has(err):
+		http.Error(w, "not found", http.StatusNotFound)
+	case server.AddressInUse.Has(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case server.Unauthorized.Has(err):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case server.Forbidden.Has(err):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case server.Blocked.Has(err):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case server.RateLimited.Has(err):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case server.InvalidMessage.Has(err), server.InvalidAttachment.Has(err),
+		server.InvalidReport.Has(err), server.InvalidImport.Has(err), server.InvalidPage.Has(err),
+		server.InvalidVariant.Has(err), server.InvalidUpdate.Has(err),
+		server.InvalidDeletion.Has(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		return false
+	}
+
+	return true
 }
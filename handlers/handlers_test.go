@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+	"ladybug/server"
+	"ladybug/validate"
+)
+
+//handlerTest serves the api from a fresh in memory database
+type handlerTest struct {
+	t       *testing.T
+	db      *database.DB
+	handler http.Handler
+}
+
+func newHandlerTest(t *testing.T, name string) *handlerTest {
+	db, err := database.OpenTraced("sqlite3", "file:"+name+"?mode=memory&cache=shared")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(db.Schema())
+	require.NoError(t, err)
+
+	handler := NewHandler(db, Config{Hub: server.NewLocalHub(), Mailer: server.LogMailer{}})
+	return &handlerTest{t: t, db: db, handler: handler}
+}
+
+//signUpBuyer signs a buyer up and returns their id and session id
+func (h *handlerTest) signUpBuyer(email string) (buyer_id, session string) {
+	ctx := context.Background()
+	buyers := server.NewBuyerServer(h.db, nil, nil, nil, server.LogMailer{}, 0)
+	resp, err := buyers.BuyerSignUp(ctx, &server.SignUpRequest{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Password:  "Password1!",
+		Email:     email,
+		BillingAddress: &validate.Address{
+			CountryCode: "US",
+			Line1:       "21 heartbreak ln",
+			City:        "Paris",
+			Region:      "Florida",
+			PostalCode:  "87569",
+		},
+	})
+	require.NoError(h.t, err)
+
+	buyer, err := h.db.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(resp.Session.BuyerPk))
+	require.NoError(h.t, err)
+
+	return buyer.Id, resp.Session.Id
+}
+
+//serveBuyer sends a request the way a browser would with the buyer's session
+func (h *handlerTest) serveBuyer(session, method, path, body string) *httptest.ResponseRecorder {
+	return h.serve(buyerSessionCookie, session, method, path, body)
+}
+
+//createVendor creates a vendor with an owner and returns the vendor's id and the owner's session
+//id
+func (h *handlerTest) createVendor() (vendor_id, session string) {
+	ctx := context.Background()
+	vendor, err := h.db.Create_Vendor(ctx,
+		database.Vendor_Id(uuid.NewV4().String()),
+		database.Vendor_Fein(uuid.NewV4().String()))
+	require.NoError(h.t, err)
+
+	owner, err := h.db.Create_ExecutiveContact(ctx,
+		database.ExecutiveContact_Id(uuid.NewV4().String()),
+		database.ExecutiveContact_VendorPk(vendor.Pk),
+		database.ExecutiveContact_FirstName("some_firstName"),
+		database.ExecutiveContact_LastName("some_last_name"),
+		database.ExecutiveContact_Role(server.OwnerRole))
+	require.NoError(h.t, err)
+
+	vendor_session, err := h.db.Create_VendorSession(ctx,
+		database.VendorSession_VendorPk(vendor.Pk),
+		database.VendorSession_ExecutiveContactPk(owner.Pk),
+		database.VendorSession_Id(uuid.NewV4().String()))
+	require.NoError(h.t, err)
+
+	return vendor.Id, vendor_session.Id
+}
+
+//serveVendor sends a request the way a browser would with the vendor's session
+func (h *handlerTest) serveVendor(session, method, path, body string) *httptest.ResponseRecorder {
+	return h.serve(vendorSessionCookie, session, method, path, body)
+}
+
+func (h *handlerTest) serve(cookie, session, method, path, body string) (
+	resp *httptest.ResponseRecorder) {
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: cookie, Value: session})
+		req.Header.Set(csrfHeader, csrfToken(session))
+	}
+	req.Header.Set("Origin", "http://"+req.Host)
+
+	resp = httptest.NewRecorder()
+	h.handler.ServeHTTP(resp, req)
+	return resp
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ladybug/database"
+	"ladybug/server"
+)
+
+const readyTimeout = 2 * time.Second
+
+//healthHandler answers the load balancer's probes
+type healthHandler struct {
+	db       *database.DB
+	draining <-chan struct{}
+}
+
+//healthz says the process is up. it does not look at anything else so a database outage does not
+//get every instance restarted
+func (h *healthHandler) healthz(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprint(w, "ok")
+}
+
+//readyz says the instance can take traffic: it is not shutting down and the database answers
+func (h *healthHandler) readyz(w http.ResponseWriter, req *http.Request) {
+	select {
+	case <-h.draining:
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), readyTimeout)
+	defer cancel()
+
+	err := h.db.PingContext(ctx)
+	if err != nil {
+		server.Logger(ctx).Warnf("readiness: database unreachable: %+v", err)
+		http.Error(w, "database unreachable", http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprint(w, "ok")
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/server"
+)
+
+func TestPostMessageRoutes(t *testing.T) {
+	h := newHandlerTest(t, "messages")
+	buyer_id, buyer_session := h.signUpBuyer("ada@example.com")
+	vendor_id, vendor_session := h.createVendor()
+
+	//the buyer starts the conversation
+	resp := h.serveBuyer(buyer_session, "POST", "/api/buyer/conversation/message",
+		fmt.Sprintf(`{"vendorId": %q, "messageDescription": "is this in stock?"}`, vendor_id))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var posted server.PostBuyerMessageToConversationResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &posted))
+	require.Equal(t, "is this in stock?", posted.Message.Description)
+	require.EqualValues(t, 1, posted.Message.MessageNumber)
+
+	//and the vendor answers in it
+	resp = h.serveVendor(vendor_session, "POST", "/api/vendor/conversation/message",
+		fmt.Sprintf(`{"buyerId": %q, "messageDescription": "it is"}`, buyer_id))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var answered server.PostVendorMessageToConversationResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &answered))
+	require.False(t, answered.Message.BuyerSent)
+	require.EqualValues(t, 2, answered.Message.MessageNumber)
+
+	//bad attachments are the client's fault
+	resp = h.serveBuyer(buyer_session, "POST", "/api/buyer/conversation/message",
+		fmt.Sprintf(`{"vendorId": %q, "messageDescription": "see attached",
+			"attachments": [{"filename": "", "data": "aGk="}]}`, vendor_id))
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	//and posting takes a session
+	resp = h.serveBuyer("", "POST", "/api/buyer/conversation/message",
+		fmt.Sprintf(`{"vendorId": %q, "messageDescription": "hi"}`, vendor_id))
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestPageMessageRoutes(t *testing.T) {
+	h := newHandlerTest(t, "message_pages")
+	buyer_id, buyer_session := h.signUpBuyer("ada@example.com")
+	vendor_id, vendor_session := h.createVendor()
+
+	resp := h.serveBuyer(buyer_session, "POST", "/api/buyer/conversation/message",
+		fmt.Sprintf(`{"vendorId": %q, "messageDescription": "is this in stock?"}`, vendor_id))
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = h.serveVendor(vendor_session, "POST", "/api/vendor/conversation/message",
+		fmt.Sprintf(`{"buyerId": %q, "messageDescription": "it is"}`, buyer_id))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	unreadCounts := func() server.BuyerUnreadCountsResp {
+		resp := h.serveBuyer(buyer_session, "GET", "/api/buyer/conversations/unread-counts", "")
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var counts server.BuyerUnreadCountsResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &counts))
+		return counts
+	}
+	counts := unreadCounts()
+	require.Len(t, counts.Conversations, 1)
+	buyer_path := "/api/buyer/conversations/" + counts.Conversations[0].Id + "/messages"
+	vendor_path := "/api/vendor/conversations/" + counts.Conversations[0].Id + "/messages"
+
+	page := func(resp *httptest.ResponseRecorder) server.MessagePage {
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var page server.MessagePage
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+		return page
+	}
+
+	//the cursors come from the query string
+	newest := page(h.serveBuyer(buyer_session, "GET", buyer_path, ""))
+	require.Len(t, newest.Messages, 2)
+	require.EqualValues(t, 2, newest.After)
+
+	older := page(h.serveBuyer(buyer_session, "GET", buyer_path+"?before=2", ""))
+	require.Len(t, older.Messages, 1)
+	require.Equal(t, "is this in stock?", older.Messages[0].Description)
+
+	newer := page(h.serveVendor(vendor_session, "GET", vendor_path+"?after=1", ""))
+	require.Len(t, newer.Messages, 1)
+	require.Equal(t, "it is", newer.Messages[0].Description)
+
+	//pages are only marked read when asked
+	require.EqualValues(t, 1, unreadCounts().TotalUnread)
+	page(h.serveBuyer(buyer_session, "GET", buyer_path+"?markRead=true", ""))
+	require.EqualValues(t, 0, unreadCounts().TotalUnread)
+
+	//bad cursors are the client's fault
+	for _, query := range []string{"?before=2&after=1", "?before=x", "?after=-1",
+		"?markRead=maybe"} {
+
+		resp = h.serveBuyer(buyer_session, "GET", buyer_path+query, "")
+		require.Equal(t, http.StatusBadRequest, resp.Code, query)
+	}
+
+	//and conversations of other buyers cannot be read
+	_, other_session := h.signUpBuyer("grace@example.com")
+	resp = h.serveBuyer(other_session, "GET", buyer_path, "")
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "ladybug_http_request_duration_seconds",
+	Help: "How long requests took to serve, by route pattern, method and status.",
+}, []string{"route", "method", "status"})
+
+func init() {
+	prometheus.MustRegister(requestDuration)
+}
+
+//measureRequests times every request. routes are labelled by their chi pattern so ids in paths do
+//not make a series per product or conversation
+func measureRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		//the route context is filled in while routing, which happens after this middleware
+		route := "unmatched"
+		if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		requestDuration.WithLabelValues(route, req.Method,
+			strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	})
+}
@@ -1,34 +1,264 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 
 	"ladybug/database"
+	"ladybug/server"
 )
 
+const requestIdHeader = "X-Request-ID"
+
+//requestIdPattern is what an X-Request-ID sent by a client or proxy has to look like to be used.
+//anything else gets a fresh id so the logs cannot be forged into
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+//clientIP is the address a request came from. ip_header names the header the proxy in front of
+//the api puts the client's address in. when it is X-Forwarded-For the last address is used since
+//that is the one the proxy added, and clients can put anything before it
+func clientIP(req *http.Request, ip_header string) string {
+	if ip_header != "" {
+		addresses := strings.Split(req.Header.Get(ip_header), ",")
+		ip := strings.TrimSpace(addresses[len(addresses)-1])
+		if net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
+}
+
+//withRequestMeta records who a request came from so the audit log and rate limits can tell
+//clients apart. the request gets an id, echoed back in X-Request-ID, that every log line for it
+//carries
+func withRequestMeta(ip_header string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ip := clientIP(req, ip_header)
+
+			request_id := req.Header.Get(requestIdHeader)
+			if !requestIdPattern.MatchString(request_id) {
+				request_id = uuid.NewV4().String()
+			}
+			w.Header().Set(requestIdHeader, request_id)
+
+			ctx := server.WithRequestMeta(req.Context(), server.RequestMeta{
+				RequestId: request_id,
+				IP:        ip,
+				UserAgent: req.UserAgent(),
+			})
+			ctx = server.WithLogger(ctx, server.Logger(ctx).WithField("request_id", request_id))
+
+			handler.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+type accessKey struct{}
+
+//access is filled in as a request makes its way through the handlers. the auth middleware runs
+//after logRequests so it leaves the principal here instead of only in its own context
+type access struct {
+	principal string
+}
+
+//setPrincipal records who a request was authenticated as for the access log and adds them to the
+//request's logger
+func setPrincipal(req *http.Request, principal string) *http.Request {
+	ctx := req.Context()
+	if a, ok := ctx.Value(accessKey{}).(*access); ok {
+		a.principal = principal
+	}
+
+	return req.WithContext(server.WithLogger(ctx,
+		server.Logger(ctx).WithField("principal", principal)))
+}
+
+//statusRecorder remembers the status and size of a response for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+//Unwrap lets http.ResponseController reach the connection's writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+//Flush keeps event streams working through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//logRequests writes an access log line for every request once it has been served
+func logRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		a := &access{}
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		ctx := context.WithValue(req.Context(), accessKey{}, a)
+		handler.ServeHTTP(recorder, req.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		server.Logger(ctx).WithFields(logrus.Fields{
+			"method":     req.Method,
+			"path":       req.URL.Path,
+			"status":     recorder.status,
+			"bytes":      recorder.bytes,
+			"latency_ms": float64(time.Since(start)) / float64(time.Millisecond),
+			"ip":         server.GetRequestMeta(ctx).IP,
+			"principal":  a.principal,
+		}).Info("request")
+	})
+}
+
 type authMiddleware struct {
-	db *database.DB
+	db      *database.DB
+	vendors *server.VendorServer
+}
+
+type apiKeyKey struct{}
+
+//withApiKey records the api key a request was authenticated with
+func withApiKey(ctx context.Context, auth *server.VendorApiKeyAuth) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, auth)
+}
+
+//getApiKey returns the api key a request was authenticated with, or nil for sessions
+func getApiKey(ctx context.Context) *server.VendorApiKeyAuth {
+	auth, _ := ctx.Value(apiKeyKey{}).(*server.VendorApiKeyAuth)
+	return auth
+}
+
+//CheckVendorCredentials lets vendors in with a session cookie or, for their own systems, an api key
+//sent as a bearer token. keys act as the contact that created them like sessions do
+func (a *authMiddleware) CheckVendorCredentials(handler http.Handler) http.Handler {
+	check_cookie := a.CheckVendorSessionCookie(handler)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization := req.Header.Get("Authorization")
+		if authorization == "" {
+			check_cookie.ServeHTTP(w, req)
+			return
+		}
+
+		key := strings.TrimPrefix(authorization, "Bearer ")
+		if key == authorization {
+			http.Error(w, "expected a bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		auth, err := a.vendors.AuthenticateVendorApiKey(req.Context(), key)
+		if err != nil {
+			if !server.Unauthorized.Has(err) {
+				server.Logger(req.Context()).Errorf("%+v", err)
+			}
+			http.Error(w, "invalid api key", http.StatusUnauthorized)
+			return
+		}
+
+		//browsers do not attach keys to forged requests
+		req = exemptCSRF(setPrincipal(req, "vendor_api_key:"+auth.ApiKeyId))
+		c := withApiKey(WithVendorPk(req.Context(), auth.VendorPk), auth)
+		req = req.WithContext(WithExecutiveContactPk(c, auth.ExecutiveContactPk))
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+//requireScope keeps api keys without scope out of a route. sessions are only limited by their role
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if auth := getApiKey(req.Context()); auth != nil && !hasScope(auth.Scopes, scope) {
+				http.Error(w, fmt.Sprintf("api key lacks the %s scope", scope),
+					http.StatusForbidden)
+				return
+			}
+
+			handler.ServeHTTP(w, req)
+		})
+	}
+}
+
+//requireSession keeps api keys out of routes no scope covers, such as managing the team or keys
+func requireSession(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if getApiKey(req.Context()) != nil {
+			http.Error(w, "api keys cannot be used here", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (a *authMiddleware) CheckVendorSessionCookie(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 
-		cookie, err := req.Cookie("vendor_session")
+		cookie, err := req.Cookie(vendorSessionCookie)
 		if err != nil {
 			http.Error(w, fmt.Sprint(err), http.StatusUnauthorized)
 			return
 		}
 
-		pk_row, err := a.db.Get_VendorSession_VendorPk_By_Id(req.Context(),
+		ctx := req.Context()
+		session, err := a.db.WithContext(ctx).Get_VendorSession_By_Id(ctx,
 			database.VendorSession_Id(cookie.Value))
 		if err != nil {
 			http.Error(w, fmt.Sprint(err), http.StatusUnauthorized)
 			return
 		}
 
-		c := req.Context()
-		req = req.WithContext(WithBuyerPk(c, pk_row.VendorPk))
+		req = setPrincipal(req, fmt.Sprintf("executive_contact:%d", session.ExecutiveContactPk))
+		c := WithVendorPk(req.Context(), session.VendorPk)
+		req = req.WithContext(WithExecutiveContactPk(c, session.ExecutiveContactPk))
 
 		handler.ServeHTTP(w, req)
 	})
@@ -37,19 +267,21 @@ func (a *authMiddleware) CheckVendorSessionCookie(handler http.Handler) http.Han
 func (a *authMiddleware) CheckBuyerSessionCookie(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 
-		cookie, err := req.Cookie("buyer_session")
+		cookie, err := req.Cookie(buyerSessionCookie)
 		if err != nil {
 			http.Error(w, fmt.Sprint(err), http.StatusUnauthorized)
 			return
 		}
 
-		pk_row, err := a.db.Get_BuyerSession_BuyerPk_By_Id(req.Context(),
+		ctx := req.Context()
+		pk_row, err := a.db.WithContext(ctx).Get_BuyerSession_BuyerPk_By_Id(ctx,
 			database.BuyerSession_Id(cookie.Value))
 		if err != nil {
 			http.Error(w, fmt.Sprint(err), http.StatusUnauthorized)
 			return
 		}
 
+		req = setPrincipal(req, fmt.Sprintf("buyer:%d", pk_row.BuyerPk))
 		c := req.Context()
 		req = req.WithContext(WithBuyerPk(c, pk_row.BuyerPk))
 
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/products", nil)
+	req.RemoteAddr = "10.0.0.2:5123"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.4")
+
+	//without a trusted header the connection's address is used whatever the client sends
+	require.Equal(t, clientIP(req, ""), "10.0.0.2")
+
+	//the proxy appends the address it saw, so anything before it could be forged
+	require.Equal(t, clientIP(req, "X-Forwarded-For"), "198.51.100.4")
+
+	//a missing or garbled header falls back to the connection
+	req.Header.Set("X-Forwarded-For", "not an address")
+	require.Equal(t, clientIP(req, "X-Forwarded-For"), "10.0.0.2")
+	req.Header.Del("X-Forwarded-For")
+	require.Equal(t, clientIP(req, "X-Forwarded-For"), "10.0.0.2")
+}
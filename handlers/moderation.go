@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ladybug/server"
+)
+
+func (u *buyerHandler) blockBuyerConversation(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var block_req server.BlockBuyerConversationReq
+	err := decoder.Decode(&block_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	block_req.BuyerPk = GetBuyerPk(req.Context())
+
+	resp, err := u.buyerServer.BlockBuyerConversation(req.Context(), &block_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) reportBuyerConversation(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var report_req server.ReportBuyerConversationReq
+	err := decoder.Decode(&report_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	report_req.BuyerPk = GetBuyerPk(req.Context())
+
+	resp, err := u.buyerServer.ReportBuyerConversation(req.Context(), &report_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) blockVendorConversation(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var block_req server.BlockVendorConversationReq
+	err := decoder.Decode(&block_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	block_req.VendorPk = GetVendorPk(req.Context())
+	block_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+	resp, err := v.vendorServer.BlockVendorConversation(req.Context(), &block_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) reportVendorConversation(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var report_req server.ReportVendorConversationReq
+	err := decoder.Decode(&report_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	report_req.VendorPk = GetVendorPk(req.Context())
+	report_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+	resp, err := v.vendorServer.ReportVendorConversation(req.Context(), &report_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
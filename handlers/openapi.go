@@ -0,0 +1,629 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/server"
+)
+
+//apiAuth is what an operation needs to be called with
+type apiAuth int
+
+const (
+	noAuth apiAuth = iota
+	buyerAuth
+	//vendorAuth takes a vendor session or an api key with the operation's scope
+	vendorAuth
+	vendorSessionAuth
+	adminAuth
+)
+
+//apiOperation documents one api route. request and response are values of the types decoded from
+//and written as the json bodies, nil when there is no json body
+type apiOperation struct {
+	method string
+	path   string
+	id     string
+	auth   apiAuth
+	scope  string
+
+	//query maps query parameter names to their openapi types
+	query    map[string]string
+	request  interface{}
+	response interface{}
+
+	//requestType and responseType are the content types of bodies that are not json
+	requestType  string
+	responseType string
+}
+
+//messagePageQuery are the query parameters of a request for a page of messages
+var messagePageQuery = map[string]string{"before": "integer", "after": "integer",
+	"markRead": "boolean"}
+
+//apiOperations is every route under /api. TestOpenAPIRoutes fails when a route is added without
+//being documented here
+var apiOperations = []apiOperation{
+	{method: "GET", path: "/api/openapi.json", id: "openAPI", responseType: "application/json"},
+
+	{method: "POST", path: "/api/buyer/sign-up", id: "buyerSignUp",
+		request: server.SignUpRequest{}},
+	{method: "POST", path: "/api/buyer/login", id: "buyerLogin",
+		request: server.LogInRequest{}},
+
+	{method: "GET", path: "/api/buyer/events", id: "buyerEvents", auth: buyerAuth,
+		query: map[string]string{"resume": "string"}, responseType: "text/event-stream"},
+	{method: "GET", path: "/api/vendor/events", id: "vendorEvents", auth: vendorAuth,
+		scope: server.MessagingScope, query: map[string]string{"resume": "string"},
+		responseType: "text/event-stream"},
+
+	{method: "POST", path: "/api/buyer/conversation/read", id: "markBuyerConversationRead",
+		auth: buyerAuth, request: server.MarkBuyerConversationReadReq{},
+		response: server.MarkBuyerConversationReadResp{}},
+	{method: "GET", path: "/api/buyer/conversations/unread-counts", id: "getBuyerUnreadCounts",
+		auth: buyerAuth, response: server.BuyerUnreadCountsResp{}},
+	{method: "POST", path: "/api/vendor/conversation/read", id: "markVendorConversationRead",
+		auth: vendorAuth, scope: server.MessagingScope,
+		request:  server.MarkVendorConversationReadReq{},
+		response: server.MarkVendorConversationReadResp{}},
+	{method: "GET", path: "/api/vendor/conversations/unread-counts",
+		id: "getVendorUnreadCounts", auth: vendorAuth, scope: server.MessagingScope,
+		response: server.VendorUnreadCountsResp{}},
+
+	{method: "GET", path: "/api/buyer/conversations/{conversationId}/messages",
+		id: "pagedBuyerMessagesByConversationId", auth: buyerAuth, query: messagePageQuery,
+		response: server.PagedBuyerMessagesByConversationIdResp{}},
+	{method: "GET", path: "/api/vendor/conversations/{conversationId}/messages",
+		id: "pagedVendorMessagesByConversationId", auth: vendorAuth,
+		scope: server.MessagingScope, query: messagePageQuery,
+		response: server.PagedVendorMessagesByConversationIdResp{}},
+	{method: "POST", path: "/api/buyer/conversation/message",
+		id: "postBuyerMessageToConversation", auth: buyerAuth,
+		request:  server.PostBuyerMessageToConversationReq{},
+		response: server.PostBuyerMessageToConversationResp{}},
+	{method: "POST", path: "/api/vendor/conversation/message",
+		id: "postVendorMessageToConversation", auth: vendorAuth, scope: server.MessagingScope,
+		request:  server.PostVendorMessageToConversationReq{},
+		response: server.PostVendorMessageToConversationResp{}},
+
+	{method: "GET", path: "/api/buyer/attachments/{attachmentId}", id: "buyerMessageAttachment",
+		auth: buyerAuth, responseType: "application/octet-stream"},
+	{method: "GET", path: "/api/vendor/attachments/{attachmentId}",
+		id: "vendorMessageAttachment", auth: vendorAuth, scope: server.MessagingScope,
+		responseType: "application/octet-stream"},
+
+	{method: "GET", path: "/api/buyer/messages/search", id: "searchBuyerMessages",
+		auth: buyerAuth, query: map[string]string{"q": "string", "offset": "integer"},
+		response: server.SearchBuyerMessagesResp{}},
+	{method: "GET", path: "/api/vendor/messages/search", id: "searchVendorMessages",
+		auth: vendorAuth, scope: server.MessagingScope,
+		query:    map[string]string{"q": "string", "offset": "integer"},
+		response: server.SearchVendorMessagesResp{}},
+
+	{method: "POST", path: "/api/buyer/conversation/block", id: "blockBuyerConversation",
+		auth: buyerAuth, request: server.BlockBuyerConversationReq{},
+		response: server.BlockBuyerConversationResp{}},
+	{method: "POST", path: "/api/buyer/conversation/report", id: "reportBuyerConversation",
+		auth: buyerAuth, request: server.ReportBuyerConversationReq{},
+		response: server.ReportBuyerConversationResp{}},
+	{method: "POST", path: "/api/vendor/conversation/block", id: "blockVendorConversation",
+		auth: vendorAuth, scope: server.MessagingScope,
+		request:  server.BlockVendorConversationReq{},
+		response: server.BlockVendorConversationResp{}},
+	{method: "POST", path: "/api/vendor/conversation/report", id: "reportVendorConversation",
+		auth: vendorAuth, scope: server.MessagingScope,
+		request:  server.ReportVendorConversationReq{},
+		response: server.ReportVendorConversationResp{}},
+
+	{method: "GET", path: "/api/buyer", id: "getBuyer", auth: buyerAuth,
+		response: server.GetBuyerResponse{}},
+	{method: "PUT", path: "/api/buyer", id: "updateBuyer", auth: buyerAuth,
+		request: server.UpdateBuyerRequest{}, response: server.UpdateBuyerResponse{}},
+
+	{method: "GET", path: "/api/buyer/addresses", id: "listAddresses", auth: buyerAuth,
+		response: server.ListAddressesResp{}},
+	{method: "POST", path: "/api/buyer/addresses", id: "addAddress", auth: buyerAuth,
+		request: server.AddAddressReq{}, response: server.AddAddressResp{}},
+	{method: "POST", path: "/api/buyer/addresses/{addressId}", id: "updateAddress",
+		auth: buyerAuth, request: server.UpdateAddressReq{},
+		response: server.UpdateAddressResp{}},
+	{method: "DELETE", path: "/api/buyer/addresses/{addressId}", id: "deleteAddress",
+		auth: buyerAuth, response: server.DeleteAddressResp{}},
+	{method: "POST", path: "/api/buyer/addresses/{addressId}/default", id: "setDefaultAddress",
+		auth: buyerAuth, request: server.SetDefaultAddressReq{},
+		response: server.SetDefaultAddressResp{}},
+	{method: "POST", path: "/api/buyer/emails", id: "addEmail", auth: buyerAuth,
+		request: server.AddBuyerEmailReq{}, response: server.AddBuyerEmailResp{}},
+	{method: "POST", path: "/api/buyer/emails/verify", id: "verifyEmail", auth: buyerAuth,
+		request: server.VerifyBuyerEmailReq{}, response: server.VerifyBuyerEmailResp{}},
+	{method: "POST", path: "/api/buyer/emails/{emailId}/primary", id: "setPrimaryEmail",
+		auth: buyerAuth, response: server.SetPrimaryBuyerEmailResp{}},
+	{method: "DELETE", path: "/api/buyer/emails/{emailId}", id: "removeEmail", auth: buyerAuth,
+		response: server.RemoveBuyerEmailResp{}},
+
+	{method: "GET", path: "/api/buyer/export", id: "exportBuyerData", auth: buyerAuth,
+		responseType: "application/zip"},
+	{method: "GET", path: "/api/buyer/deletion", id: "getBuyerDeletion", auth: buyerAuth,
+		response: server.GetBuyerDeletionResp{}},
+	{method: "POST", path: "/api/buyer/deletion", id: "requestBuyerDeletion", auth: buyerAuth,
+		request: server.RequestBuyerDeletionReq{}, response: server.RequestBuyerDeletionResp{}},
+	{method: "DELETE", path: "/api/buyer/deletion", id: "cancelBuyerDeletion", auth: buyerAuth,
+		response: server.CancelBuyerDeletionResp{}},
+
+	{method: "GET", path: "/api/storefront/{slug}", id: "getStorefront",
+		response: server.StorefrontResp{}},
+	{method: "GET", path: "/api/storefront/{slug}/products", id: "storefrontProducts",
+		query: map[string]string{"pageToken": "string"}, response: server.ProductResponse{}},
+	{method: "GET", path: "/api/vendor/profile", id: "getVendorProfile", auth: vendorAuth,
+		scope: server.ReadCatalogScope, response: server.GetVendorProfileResp{}},
+	{method: "POST", path: "/api/vendor/profile", id: "updateVendorProfile", auth: vendorAuth,
+		scope: server.WriteCatalogScope, request: server.UpdateVendorProfileReq{},
+		response: server.UpdateVendorProfileResp{}},
+
+	{method: "GET", path: "/api/products/{productId}/variants", id: "productVariants",
+		response: server.ProductVariantsResp{}},
+	{method: "POST", path: "/api/buyer/product/trial", id: "buyerProductTrial", auth: buyerAuth,
+		request: server.StartProductTrialReq{}, response: server.StartProductTrialResp{}},
+	{method: "POST", path: "/api/vendor/products/{productId}/variants", id: "addProductVariant",
+		auth: vendorAuth, scope: server.WriteCatalogScope,
+		request: server.AddProductVariantReq{}, response: server.AddProductVariantResp{}},
+	{method: "POST", path: "/api/vendor/variants/{variantId}", id: "updateProductVariant",
+		auth: vendorAuth, scope: server.WriteCatalogScope,
+		request:  server.UpdateProductVariantReq{},
+		response: server.UpdateProductVariantResp{}},
+
+	{method: "GET", path: "/api/vendor/reports/sales", id: "vendorSalesReport",
+		auth: vendorAuth, scope: server.ReadOrdersScope,
+		query:    map[string]string{"from": "integer", "to": "integer", "interval": "string"},
+		response: server.VendorSalesReportResp{}},
+	{method: "GET", path: "/api/vendor/reports/sales.csv", id: "exportVendorSales",
+		auth: vendorAuth, scope: server.ReadOrdersScope,
+		query:        map[string]string{"from": "integer", "to": "integer", "interval": "string"},
+		responseType: "text/csv"},
+	{method: "GET", path: "/api/vendor/reports/low-stock", id: "lowStock", auth: vendorAuth,
+		scope: server.ReadCatalogScope, query: map[string]string{"threshold": "integer"},
+		response: server.LowStockResp{}},
+
+	{method: "POST", path: "/api/vendor/catalog/import", id: "importCatalog", auth: vendorAuth,
+		scope: server.WriteCatalogScope, query: map[string]string{"format": "string"},
+		requestType: "text/csv", response: server.ImportCatalogResp{}},
+	{method: "GET", path: "/api/vendor/catalog/import/{importId}", id: "getCatalogImport",
+		auth: vendorAuth, scope: server.ReadCatalogScope,
+		response: server.GetCatalogImportResp{}},
+	{method: "GET", path: "/api/vendor/catalog/export", id: "exportCatalog", auth: vendorAuth,
+		scope: server.ReadCatalogScope, query: map[string]string{"format": "string"},
+		responseType: "text/csv"},
+
+	{method: "POST", path: "/api/vendor/team/accept", id: "acceptVendorInvite",
+		request: server.AcceptVendorInviteReq{}, response: server.AcceptVendorInviteResp{}},
+	{method: "GET", path: "/api/vendor/team", id: "getVendorTeam", auth: vendorSessionAuth,
+		response: server.GetVendorTeamResp{}},
+	{method: "POST", path: "/api/vendor/team/invite", id: "inviteExecutiveContact",
+		auth: vendorSessionAuth, request: server.InviteExecutiveContactReq{},
+		response: server.InviteExecutiveContactResp{}},
+	{method: "DELETE", path: "/api/vendor/team/{contactId}", id: "removeExecutiveContact",
+		auth: vendorSessionAuth, response: server.RemoveExecutiveContactResp{}},
+	{method: "POST", path: "/api/vendor/team/{contactId}/role", id: "setExecutiveContactRole",
+		auth: vendorSessionAuth, request: server.SetExecutiveContactRoleReq{},
+		response: server.SetExecutiveContactRoleResp{}},
+
+	{method: "GET", path: "/api/vendor/api-keys", id: "listVendorApiKeys",
+		auth: vendorSessionAuth, response: server.ListVendorApiKeysResp{}},
+	{method: "POST", path: "/api/vendor/api-keys", id: "createVendorApiKey",
+		auth: vendorSessionAuth, request: server.CreateVendorApiKeyReq{},
+		response: server.CreateVendorApiKeyResp{}},
+	{method: "DELETE", path: "/api/vendor/api-keys/{apiKeyId}", id: "revokeVendorApiKey",
+		auth: vendorSessionAuth, response: server.RevokeVendorApiKeyResp{}},
+
+	{method: "GET", path: "/api/vendor/webhooks", id: "listWebhookEndpoints",
+		auth: vendorSessionAuth, response: server.ListWebhookEndpointsResp{}},
+	{method: "POST", path: "/api/vendor/webhooks", id: "createWebhookEndpoint",
+		auth: vendorSessionAuth, request: server.CreateWebhookEndpointReq{},
+		response: server.CreateWebhookEndpointResp{}},
+	{method: "POST", path: "/api/vendor/webhooks/{endpointId}", id: "updateWebhookEndpoint",
+		auth: vendorSessionAuth, request: server.UpdateWebhookEndpointReq{},
+		response: server.UpdateWebhookEndpointResp{}},
+	{method: "DELETE", path: "/api/vendor/webhooks/{endpointId}", id: "deleteWebhookEndpoint",
+		auth: vendorSessionAuth, response: server.DeleteWebhookEndpointResp{}},
+	{method: "GET", path: "/api/vendor/webhooks/{endpointId}/deliveries",
+		id: "pagedWebhookDeliveries", auth: vendorSessionAuth,
+		query:    map[string]string{"offset": "integer"},
+		response: server.PagedWebhookDeliveriesResp{}},
+	{method: "POST", path: "/api/vendor/webhooks/deliveries/{deliveryId}/replay",
+		id: "replayWebhookDelivery", auth: vendorSessionAuth,
+		response: server.ReplayWebhookDeliveryResp{}},
+
+	{method: "GET", path: "/api/vendor/deletion", id: "getVendorDeletion",
+		auth: vendorSessionAuth, response: server.GetVendorDeletionResp{}},
+	{method: "POST", path: "/api/vendor/deletion", id: "requestVendorDeletion",
+		auth: vendorSessionAuth, response: server.RequestVendorDeletionResp{}},
+	{method: "DELETE", path: "/api/vendor/deletion", id: "cancelVendorDeletion",
+		auth: vendorSessionAuth, response: server.CancelVendorDeletionResp{}},
+
+	{method: "GET", path: "/api/admin/reports", id: "pagedOpenConversationReports",
+		auth: adminAuth, query: map[string]string{"offset": "integer"},
+		response: server.PagedOpenConversationReportsResp{}},
+	{method: "POST", path: "/api/admin/reports/{reportId}/resolve",
+		id: "resolveConversationReport", auth: adminAuth,
+		request:  server.ResolveConversationReportReq{},
+		response: server.ResolveConversationReportResp{}},
+	{method: "GET", path: "/api/admin/audit-events", id: "pagedAuditEvents", auth: adminAuth,
+		query: map[string]string{"actor": "string", "target": "string", "from": "integer",
+			"to": "integer", "offset": "integer"},
+		response: server.PagedAuditEventsResp{}},
+}
+
+var pathParamRe = regexp.MustCompile(`{([^}]+)}`)
+
+//newOpenAPIDocument builds an openapi 3 document for operations. the schemas follow the json tags
+//of the request and response types. fields without a json tag are filled in by the handlers from
+//the session and the url so they are left out, and a malformed tag is an error
+func newOpenAPIDocument(operations []apiOperation) (map[string]interface{}, error) {
+	schemas := &openAPISchemas{types: map[string]reflect.Type{}, schemas: map[string]interface{}{}}
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range operations {
+		var params []interface{}
+		path_params := map[string]bool{}
+		for _, match := range pathParamRe.FindAllStringSubmatch(op.path, -1) {
+			path_params[match[1]] = true
+			params = append(params, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		var names []string
+		for name := range op.query {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "query",
+				"schema": map[string]interface{}{"type": op.query[name]},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"operationId": op.id,
+			"security":    op.auth.security(),
+			"responses":   map[string]interface{}{},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.scope != "" {
+			operation["description"] = "api keys need the " + op.scope + " scope"
+		}
+
+		switch {
+		case op.request != nil:
+			//fields named after path parameters are set from the url
+			schema, err := schemas.structSchema(reflect.TypeOf(op.request), path_params)
+			if err != nil {
+				return nil, errs.New("%s %s: %v", op.method, op.path, err)
+			}
+			operation["requestBody"] = openAPIContent("application/json", schema)
+		case op.requestType != "":
+			operation["requestBody"] = openAPIContent(op.requestType,
+				map[string]interface{}{"type": "string", "format": "binary"})
+		}
+
+		ok := map[string]interface{}{"description": "ok"}
+		switch {
+		case op.response != nil:
+			schema, err := schemas.schema(reflect.TypeOf(op.response))
+			if err != nil {
+				return nil, errs.New("%s %s: %v", op.method, op.path, err)
+			}
+			ok["content"] = openAPIContent("application/json", schema)["content"]
+		case op.responseType != "":
+			ok["content"] = openAPIContent(op.responseType,
+				map[string]interface{}{"type": "string"})["content"]
+		}
+		operation["responses"] = map[string]interface{}{
+			"200": ok,
+			"default": map[string]interface{}{
+				"description": "the request was refused or failed",
+				"content": openAPIContent("text/plain",
+					map[string]interface{}{"type": "string"})["content"],
+			},
+		}
+
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]interface{}{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": "Ladybug", "version": "1"},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]interface{}{
+				"buyerSession": map[string]interface{}{
+					"type": "apiKey", "in": "cookie", "name": buyerSessionCookie,
+				},
+				"vendorSession": map[string]interface{}{
+					"type": "apiKey", "in": "cookie", "name": vendorSessionCookie,
+				},
+				"vendorApiKey": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"adminToken":   map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}, nil
+}
+
+func (a apiAuth) security() []interface{} {
+	scheme := func(name string) interface{} {
+		return map[string]interface{}{name: []string{}}
+	}
+
+	switch a {
+	case buyerAuth:
+		return []interface{}{scheme("buyerSession")}
+	case vendorAuth:
+		return []interface{}{scheme("vendorSession"), scheme("vendorApiKey")}
+	case vendorSessionAuth:
+		return []interface{}{scheme("vendorSession")}
+	case adminAuth:
+		return []interface{}{scheme("adminToken")}
+	default:
+		return []interface{}{}
+	}
+}
+
+func openAPIContent(content_type string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"content": map[string]interface{}{
+			content_type: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+//openAPISchemas collects the named types the document refers to
+type openAPISchemas struct {
+	types   map[string]reflect.Type
+	schemas map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+//schema is the schema of values of t as encoding/json writes them. named structs become
+//components that are referred to
+func (s *openAPISchemas) schema(t reflect.Type) (interface{}, error) {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case rawMessageType:
+		return map[string]interface{}{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}, nil
+		}
+		items, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t, nil)
+		}
+	default:
+		return nil, errs.New("%s cannot be written as json", t)
+	}
+
+	//types from other packages are named after their package too since server has types of the
+	//same name
+	name := t.Name()
+	if t.PkgPath() != reflect.TypeOf(server.Buyer{}).PkgPath() {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if seen, ok := s.types[name]; ok {
+		if seen != t {
+			return nil, errs.New("%s and %s have the same name", seen, t)
+		}
+		return ref, nil
+	}
+
+	//the type is recorded before its fields so types that refer to themselves end
+	s.types[name] = t
+	schema, err := s.structSchema(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.schemas[name] = schema
+
+	return ref, nil
+}
+
+//structSchema is the object schema of the struct t without the properties in skip
+func (s *openAPISchemas) structSchema(t reflect.Type, skip map[string]bool) (
+	map[string]interface{}, error) {
+
+	properties := map[string]interface{}{}
+	err := s.addProperties(properties, t)
+	if err != nil {
+		return nil, err
+	}
+	for name := range skip {
+		delete(properties, name)
+	}
+
+	return map[string]interface{}{"type": "object", "properties": properties}, nil
+}
+
+func (s *openAPISchemas) addProperties(properties map[string]interface{}, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, quoted, err := jsonTag(field)
+		if err != nil {
+			return errs.New("%s.%s: %v", t, field.Name, err)
+		}
+
+		//untagged embedded structs have their fields promoted like encoding/json does
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				err := s.addProperties(properties, embedded)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" || name == "" {
+			continue
+		}
+
+		schema, err := s.schema(field.Type)
+		if err != nil {
+			return errs.New("%s.%s: %v", t, field.Name, err)
+		}
+		if quoted {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		properties[name] = schema
+	}
+
+	return nil
+}
+
+//jsonTag checks that the tag of field is made of key:"value" pairs with a json key that
+//encoding/json understands. it returns the name of the field in json, which is empty when the field
+//is untagged or left out, and whether the value is quoted as a string
+func jsonTag(field reflect.StructField) (name string, quoted bool, err error) {
+	tag := field.Tag
+	rest := string(tag)
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			return name, quoted, nil
+		}
+
+		colon := strings.Index(rest, ":")
+		if colon <= 0 || strings.ContainsAny(rest[:colon], " \"") ||
+			len(rest) == colon+1 || rest[colon+1] != '"' {
+			return "", false, errs.New("malformed struct tag %q", tag)
+		}
+		key := rest[:colon]
+		rest = rest[colon+1:]
+
+		end := 1
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return "", false, errs.New("malformed struct tag %q", tag)
+		}
+		value, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return "", false, errs.New("malformed struct tag %q", tag)
+		}
+		rest = rest[end+1:]
+
+		if key != "json" {
+			return "", false, errs.New("unknown struct tag key %q", key)
+		}
+
+		if value == "-" {
+			name = ""
+			continue
+		}
+
+		parts := strings.Split(value, ",")
+		name = parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "", "omitempty":
+			case "string":
+				quoted = true
+			default:
+				return "", false, errs.New("unknown json tag option %q", opt)
+			}
+		}
+	}
+}
+
+//openAPIHandler serves the document built from apiOperations
+type openAPIHandler struct {
+	once sync.Once
+	doc  []byte
+	err  error
+}
+
+func (h *openAPIHandler) openAPI(w http.ResponseWriter, req *http.Request) {
+	h.once.Do(func() {
+		var doc map[string]interface{}
+		doc, h.err = newOpenAPIDocument(apiOperations)
+		if h.err == nil {
+			h.doc, h.err = json.Marshal(doc)
+		}
+	})
+	if h.err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", h.err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.doc)
+}
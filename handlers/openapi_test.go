@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+//TestOpenAPIRoutes checks that apiOperations documents exactly the routes served under /api
+func TestOpenAPIRoutes(t *testing.T) {
+	handler := NewHandler(nil, Config{AdminToken: "token"})
+
+	routed := map[string]bool{}
+	err := chi.Walk(handler.Handler.(chi.Routes), func(method, route string,
+		_ http.Handler, _ ...func(http.Handler) http.Handler) error {
+
+		if strings.HasPrefix(route, "/api/") {
+			routed[method+" "+route] = true
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		require.False(t, documented[op.method+" "+op.path], "%s %s is documented twice",
+			op.method, op.path)
+		documented[op.method+" "+op.path] = true
+	}
+
+	for route := range routed {
+		require.True(t, documented[route], "%s is missing from apiOperations", route)
+	}
+	for route := range documented {
+		require.True(t, routed[route], "%s is documented but not routed", route)
+	}
+
+	//the document only builds when every tag of the types it describes is well formed
+	_, err = newOpenAPIDocument(apiOperations)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	require.Equal(t, w.Code, http.StatusOK)
+
+	var doc struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Contains(t, doc.Paths["/api/buyer/addresses/{addressId}"], "post")
+}
+
+func TestJSONTag(t *testing.T) {
+	//built by hand so vet does not object to the malformed tags
+	for _, test := range []struct {
+		tag    string
+		name   string
+		quoted bool
+		err    bool
+	}{
+		{tag: `json:"name,omitempty"`, name: "name"},
+		{tag: `json:"quoted,string"`, name: "quoted", quoted: true},
+		{tag: `json:"-"`},
+		{tag: ``},
+		{tag: `json:",omitempty"`, name: "Field"},
+		{tag: `jsons:"name"`, err: true},
+		{tag: `json"name"`, err: true},
+		{tag: `json:"name`, err: true},
+		{tag: `json:"name,omitnil"`, err: true},
+	} {
+		name, quoted, err := jsonTag(reflect.StructField{
+			Name: "Field",
+			Tag:  reflect.StructTag(test.tag),
+		})
+		if test.err {
+			require.Error(t, err, test.tag)
+			continue
+		}
+		require.NoError(t, err, test.tag)
+		require.Equal(t, name, test.name, test.tag)
+		require.Equal(t, quoted, test.quoted, test.tag)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/server"
+)
+
+//route groups that are rate limited separately. their limits come from Config.RateLimits
+const (
+	AuthRateLimit   = "auth"
+	PublicRateLimit = "public"
+	BuyerRateLimit  = "buyer"
+	VendorRateLimit = "vendor"
+	AdminRateLimit  = "admin"
+)
+
+//DefaultRateLimits are used for the groups Config.RateLimits leaves out. signing up and logging in
+//are kept low since they are what gets hammered
+var DefaultRateLimits = map[string]server.RateLimit{
+	AuthRateLimit:   {Requests: 10, Per: time.Minute},
+	PublicRateLimit: {Requests: 120, Per: time.Minute},
+	BuyerRateLimit:  {Requests: 300, Per: time.Minute},
+	VendorRateLimit: {Requests: 300, Per: time.Minute},
+	AdminRateLimit:  {Requests: 300, Per: time.Minute},
+}
+
+//ParseRateLimits reads limits written as group=requests/duration separated by commas, e.g.
+//"auth=5/1m,public=60/1m"
+func ParseRateLimits(s string) (map[string]server.RateLimit, error) {
+	limits := map[string]server.RateLimit{}
+	if strings.TrimSpace(s) == "" {
+		return limits, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		group, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, errs.New("rate limit %q is not group=requests/duration", part)
+		}
+
+		requests, per, ok := strings.Cut(value, "/")
+		if !ok {
+			return nil, errs.New("rate limit %q is not group=requests/duration", part)
+		}
+
+		n, err := strconv.Atoi(requests)
+		if err != nil {
+			return nil, errs.New("rate limit %q has a bad number of requests", part)
+		}
+
+		d, err := time.ParseDuration(per)
+		if err != nil {
+			return nil, errs.New("rate limit %q has a bad duration", part)
+		}
+
+		limits[group] = server.RateLimit{Requests: n, Per: d}
+	}
+
+	return limits, nil
+}
+
+type rateLimiter struct {
+	store  server.RateLimitStore
+	limits map[string]server.RateLimit
+	now    func() time.Time
+}
+
+func newRateLimiter(store server.RateLimitStore,
+	limits map[string]server.RateLimit) *rateLimiter {
+
+	if store == nil {
+		store = server.NewMemoryRateLimitStore()
+	}
+
+	merged := map[string]server.RateLimit{}
+	for group, limit := range DefaultRateLimits {
+		merged[group] = limit
+	}
+	for group, limit := range limits {
+		merged[group] = limit
+	}
+
+	return &rateLimiter{store: store, limits: merged, now: time.Now}
+}
+
+//Limit rate limits a group of routes. it goes after the auth middleware so signed in clients are
+//limited by who they are and everyone else by ip. groups with no requests allowed are not limited
+func (l *rateLimiter) Limit(group string) func(http.Handler) http.Handler {
+	limit := l.limits[group]
+
+	return func(handler http.Handler) http.Handler {
+		if limit.Requests <= 0 || limit.Per <= 0 {
+			return handler
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			result, err := l.store.Take(ctx, group+":"+rateLimitClient(req), limit, l.now())
+			if err != nil {
+				//an outage of the store should not take the api down with it
+				server.Logger(ctx).Errorf("rate limit %s: %+v", group, err)
+				handler.ServeHTTP(w, req)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			handler.ServeHTTP(w, req)
+		})
+	}
+}
+
+//rateLimitClient names who a request counts against
+func rateLimitClient(req *http.Request) string {
+	ctx := req.Context()
+	if auth := getApiKey(ctx); auth != nil {
+		return "vendor_api_key:" + auth.ApiKeyId
+	}
+	if buyer_pk := GetBuyerPk(ctx); buyer_pk != 0 {
+		return fmt.Sprintf("buyer:%d", buyer_pk)
+	}
+	if contact_pk := GetExecutiveContactPk(ctx); contact_pk != 0 {
+		return fmt.Sprintf("executive_contact:%d", contact_pk)
+	}
+
+	return "ip:" + server.GetRequestMeta(ctx).IP
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
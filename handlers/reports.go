@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/server"
+)
+
+//salesReportParams reads the "from" and "to" unix timestamps and the "interval" of a sales report
+func salesReportParams(req *http.Request) (*server.VendorSalesReportReq, error) {
+	values := req.URL.Query()
+
+	from, err := strconv.ParseInt(values.Get("from"), 10, 64)
+	if err != nil {
+		return nil, errs.New("invalid from %q", values.Get("from"))
+	}
+
+	to, err := strconv.ParseInt(values.Get("to"), 10, 64)
+	if err != nil {
+		return nil, errs.New("invalid to %q", values.Get("to"))
+	}
+
+	return &server.VendorSalesReportReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		From:               from,
+		To:                 to,
+		Interval:           values.Get("interval"),
+	}, nil
+}
+
+func (v *vendorHandler) vendorSalesReport(w http.ResponseWriter, req *http.Request) {
+	report_req, err := salesReportParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := v.vendorServer.VendorSalesReport(req.Context(), report_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) exportVendorSales(w http.ResponseWriter, req *http.Request) {
+	report_req, err := salesReportParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/csv")
+	h.Set("Content-Disposition", `attachment; filename="sales.csv"`)
+
+	//refusals happen before anything is written so they still get a status code. a failure part
+	//way through the rows can only be reported by dropping the connection so the client does not
+	//take a truncated file for the whole export
+	out := &trackedWriter{w: w}
+	err = v.vendorServer.ExportVendorSales(req.Context(), report_req, out)
+	if err != nil && out.written {
+		server.Logger(req.Context()).Errorf("%+v", err)
+		panic(http.ErrAbortHandler)
+	}
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+}
+
+//trackedWriter remembers whether anything has been written to w
+type trackedWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (t *trackedWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
+
+func (v *vendorHandler) lowStock(w http.ResponseWriter, req *http.Request) {
+	var threshold int
+	if t := req.URL.Query().Get("threshold"); t != "" {
+		var err error
+		threshold, err = strconv.Atoi(t)
+		if err != nil {
+			http.Error(w, "invalid threshold", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := v.vendorServer.LowStock(req.Context(), &server.LowStockReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		Threshold:          threshold,
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/server"
+)
+
+//searchParams reads the "q" and "offset" query parameters of a message search
+func searchParams(req *http.Request) (query string, offset int64, err error) {
+	values := req.URL.Query()
+
+	if v := values.Get("offset"); v != "" {
+		offset, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			return "", 0, errs.New("invalid offset %q", v)
+		}
+	}
+
+	return values.Get("q"), offset, nil
+}
+
+func writeSearchResults(w http.ResponseWriter, resp interface{}, err error) {
+	if server.InvalidSearch.Has(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) searchBuyerMessages(w http.ResponseWriter, req *http.Request) {
+	query, offset, err := searchParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := u.buyerServer.SearchBuyerMessages(req.Context(), &server.SearchBuyerMessagesReq{
+		BuyerPk: GetBuyerPk(req.Context()),
+		Query:   query,
+		Offset:  offset,
+	})
+	writeSearchResults(w, resp, err)
+}
+
+func (v *vendorHandler) searchVendorMessages(w http.ResponseWriter, req *http.Request) {
+	query, offset, err := searchParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := v.vendorServer.SearchVendorMessages(req.Context(), &server.SearchVendorMessagesReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		Query:              query,
+		Offset:             offset,
+	})
+	writeSearchResults(w, resp, err)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (u *buyerHandler) getStorefront(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.GetStorefront(req.Context(), &server.StorefrontReq{
+		Slug: chi.URLParam(req, "slug"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (u *buyerHandler) storefrontProducts(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.StorefrontProducts(req.Context(), &server.StorefrontProductsReq{
+		Slug:      chi.URLParam(req, "slug"),
+		PageToken: req.URL.Query().Get("pageToken"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) getVendorProfile(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.GetVendorProfile(req.Context(), &server.GetVendorProfileReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) updateVendorProfile(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var profile_req server.UpdateVendorProfileReq
+	err := decoder.Decode(&profile_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	profile_req.VendorPk = GetVendorPk(req.Context())
+	profile_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+	resp, err := v.vendorServer.UpdateVendorProfile(req.Context(), &profile_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
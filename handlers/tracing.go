@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"ladybug/server"
+)
+
+//tracer comes from the global provider, which does nothing until main installs an exporter
+var tracer = otel.Tracer("ladybug/handlers")
+
+//traceRequests starts a span for every request, continuing the trace of the caller when it sent
+//one. the trace id goes on the request's log lines so logs and traces can be matched up
+func traceRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(),
+			propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer.Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		if span.SpanContext().IsValid() {
+			ctx = server.WithLogger(ctx, server.Logger(ctx).WithField("trace_id",
+				span.SpanContext().TraceID().String()))
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, req.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		//the route is only known once chi has routed the request
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(req.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+		}
+		span.SetAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (u *buyerHandler) productVariants(w http.ResponseWriter, req *http.Request) {
+	resp, err := u.buyerServer.ProductVariants(req.Context(), &server.ProductVariantsReq{
+		ProductId: chi.URLParam(req, "productId"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) addProductVariant(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var variant_req server.AddProductVariantReq
+	err := decoder.Decode(&variant_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	variant_req.VendorPk = GetVendorPk(req.Context())
+	variant_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+	variant_req.ProductId = chi.URLParam(req, "productId")
+
+	resp, err := v.vendorServer.AddProductVariant(req.Context(), &variant_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) updateProductVariant(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var variant_req server.UpdateProductVariantReq
+	err := decoder.Decode(&variant_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	variant_req.VendorPk = GetVendorPk(req.Context())
+	variant_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+	variant_req.VariantId = chi.URLParam(req, "variantId")
+
+	resp, err := v.vendorServer.UpdateProductVariant(req.Context(), &variant_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
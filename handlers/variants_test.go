@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+	"ladybug/server"
+)
+
+func TestProductTrialRoute(t *testing.T) {
+	h := newHandlerTest(t, "trials")
+	_, buyer_session := h.signUpBuyer("ada@example.com")
+	vendor_id, vendor_session := h.createVendor()
+
+	//set up
+	ctx := context.Background()
+	vendor_pk, err := h.db.Get_Vendor_Pk_By_Id(ctx, database.Vendor_Id(vendor_id))
+	require.NoError(t, err)
+	shirt, err := h.db.Create_Product(ctx,
+		database.Product_Id(uuid.NewV4().String()),
+		database.Product_VendorPk(vendor_pk.Pk),
+		database.Product_Price(40),
+		database.Product_Discount(0),
+		database.Product_DiscountActive(false),
+		database.Product_Sku("SHIRT"),
+		database.Product_GoogleBucketId(""),
+		database.Product_LadybugApproved(true),
+		database.Product_ProductActive(true),
+		database.Product_NumInStock(0),
+		database.Product_Description("a shirt"),
+		database.Product_Rating(0),
+		database.Product_Attributes(`["size"]`))
+	require.NoError(t, err)
+
+	resp := h.serveVendor(vendor_session, "POST", "/api/vendor/products/"+shirt.Id+"/variants",
+		`{"sku": "SHIRT-L", "attributes": {"size": "L"}, "price": 42, "numInStock": 1}`)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var large server.AddProductVariantResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &large))
+
+	//the variant has to be picked
+	resp = h.serveBuyer(buyer_session, "POST", "/api/buyer/product/trial",
+		fmt.Sprintf(`{"vendorId": %q, "productId": %q}`, vendor_id, shirt.Id))
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = h.serveBuyer(buyer_session, "POST", "/api/buyer/product/trial",
+		fmt.Sprintf(`{"vendorId": %q, "productId": %q, "variantId": %q}`, vendor_id, shirt.Id,
+			large.Variant.Id))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var trial server.StartProductTrialResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &trial))
+	require.Equal(t, float32(42), trial.TrialProduct.TrialPrice)
+
+	//and has to belong to the product
+	resp = h.serveBuyer(buyer_session, "POST", "/api/buyer/product/trial",
+		fmt.Sprintf(`{"vendorId": %q, "productId": %q, "variantId": %q}`, vendor_id, shirt.Id,
+			uuid.NewV4().String()))
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
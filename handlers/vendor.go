@@ -4,25 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"ladybug/database"
 	"ladybug/server"
 
-	"github.com/sirupsen/logrus"
+	"github.com/go-chi/chi"
 )
 
 const (
 	vendorContextKey contextKey = iota
 	vendorPkContextKey
+	executiveContactPkContextKey
 )
 
 type vendorHandler struct {
 	vendorServer *server.VendorServer
+
+	//draining is closed when the server starts shutting down
+	draining <-chan struct{}
+
+	cookies cookies
 }
 
-func newVendorHandler(server *server.VendorServer) *vendorHandler {
-	return &vendorHandler{vendorServer: server}
+func newVendorHandler(server *server.VendorServer, draining <-chan struct{},
+	cookies cookies) *vendorHandler {
+
+	return &vendorHandler{vendorServer: server, draining: draining, cookies: cookies}
 }
 
 func WithVendor(ctx context.Context, vendor *database.Vendor) context.Context {
@@ -38,6 +45,16 @@ func GetVendorPk(ctx context.Context) int64 {
 	return pk
 }
 
+//WithExecutiveContactPk records which member of the vendor's team the session belongs to
+func WithExecutiveContactPk(ctx context.Context, pk int64) context.Context {
+	return context.WithValue(ctx, executiveContactPkContextKey, pk)
+}
+
+func GetExecutiveContactPk(ctx context.Context) int64 {
+	pk, _ := ctx.Value(executiveContactPkContextKey).(int64)
+	return pk
+}
+
 func GetVendor(ctx context.Context) *database.Vendor {
 	vendor, _ := ctx.Value(vendorContextKey).(*database.Vendor)
 	return vendor
@@ -62,12 +79,12 @@ func (v *vendorHandler) vendorSignUp(w http.ResponseWriter, req *http.Request) {
 	sign_up_resp, err := v.vendorServer.VendorSignUp(ctx, &sign_up_req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logrus.Errorf("%+v", err)
+		server.Logger(req.Context()).Errorf("%+v", err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{Name: "session", Value: sign_up_resp.Session.Id,
-		Expires: sign_up_resp.Session.CreatedAt.Add(730 * time.Hour)})
+	v.cookies.setSession(w, vendorSessionCookie, sign_up_resp.Session.Id,
+		sign_up_resp.Session.CreatedAt)
 
 	b, err := json.Marshal(sign_up_resp)
 	if err != nil {
@@ -103,11 +120,15 @@ func (v *vendorHandler) vendorProduct(w http.ResponseWriter, req *http.Request)
 	}
 
 	product_request.VendorPk = vendor_pk
+	product_request.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
 
 	register_prod_response, err := v.vendorServer.RegisterProduct(ctx, &product_request)
+	if writeClientError(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logrus.Errorf("%+v", err)
+		server.Logger(req.Context()).Errorf("%+v", err)
 		return
 	}
 
@@ -136,8 +157,12 @@ func (v *vendorHandler) getPagedVendorConversations(w http.ResponseWriter, req *
 		}
 
 		conversation_req.VendorPk = GetVendorPk(req.Context())
+		conversation_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
 
 		conversations, err := v.vendorServer.GetPagedVendorConversations(ctx, &conversation_req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
@@ -164,9 +189,15 @@ func (v *vendorHandler) getVendorConversationsUnread(w http.ResponseWriter, req
 	ctx := req.Context()
 
 	if req.Method == "GET" {
-		req := &server.VendorConversationsUnreadReq{VendorPk: GetVendorPk(req.Context())}
+		req := &server.VendorConversationsUnreadReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		}
 
 		conversations, err := v.vendorServer.GetVendorCoversationsUnread(ctx, req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
@@ -189,20 +220,54 @@ func (v *vendorHandler) getVendorConversationsUnread(w http.ResponseWriter, req
 	return
 }
 
-func (v *vendorHandler) pagedVendorMessagesByConversationId(w http.ResponseWriter, req *http.Request) {
-	ctx := req.Context()
+func (v *vendorHandler) pagedVendorMessagesByConversationId(w http.ResponseWriter,
+	req *http.Request) {
 
-	if req.Method == "GET" {
+	before, after, mark_read, err := messagePageParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		decoder := json.NewDecoder(req.Body)
-		var conversation_req server.PagedVendorMessagesByConversationIdReq
+	messages, err := v.vendorServer.PagedVendorMessagesByConversationId(req.Context(),
+		&server.PagedVendorMessagesByConversationIdReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+			ConversationId:     chi.URLParam(req, "conversationId"),
+			Before:             before,
+			After:              after,
+			MarkRead:           mark_read,
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, messages)
+}
+
+func (v *vendorHandler) postVendorMessageToConversation(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if req.Method == "POST" {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxMessageBytes))
+		var conversation_req server.PostVendorMessageToConversationReq
 		err := decoder.Decode(&conversation_req)
 		if err != nil {
-			http.Error(w, "unable to parse json", http.StatusInternalServerError)
+			http.Error(w, "unable to parse json", http.StatusBadRequest)
 			return
 		}
 
-		messages, err := v.vendorServer.PagedVendorMessagesByConversationId(ctx, &conversation_req)
+		conversation_req.VendorPk = GetVendorPk(req.Context())
+		conversation_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+		messages, err := v.vendorServer.PostVendorMessageToConversation(ctx, &conversation_req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
@@ -219,34 +284,37 @@ func (v *vendorHandler) pagedVendorMessagesByConversationId(w http.ResponseWrite
 		w.Write(b)
 
 		return
-
 	}
 
 	http.Error(w, "method not allowed", http.StatusBadRequest)
 	return
 }
 
-func (v *vendorHandler) postVendorMessageToConversation(w http.ResponseWriter, req *http.Request) {
+func (v *vendorHandler) markVendorConversationRead(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
 	if req.Method == "POST" {
 		decoder := json.NewDecoder(req.Body)
-		var conversation_req server.PostVendorMessageToConversationReq
-		err := decoder.Decode(&conversation_req)
+		var read_req server.MarkVendorConversationReadReq
+		err := decoder.Decode(&read_req)
 		if err != nil {
 			http.Error(w, "unable to parse json", http.StatusInternalServerError)
 			return
 		}
 
-		conversation_req.VendorPk = GetVendorPk(req.Context())
+		read_req.VendorPk = GetVendorPk(req.Context())
+		read_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
 
-		messages, err := v.vendorServer.PostVendorMessageToConversation(ctx, &conversation_req)
+		read_resp, err := v.vendorServer.MarkVendorConversationRead(ctx, &read_req)
+		if writeClientError(w, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		b, err := json.Marshal(messages)
+		b, err := json.Marshal(read_resp)
 		if err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
@@ -262,3 +330,59 @@ func (v *vendorHandler) postVendorMessageToConversation(w http.ResponseWriter, r
 	http.Error(w, "method not allowed", http.StatusBadRequest)
 	return
 }
+
+func (v *vendorHandler) getVendorUnreadCounts(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if req.Method == "GET" {
+		counts_req := &server.VendorUnreadCountsReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		}
+
+		counts, err := v.vendorServer.GetVendorUnreadCounts(ctx, counts_req)
+		if writeClientError(w, err) {
+			return
+		}
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		b, err := json.Marshal(counts)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "application/json")
+		w.Write(b)
+
+		return
+	}
+
+	http.Error(w, "method not allowed", http.StatusBadRequest)
+	return
+}
+
+func (v *vendorHandler) vendorMessageAttachment(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	attachment_req := &server.VendorMessageAttachmentReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		AttachmentId:       chi.URLParam(req, "attachmentId"),
+	}
+
+	attachment, err := v.vendorServer.GetVendorMessageAttachment(ctx, attachment_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeAttachment(w, attachment)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ladybug/server"
+)
+
+func (v *vendorHandler) getVendorDeletion(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.GetVendorDeletion(req.Context(), &server.GetVendorDeletionReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) requestVendorDeletion(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.RequestVendorDeletion(req.Context(),
+		&server.RequestVendorDeletionReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) cancelVendorDeletion(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.CancelVendorDeletion(req.Context(),
+		&server.CancelVendorDeletionReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (v *vendorHandler) listVendorApiKeys(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.ListVendorApiKeys(req.Context(), &server.ListVendorApiKeysReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) createVendorApiKey(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var create_req server.CreateVendorApiKeyReq
+	err := decoder.Decode(&create_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	create_req.VendorPk = GetVendorPk(req.Context())
+	create_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+	resp, err := v.vendorServer.CreateVendorApiKey(req.Context(), &create_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) revokeVendorApiKey(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.RevokeVendorApiKey(req.Context(), &server.RevokeVendorApiKeyReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		ApiKeyId:           chi.URLParam(req, "apiKeyId"),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+	"ladybug/server"
+)
+
+func TestVendorApiKeyRoutes(t *testing.T) {
+	db, err := database.OpenTraced("sqlite3", "file:handlers?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(db.Schema())
+	require.NoError(t, err)
+
+	//set up
+	ctx := context.Background()
+	vendor, err := db.Create_Vendor(ctx,
+		database.Vendor_Id(uuid.NewV4().String()),
+		database.Vendor_Fein(uuid.NewV4().String()))
+	require.NoError(t, err)
+	owner, err := db.Create_ExecutiveContact(ctx,
+		database.ExecutiveContact_Id(uuid.NewV4().String()),
+		database.ExecutiveContact_VendorPk(vendor.Pk),
+		database.ExecutiveContact_FirstName("some_firstName"),
+		database.ExecutiveContact_LastName("some_last_name"),
+		database.ExecutiveContact_Role(server.OwnerRole))
+	require.NoError(t, err)
+
+	vendors := server.NewVendorServer(db, nil, nil, nil, server.LogMailer{}, 0)
+	createKey := func(scopes ...string) *server.CreateVendorApiKeyResp {
+		resp, err := vendors.CreateVendorApiKey(ctx, &server.CreateVendorApiKeyReq{
+			VendorPk:           vendor.Pk,
+			ExecutiveContactPk: owner.Pk,
+			Name:               "erp",
+			Scopes:             scopes,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+	catalog_key := createKey(server.ReadCatalogScope, server.WriteCatalogScope)
+	orders_key := createKey(server.ReadOrdersScope)
+	revoked_key := createKey(server.ReadCatalogScope)
+	_, err = vendors.RevokeVendorApiKey(ctx, &server.RevokeVendorApiKeyReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ApiKeyId:           revoked_key.ApiKey.Id,
+	})
+	require.NoError(t, err)
+
+	handler := NewHandler(db, Config{})
+	serve := func(method, path, authorization, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	//keys reach the routes their scopes cover
+	require.Equal(t, serve("GET", "/api/vendor/profile", "Bearer "+catalog_key.Key, ""),
+		http.StatusOK)
+	require.Equal(t, serve("GET", "/api/vendor/profile", "Bearer "+orders_key.Key, ""),
+		http.StatusForbidden)
+
+	//and never the routes no scope covers
+	require.Equal(t, serve("GET", "/api/vendor/api-keys", "Bearer "+catalog_key.Key, ""),
+		http.StatusForbidden)
+	require.Equal(t, serve("GET", "/api/vendor/team", "Bearer "+catalog_key.Key, ""),
+		http.StatusForbidden)
+
+	//anything but a live key is turned away
+	require.Equal(t, serve("GET", "/api/vendor/profile", "Token "+catalog_key.Key, ""),
+		http.StatusUnauthorized)
+	require.Equal(t, serve("GET", "/api/vendor/profile", "Bearer "+revoked_key.Key, ""),
+		http.StatusUnauthorized)
+
+	//browsers do not send keys so changes made with them skip the csrf checks
+	require.Equal(t, serve("POST", "/api/vendor/profile", "Bearer "+catalog_key.Key,
+		`{"displayName": "Some Vendor", "slug": "some-vendor"}`), http.StatusOK)
+}
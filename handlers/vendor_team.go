@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (v *vendorHandler) getVendorTeam(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.GetVendorTeam(req.Context(), &server.GetVendorTeamReq{
+		VendorPk:           GetVendorPk(req.Context()),
+		ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+	})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) inviteExecutiveContact(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var invite_req server.InviteExecutiveContactReq
+	err := decoder.Decode(&invite_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	invite_req.VendorPk = GetVendorPk(req.Context())
+	invite_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+	resp, err := v.vendorServer.InviteExecutiveContact(req.Context(), &invite_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) acceptVendorInvite(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var accept_req server.AcceptVendorInviteReq
+	err := decoder.Decode(&accept_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := v.vendorServer.AcceptVendorInvite(req.Context(), &accept_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	v.cookies.setSession(w, vendorSessionCookie, resp.Session.Id, resp.Session.CreatedAt)
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) removeExecutiveContact(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.RemoveExecutiveContact(req.Context(),
+		&server.RemoveExecutiveContactReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+			ContactId:          chi.URLParam(req, "contactId"),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) setExecutiveContactRole(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var role_req server.SetExecutiveContactRoleReq
+	err := decoder.Decode(&role_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	role_req.VendorPk = GetVendorPk(req.Context())
+	role_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+	role_req.ContactId = chi.URLParam(req, "contactId")
+
+	resp, err := v.vendorServer.SetExecutiveContactRole(req.Context(), &role_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, resp)
+}
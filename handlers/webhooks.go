@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"ladybug/server"
+)
+
+func (v *vendorHandler) listWebhookEndpoints(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.ListWebhookEndpoints(req.Context(),
+		&server.ListWebhookEndpointsReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) createWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var create_req server.CreateWebhookEndpointReq
+	err := decoder.Decode(&create_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	create_req.VendorPk = GetVendorPk(req.Context())
+	create_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+
+	resp, err := v.vendorServer.CreateWebhookEndpoint(req.Context(), &create_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) updateWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var update_req server.UpdateWebhookEndpointReq
+	err := decoder.Decode(&update_req)
+	if err != nil {
+		http.Error(w, "unable to parse json", http.StatusBadRequest)
+		return
+	}
+
+	update_req.VendorPk = GetVendorPk(req.Context())
+	update_req.ExecutiveContactPk = GetExecutiveContactPk(req.Context())
+	update_req.EndpointId = chi.URLParam(req, "endpointId")
+
+	resp, err := v.vendorServer.UpdateWebhookEndpoint(req.Context(), &update_req)
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) deleteWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.DeleteWebhookEndpoint(req.Context(),
+		&server.DeleteWebhookEndpointReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+			EndpointId:         chi.URLParam(req, "endpointId"),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+//pagedWebhookDeliveries is the delivery log of an endpoint, paged with the offset query parameter
+func (v *vendorHandler) pagedWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	offset, err := queryInt64(req, "offset")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := v.vendorServer.PagedWebhookDeliveries(req.Context(),
+		&server.PagedWebhookDeliveriesReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+			EndpointId:         chi.URLParam(req, "endpointId"),
+			Offset:             offset,
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+func (v *vendorHandler) replayWebhookDelivery(w http.ResponseWriter, req *http.Request) {
+	resp, err := v.vendorServer.ReplayWebhookDelivery(req.Context(),
+		&server.ReplayWebhookDeliveryReq{
+			VendorPk:           GetVendorPk(req.Context()),
+			ExecutiveContactPk: GetExecutiveContactPk(req.Context()),
+			DeliveryId:         chi.URLParam(req, "deliveryId"),
+		})
+	if writeClientError(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		server.Logger(req.Context()).Errorf("%+v", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
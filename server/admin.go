@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	reportRequestLimit = 20
+)
+
+//AdminServer is used by marketplace staff. it is not tied to a buyer or vendor session
+type AdminServer struct {
+	db *database.DB
+}
+
+func NewAdminServer(db *database.DB) *AdminServer {
+	return &AdminServer{db: db}
+}
+
+type ConversationReport struct {
+	Id             string     `json:"id"`
+	ConversationId string     `json:"conversationId"`
+	Reporter       string     `json:"reporter"`
+	Reason         string     `json:"reason"`
+	Status         string     `json:"status"`
+	Resolution     string     `json:"resolution"`
+	CreatedAt      int64      `json:"createdAt"`
+	Transcript     []*Message `json:"transcript"`
+}
+
+func conversationReportFromDB(ctx context.Context, tx *database.Tx,
+	report *database.ConversationReport) (*ConversationReport, error) {
+
+	conversation, err := tx.Get_Conversation_By_Pk(ctx,
+		database.Conversation_Pk(report.ConversationPk))
+	if err != nil {
+		return nil, err
+	}
+
+	var transcript []*Message
+	err = json.Unmarshal([]byte(report.Transcript), &transcript)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return &ConversationReport{
+		Id:             report.Id,
+		ConversationId: conversation.Id,
+		Reporter:       report.Reporter,
+		Reason:         report.Reason,
+		Status:         report.Status,
+		Resolution:     report.Resolution,
+		CreatedAt:      report.CreatedAt.Unix(),
+		Transcript:     transcript,
+	}, nil
+}
+
+type PagedOpenConversationReportsReq struct {
+	Offset int64 `json:"offset"`
+}
+
+type PagedOpenConversationReportsResp struct {
+	Reports []*ConversationReport `json:"reports"`
+	Offset  int64                 `json:"offset"`
+}
+
+//PagedOpenConversationReports returns the review queue, oldest report first
+func (a *AdminServer) PagedOpenConversationReports(ctx context.Context,
+	req *PagedOpenConversationReportsReq) (resp *PagedOpenConversationReportsResp, err error) {
+
+	reports := []*ConversationReport{}
+	err = a.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		db_reports, err := tx.Limited_ConversationReport_By_Status_OrderBy_Asc_CreatedAt(ctx,
+			database.ConversationReport_Status(reportOpen), reportRequestLimit, req.Offset)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range db_reports {
+			report, err := conversationReportFromDB(ctx, tx, r)
+			if err != nil {
+				return err
+			}
+			reports = append(reports, report)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PagedOpenConversationReportsResp{
+		Reports: reports,
+		Offset:  req.Offset + reportRequestLimit,
+	}, nil
+}
+
+type ResolveConversationReportReq struct {
+	ReportId   string `json:"reportId"`
+	Resolution string `json:"resolution"`
+}
+
+type ResolveConversationReportResp struct {
+	Report *ConversationReport `json:"report"`
+}
+
+//ResolveConversationReport takes a report off the review queue, recording what was done about it
+func (a *AdminServer) ResolveConversationReport(ctx context.Context,
+	req *ResolveConversationReportReq) (resp *ResolveConversationReportResp, err error) {
+
+	var report *ConversationReport
+	err = a.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		db_report, err := tx.Find_ConversationReport_By_Id(ctx,
+			database.ConversationReport_Id(req.ReportId))
+		if err != nil {
+			return err
+		}
+
+		if db_report == nil {
+			return NotFound.New("report not found")
+		}
+
+		before := auditFields{"status": db_report.Status, "resolution": db_report.Resolution}
+		db_report, err = tx.Update_ConversationReport_By_Pk(ctx,
+			database.ConversationReport_Pk(db_report.Pk),
+			database.ConversationReport_Update_Fields{
+				Status:     database.ConversationReport_Status(reportResolved),
+				Resolution: database.ConversationReport_Resolution(req.Resolution),
+			})
+		if err != nil {
+			return err
+		}
+
+		err = audit(ctx, tx, &auditEvent{
+			actorKind:  AdminActor,
+			actorId:    adminActorId,
+			action:     AuditReportResolve,
+			targetKind: "conversation_report",
+			targetId:   db_report.Id,
+			before:     before,
+			after:      auditFields{"status": db_report.Status, "resolution": db_report.Resolution},
+		})
+		if err != nil {
+			return err
+		}
+
+		report, err = conversationReportFromDB(ctx, tx, db_report)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResolveConversationReportResp{
+		Report: report,
+	}, nil
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	auditRequestLimit = 50
+
+	BuyerActor  = "buyer"
+	VendorActor = "vendor"
+	AdminActor  = "admin"
+	SystemActor = "system"
+
+	//adminActorId is recorded for actions taken with the admin token. it is shared by all staff
+	adminActorId = "admin"
+
+	//redacted stands in for secrets such as passwords, and for personal data such as names, in
+	//audit diffs. the audit log outlives erased accounts so it must not hold either
+	redacted = "[redacted]"
+)
+
+const (
+	AuditBuyerSignUp           = "buyer.sign_up"
+	AuditBuyerLogIn            = "buyer.log_in"
+	AuditBuyerUpdate           = "buyer.update"
+	AuditBuyerEmailAdd         = "buyer.email_add"
+	AuditBuyerEmailVerify      = "buyer.email_verify"
+	AuditBuyerEmailPrimary     = "buyer.email_primary"
+	AuditBuyerEmailRemove      = "buyer.email_remove"
+	AuditBuyerDeletionRequest  = "buyer.deletion_request"
+	AuditBuyerDeletionCancel   = "buyer.deletion_cancel"
+	AuditBuyerDelete           = "buyer.delete"
+	AuditVendorSignUp          = "vendor.sign_up"
+	AuditVendorDeletionRequest = "vendor.deletion_request"
+	AuditVendorDeletionCancel  = "vendor.deletion_cancel"
+	AuditVendorDelete          = "vendor.delete"
+	AuditInviteAccept          = "executive_contact.invite_accept"
+	AuditContactRemove         = "executive_contact.remove"
+	AuditContactRole           = "executive_contact.role"
+	AuditSessionRevoke         = "session.revoke"
+	AuditApiKeyCreate          = "vendor_api_key.create"
+	AuditApiKeyRevoke          = "vendor_api_key.revoke"
+	AuditWebhookCreate         = "webhook_endpoint.create"
+	AuditWebhookUpdate         = "webhook_endpoint.update"
+	AuditWebhookDelete         = "webhook_endpoint.delete"
+	AuditWebhookDisable        = "webhook_endpoint.disable"
+	AuditWebhookReplay         = "webhook_delivery.replay"
+	AuditProductCreate         = "product.create"
+	AuditProductUpdate         = "product.update"
+	AuditVariantCreate         = "product_variant.create"
+	AuditVariantUpdate         = "product_variant.update"
+	AuditConversationBlock     = "conversation.block"
+	AuditConversationReport    = "conversation.report"
+	AuditReportResolve         = "conversation_report.resolve"
+)
+
+type requestMetaKey struct{}
+
+//RequestMeta is what is known about the http request an action arrived on
+type RequestMeta struct {
+	RequestId string
+	IP        string
+	UserAgent string
+}
+
+//WithRequestMeta attaches meta to ctx so audit events written while handling the request carry it
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+//GetRequestMeta returns the meta attached to ctx. it is empty for work that did not come from a
+//request
+func GetRequestMeta(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+//detachedContext is for work that outlives the request that started it. it keeps the request's
+//meta and logger but not its cancellation
+func detachedContext(ctx context.Context) context.Context {
+	detached := WithRequestMeta(context.Background(), GetRequestMeta(ctx))
+	return WithLogger(detached, Logger(ctx))
+}
+
+//AuditChange is the value of a field before and after an action. Before is nil for things that
+//were created and After is nil for things that were removed
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type auditFields map[string]interface{}
+
+//diffFields keeps only the fields whose value differs between before and after
+func diffFields(before, after auditFields) map[string]*AuditChange {
+	diff := map[string]*AuditChange{}
+	for name, value := range after {
+		if old, ok := before[name]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		diff[name] = &AuditChange{Before: before[name], After: value}
+	}
+	for name, value := range before {
+		if _, ok := after[name]; !ok {
+			diff[name] = &AuditChange{Before: value}
+		}
+	}
+
+	return diff
+}
+
+type auditEvent struct {
+	actorKind  string
+	actorId    string
+	action     string
+	targetKind string
+	targetId   string
+	before     auditFields
+	after      auditFields
+}
+
+//audit appends an event to the audit log. it is called with the transaction that made the change
+//so that the change and its record are committed together
+func audit(ctx context.Context, tx *database.Tx, event *auditEvent) error {
+	diff, err := json.Marshal(diffFields(event.before, event.after))
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	meta := GetRequestMeta(ctx)
+	return tx.CreateNoReturn_AuditEvent(ctx,
+		database.AuditEvent_Id(uuid.NewV4().String()),
+		database.AuditEvent_ActorKind(event.actorKind),
+		database.AuditEvent_ActorId(event.actorId),
+		database.AuditEvent_Action(event.action),
+		database.AuditEvent_TargetKind(event.targetKind),
+		database.AuditEvent_TargetId(event.targetId),
+		database.AuditEvent_Diff(string(diff)),
+		database.AuditEvent_Ip(meta.IP),
+		database.AuditEvent_UserAgent(meta.UserAgent))
+}
+
+//buyerId looks up the public id of a buyer for audit events
+func buyerId(ctx context.Context, tx *database.Tx, buyer_pk int64) (string, error) {
+	buyer, err := tx.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(buyer_pk))
+	if err != nil {
+		return "", err
+	}
+
+	return buyer.Id, nil
+}
+
+//vendorId looks up the public id of a vendor for audit events
+func vendorId(ctx context.Context, tx *database.Tx, vendor_pk int64) (string, error) {
+	vendor, err := tx.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(vendor_pk))
+	if err != nil {
+		return "", err
+	}
+
+	return vendor.Id, nil
+}
+
+//auditBuyer records an action a buyer took on their own account
+func auditBuyer(ctx context.Context, tx *database.Tx, buyer_pk int64, action string,
+	before, after auditFields) error {
+
+	return auditBuyerOn(ctx, tx, buyer_pk, action, "buyer", "", before, after)
+}
+
+//auditBuyerOn records an action a buyer took on something. an empty target_id is the buyer
+func auditBuyerOn(ctx context.Context, tx *database.Tx, buyer_pk int64, action, target_kind,
+	target_id string, before, after auditFields) error {
+
+	id, err := buyerId(ctx, tx, buyer_pk)
+	if err != nil {
+		return err
+	}
+
+	if target_id == "" {
+		target_id = id
+	}
+
+	return audit(ctx, tx, &auditEvent{
+		actorKind:  BuyerActor,
+		actorId:    id,
+		action:     action,
+		targetKind: target_kind,
+		targetId:   target_id,
+		before:     before,
+		after:      after,
+	})
+}
+
+//auditContact records an action a member of a vendor's team took
+func auditContact(ctx context.Context, tx *database.Tx, contact_id, action, target_kind,
+	target_id string, before, after auditFields) error {
+
+	return audit(ctx, tx, &auditEvent{
+		actorKind:  VendorActor,
+		actorId:    contact_id,
+		action:     action,
+		targetKind: target_kind,
+		targetId:   target_id,
+		before:     before,
+		after:      after,
+	})
+}
+
+func productAuditFields(p *database.Product) auditFields {
+	return auditFields{
+		"sku":            p.Sku,
+		"description":    p.Description,
+		"price":          p.Price,
+		"discount":       p.Discount,
+		"discountActive": p.DiscountActive,
+		"numInStock":     p.NumInStock,
+		"productActive":  p.ProductActive,
+		"googleBucketId": p.GoogleBucketId,
+	}
+}
+
+func variantAuditFields(v *database.ProductVariant) auditFields {
+	return auditFields{
+		"sku":            v.Sku,
+		"attributes":     v.Attributes,
+		"price":          v.Price,
+		"discount":       v.Discount,
+		"discountActive": v.DiscountActive,
+		"numInStock":     v.NumInStock,
+		"googleBucketId": v.GoogleBucketId,
+	}
+}
+
+type AuditEvent struct {
+	Id         string                  `json:"id"`
+	CreatedAt  int64                   `json:"createdAt"`
+	ActorKind  string                  `json:"actorKind"`
+	ActorId    string                  `json:"actorId"`
+	Action     string                  `json:"action"`
+	TargetKind string                  `json:"targetKind"`
+	TargetId   string                  `json:"targetId"`
+	Diff       map[string]*AuditChange `json:"diff"`
+	IP         string                  `json:"ip"`
+	UserAgent  string                  `json:"userAgent"`
+}
+
+func AuditEventFromDB(event *database.AuditEvent) (*AuditEvent, error) {
+	var diff map[string]*AuditChange
+	err := json.Unmarshal([]byte(event.Diff), &diff)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return &AuditEvent{
+		Id:         event.Id,
+		CreatedAt:  event.CreatedAt.Unix(),
+		ActorKind:  event.ActorKind,
+		ActorId:    event.ActorId,
+		Action:     event.Action,
+		TargetKind: event.TargetKind,
+		TargetId:   event.TargetId,
+		Diff:       diff,
+		IP:         event.Ip,
+		UserAgent:  event.UserAgent,
+	}, nil
+}
+
+type PagedAuditEventsReq struct {
+	ActorId  string `json:"actorId"`
+	TargetId string `json:"targetId"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+	Offset   int64  `json:"offset"`
+}
+
+type PagedAuditEventsResp struct {
+	Events []*AuditEvent `json:"events"`
+	Offset int64         `json:"offset"`
+}
+
+//PagedAuditEvents returns audit events newest first. ActorId and TargetId are ignored when empty.
+//From and To are unix times bounding when the events happened, To defaults to now
+func (a *AdminServer) PagedAuditEvents(ctx context.Context, req *PagedAuditEventsReq) (
+	resp *PagedAuditEventsResp, err error) {
+
+	to := time.Now()
+	if req.To != 0 {
+		to = time.Unix(req.To, 0)
+	}
+	from := database.AuditEvent_CreatedAt(time.Unix(req.From, 0).UTC())
+	until := database.AuditEvent_CreatedAt(to.UTC())
+
+	var db_events []*database.AuditEvent
+	switch {
+	case req.ActorId != "" && req.TargetId != "":
+		db_events, err = a.db.WithContext(ctx).Limited_AuditEvent_By_ActorId_And_TargetId_And_CreatedAt_GreaterOrEqual_And_CreatedAt_Less_OrderBy_Desc_CreatedAt(
+			ctx, database.AuditEvent_ActorId(req.ActorId),
+			database.AuditEvent_TargetId(req.TargetId), from, until,
+			auditRequestLimit, req.Offset)
+	case req.ActorId != "":
+		db_events, err = a.db.WithContext(ctx).Limited_AuditEvent_By_ActorId_And_CreatedAt_GreaterOrEqual_And_CreatedAt_Less_OrderBy_Desc_CreatedAt(
+			ctx, database.AuditEvent_ActorId(req.ActorId), from, until,
+			auditRequestLimit, req.Offset)
+	case req.TargetId != "":
+		db_events, err = a.db.WithContext(ctx).Limited_AuditEvent_By_TargetId_And_CreatedAt_GreaterOrEqual_And_CreatedAt_Less_OrderBy_Desc_CreatedAt(
+			ctx, database.AuditEvent_TargetId(req.TargetId), from, until,
+			auditRequestLimit, req.Offset)
+	default:
+		db_events, err = a.db.WithContext(ctx).Limited_AuditEvent_By_CreatedAt_GreaterOrEqual_And_CreatedAt_Less_OrderBy_Desc_CreatedAt(
+			ctx, from, until, auditRequestLimit, req.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*AuditEvent{}
+	for _, e := range db_events {
+		event, err := AuditEventFromDB(e)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return &PagedAuditEventsResp{
+		Events: events,
+		Offset: req.Offset + auditRequestLimit,
+	}, nil
+}
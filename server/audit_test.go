@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditBuyerUpdate(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := WithRequestMeta(context.Background(), RequestMeta{
+		IP:        "203.0.113.7",
+		UserAgent: "test-agent",
+	})
+	admin := NewAdminServer(test.db)
+	buyer := test.createFullTestBuyer(ctx)
+
+	req := getFullUpdateBuyerRequest(buyer)
+	req.LastName = "Changed"
+	req.NewPassword = "Password1!"
+	_, err := test.BuyerServer.UpdateBuyer(ctx, req)
+	require.NoError(t, err)
+
+	//a refused update leaves no record
+	req.CurrentPassword = "not the password"
+	_, err = test.BuyerServer.UpdateBuyer(ctx, req)
+	require.Error(t, err)
+
+	resp, err := admin.PagedAuditEvents(ctx, &PagedAuditEventsReq{TargetId: buyer.Id})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 2)
+
+	update := resp.Events[0]
+	require.Equal(t, update.Action, AuditBuyerUpdate)
+	require.Equal(t, update.ActorKind, BuyerActor)
+	require.Equal(t, update.ActorId, buyer.Id)
+	require.Equal(t, update.IP, "203.0.113.7")
+	require.Equal(t, update.UserAgent, "test-agent")
+	require.Equal(t, update.Diff["lastName"].After, redacted)
+	require.Equal(t, update.Diff["password"].After, redacted)
+	require.NotContains(t, update.Diff, "firstName")
+
+	//names and email addresses are kept out of the log
+	for _, event := range resp.Events {
+		for _, change := range event.Diff {
+			require.NotEqual(t, change.Before, buyer.LastName)
+			require.NotEqual(t, change.After, "Changed")
+			require.NotEqual(t, change.After, buyer.emails[0].Address)
+		}
+	}
+
+	require.Equal(t, resp.Events[1].Action, AuditBuyerSignUp)
+	require.Empty(t, resp.Events[1].Diff)
+}
+
+func TestAuditProductChanges(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	admin := NewAdminServer(test.db)
+	vendor := test.createVendorInDB(ctx)
+	manager := test.createExecutiveContact(ctx, vendor.Pk, CatalogManagerRole)
+
+	resp, err := test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		UnitPrice:          20,
+		SKU:                "MUG",
+		ProductActive:      true,
+		NumberInStock:      3,
+	})
+	require.NoError(t, err)
+
+	//imports are audited as the contact who started them
+	test.importCatalog(ctx, vendor.Pk, manager.Pk, CSVFormat,
+		"sku,description,price,discount,discount_active,num_in_stock,product_active,"+
+			"google_bucket_id\nMUG,a mug,25,0,false,3,true,\n")
+
+	events, err := admin.PagedAuditEvents(ctx, &PagedAuditEventsReq{
+		ActorId:  manager.Id,
+		TargetId: resp.ProductId,
+	})
+	require.NoError(t, err)
+	require.Len(t, events.Events, 2)
+
+	price := events.Events[0]
+	require.Equal(t, price.Action, AuditProductUpdate)
+	require.Equal(t, price.Diff["price"].Before, 20.0)
+	require.Equal(t, price.Diff["price"].After, 25.0)
+	require.NotContains(t, price.Diff, "sku")
+
+	require.Equal(t, events.Events[1].Action, AuditProductCreate)
+
+	//the time range bounds what is returned
+	events, err = admin.PagedAuditEvents(ctx, &PagedAuditEventsReq{
+		ActorId: manager.Id,
+		To:      time.Now().Add(-time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	require.Empty(t, events.Events)
+}
+
+func TestAuditSessionRevocation(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	admin := NewAdminServer(test.db)
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	agent := test.createExecutiveContact(ctx, vendor.Pk, SupportAgentRole)
+
+	_, err := test.VendorServer.RemoveExecutiveContact(ctx, &RemoveExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ContactId:          agent.Id,
+	})
+	require.NoError(t, err)
+
+	events, err := admin.PagedAuditEvents(ctx, &PagedAuditEventsReq{TargetId: agent.Id})
+	require.NoError(t, err)
+	require.Len(t, events.Events, 2)
+
+	actions := []string{events.Events[0].Action, events.Events[1].Action}
+	require.Contains(t, actions, AuditSessionRevoke)
+	require.Contains(t, actions, AuditContactRemove)
+	require.Equal(t, events.Events[0].ActorId, owner.Id)
+}
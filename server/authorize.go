@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+//NotFound is returned when a record does not exist or belongs to someone else. callers are not
+//told which so that ids cannot be probed
+var NotFound = errs.Class("")
+
+func conversationNotFound() error {
+	return NotFound.New("conversation not found")
+}
+
+//authorizeBuyerConversation loads a conversation by id inside an open transaction and makes sure
+//the buyer is a participant. every buyer operation on an existing conversation goes through here
+func authorizeBuyerConversation(ctx context.Context, tx *database.Tx, buyer_pk int64,
+	conversation_id string) (*database.Conversation, error) {
+
+	conversation, err := tx.Find_Conversation_By_Id(ctx, database.Conversation_Id(conversation_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil || conversation.BuyerPk != buyer_pk {
+		return nil, conversationNotFound()
+	}
+
+	return conversation, nil
+}
+
+//authorizeVendorConversation is the vendor side of authorizeBuyerConversation
+func authorizeVendorConversation(ctx context.Context, tx *database.Tx, vendor_pk int64,
+	conversation_id string) (*database.Conversation, error) {
+
+	conversation, err := tx.Find_Conversation_By_Id(ctx, database.Conversation_Id(conversation_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation == nil || conversation.VendorPk != vendor_pk {
+		return nil, conversationNotFound()
+	}
+
+	return conversation, nil
+}
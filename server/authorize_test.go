@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuyerCannotReadAnotherBuyersConversation(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	intruder := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	conversation := test.createConversationInDB(buyer, vendor)
+	test.createMessageHistory(ctx, conversation, 10)
+
+	//paged messages
+	_, err := test.BuyerServer.PagedBuyerMessagesByConversationId(ctx,
+		&PagedBuyerMessagesByConversationIdReq{
+			BuyerPk:        intruder.Pk,
+			ConversationId: conversation.Id,
+			MarkRead:       true,
+		})
+	require.True(t, NotFound.Has(err))
+
+	//messages since a resume point
+	_, err = test.BuyerServer.BuyerMessagesSince(ctx, &BuyerMessagesSinceReq{
+		BuyerPk:        intruder.Pk,
+		ConversationId: conversation.Id,
+	})
+	require.True(t, NotFound.Has(err))
+
+	//marking read
+	_, err = test.BuyerServer.MarkBuyerConversationRead(ctx, &MarkBuyerConversationReadReq{
+		BuyerPk:            intruder.Pk,
+		ConversationId:     conversation.Id,
+		ConversationNumber: 10,
+	})
+	require.True(t, NotFound.Has(err))
+
+	//nothing the intruder did changed the conversation
+	after := test.getConversation(ctx, vendor.Pk, buyer.Pk)
+	require.Equal(t, after.BuyerLastRead, conversation.BuyerLastRead)
+}
+
+func TestVendorCannotReadAnotherVendorsConversation(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	intruder := test.createVendorInDB(ctx)
+	intruder_owner := test.createExecutiveContact(ctx, intruder.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	conversation := test.createConversationInDB(buyer, vendor)
+	test.createMessageHistory(ctx, conversation, 10)
+
+	//paged messages
+	_, err := test.VendorServer.PagedVendorMessagesByConversationId(ctx,
+		&PagedVendorMessagesByConversationIdReq{
+			VendorPk:           intruder.Pk,
+			ExecutiveContactPk: intruder_owner.Pk,
+			ConversationId:     conversation.Id,
+		})
+	require.True(t, NotFound.Has(err))
+
+	//messages since a resume point
+	_, err = test.VendorServer.VendorMessagesSince(ctx, &VendorMessagesSinceReq{
+		VendorPk:           intruder.Pk,
+		ExecutiveContactPk: intruder_owner.Pk,
+		ConversationId:     conversation.Id,
+	})
+	require.True(t, NotFound.Has(err))
+
+	//marking read
+	_, err = test.VendorServer.MarkVendorConversationRead(ctx, &MarkVendorConversationReadReq{
+		VendorPk:           intruder.Pk,
+		ExecutiveContactPk: intruder_owner.Pk,
+		ConversationId:     conversation.Id,
+		ConversationNumber: 10,
+	})
+	require.True(t, NotFound.Has(err))
+
+	//the participating vendor can still read it
+	resp, err := test.VendorServer.PagedVendorMessagesByConversationId(ctx,
+		&PagedVendorMessagesByConversationIdReq{
+			VendorPk:           vendor.Pk,
+			ExecutiveContactPk: owner.Pk,
+			ConversationId:     conversation.Id,
+		})
+	require.NoError(t, err)
+	require.Len(t, resp.Messages, 10)
+}
+
+func TestUnknownConversationIsNotFound(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	//a missing conversation looks the same as someone else's
+	_, err := test.BuyerServer.PagedBuyerMessagesByConversationId(ctx,
+		&PagedBuyerMessagesByConversationIdReq{
+			BuyerPk:        buyer.Pk,
+			ConversationId: "not-a-conversation",
+		})
+	require.True(t, NotFound.Has(err))
+	require.EqualError(t, err, "conversation not found")
+}
+
+func TestVendorCannotDownloadAnotherVendorsAttachment(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	intruder := test.createVendorInDB(ctx)
+	intruder_owner := test.createExecutiveContact(ctx, intruder.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	resp, err := test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "private",
+			Attachments: []*AttachmentUpload{
+				{Filename: "id.pdf", Data: []byte("%PDF-1.4 secret")},
+			},
+		})
+	require.NoError(t, err)
+
+	_, err = test.VendorServer.GetVendorMessageAttachment(ctx, &VendorMessageAttachmentReq{
+		VendorPk:           intruder.Pk,
+		ExecutiveContactPk: intruder_owner.Pk,
+		AttachmentId:       resp.Message.Attachments[0].Id,
+	})
+	require.True(t, NotFound.Has(err))
+
+	_, err = test.BuyerServer.GetBuyerMessageAttachment(ctx, &BuyerMessageAttachmentReq{
+		BuyerPk:      test.createBuyer(ctx, &createBuyerInDBOptions{}).Pk,
+		AttachmentId: resp.Message.Attachments[0].Id,
+	})
+	require.True(t, NotFound.Has(err))
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zeebo/errs"
+)
+
+//BlobStore holds file contents that are too large to keep in the database, such as message
+//attachments. the database only keeps the key
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//DirBlobStore keeps blobs as files under a directory on local disk
+type DirBlobStore struct {
+	dir string
+}
+
+func NewDirBlobStore(dir string) (*DirBlobStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return &DirBlobStore{dir: dir}, nil
+}
+
+func (s *DirBlobStore) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", errs.New("invalid blob key %q", key)
+	}
+
+	return path, nil
+}
+
+func (s *DirBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	return errs.Wrap(ioutil.WriteFile(path, data, 0600))
+}
+
+func (s *DirBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return data, nil
+}
+
+//Delete removes a blob. deleting a blob that does not exist is not an error
+func (s *DirBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return errs.Wrap(err)
+}
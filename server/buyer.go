@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"strings"
+	"time"
 
 	"ladybug/database"
 
@@ -18,26 +19,48 @@ const (
 )
 
 type BuyerServer struct {
-	db *database.DB
+	db     *database.DB
+	hub    Hub
+	blobs  BlobStore
+	filter ContentFilter
+	mailer Mailer
+
+	//deletionGrace is how long accounts wait between being scheduled for deletion and deleted
+	deletionGrace time.Duration
 }
 
-func NewBuyerServer(db *database.DB) *BuyerServer {
-	return &BuyerServer{db: db}
+//NewBuyerServer returns a BuyerServer. DefaultDeletionGracePeriod is used when deletion_grace is
+//not positive
+func NewBuyerServer(db *database.DB, hub Hub, blobs BlobStore, filter ContentFilter,
+	mailer Mailer, deletion_grace time.Duration) *BuyerServer {
+
+	if deletion_grace <= 0 {
+		deletion_grace = DefaultDeletionGracePeriod
+	}
+
+	return &BuyerServer{db: db, hub: hub, blobs: blobs, filter: filter, mailer: mailer,
+		deletionGrace: deletion_grace}
 }
 
 type BuyerEmail struct {
-	Address string
+	Id       string `json:"id"`
+	Address  string `json:"address"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
 }
 
 type Buyer struct {
-	FirstName string        `jsons:"firstName"`
-	LastName  string        `jsons:"lastName"`
+	FirstName string        `json:"firstName"`
+	LastName  string        `json:"lastName"`
 	Emails    []*BuyerEmail `json:"emails"`
 }
 
 func BuyerEmailFromDB(email *database.BuyerEmail) *BuyerEmail {
 	return &BuyerEmail{
-		Address: email.Address,
+		Id:       email.Id,
+		Address:  email.Address,
+		Primary:  email.IsPrimary,
+		Verified: email.Verified,
 	}
 }
 
@@ -69,6 +92,9 @@ type GetBuyerResponse struct {
 func (u *BuyerServer) GetBuyer(ctx context.Context, req *GetBuyerRequest) (
 	resp *GetBuyerResponse, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.GetBuyer")
+	defer end(&err)
+
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 		buyer, err := tx.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(req.BuyerPk))
 		if err != nil {
@@ -98,7 +124,12 @@ type LogInRequest struct {
 func (u *BuyerServer) BuyerLogIn(ctx context.Context, req *LogInRequest) (
 	resp *database.BuyerSession, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.BuyerLogIn")
+	defer end(&err)
+	defer func() { countLogIn(err) }()
+
 	var email *database.BuyerEmail
+	var buyer *database.Buyer
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 		email, err = tx.Find_BuyerEmail_By_Address(ctx,
 			database.BuyerEmail_Address(strings.ToLower(req.Email)))
@@ -106,17 +137,20 @@ func (u *BuyerServer) BuyerLogIn(ctx context.Context, req *LogInRequest) (
 			return err
 		}
 
-		if email == nil {
+		//only the primary email logs in. the others are still waiting on verification or are
+		//kept for the buyer's records
+		if email == nil || !email.IsPrimary {
 			return errs.New("No email exists with that address")
 		}
 
-		return nil
+		buyer, err = tx.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(email.BuyerPk))
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := comparePasswordHash(req.Password, email.SaltedHash); err != nil {
+	if err := comparePasswordHash(req.Password, buyer.SaltedHash); err != nil {
 		return nil, err
 	}
 
@@ -138,7 +172,7 @@ func (u *BuyerServer) BuyerLogIn(ctx context.Context, req *LogInRequest) (
 			}
 		}
 
-		return nil
+		return auditBuyer(ctx, tx, email.BuyerPk, AuditBuyerLogIn, nil, nil)
 	})
 	if err != nil {
 		return nil, err
@@ -156,6 +190,10 @@ type Product struct {
 	GoogleBucketId string  `json:"googleBucketId"`
 	NumInStock     int     `json:"numInStock"`
 	Description    string  `json:"description"`
+	Rating         float32 `json:"rating"`
+
+	//Attributes is empty unless the product is sold as variants
+	Attributes []string `json:"attributes"`
 }
 
 type ProductResponse struct {
@@ -180,6 +218,8 @@ func ProductsFromDB(db_products []*database.Product) []*Product {
 			GoogleBucketId: p.GoogleBucketId,
 			NumInStock:     p.NumInStock,
 			Description:    p.Description,
+			Rating:         p.Rating,
+			Attributes:     decodeAttributeNames(p.Attributes),
 		})
 	}
 
@@ -189,6 +229,9 @@ func ProductsFromDB(db_products []*database.Product) []*Product {
 func (u *BuyerServer) BuyerProducts(ctx context.Context, req *ProductRequest) (
 	resp *ProductResponse, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.BuyerProducts")
+	defer end(&err)
+
 	product_response := &ProductResponse{}
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 
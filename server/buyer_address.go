@@ -0,0 +1,418 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+	"ladybug/validate"
+)
+
+const maxBuyerAddresses = 20
+
+//AddressInUse is returned when changing or deleting an address that an open trial is being
+//shipped to
+var AddressInUse = errs.Class("address in use")
+
+type Address struct {
+	Id              string `json:"id"`
+	CountryCode     string `json:"countryCode"`
+	Line1           string `json:"line1"`
+	Line2           string `json:"line2"`
+	City            string `json:"city"`
+	Region          string `json:"region"`
+	PostalCode      string `json:"postalCode"`
+	DefaultBilling  bool   `json:"defaultBilling"`
+	DefaultShipping bool   `json:"defaultShipping"`
+}
+
+func AddressFromDB(address *database.Address) *Address {
+	return &Address{
+		Id:              address.Id,
+		CountryCode:     address.CountryCode,
+		Line1:           address.Line1,
+		Line2:           address.Line2,
+		City:            address.City,
+		Region:          address.Region,
+		PostalCode:      address.PostalCode,
+		DefaultBilling:  address.IsDefaultBilling,
+		DefaultShipping: address.IsDefaultShipping,
+	}
+}
+
+func AddressesFromDB(addresses []*database.Address) []*Address {
+	out := []*Address{}
+	for _, address := range addresses {
+		out = append(out, AddressFromDB(address))
+	}
+	return out
+}
+
+//findBuyerAddress loads one of the buyer's addresses by id
+func findBuyerAddress(ctx context.Context, tx *database.Tx, buyer_pk int64, address_id string) (
+	*database.Address, error) {
+
+	address, err := tx.Find_Address_By_Id(ctx, database.Address_Id(address_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if address == nil || address.BuyerPk != buyer_pk {
+		return nil, NotFound.New("address not found")
+	}
+
+	return address, nil
+}
+
+//setDefaultAddress makes address the buyer's default billing and/or shipping address and clears
+//the flag from whichever address had it before
+func setDefaultAddress(ctx context.Context, tx *database.Tx, address *database.Address,
+	billing, shipping bool) error {
+
+	addresses, err := tx.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+		database.Address_BuyerPk(address.BuyerPk))
+	if err != nil {
+		return err
+	}
+
+	for _, a := range addresses {
+		is_default_billing := a.IsDefaultBilling
+		is_default_shipping := a.IsDefaultShipping
+		if billing {
+			is_default_billing = a.Pk == address.Pk
+		}
+		if shipping {
+			is_default_shipping = a.Pk == address.Pk
+		}
+
+		if is_default_billing == a.IsDefaultBilling && is_default_shipping == a.IsDefaultShipping {
+			continue
+		}
+
+		err = tx.UpdateNoReturn_Address_By_Pk(ctx, database.Address_Pk(a.Pk),
+			database.Address_Update_Fields{
+				IsDefaultBilling:  database.Address_IsDefaultBilling(is_default_billing),
+				IsDefaultShipping: database.Address_IsDefaultShipping(is_default_shipping),
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type ListAddressesReq struct {
+	BuyerPk int64
+}
+
+type ListAddressesResp struct {
+	Addresses []*Address `json:"addresses"`
+}
+
+func (u *BuyerServer) ListAddresses(ctx context.Context, req *ListAddressesReq) (
+	resp *ListAddressesResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.ListAddresses")
+	defer end(&err)
+
+	var addresses []*database.Address
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		addresses, err = tx.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+			database.Address_BuyerPk(req.BuyerPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListAddressesResp{
+		Addresses: AddressesFromDB(addresses),
+	}, nil
+}
+
+type AddAddressReq struct {
+	BuyerPk int64
+	Address *validate.Address `json:"address"`
+	//IsBilling records whether the address was entered as a billing address. it does not make it
+	//the default billing address
+	IsBilling       bool `json:"isBilling"`
+	DefaultBilling  bool `json:"defaultBilling"`
+	DefaultShipping bool `json:"defaultShipping"`
+}
+
+type AddAddressResp struct {
+	Address *Address `json:"address"`
+}
+
+//AddAddress adds an address to the buyer's address book. a buyer's first address becomes their
+//default billing and shipping address
+func (u *BuyerServer) AddAddress(ctx context.Context, req *AddAddressReq) (
+	resp *AddAddressResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.AddAddress")
+	defer end(&err)
+
+	validate.NormalizeAddress(req.Address)
+	if err := validate.CheckAddress(req.Address); err != nil {
+		return nil, err
+	}
+
+	var address *database.Address
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		count, err := tx.Count_Address_By_BuyerPk(ctx, database.Address_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		if count >= maxBuyerAddresses {
+			return errs.New("you cannot save more than %d addresses", maxBuyerAddresses)
+		}
+
+		address, err = tx.Create_Address(ctx,
+			database.Address_BuyerPk(req.BuyerPk),
+			database.Address_CountryCode(req.Address.CountryCode),
+			database.Address_Line1(req.Address.Line1),
+			database.Address_Line2(req.Address.Line2),
+			database.Address_City(req.Address.City),
+			database.Address_Region(req.Address.Region),
+			database.Address_PostalCode(req.Address.PostalCode),
+			database.Address_IsBilling(req.IsBilling),
+			database.Address_IsDefaultBilling(false),
+			database.Address_IsDefaultShipping(false),
+			database.Address_Id(uuid.NewV4().String()))
+		if err != nil {
+			return err
+		}
+
+		billing, shipping := req.DefaultBilling || count == 0, req.DefaultShipping || count == 0
+		if !billing && !shipping {
+			return nil
+		}
+
+		err = setDefaultAddress(ctx, tx, address, billing, shipping)
+		if err != nil {
+			return err
+		}
+
+		address.IsDefaultBilling, address.IsDefaultShipping = billing, shipping
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddAddressResp{
+		Address: AddressFromDB(address),
+	}, nil
+}
+
+type UpdateAddressReq struct {
+	BuyerPk   int64
+	AddressId string            `json:"addressId"`
+	Address   *validate.Address `json:"address"`
+}
+
+type UpdateAddressResp struct {
+	Address *Address `json:"address"`
+}
+
+func (u *BuyerServer) UpdateAddress(ctx context.Context, req *UpdateAddressReq) (
+	resp *UpdateAddressResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.UpdateAddress")
+	defer end(&err)
+
+	validate.NormalizeAddress(req.Address)
+	if err := validate.CheckAddress(req.Address); err != nil {
+		return nil, err
+	}
+
+	var address *database.Address
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		address, err = findBuyerAddress(ctx, tx, req.BuyerPk, req.AddressId)
+		if err != nil {
+			return err
+		}
+
+		err = checkAddressNotInUse(ctx, tx, address, u.db.Hooks.Now())
+		if err != nil {
+			return err
+		}
+
+		address, err = tx.Update_Address_By_Pk(ctx, database.Address_Pk(address.Pk),
+			database.Address_Update_Fields{
+				CountryCode: database.Address_CountryCode(req.Address.CountryCode),
+				Line1:       database.Address_Line1(req.Address.Line1),
+				Line2:       database.Address_Line2(req.Address.Line2),
+				City:        database.Address_City(req.Address.City),
+				Region:      database.Address_Region(req.Address.Region),
+				PostalCode:  database.Address_PostalCode(req.Address.PostalCode),
+			})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateAddressResp{
+		Address: AddressFromDB(address),
+	}, nil
+}
+
+//checkAddressNotInUse refuses to let an address change while a trial that has not been returned
+//or run out is being shipped to it
+func checkAddressNotInUse(ctx context.Context, tx *database.Tx, address *database.Address,
+	now time.Time) error {
+
+	in_use, err := tx.Has_TrialProduct_By_ShippingAddressPk_And_IsReturned_Equal_False_And_CreatedAt_Greater(
+		ctx, database.TrialProduct_ShippingAddressPk(address.Pk),
+		database.TrialProduct_CreatedAt(now.UTC().Add(-time.Hour*trialPeriodHours)))
+	if err != nil {
+		return err
+	}
+
+	if in_use {
+		return AddressInUse.New("a trial is still being shipped to this address")
+	}
+
+	return nil
+}
+
+type SetDefaultAddressReq struct {
+	BuyerPk   int64
+	AddressId string `json:"addressId"`
+	Billing   bool   `json:"billing"`
+	Shipping  bool   `json:"shipping"`
+}
+
+type SetDefaultAddressResp struct {
+	Addresses []*Address `json:"addresses"`
+}
+
+//SetDefaultAddress makes an address the buyer's default billing address, shipping address or
+//both. it returns the whole address book since the previous defaults change too
+func (u *BuyerServer) SetDefaultAddress(ctx context.Context, req *SetDefaultAddressReq) (
+	resp *SetDefaultAddressResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.SetDefaultAddress")
+	defer end(&err)
+
+	if !req.Billing && !req.Shipping {
+		return nil, errs.New("choose billing, shipping or both")
+	}
+
+	var addresses []*database.Address
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		address, err := findBuyerAddress(ctx, tx, req.BuyerPk, req.AddressId)
+		if err != nil {
+			return err
+		}
+
+		err = setDefaultAddress(ctx, tx, address, req.Billing, req.Shipping)
+		if err != nil {
+			return err
+		}
+
+		addresses, err = tx.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+			database.Address_BuyerPk(req.BuyerPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetDefaultAddressResp{
+		Addresses: AddressesFromDB(addresses),
+	}, nil
+}
+
+type DeleteAddressReq struct {
+	BuyerPk   int64
+	AddressId string `json:"addressId"`
+}
+
+type DeleteAddressResp struct {
+	Addresses []*Address `json:"addresses"`
+}
+
+//DeleteAddress removes an address from the buyer's address book. addresses that an open trial
+//is being shipped to cannot be deleted. when a default is deleted the buyer's oldest remaining
+//address takes its place
+func (u *BuyerServer) DeleteAddress(ctx context.Context, req *DeleteAddressReq) (
+	resp *DeleteAddressResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.DeleteAddress")
+	defer end(&err)
+
+	var addresses []*database.Address
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		address, err := findBuyerAddress(ctx, tx, req.BuyerPk, req.AddressId)
+		if err != nil {
+			return err
+		}
+
+		err = checkAddressNotInUse(ctx, tx, address, u.db.Hooks.Now())
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_Address_By_Pk(ctx, database.Address_Pk(address.Pk))
+		if err != nil {
+			return err
+		}
+
+		addresses, err = tx.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+			database.Address_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		if len(addresses) == 0 || (!address.IsDefaultBilling && !address.IsDefaultShipping) {
+			return nil
+		}
+
+		err = setDefaultAddress(ctx, tx, addresses[0], address.IsDefaultBilling,
+			address.IsDefaultShipping)
+		if err != nil {
+			return err
+		}
+
+		addresses, err = tx.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+			database.Address_BuyerPk(req.BuyerPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteAddressResp{
+		Addresses: AddressesFromDB(addresses),
+	}, nil
+}
+
+//trialShippingAddress picks the address a trial is shipped to. it is the buyer's default
+//shipping address unless they chose another one
+func trialShippingAddress(ctx context.Context, tx *database.Tx, buyer_pk int64,
+	address_id string) (int64, error) {
+
+	if address_id != "" {
+		address, err := findBuyerAddress(ctx, tx, buyer_pk, address_id)
+		if err != nil {
+			return 0, err
+		}
+		return address.Pk, nil
+	}
+
+	address, err := tx.First_Address_By_BuyerPk_And_IsDefaultShipping_Equal_True(ctx,
+		database.Address_BuyerPk(buyer_pk))
+	if err != nil || address == nil {
+		return 0, err
+	}
+
+	return address.Pk, nil
+}
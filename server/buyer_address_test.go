@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+	"ladybug/validate"
+)
+
+func (h *serverTest) addAddress(ctx context.Context, buyer_pk int64, street string) *Address {
+	resp, err := h.BuyerServer.AddAddress(ctx, &AddAddressReq{
+		BuyerPk: buyer_pk,
+		Address: &validate.Address{
+			Line1:      street,
+			City:       "Portland",
+			Region:     "OR",
+			PostalCode: "97201",
+		},
+	})
+	require.NoError(h.t, err)
+
+	return resp.Address
+}
+
+func TestBuyerAddressBook(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	other_buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	//the first address is the default for both
+	home := test.addAddress(ctx, buyer.Pk, "1 Main St")
+	require.True(t, home.DefaultBilling)
+	require.True(t, home.DefaultShipping)
+
+	work := test.addAddress(ctx, buyer.Pk, "2 Office Park")
+	require.False(t, work.DefaultShipping)
+
+	_, err := test.BuyerServer.AddAddress(ctx, &AddAddressReq{
+		BuyerPk: buyer.Pk,
+		Address: &validate.Address{Line1: "3 Nowhere"},
+	})
+	require.Error(t, err)
+
+	//moving the shipping default leaves billing alone
+	defaults, err := test.BuyerServer.SetDefaultAddress(ctx, &SetDefaultAddressReq{
+		BuyerPk:   buyer.Pk,
+		AddressId: work.Id,
+		Shipping:  true,
+	})
+	require.NoError(t, err)
+	require.Len(t, defaults.Addresses, 2)
+	require.True(t, defaults.Addresses[0].DefaultBilling)
+	require.False(t, defaults.Addresses[0].DefaultShipping)
+	require.True(t, defaults.Addresses[1].DefaultShipping)
+
+	updated, err := test.BuyerServer.UpdateAddress(ctx, &UpdateAddressReq{
+		BuyerPk:   buyer.Pk,
+		AddressId: work.Id,
+		Address: &validate.Address{
+			CountryCode: "ca",
+			Line1:       "20 Office Park",
+			City:        "Toronto",
+			Region:      "ON",
+			PostalCode:  "m5v 3l9",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, updated.Address.CountryCode, "CA")
+	require.Equal(t, updated.Address.PostalCode, "M5V 3L9")
+	require.True(t, updated.Address.DefaultShipping)
+
+	//other buyers cannot touch the address
+	_, err = test.BuyerServer.UpdateAddress(ctx, &UpdateAddressReq{
+		BuyerPk:   other_buyer.Pk,
+		AddressId: work.Id,
+		Address: &validate.Address{Line1: "x", City: "x", Region: "x",
+			PostalCode: "12345"},
+	})
+	require.True(t, NotFound.Has(err))
+
+	_, err = test.BuyerServer.DeleteAddress(ctx, &DeleteAddressReq{
+		BuyerPk:   other_buyer.Pk,
+		AddressId: work.Id,
+	})
+	require.True(t, NotFound.Has(err))
+
+	//deleting the shipping default hands it to the oldest remaining address
+	deleted, err := test.BuyerServer.DeleteAddress(ctx, &DeleteAddressReq{
+		BuyerPk:   buyer.Pk,
+		AddressId: work.Id,
+	})
+	require.NoError(t, err)
+	require.Len(t, deleted.Addresses, 1)
+	require.True(t, deleted.Addresses[0].DefaultShipping)
+
+	list, err := test.BuyerServer.ListAddresses(ctx, &ListAddressesReq{BuyerPk: buyer.Pk})
+	require.NoError(t, err)
+	require.Len(t, list.Addresses, 1)
+	require.Equal(t, list.Addresses[0].Id, home.Id)
+
+	//entering a billing address does not make it the default and vice versa
+	added, err := test.BuyerServer.AddAddress(ctx, &AddAddressReq{
+		BuyerPk: buyer.Pk,
+		Address: &validate.Address{Line1: "4 Bank St", City: "Portland", Region: "OR",
+			PostalCode: "97201"},
+		IsBilling: true,
+	})
+	require.NoError(t, err)
+	require.False(t, added.Address.DefaultBilling)
+
+	billing, err := test.db.Find_Address_By_Id(ctx, database.Address_Id(added.Address.Id))
+	require.NoError(t, err)
+	require.True(t, billing.IsBilling)
+
+	added, err = test.BuyerServer.AddAddress(ctx, &AddAddressReq{
+		BuyerPk: buyer.Pk,
+		Address: &validate.Address{Line1: "5 Mill St", City: "Portland", Region: "OR",
+			PostalCode: "97201"},
+		DefaultBilling: true,
+	})
+	require.NoError(t, err)
+	require.True(t, added.Address.DefaultBilling)
+
+	shipping, err := test.db.Find_Address_By_Id(ctx, database.Address_Id(added.Address.Id))
+	require.NoError(t, err)
+	require.False(t, shipping.IsBilling)
+}
+
+func TestAddressWithOpenTrial(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	product := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+	home := test.addAddress(ctx, buyer.Pk, "1 Main St")
+
+	resp, err := test.BuyerServer.StartProductTrial(ctx, &StartProductTrialReq{
+		BuyerPk:   buyer.Pk,
+		VendorId:  vendor.Id,
+		ProductId: product.Id,
+	})
+	require.NoError(t, err)
+
+	//the trial ships to the default shipping address so it cannot be changed or deleted
+	moved := &validate.Address{
+		Line1:      "2 Main St",
+		City:       "Portland",
+		Region:     "OR",
+		PostalCode: "97201",
+	}
+	_, err = test.BuyerServer.UpdateAddress(ctx, &UpdateAddressReq{
+		BuyerPk:   buyer.Pk,
+		AddressId: home.Id,
+		Address:   moved,
+	})
+	require.True(t, AddressInUse.Has(err))
+
+	_, err = test.BuyerServer.DeleteAddress(ctx, &DeleteAddressReq{
+		BuyerPk:   buyer.Pk,
+		AddressId: home.Id,
+	})
+	require.True(t, AddressInUse.Has(err))
+
+	//trials that have run out no longer hold the address
+	test.at(time.Now().Add(time.Hour*(trialPeriodHours+1)), func() {
+		_, err = test.BuyerServer.UpdateAddress(ctx, &UpdateAddressReq{
+			BuyerPk:   buyer.Pk,
+			AddressId: home.Id,
+			Address:   moved,
+		})
+		require.NoError(t, err)
+	})
+
+	//once the trial is returned the address can go
+	trial, err := test.db.Find_TrialProduct_By_Id(ctx,
+		database.TrialProduct_Id(resp.TrialProduct.Id))
+	require.NoError(t, err)
+	_, err = test.db.Update_TrialProduct_By_Pk(ctx, database.TrialProduct_Pk(trial.Pk),
+		database.TrialProduct_Update_Fields{
+			IsReturned: database.TrialProduct_IsReturned(true),
+		})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.DeleteAddress(ctx, &DeleteAddressReq{
+		BuyerPk:   buyer.Pk,
+		AddressId: home.Id,
+	})
+	require.NoError(t, err)
+}
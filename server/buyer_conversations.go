@@ -17,6 +17,9 @@ type BuyerConversationsUnreadResp struct {
 func (u *BuyerServer) GetBuyerConversationsUnread(ctx context.Context,
 	req *BuyerConversationsUnreadReq) (resp *BuyerConversationsUnreadResp, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.GetBuyerConversationsUnread")
+	defer end(&err)
+
 	var conversations []*database.Conversation
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 		conversations, err = tx.All_Conversation_By_BuyerPk_And_BuyerUnread_Equal_True(ctx,
@@ -49,6 +52,9 @@ type PagedBuyerConversationResp struct {
 func (u *BuyerServer) GetPagedBuyerConversations(ctx context.Context, req *PagedBuyerConversationsReq) (
 	resp *PagedBuyerConversationResp, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.GetPagedBuyerConversations")
+	defer end(&err)
+
 	var conversations []*database.Conversation
 	var ctoken string
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
@@ -69,3 +75,211 @@ func (u *BuyerServer) GetPagedBuyerConversations(ctx context.Context, req *Paged
 		Conversations: ConversationsFromDB(conversations),
 	}, nil
 }
+
+//markBuyerConversationRead advances the buyer's read pointer inside an open transaction and
+//returns the conversation as it is after the update
+func markBuyerConversationRead(ctx context.Context, tx *database.Tx,
+	conversation *database.Conversation, conversation_number int64) (
+	*database.Conversation, error) {
+
+	last_read := lastReadUpTo(conversation.BuyerLastRead, conversation_number,
+		conversation.MessageCount)
+	unread := last_read < conversation.MessageCount
+	if last_read == conversation.BuyerLastRead && unread == conversation.BuyerUnread {
+		return conversation, nil
+	}
+
+	return tx.Update_Conversation_By_Pk(ctx,
+		database.Conversation_Pk(conversation.Pk),
+		database.Conversation_Update_Fields{
+			BuyerLastRead: database.Conversation_BuyerLastRead(last_read),
+			BuyerUnread:   database.Conversation_BuyerUnread(unread),
+		})
+}
+
+type MarkBuyerConversationReadReq struct {
+	BuyerPk            int64
+	ConversationId     string `json:"conversationId"`
+	ConversationNumber int64  `json:"conversationNumber"`
+}
+
+type MarkBuyerConversationReadResp struct {
+	LastRead    int64 `json:"lastRead"`
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+//MarkBuyerConversationRead marks the conversation read up to and including conversation number
+func (u *BuyerServer) MarkBuyerConversationRead(ctx context.Context,
+	req *MarkBuyerConversationReadReq) (resp *MarkBuyerConversationReadResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.MarkBuyerConversationRead")
+	defer end(&err)
+
+	var before, conversation *database.Conversation
+	var unread_count int64
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		before, err = authorizeBuyerConversation(ctx, tx, req.BuyerPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		conversation, err = markBuyerConversationRead(ctx, tx, before, req.ConversationNumber)
+		if err != nil {
+			return err
+		}
+
+		unread_count, err = tx.Count_Conversation_By_BuyerPk_And_BuyerUnread_Equal_True(ctx,
+			database.Conversation_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.BuyerLastRead != before.BuyerLastRead {
+		publishRead(ctx, u.hub, BuyerTopic(conversation.BuyerPk),
+			VendorTopic(conversation.VendorPk), conversation.Id, conversation.BuyerLastRead,
+			unread_count)
+	}
+
+	return &MarkBuyerConversationReadResp{
+		LastRead:    conversation.BuyerLastRead,
+		UnreadCount: conversation.MessageCount - conversation.BuyerLastRead,
+	}, nil
+}
+
+type BuyerUnreadCountsReq struct {
+	BuyerPk int64
+}
+
+type BuyerUnreadCountsResp struct {
+	TotalUnread   int64                 `json:"totalUnread"`
+	Conversations []*ConversationUnread `json:"conversations"`
+}
+
+//GetBuyerUnreadCounts returns the number of unread messages in each unread conversation and the
+//total across all of them
+func (u *BuyerServer) GetBuyerUnreadCounts(ctx context.Context, req *BuyerUnreadCountsReq) (
+	resp *BuyerUnreadCountsResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.GetBuyerUnreadCounts")
+	defer end(&err)
+
+	var conversations []*database.Conversation
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		conversations, err = tx.All_Conversation_By_BuyerPk_And_BuyerUnread_Equal_True(ctx,
+			database.Conversation_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &BuyerUnreadCountsResp{Conversations: []*ConversationUnread{}}
+	for _, c := range conversations {
+		unread := c.MessageCount - c.BuyerLastRead
+		resp.TotalUnread += unread
+		resp.Conversations = append(resp.Conversations, &ConversationUnread{
+			Id:          c.Id,
+			UnreadCount: unread,
+			LastRead:    c.BuyerLastRead,
+		})
+	}
+
+	return resp, nil
+}
+
+type BlockBuyerConversationReq struct {
+	BuyerPk        int64
+	ConversationId string `json:"conversationId"`
+	Blocked        bool   `json:"blocked"`
+}
+
+type BlockBuyerConversationResp struct {
+	Blocked bool `json:"blocked"`
+}
+
+//BlockBuyerConversation blocks or unblocks the vendor in a conversation. while it is blocked any
+//message the vendor posts to it is rejected
+func (u *BuyerServer) BlockBuyerConversation(ctx context.Context,
+	req *BlockBuyerConversationReq) (resp *BlockBuyerConversationResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.BlockBuyerConversation")
+	defer end(&err)
+
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		conversation, err := authorizeBuyerConversation(ctx, tx, req.BuyerPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		err = tx.UpdateNoReturn_Conversation_By_Pk(ctx,
+			database.Conversation_Pk(conversation.Pk),
+			database.Conversation_Update_Fields{
+				BlockedByBuyer: database.Conversation_BlockedByBuyer(req.Blocked),
+			})
+		if err != nil {
+			return err
+		}
+
+		return auditBuyerOn(ctx, tx, req.BuyerPk, AuditConversationBlock, "conversation",
+			conversation.Id, auditFields{"blockedByBuyer": conversation.BlockedByBuyer},
+			auditFields{"blockedByBuyer": req.Blocked})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockBuyerConversationResp{
+		Blocked: req.Blocked,
+	}, nil
+}
+
+type ReportBuyerConversationReq struct {
+	BuyerPk        int64
+	ConversationId string `json:"conversationId"`
+	Reason         string `json:"reason"`
+}
+
+type ReportBuyerConversationResp struct {
+	ReportId string `json:"reportId"`
+}
+
+//ReportBuyerConversation sends a conversation to the admin review queue
+func (u *BuyerServer) ReportBuyerConversation(ctx context.Context,
+	req *ReportBuyerConversationReq) (resp *ReportBuyerConversationResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.ReportBuyerConversation")
+	defer end(&err)
+
+	var report *database.ConversationReport
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		conversation, err := authorizeBuyerConversation(ctx, tx, req.BuyerPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		report, err = reportConversation(ctx, tx, conversation, buyerReporter, req.Reason)
+		if err != nil {
+			return err
+		}
+
+		return auditBuyerOn(ctx, tx, req.BuyerPk, AuditConversationReport, "conversation",
+			conversation.Id, nil, auditFields{"reportId": report.Id, "reason": req.Reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReportBuyerConversationResp{
+		ReportId: report.Id,
+	}, nil
+}
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+//DefaultDeletionGracePeriod is how long a buyer has to change their mind after asking for their
+//account to be deleted
+const DefaultDeletionGracePeriod = 14 * 24 * time.Hour
+
+//InvalidDeletion is returned when an account deletion is asked for with the wrong password or
+//one is already scheduled
+var InvalidDeletion = errs.Class("invalid deletion")
+
+type BuyerDeletion struct {
+	RequestedAt int64 `json:"requestedAt"`
+	DeleteAfter int64 `json:"deleteAfter"`
+}
+
+func BuyerDeletionFromDB(deletion *database.BuyerDeletion) *BuyerDeletion {
+	if deletion == nil {
+		return nil
+	}
+
+	return &BuyerDeletion{
+		RequestedAt: deletion.CreatedAt.Unix(),
+		DeleteAfter: deletion.DeleteAfter.Unix(),
+	}
+}
+
+type GetBuyerDeletionReq struct {
+	BuyerPk int64
+}
+
+type GetBuyerDeletionResp struct {
+	//Deletion is nil unless the buyer has asked for their account to be deleted
+	Deletion *BuyerDeletion `json:"deletion"`
+}
+
+func (u *BuyerServer) GetBuyerDeletion(ctx context.Context, req *GetBuyerDeletionReq) (
+	resp *GetBuyerDeletionResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.GetBuyerDeletion")
+	defer end(&err)
+
+	var deletion *database.BuyerDeletion
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		deletion, err = tx.Find_BuyerDeletion_By_BuyerPk(ctx,
+			database.BuyerDeletion_BuyerPk(req.BuyerPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetBuyerDeletionResp{
+		Deletion: BuyerDeletionFromDB(deletion),
+	}, nil
+}
+
+type RequestBuyerDeletionReq struct {
+	BuyerPk  int64
+	Password string `json:"password"`
+}
+
+type RequestBuyerDeletionResp struct {
+	Deletion *BuyerDeletion `json:"deletion"`
+}
+
+//RequestBuyerDeletion schedules the buyer's account to be deleted once the grace period is over.
+//the buyer can still log in and cancel until then
+func (u *BuyerServer) RequestBuyerDeletion(ctx context.Context, req *RequestBuyerDeletionReq) (
+	resp *RequestBuyerDeletionResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.RequestBuyerDeletion")
+	defer end(&err)
+
+	var deletion *database.BuyerDeletion
+	var primary *database.BuyerEmail
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		buyer, err := tx.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		err = comparePasswordHash(req.Password, buyer.SaltedHash)
+		if err != nil {
+			return InvalidDeletion.New("password does not match")
+		}
+
+		existing, err := tx.Find_BuyerDeletion_By_BuyerPk(ctx,
+			database.BuyerDeletion_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			return InvalidDeletion.New("your account is already scheduled to be deleted")
+		}
+
+		deletion, err = tx.Create_BuyerDeletion(ctx,
+			database.BuyerDeletion_BuyerPk(req.BuyerPk),
+			database.BuyerDeletion_DeleteAfter(u.db.Hooks.Now().UTC().Add(u.deletionGrace)))
+		if err != nil {
+			return err
+		}
+
+		err = auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerDeletionRequest, nil,
+			auditFields{"deleteAfter": deletion.DeleteAfter.Unix()})
+		if err != nil {
+			return err
+		}
+
+		primary, err = tx.First_BuyerEmail_By_BuyerPk_And_IsPrimary_Equal_True(ctx,
+			database.BuyerEmail_BuyerPk(req.BuyerPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if primary != nil {
+		err = u.mailer.SendMail(ctx, primary.Address, "Your Ladybug account will be deleted",
+			fmt.Sprintf("Your account and personal data will be deleted on %s. Log in before "+
+				"then to cancel.", deletion.DeleteAfter.Format("January 2, 2006")))
+		if err != nil {
+			Logger(ctx).Errorf("unable to mail deletion notice to buyer %d: %+v", req.BuyerPk, err)
+		}
+	}
+
+	return &RequestBuyerDeletionResp{
+		Deletion: BuyerDeletionFromDB(deletion),
+	}, nil
+}
+
+type CancelBuyerDeletionReq struct {
+	BuyerPk int64
+}
+
+type CancelBuyerDeletionResp struct{}
+
+func (u *BuyerServer) CancelBuyerDeletion(ctx context.Context, req *CancelBuyerDeletionReq) (
+	resp *CancelBuyerDeletionResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.CancelBuyerDeletion")
+	defer end(&err)
+
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		deleted, err := tx.Delete_BuyerDeletion_By_BuyerPk(ctx,
+			database.BuyerDeletion_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		if !deleted {
+			return NotFound.New("your account is not scheduled to be deleted")
+		}
+
+		return auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerDeletionCancel, nil, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CancelBuyerDeletionResp{}, nil
+}
+
+//eraseBuyer removes a buyer's personal data. reviews and conversations are kept for the vendors
+//and the rest of the marketplace but no longer point at the buyer, and attachments the buyer sent
+//are dropped. trials and purchases are kept for vendor sales records but lose their buyer and
+//shipping address. it returns the keys of the attachment blobs to delete once the transaction has
+//committed
+func eraseBuyer(ctx context.Context, tx *database.Tx, buyer_pk int64) (
+	blob_keys []string, err error) {
+
+	id, err := buyerId(ctx, tx, buyer_pk)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := tx.All_ProductReview_By_BuyerPk(ctx, database.ProductReview_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, review := range reviews {
+		err = tx.UpdateNoReturn_ProductReview_By_Pk(ctx, database.ProductReview_Pk(review.Pk),
+			database.ProductReview_Update_Fields{
+				BuyerPk: database.ProductReview_BuyerPk(0),
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conversations, err := tx.All_Conversation_By_BuyerPk(ctx,
+		database.Conversation_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, conversation := range conversations {
+		attachments, err :=
+			tx.All_MessageAttachment_By_Message_ConversationPk_And_Message_BuyerSent_Equal_True(
+				ctx, database.Message_ConversationPk(conversation.Pk))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, attachment := range attachments {
+			_, err = tx.Delete_MessageAttachment_By_Pk(ctx,
+				database.MessageAttachment_Pk(attachment.Pk))
+			if err != nil {
+				return nil, err
+			}
+			blob_keys = append(blob_keys, attachment.BlobKey)
+		}
+
+		err = tx.UpdateNoReturn_Conversation_By_Pk(ctx, database.Conversation_Pk(conversation.Pk),
+			database.Conversation_Update_Fields{
+				BuyerPk:        database.Conversation_BuyerPk(0),
+				BlockedByBuyer: database.Conversation_BlockedByBuyer(true),
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	trials, err := tx.All_TrialProduct_By_BuyerPk_OrderBy_Asc_CreatedAt(ctx,
+		database.TrialProduct_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trial := range trials {
+		_, err = tx.Update_TrialProduct_By_Pk(ctx, database.TrialProduct_Pk(trial.Pk),
+			database.TrialProduct_Update_Fields{
+				BuyerPk:           database.TrialProduct_BuyerPk(0),
+				ShippingAddressPk: database.TrialProduct_ShippingAddressPk(0),
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	purchases, err := tx.All_PurchasedProduct_By_BuyerPk_OrderBy_Asc_CreatedAt(ctx,
+		database.PurchasedProduct_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, purchase := range purchases {
+		err = tx.UpdateNoReturn_PurchasedProduct_By_Pk(ctx,
+			database.PurchasedProduct_Pk(purchase.Pk),
+			database.PurchasedProduct_Update_Fields{
+				BuyerPk: database.PurchasedProduct_BuyerPk(0),
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	revoked, err := tx.Delete_BuyerSession_By_BuyerPk(ctx, database.BuyerSession_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	err = audit(ctx, tx, &auditEvent{
+		actorKind:  SystemActor,
+		action:     AuditSessionRevoke,
+		targetKind: "buyer",
+		targetId:   id,
+		after:      auditFields{"sessions": revoked},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Delete_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Delete_Address_By_BuyerPk(ctx, database.Address_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Delete_BuyerDeletion_By_BuyerPk(ctx, database.BuyerDeletion_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Delete_Buyer_By_Pk(ctx, database.Buyer_Pk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	err = audit(ctx, tx, &auditEvent{
+		actorKind:  SystemActor,
+		action:     AuditBuyerDelete,
+		targetKind: "buyer",
+		targetId:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return blob_keys, nil
+}
+
+//DeleteDueBuyers erases every buyer whose grace period ended before now. each buyer is erased in
+//their own transaction so one failure is logged and does not hold up the rest
+func (u *BuyerServer) DeleteDueBuyers(ctx context.Context, now time.Time) (
+	deleted int, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.DeleteDueBuyers")
+	defer end(&err)
+
+	due, err := u.db.WithContext(ctx).All_BuyerDeletion_By_DeleteAfter_LessOrEqual(ctx,
+		database.BuyerDeletion_DeleteAfter(now.UTC()))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, deletion := range due {
+		var blob_keys []string
+		err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+			blob_keys, err = eraseBuyer(ctx, tx, deletion.BuyerPk)
+			return err
+		})
+		if err != nil {
+			Logger(ctx).Errorf("unable to delete buyer %d: %+v", deletion.BuyerPk, err)
+			continue
+		}
+		deleted++
+
+		for _, key := range blob_keys {
+			err = u.blobs.Delete(ctx, key)
+			if err != nil {
+				Logger(ctx).Errorf("unable to delete attachment %s: %+v", key, err)
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+//RunBuyerDeletions calls DeleteDueBuyers every interval until ctx is done
+func (u *BuyerServer) RunBuyerDeletions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := u.DeleteDueBuyers(ctx, u.db.Hooks.Now())
+		if err != nil {
+			Logger(ctx).Errorf("deleting buyers failed: %+v", err)
+		}
+		if deleted > 0 {
+			Logger(ctx).Infof("deleted %d buyer accounts", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
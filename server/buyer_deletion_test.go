@@ -0,0 +1,202 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+//readExport unzips a buyer export into its files
+func (h *serverTest) readExport(data []byte) map[string][]byte {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(h.t, err)
+
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(h.t, err)
+		files[f.Name], err = ioutil.ReadAll(r)
+		require.NoError(h.t, err)
+		require.NoError(h.t, r.Close())
+	}
+
+	return files
+}
+
+func TestExportBuyerData(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createFullTestBuyer(ctx)
+	product := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+	test.createTrial(ctx, buyer.Pk, product, false)
+	test.purchaseProduct(ctx, buyer.Pk, vendor.Pk, product)
+	test.createDefaultProductReview(ctx, buyer.Pk, product.Pk)
+	_, err := test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "where is my order?",
+		})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = test.BuyerServer.ExportBuyerData(ctx, &ExportBuyerDataReq{BuyerPk: buyer.Pk}, &out)
+	require.NoError(t, err)
+
+	files := test.readExport(out.Bytes())
+	require.Len(t, files, 8)
+
+	var profile ExportedProfile
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	require.Equal(t, profile.FirstName, buyer.FirstName)
+
+	var emails []*BuyerEmail
+	require.NoError(t, json.Unmarshal(files["emails.json"], &emails))
+	require.Equal(t, emails[0].Address, buyer.emails[0].Address)
+
+	var trials []*ExportedTrial
+	require.NoError(t, json.Unmarshal(files["trials.json"], &trials))
+	require.Len(t, trials, 1)
+	require.Equal(t, trials[0].ProductId, product.Id)
+
+	var conversations []*ExportedConversation
+	require.NoError(t, json.Unmarshal(files["conversations.json"], &conversations))
+	require.Len(t, conversations, 1)
+	require.Equal(t, conversations[0].VendorId, vendor.Id)
+	require.Equal(t, conversations[0].Messages[0].Description, "where is my order?")
+
+	//session ids log the buyer in so they are left out
+	require.NotContains(t, string(files["sessions.json"]), `"id"`)
+}
+
+func TestBuyerDeletion(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createFullTestBuyer(ctx)
+	product := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+	review := test.createDefaultProductReview(ctx, buyer.Pk, product.Pk)
+	purchase := test.purchaseProduct(ctx, buyer.Pk, vendor.Pk, product)
+	trial := test.createTrial(ctx, buyer.Pk, product, false)
+	message, err := test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "my receipt",
+			Attachments: []*AttachmentUpload{
+				{Filename: "receipt.pdf", Data: []byte("%PDF-1.4 the receipt")},
+			},
+		})
+	require.NoError(t, err)
+
+	//the password has to be given again
+	_, err = test.BuyerServer.RequestBuyerDeletion(ctx, &RequestBuyerDeletionReq{
+		BuyerPk:  buyer.Pk,
+		Password: "not the password",
+	})
+	require.True(t, InvalidDeletion.Has(err))
+
+	deletion_req := &RequestBuyerDeletionReq{
+		BuyerPk:  buyer.Pk,
+		Password: buyer.emails[0].unsaltedPassword,
+	}
+	resp, err := test.BuyerServer.RequestBuyerDeletion(ctx, deletion_req)
+	require.NoError(t, err)
+	require.Equal(t, test.mailer.sent[len(test.mailer.sent)-1].To, buyer.emails[0].Address)
+
+	grace_over := time.Unix(resp.Deletion.DeleteAfter, 0).Add(time.Second)
+
+	//cancelling keeps the account
+	_, err = test.BuyerServer.CancelBuyerDeletion(ctx, &CancelBuyerDeletionReq{BuyerPk: buyer.Pk})
+	require.NoError(t, err)
+
+	deleted, err := test.BuyerServer.DeleteDueBuyers(ctx, grace_over)
+	require.NoError(t, err)
+	require.Equal(t, deleted, 0)
+
+	_, err = test.BuyerServer.CancelBuyerDeletion(ctx, &CancelBuyerDeletionReq{BuyerPk: buyer.Pk})
+	require.True(t, NotFound.Has(err))
+
+	//nothing happens during the grace period
+	_, err = test.BuyerServer.RequestBuyerDeletion(ctx, deletion_req)
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.RequestBuyerDeletion(ctx, deletion_req)
+	require.True(t, InvalidDeletion.Has(err))
+
+	deleted, err = test.BuyerServer.DeleteDueBuyers(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, deleted, 0)
+
+	deleted, err = test.BuyerServer.DeleteDueBuyers(ctx, grace_over.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, deleted, 1)
+
+	//the buyer and their personal data are gone
+	_, err = test.BuyerServer.BuyerLogIn(ctx, &LogInRequest{
+		Email:    buyer.emails[0].Address,
+		Password: buyer.emails[0].unsaltedPassword,
+	})
+	require.Error(t, err)
+
+	_, err = test.db.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(buyer.Pk))
+	require.Error(t, err)
+
+	sessions, err := test.db.All_BuyerSession_By_BuyerPk(ctx,
+		database.BuyerSession_BuyerPk(buyer.Pk))
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	addresses, err := test.db.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+		database.Address_BuyerPk(buyer.Pk))
+	require.NoError(t, err)
+	require.Empty(t, addresses)
+
+	//reviews and messages stay but no longer point at the buyer
+	anonymous, err := test.db.Get_ProductReview_By_Pk(ctx, database.ProductReview_Pk(review.Pk))
+	require.NoError(t, err)
+	require.Equal(t, anonymous.BuyerPk, int64(0))
+	require.Equal(t, anonymous.Description, review.Description)
+
+	conversations, err := test.VendorServer.GetPagedVendorConversations(ctx,
+		&PagedVendorConversationReq{
+			VendorPk:           vendor.Pk,
+			ExecutiveContactPk: owner.Pk,
+		})
+	require.NoError(t, err)
+	require.Len(t, conversations.Conversations, 1)
+
+	_, err = test.VendorServer.GetVendorMessageAttachment(ctx, &VendorMessageAttachmentReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		AttachmentId:       message.Message.Attachments[0].Id,
+	})
+	require.True(t, NotFound.Has(err))
+
+	//sales records are kept for the vendor but no longer point at the buyer
+	kept_purchase, err := test.db.Get_PurchasedProduct_By_Pk(ctx,
+		database.PurchasedProduct_Pk(purchase.Pk))
+	require.NoError(t, err)
+	require.Equal(t, kept_purchase.BuyerPk, int64(0))
+
+	kept_trial, err := test.db.Get_TrialProduct_By_Pk(ctx, database.TrialProduct_Pk(trial.Pk))
+	require.NoError(t, err)
+	require.Equal(t, kept_trial.BuyerPk, int64(0))
+	require.Equal(t, kept_trial.ShippingAddressPk, int64(0))
+}
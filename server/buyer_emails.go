@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+	"ladybug/validate"
+)
+
+const (
+	maxBuyerEmails = 5
+
+	//emailVerificationExpiry is how long a verification token works. an address that was never
+	//verified can be added by someone else after this
+	emailVerificationExpiry = 24 * time.Hour
+)
+
+//findBuyerEmail loads one of the buyer's emails by id
+func findBuyerEmail(ctx context.Context, tx *database.Tx, buyer_pk int64, email_id string) (
+	*database.BuyerEmail, error) {
+
+	email, err := tx.Find_BuyerEmail_By_Id(ctx, database.BuyerEmail_Id(email_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if email == nil || email.BuyerPk != buyer_pk {
+		return nil, NotFound.New("email not found")
+	}
+
+	return email, nil
+}
+
+//emailVerificationExpired reports whether an unverified email's token can no longer be used
+func emailVerificationExpired(email *database.BuyerEmail) bool {
+	return !email.Verified && time.Since(email.CreatedAt) > emailVerificationExpiry
+}
+
+//claimBuyerEmail makes sure nobody else has the address. an address someone added but never
+//verified is freed once its verification expires
+func claimBuyerEmail(ctx context.Context, tx *database.Tx, address string) error {
+	existing, err := tx.Find_BuyerEmail_By_Address(ctx, database.BuyerEmail_Address(address))
+	if err != nil || existing == nil {
+		return err
+	}
+
+	if !emailVerificationExpired(existing) {
+		return errs.New("that email is already in use")
+	}
+
+	_, err = tx.Delete_BuyerEmail_By_Pk(ctx, database.BuyerEmail_Pk(existing.Pk))
+	return err
+}
+
+type AddBuyerEmailReq struct {
+	BuyerPk int64
+	Email   string `json:"email"`
+}
+
+type AddBuyerEmailResp struct {
+	Email *BuyerEmail `json:"email"`
+}
+
+//AddBuyerEmail adds an address to the buyer's account and mails it a verification token. the
+//address cannot be made primary until it is verified
+func (u *BuyerServer) AddBuyerEmail(ctx context.Context, req *AddBuyerEmailReq) (
+	resp *AddBuyerEmailResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.AddBuyerEmail")
+	defer end(&err)
+
+	address := strings.ToLower(req.Email)
+	if err := validate.CheckEmail(address); err != nil {
+		return nil, err
+	}
+
+	token := uuid.NewV4().String()
+	var email *database.BuyerEmail
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		err := claimBuyerEmail(ctx, tx, address)
+		if err != nil {
+			return err
+		}
+
+		count, err := tx.Count_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		if count >= maxBuyerEmails {
+			return errs.New("you cannot have more than %d emails", maxBuyerEmails)
+		}
+
+		email, err = tx.Create_BuyerEmail(ctx,
+			database.BuyerEmail_BuyerPk(req.BuyerPk),
+			database.BuyerEmail_Address(address),
+			database.BuyerEmail_Id(uuid.NewV4().String()),
+			database.BuyerEmail_IsPrimary(false),
+			database.BuyerEmail_Verified(false),
+			database.BuyerEmail_VerificationHash(hashToken(token)))
+		if err != nil {
+			return err
+		}
+
+		return auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerEmailAdd, nil,
+			auditFields{"emailId": email.Id})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.mailer.SendMail(ctx, address, "Verify your Ladybug email",
+		fmt.Sprintf("Use this token to verify your email within %d hours: %s",
+			int(emailVerificationExpiry.Hours()), token))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddBuyerEmailResp{
+		Email: BuyerEmailFromDB(email),
+	}, nil
+}
+
+type VerifyBuyerEmailReq struct {
+	BuyerPk int64
+	Token   string `json:"token"`
+}
+
+type VerifyBuyerEmailResp struct {
+	Email *BuyerEmail `json:"email"`
+}
+
+func (u *BuyerServer) VerifyBuyerEmail(ctx context.Context, req *VerifyBuyerEmailReq) (
+	resp *VerifyBuyerEmailResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.VerifyBuyerEmail")
+	defer end(&err)
+
+	var email *database.BuyerEmail
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		email, err = tx.Find_BuyerEmail_By_VerificationHash(ctx,
+			database.BuyerEmail_VerificationHash(hashToken(req.Token)))
+		if err != nil {
+			return err
+		}
+
+		if email == nil || email.BuyerPk != req.BuyerPk || email.Verified {
+			return NotFound.New("verification not found")
+		}
+
+		if emailVerificationExpired(email) {
+			return errs.New("the verification has expired. remove the email and add it again")
+		}
+
+		err = tx.UpdateNoReturn_BuyerEmail_By_Pk(ctx, database.BuyerEmail_Pk(email.Pk),
+			database.BuyerEmail_Update_Fields{
+				Verified:         database.BuyerEmail_Verified(true),
+				VerificationHash: database.BuyerEmail_VerificationHash(""),
+			})
+		if err != nil {
+			return err
+		}
+
+		email.Verified = true
+
+		return auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerEmailVerify, nil,
+			auditFields{"emailId": email.Id})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyBuyerEmailResp{
+		Email: BuyerEmailFromDB(email),
+	}, nil
+}
+
+type SetPrimaryBuyerEmailReq struct {
+	BuyerPk int64
+	EmailId string `json:"emailId"`
+}
+
+type SetPrimaryBuyerEmailResp struct {
+	Emails []*BuyerEmail `json:"emails"`
+}
+
+//SetPrimaryBuyerEmail changes the address the buyer logs in with and that mail is sent to
+func (u *BuyerServer) SetPrimaryBuyerEmail(ctx context.Context, req *SetPrimaryBuyerEmailReq) (
+	resp *SetPrimaryBuyerEmailResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.SetPrimaryBuyerEmail")
+	defer end(&err)
+
+	var emails []*database.BuyerEmail
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		email, err := findBuyerEmail(ctx, tx, req.BuyerPk, req.EmailId)
+		if err != nil {
+			return err
+		}
+
+		if !email.Verified {
+			return errs.New("verify the email before making it primary")
+		}
+
+		emails, err = tx.All_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		before := auditFields{}
+		for _, e := range emails {
+			if e.IsPrimary {
+				before["primaryEmailId"] = e.Id
+			}
+
+			is_primary := e.Pk == email.Pk
+			if e.IsPrimary == is_primary {
+				continue
+			}
+
+			err = tx.UpdateNoReturn_BuyerEmail_By_Pk(ctx, database.BuyerEmail_Pk(e.Pk),
+				database.BuyerEmail_Update_Fields{
+					IsPrimary: database.BuyerEmail_IsPrimary(is_primary),
+				})
+			if err != nil {
+				return err
+			}
+
+			e.IsPrimary = is_primary
+		}
+
+		return auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerEmailPrimary, before,
+			auditFields{"primaryEmailId": email.Id})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetPrimaryBuyerEmailResp{
+		Emails: BuyerEmailsFromDB(emails),
+	}, nil
+}
+
+type RemoveBuyerEmailReq struct {
+	BuyerPk int64
+	EmailId string `json:"emailId"`
+}
+
+type RemoveBuyerEmailResp struct {
+	Emails []*BuyerEmail `json:"emails"`
+}
+
+//RemoveBuyerEmail removes an address from the buyer's account. the primary email has to be
+//replaced before it can be removed
+func (u *BuyerServer) RemoveBuyerEmail(ctx context.Context, req *RemoveBuyerEmailReq) (
+	resp *RemoveBuyerEmailResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.RemoveBuyerEmail")
+	defer end(&err)
+
+	var emails []*database.BuyerEmail
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		email, err := findBuyerEmail(ctx, tx, req.BuyerPk, req.EmailId)
+		if err != nil {
+			return err
+		}
+
+		if email.IsPrimary {
+			return errs.New("make another email primary before removing this one")
+		}
+
+		_, err = tx.Delete_BuyerEmail_By_Pk(ctx, database.BuyerEmail_Pk(email.Pk))
+		if err != nil {
+			return err
+		}
+
+		err = auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerEmailRemove,
+			auditFields{"emailId": email.Id}, nil)
+		if err != nil {
+			return err
+		}
+
+		emails, err = tx.All_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(req.BuyerPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoveBuyerEmailResp{
+		Emails: BuyerEmailsFromDB(emails),
+	}, nil
+}
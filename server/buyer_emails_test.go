@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuyerEmails(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	buyer := test.createFullTestBuyer(ctx)
+	primary := buyer.emails[0]
+
+	added, err := test.BuyerServer.AddBuyerEmail(ctx, &AddBuyerEmailReq{
+		BuyerPk: buyer.Pk,
+		Email:   "Second@Email.com",
+	})
+	require.NoError(t, err)
+	require.Equal(t, added.Email.Address, "second@email.com")
+	require.False(t, added.Email.Verified)
+	require.Equal(t, test.mailer.sent[len(test.mailer.sent)-1].To, "second@email.com")
+
+	//an unverified email cannot be used to log in or be made primary
+	_, err = test.BuyerServer.BuyerLogIn(ctx, &LogInRequest{
+		Email:    "second@email.com",
+		Password: defaultPassword,
+	})
+	require.Error(t, err)
+
+	set_primary := &SetPrimaryBuyerEmailReq{BuyerPk: buyer.Pk, EmailId: added.Email.Id}
+	_, err = test.BuyerServer.SetPrimaryBuyerEmail(ctx, set_primary)
+	require.Error(t, err)
+
+	//the token only works for the buyer it was sent to
+	token := test.mailedToken()
+	other_buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	_, err = test.BuyerServer.VerifyBuyerEmail(ctx, &VerifyBuyerEmailReq{
+		BuyerPk: other_buyer.Pk,
+		Token:   token,
+	})
+	require.True(t, NotFound.Has(err))
+
+	verified, err := test.BuyerServer.VerifyBuyerEmail(ctx, &VerifyBuyerEmailReq{
+		BuyerPk: buyer.Pk,
+		Token:   token,
+	})
+	require.NoError(t, err)
+	require.True(t, verified.Email.Verified)
+
+	//the new primary logs in with the same password and the old one no longer does
+	emails, err := test.BuyerServer.SetPrimaryBuyerEmail(ctx, set_primary)
+	require.NoError(t, err)
+	require.Len(t, emails.Emails, 2)
+	require.False(t, emails.Emails[0].Primary)
+	require.True(t, emails.Emails[1].Primary)
+
+	_, err = test.BuyerServer.BuyerLogIn(ctx, &LogInRequest{
+		Email:    "second@email.com",
+		Password: defaultPassword,
+	})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.BuyerLogIn(ctx, &LogInRequest{
+		Email:    primary.Address,
+		Password: defaultPassword,
+	})
+	require.Error(t, err)
+
+	//the primary cannot be removed but the others can
+	_, err = test.BuyerServer.RemoveBuyerEmail(ctx, &RemoveBuyerEmailReq{
+		BuyerPk: buyer.Pk,
+		EmailId: added.Email.Id,
+	})
+	require.Error(t, err)
+
+	removed, err := test.BuyerServer.RemoveBuyerEmail(ctx, &RemoveBuyerEmailReq{
+		BuyerPk: buyer.Pk,
+		EmailId: primary.Id,
+	})
+	require.NoError(t, err)
+	require.Len(t, removed.Emails, 1)
+}
+
+func TestAddBuyerEmailInUse(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	buyer := test.createFullTestBuyer(ctx)
+	squatter := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	_, err := test.BuyerServer.AddBuyerEmail(ctx, &AddBuyerEmailReq{
+		BuyerPk: squatter.Pk,
+		Email:   buyer.emails[0].Address,
+	})
+	require.EqualError(t, err, "that email is already in use")
+
+	_, err = test.BuyerServer.AddBuyerEmail(ctx, &AddBuyerEmailReq{
+		BuyerPk: squatter.Pk,
+		Email:   "claimed@email.com",
+	})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.AddBuyerEmail(ctx, &AddBuyerEmailReq{
+		BuyerPk: buyer.Pk,
+		Email:   "claimed@email.com",
+	})
+	require.EqualError(t, err, "that email is already in use")
+
+	//an address nobody verified is freed once the verification expires
+	test.at(time.Now().Add(-emailVerificationExpiry-time.Hour), func() {
+		_, err = test.BuyerServer.AddBuyerEmail(ctx, &AddBuyerEmailReq{
+			BuyerPk: squatter.Pk,
+			Email:   "stale@email.com",
+		})
+		require.NoError(t, err)
+	})
+
+	_, err = test.BuyerServer.AddBuyerEmail(ctx, &AddBuyerEmailReq{
+		BuyerPk: buyer.Pk,
+		Email:   "stale@email.com",
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+type ExportedProfile struct {
+	Id        string `json:"id"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+//ExportedSession leaves out the session id since it is what logs the buyer in
+type ExportedSession struct {
+	CreatedAt int64 `json:"createdAt"`
+}
+
+type ExportedTrial struct {
+	Id         string  `json:"id"`
+	ProductId  string  `json:"productId"`
+	TrialPrice float32 `json:"trialPrice"`
+	Returned   bool    `json:"returned"`
+	CreatedAt  int64   `json:"createdAt"`
+}
+
+type ExportedPurchase struct {
+	Id            string  `json:"id"`
+	ProductId     string  `json:"productId"`
+	PurchasePrice float32 `json:"purchasePrice"`
+	CreatedAt     int64   `json:"createdAt"`
+}
+
+type ExportedReview struct {
+	Id          string `json:"id"`
+	ProductId   string `json:"productId"`
+	Rating      int    `json:"rating"`
+	Description string `json:"description"`
+}
+
+type ExportedConversation struct {
+	Id        string     `json:"id"`
+	VendorId  string     `json:"vendorId"`
+	CreatedAt int64      `json:"createdAt"`
+	Messages  []*Message `json:"messages"`
+}
+
+//buyerExport is everything the marketplace keeps about a buyer. each field becomes one json file
+//in the exported zip
+type buyerExport struct {
+	profile       *ExportedProfile
+	emails        []*BuyerEmail
+	addresses     []*Address
+	sessions      []*ExportedSession
+	trials        []*ExportedTrial
+	purchases     []*ExportedPurchase
+	reviews       []*ExportedReview
+	conversations []*ExportedConversation
+}
+
+//exportIds looks up the public ids of the products and vendors a buyer's records point at. each
+//is only loaded once
+type exportIds struct {
+	products map[int64]string
+	vendors  map[int64]string
+}
+
+func (ids *exportIds) product(ctx context.Context, tx *database.Tx, pk int64) (string, error) {
+	if id, ok := ids.products[pk]; ok {
+		return id, nil
+	}
+
+	product, err := tx.Get_Product_By_Pk(ctx, database.Product_Pk(pk))
+	if err != nil {
+		return "", err
+	}
+
+	ids.products[pk] = product.Id
+	return product.Id, nil
+}
+
+func (ids *exportIds) vendor(ctx context.Context, tx *database.Tx, pk int64) (string, error) {
+	if id, ok := ids.vendors[pk]; ok {
+		return id, nil
+	}
+
+	vendor, err := tx.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(pk))
+	if err != nil {
+		return "", err
+	}
+
+	ids.vendors[pk] = vendor.Id
+	return vendor.Id, nil
+}
+
+func loadBuyerExport(ctx context.Context, tx *database.Tx, buyer_pk int64) (
+	*buyerExport, error) {
+
+	ids := &exportIds{products: map[int64]string{}, vendors: map[int64]string{}}
+	out := &buyerExport{
+		sessions:      []*ExportedSession{},
+		trials:        []*ExportedTrial{},
+		purchases:     []*ExportedPurchase{},
+		reviews:       []*ExportedReview{},
+		conversations: []*ExportedConversation{},
+	}
+
+	buyer, err := tx.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	out.profile = &ExportedProfile{
+		Id:        buyer.Id,
+		FirstName: buyer.FirstName,
+		LastName:  buyer.LastName,
+		CreatedAt: buyer.CreatedAt.Unix(),
+	}
+
+	emails, err := tx.All_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	out.emails = BuyerEmailsFromDB(emails)
+
+	addresses, err := tx.All_Address_By_BuyerPk_OrderBy_Asc_Pk(ctx,
+		database.Address_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	out.addresses = AddressesFromDB(addresses)
+
+	sessions, err := tx.All_BuyerSession_By_BuyerPk(ctx, database.BuyerSession_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		out.sessions = append(out.sessions, &ExportedSession{
+			CreatedAt: session.CreatedAt.Unix(),
+		})
+	}
+
+	trials, err := tx.All_TrialProduct_By_BuyerPk_OrderBy_Asc_CreatedAt(ctx,
+		database.TrialProduct_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	for _, trial := range trials {
+		product_id, err := ids.product(ctx, tx, trial.ProductPk)
+		if err != nil {
+			return nil, err
+		}
+		out.trials = append(out.trials, &ExportedTrial{
+			Id:         trial.Id,
+			ProductId:  product_id,
+			TrialPrice: trial.TrialPrice,
+			Returned:   trial.IsReturned,
+			CreatedAt:  trial.CreatedAt.Unix(),
+		})
+	}
+
+	purchases, err := tx.All_PurchasedProduct_By_BuyerPk_OrderBy_Asc_CreatedAt(ctx,
+		database.PurchasedProduct_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	for _, purchase := range purchases {
+		product_id, err := ids.product(ctx, tx, purchase.ProductPk)
+		if err != nil {
+			return nil, err
+		}
+		out.purchases = append(out.purchases, &ExportedPurchase{
+			Id:            purchase.Id,
+			ProductId:     product_id,
+			PurchasePrice: purchase.PurchasePrice,
+			CreatedAt:     purchase.CreatedAt.Unix(),
+		})
+	}
+
+	reviews, err := tx.All_ProductReview_By_BuyerPk(ctx, database.ProductReview_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	for _, review := range reviews {
+		product_id, err := ids.product(ctx, tx, review.ProductPk)
+		if err != nil {
+			return nil, err
+		}
+		out.reviews = append(out.reviews, &ExportedReview{
+			Id:          review.Id,
+			ProductId:   product_id,
+			Rating:      review.Rating,
+			Description: review.Description,
+		})
+	}
+
+	conversations, err := tx.All_Conversation_By_BuyerPk(ctx,
+		database.Conversation_BuyerPk(buyer_pk))
+	if err != nil {
+		return nil, err
+	}
+	for _, conversation := range conversations {
+		vendor_id, err := ids.vendor(ctx, tx, conversation.VendorPk)
+		if err != nil {
+			return nil, err
+		}
+
+		messages, err := tx.All_Message_By_ConversationPk(ctx,
+			database.Message_ConversationPk(conversation.Pk))
+		if err != nil {
+			return nil, err
+		}
+
+		out.conversations = append(out.conversations, &ExportedConversation{
+			Id:        conversation.Id,
+			VendorId:  vendor_id,
+			CreatedAt: conversation.CreatedAt.Unix(),
+			Messages:  MessagesWithReceiptsFromDB(conversation, messages),
+		})
+	}
+
+	return out, nil
+}
+
+//writeZip writes each file of the export as indented json
+func (e *buyerExport) writeZip(w io.Writer) error {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", e.profile},
+		{"emails.json", e.emails},
+		{"addresses.json", e.addresses},
+		{"sessions.json", e.sessions},
+		{"trials.json", e.trials},
+		{"purchases.json", e.purchases},
+		{"reviews.json", e.reviews},
+		{"conversations.json", e.conversations},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return errs.Wrap(err)
+		}
+
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+
+		_, err = f.Write(data)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	return errs.Wrap(archive.Close())
+}
+
+type ExportBuyerDataReq struct {
+	BuyerPk int64
+}
+
+//ExportBuyerData writes a zip of json files holding the buyer's profile, emails, addresses,
+//sessions, trials, purchases, reviews and conversations to w
+func (u *BuyerServer) ExportBuyerData(ctx context.Context, req *ExportBuyerDataReq,
+	w io.Writer) (err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.ExportBuyerData")
+	defer end(&err)
+
+	var export *buyerExport
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		export, err = loadBuyerExport(ctx, tx, req.BuyerPk)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return export.writeZip(w)
+}
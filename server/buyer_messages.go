@@ -12,6 +12,9 @@ type PostBuyerMessageToConversationReq struct {
 	BuyerPk            int64
 	VendorId           string `json:"vendorId"`
 	MessageDescription string `json:"messageDescription"`
+
+	Attachments []*AttachmentUpload `json:"attachments"`
+	References  *MessageReferences  `json:"references"`
 }
 
 type PostBuyerMessageToConversationResp struct {
@@ -21,7 +24,22 @@ type PostBuyerMessageToConversationResp struct {
 func (u *BuyerServer) PostBuyerMessageToConversation(ctx context.Context,
 	req *PostBuyerMessageToConversationReq) (resp *PostBuyerMessageToConversationResp, err error) {
 
-	var message *database.Message
+	ctx, end := startSpan(ctx, "BuyerServer.PostBuyerMessageToConversation")
+	defer end(&err)
+
+	err = ValidateAttachments(req.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict, err := checkMessage(ctx, u.filter, true, req.MessageDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversation *database.Conversation
+	var message *Message
+	var unread_count int64
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 
 		vendor_pk_field, err := tx.Get_Vendor_Pk_By_Id(ctx,
@@ -30,13 +48,29 @@ func (u *BuyerServer) PostBuyerMessageToConversation(ctx context.Context,
 			return err
 		}
 
-		conversation, err := tx.Find_Conversation_By_VendorPk_And_BuyerPk(ctx,
+		conversation, err = tx.Find_Conversation_By_VendorPk_And_BuyerPk(ctx,
 			database.Conversation_VendorPk(vendor_pk_field.Pk),
 			database.Conversation_BuyerPk(req.BuyerPk))
 		if err != nil {
 			return err
 		}
 
+		if conversation != nil {
+			conversation, err = lockConversation(ctx, tx, conversation.Pk)
+			if err != nil {
+				return err
+			}
+		}
+
+		if conversation != nil && conversation.BlockedByVendor {
+			return Blocked.New("the vendor has blocked this conversation")
+		}
+
+		err = checkBuyerSendRate(ctx, tx, req.BuyerPk, u.db.Hooks.Now())
+		if err != nil {
+			return err
+		}
+
 		//if buyer and vendor have not had a conversation before create new conversation
 		if conversation == nil {
 			conversation, err = tx.Create_Conversation(ctx,
@@ -45,14 +79,19 @@ func (u *BuyerServer) PostBuyerMessageToConversation(ctx context.Context,
 				database.Conversation_BuyerUnread(false),
 				database.Conversation_VendorUnread(true),
 				database.Conversation_MessageCount(1),
+				database.Conversation_BuyerLastRead(1),
+				database.Conversation_VendorLastRead(0),
+				database.Conversation_BlockedByBuyer(false),
+				database.Conversation_BlockedByVendor(false),
 				database.Conversation_Id(uuid.NewV4().String()))
 			if err != nil {
 				return err
 			}
 		} else {
 			conversation_updates := database.Conversation_Update_Fields{
-				MessageCount: database.Conversation_MessageCount(conversation.MessageCount + 1),
-				VendorUnread: database.Conversation_VendorUnread(true),
+				MessageCount:  database.Conversation_MessageCount(conversation.MessageCount + 1),
+				VendorUnread:  database.Conversation_VendorUnread(true),
+				BuyerLastRead: database.Conversation_BuyerLastRead(conversation.MessageCount + 1),
 			}
 
 			conversation, err = tx.Update_Conversation_By_Pk(ctx,
@@ -63,49 +102,108 @@ func (u *BuyerServer) PostBuyerMessageToConversation(ctx context.Context,
 			}
 		}
 
-		message, err = tx.Create_Message(ctx,
+		db_message, err := tx.Create_Message(ctx,
 			database.Message_Id(uuid.NewV4().String()),
 			database.Message_BuyerSent(true),
 			database.Message_Description(req.MessageDescription),
+			database.Message_SearchText(messageSearchText(req.MessageDescription)),
 			database.Message_ConversationPk(conversation.Pk),
 			database.Message_ConversationNumber(conversation.MessageCount))
 		if err != nil {
 			return err
 		}
 
-		return nil
+		err = attachToMessage(ctx, tx, u.blobs, conversation, db_message, req.Attachments,
+			req.References)
+		if err != nil {
+			return err
+		}
+
+		if verdict == FlagMessage {
+			err = flagConversation(ctx, tx, conversation)
+			if err != nil {
+				return err
+			}
+		}
+
+		messages, err := expandMessages(ctx, tx, conversation, []*database.Message{db_message})
+		if err != nil {
+			return err
+		}
+		message = messages[0]
+
+		unread_count, err = tx.Count_Conversation_By_VendorPk_And_VendorUnread_Equal_True(ctx,
+			database.Conversation_VendorPk(conversation.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		return enqueueWebhook(ctx, tx, conversation.VendorPk, MessageCreatedEvent,
+			&MessageCreatedWebhook{ConversationId: conversation.Id, Message: message})
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	messagesPosted.WithLabelValues("buyer").Inc()
+	publishNewMessage(ctx, u.hub,
+		BuyerTopic(conversation.BuyerPk), VendorTopic(conversation.VendorPk),
+		conversation, message, unread_count)
+
 	return &PostBuyerMessageToConversationResp{
-		Message: MessageFromDB(message),
+		Message: message,
 	}, nil
 }
 
 type PagedBuyerMessagesByConversationIdReq struct {
-	Offset         int64  `json:"offset"`
+	BuyerPk        int64
 	ConversationId string `json:"conversationId"`
+	Before         int64  `json:"before"`
+	After          int64  `json:"after"`
+	MarkRead       bool   `json:"markRead"`
 }
 
 type PagedBuyerMessagesByConversationIdResp struct {
-	Offset   int64      `json:"offset"`
-	Messages []*Message `json:"messages"`
+	MessagePage
 }
 
 func (u *BuyerServer) PagedBuyerMessagesByConversationId(ctx context.Context,
 	req *PagedBuyerMessagesByConversationIdReq) (resp *PagedBuyerMessagesByConversationIdResp, err error) {
 
-	var messages []*database.Message
+	ctx, end := startSpan(ctx, "BuyerServer.PagedBuyerMessagesByConversationId")
+	defer end(&err)
+
+	var before, conversation *database.Conversation
+	var db_messages []*database.Message
+	var messages []*Message
+	var unread_count int64
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
-		conversation, err := tx.Get_Conversation_By_Id(ctx, database.Conversation_Id(req.ConversationId))
+		before, err = authorizeBuyerConversation(ctx, tx, req.BuyerPk, req.ConversationId)
 		if err != nil {
 			return err
 		}
+		conversation = before
 
-		messages, err = tx.Limited_Message_By_ConversationPk_OrderBy_Desc_CreatedAt(ctx,
-			database.Message_ConversationPk(conversation.Pk), messageRequestLimit, req.Offset)
+		db_messages, err = pageMessages(ctx, tx, conversation, req.Before, req.After)
+		if err != nil {
+			return err
+		}
+
+		if req.MarkRead && len(db_messages) > 0 {
+			conversation, err = markBuyerConversationRead(ctx, tx, conversation,
+				newestConversationNumber(db_messages))
+			if err != nil {
+				return err
+			}
+
+			unread_count, err = tx.Count_Conversation_By_BuyerPk_And_BuyerUnread_Equal_True(ctx,
+				database.Conversation_BuyerPk(conversation.BuyerPk))
+			if err != nil {
+				return err
+			}
+		}
+
+		messages, err = expandMessages(ctx, tx, conversation, db_messages)
 		if err != nil {
 			return err
 		}
@@ -116,10 +214,162 @@ func (u *BuyerServer) PagedBuyerMessagesByConversationId(ctx context.Context,
 		return nil, err
 	}
 
-	offset := req.Offset + messageRequestLimit
+	if conversation.BuyerLastRead != before.BuyerLastRead {
+		publishRead(ctx, u.hub, BuyerTopic(conversation.BuyerPk),
+			VendorTopic(conversation.VendorPk), conversation.Id, conversation.BuyerLastRead,
+			unread_count)
+	}
+
+	page := MessagePage{Messages: messages}
+	page.Before, page.After = messagePageCursors(db_messages, req.Before, req.After)
 
 	return &PagedBuyerMessagesByConversationIdResp{
-		Messages: MessagesFromDB(messages),
-		Offset:   offset,
+		MessagePage: page,
+	}, nil
+}
+
+//SubscribeBuyerEvents subscribes to new messages and unread counts for a buyer. the caller must
+//close the subscription when the session ends
+func (u *BuyerServer) SubscribeBuyerEvents(buyer_pk int64) *Subscription {
+	return u.hub.Subscribe(BuyerTopic(buyer_pk))
+}
+
+type BuyerMessagesSinceReq struct {
+	BuyerPk            int64
+	ConversationId     string `json:"conversationId"`
+	ConversationNumber int64  `json:"conversationNumber"`
+}
+
+type BuyerMessagesSinceResp struct {
+	Messages []*Message `json:"messages"`
+}
+
+//BuyerMessagesSince returns the messages in a conversation after the last conversation number a
+//client has seen. it is used to catch up after reconnecting to the event stream
+func (u *BuyerServer) BuyerMessagesSince(ctx context.Context, req *BuyerMessagesSinceReq) (
+	resp *BuyerMessagesSinceResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.BuyerMessagesSince")
+	defer end(&err)
+
+	var messages []*Message
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		conversation, err := authorizeBuyerConversation(ctx, tx, req.BuyerPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		db_messages, err := tx.All_Message_By_ConversationPk_And_ConversationNumber_Greater_OrderBy_Asc_ConversationNumber(
+			ctx, database.Message_ConversationPk(conversation.Pk),
+			database.Message_ConversationNumber(req.ConversationNumber))
+		if err != nil {
+			return err
+		}
+
+		messages, err = expandMessages(ctx, tx, conversation, db_messages)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BuyerMessagesSinceResp{
+		Messages: messages,
+	}, nil
+}
+
+type SearchBuyerMessagesReq struct {
+	BuyerPk int64
+	Query   string `json:"query"`
+	Offset  int64  `json:"offset"`
+}
+
+type SearchBuyerMessagesResp struct {
+	Hits   []*MessageSearchHit `json:"hits"`
+	Offset int64               `json:"offset"`
+}
+
+//SearchBuyerMessages finds messages containing the query across all of the buyer's conversations,
+//newest first
+func (u *BuyerServer) SearchBuyerMessages(ctx context.Context, req *SearchBuyerMessagesReq) (
+	resp *SearchBuyerMessagesResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.SearchBuyerMessages")
+	defer end(&err)
+
+	words, err := messageSearchWords(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []*MessageSearchHit
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		db_messages, err := tx.SearchBuyerMessages(ctx, req.BuyerPk, words, searchRequestLimit,
+			req.Offset)
+		if err != nil {
+			return err
+		}
+
+		hits, err = searchHits(ctx, tx, db_messages)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchBuyerMessagesResp{
+		Hits:   hits,
+		Offset: req.Offset + searchRequestLimit,
+	}, nil
+}
+
+type BuyerMessageAttachmentReq struct {
+	BuyerPk      int64
+	AttachmentId string `json:"attachmentId"`
+}
+
+//GetBuyerMessageAttachment returns the contents of an attachment in one of the buyer's
+//conversations
+func (u *BuyerServer) GetBuyerMessageAttachment(ctx context.Context,
+	req *BuyerMessageAttachmentReq) (resp *MessageAttachmentResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.GetBuyerMessageAttachment")
+	defer end(&err)
+
+	var attachment *database.MessageAttachment
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		var conversation *database.Conversation
+		attachment, conversation, err = loadAttachment(ctx, tx, req.AttachmentId)
+		if err != nil {
+			return err
+		}
+
+		if conversation.BuyerPk != req.BuyerPk {
+			return NotFound.New("attachment not found")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := u.blobs.Get(ctx, attachment.BlobKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageAttachmentResp{
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Data:        data,
 	}, nil
 }
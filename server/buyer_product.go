@@ -22,6 +22,9 @@ type UpdateProductReviewResp struct {
 func (u *BuyerServer) UpdateProductReview(ctx context.Context, req *UpdateProductReviewReq) (
 	resp *UpdateProductReviewResp, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.UpdateProductReview")
+	defer end(&err)
+
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 		product_review, err := tx.Find_ProductReview_By_Product_Id_And_ProductReview_BuyerPk(ctx,
 			database.Product_Id(req.ProductId),
@@ -35,7 +38,7 @@ func (u *BuyerServer) UpdateProductReview(ctx context.Context, req *UpdateProduc
 		}
 
 		err = tx.UpdateNoReturn_ProductReview_By_Pk(ctx,
-			database.ProductReview_Pk(product_review.ProductPk),
+			database.ProductReview_Pk(product_review.Pk),
 			database.ProductReview_Update_Fields{
 				Rating:      database.ProductReview_Rating(req.Stars),
 				Description: database.ProductReview_Description(req.Description)})
@@ -43,7 +46,7 @@ func (u *BuyerServer) UpdateProductReview(ctx context.Context, req *UpdateProduc
 			return err
 		}
 
-		return nil
+		return updateProductRating(ctx, tx, product_review.ProductPk)
 	})
 	if err != nil {
 		return nil, err
@@ -66,6 +69,9 @@ type ProductReviewResp struct {
 func (u *BuyerServer) ReviewProduct(ctx context.Context, req *ProductReviewReq) (
 	resp *ProductReviewResp, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.ReviewProduct")
+	defer end(&err)
+
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 		has_purchased, err := tx.Has_PurchasedProduct_By_BuyerPk(ctx,
 			database.PurchasedProduct_BuyerPk(req.BuyerPk))
@@ -103,7 +109,7 @@ func (u *BuyerServer) ReviewProduct(ctx context.Context, req *ProductReviewReq)
 			return err
 		}
 
-		return nil
+		return updateProductRating(ctx, tx, product_pk_field.Pk)
 	})
 	if err != nil {
 		return nil, err
@@ -116,6 +122,12 @@ type StartProductTrialReq struct {
 	BuyerPk   int64
 	VendorId  string `json:"vendorId"`
 	ProductId string `json:"productId"`
+
+	//VariantId picks which variant to try. it is required when the product has variants
+	VariantId string `json:"variantId"`
+
+	//ShippingAddressId defaults to the buyer's default shipping address
+	ShippingAddressId string `json:"shippingAddressId"`
 }
 
 type StartProductTrialResp struct {
@@ -125,6 +137,9 @@ type StartProductTrialResp struct {
 func (u *BuyerServer) StartProductTrial(ctx context.Context, req *StartProductTrialReq) (
 	resp *StartProductTrialResp, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.StartProductTrial")
+	defer end(&err)
+
 	var trial_product *database.TrialProduct
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 
@@ -134,8 +149,17 @@ func (u *BuyerServer) StartProductTrial(ctx context.Context, req *StartProductTr
 			return err
 		}
 
-		product, err := tx.Get_Product_Pk_Product_Price_By_Id(ctx,
-			database.Product_Id(req.ProductId))
+		product, err := tx.Get_Product_By_Id(ctx, database.Product_Id(req.ProductId))
+		if err != nil {
+			return err
+		}
+
+		variant_pk, price, err := trialVariant(ctx, tx, product, req.VariantId)
+		if err != nil {
+			return err
+		}
+
+		address_pk, err := trialShippingAddress(ctx, tx, req.BuyerPk, req.ShippingAddressId)
 		if err != nil {
 			return err
 		}
@@ -145,17 +169,56 @@ func (u *BuyerServer) StartProductTrial(ctx context.Context, req *StartProductTr
 			database.TrialProduct_VendorPk(vendor_pk_field.Pk),
 			database.TrialProduct_BuyerPk(req.BuyerPk),
 			database.TrialProduct_ProductPk(product.Pk),
-			database.TrialProduct_TrialPrice(product.Price),
+			database.TrialProduct_VariantPk(variant_pk),
+			database.TrialProduct_TrialPrice(price),
 			database.TrialProduct_IsReturned(false),
+			database.TrialProduct_ShippingAddressPk(address_pk),
 		)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return enqueueWebhook(ctx, tx, vendor_pk_field.Pk, TrialStartedEvent,
+			&TrialStartedWebhook{
+				ProductId: product.Id,
+				VariantId: req.VariantId,
+				Trial:     TrialFromDB(trial_product),
+			})
 	})
+	if err != nil {
+		return nil, err
+	}
+	trialsStarted.Inc()
 
 	return &StartProductTrialResp{
 		TrialProduct: TrialFromDB(trial_product),
 	}, nil
 }
+
+//trialVariant finds the variant of product a trial is for and the price to charge. products
+//without variants are tried as they are and have no variant pk
+func trialVariant(ctx context.Context, tx *database.Tx, product *database.Product,
+	variant_id string) (variant_pk int64, price float32, err error) {
+
+	if len(decodeAttributeNames(product.Attributes)) == 0 {
+		if variant_id != "" {
+			return 0, 0, NotFound.New("variant not found")
+		}
+		return 0, product.Price, nil
+	}
+
+	if variant_id == "" {
+		return 0, 0, InvalidVariant.New("choose which variant of the product to try")
+	}
+
+	variant, err := tx.Find_ProductVariant_By_Id(ctx, database.ProductVariant_Id(variant_id))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if variant == nil || variant.ProductPk != product.Pk {
+		return 0, 0, NotFound.New("variant not found")
+	}
+
+	return variant.Pk, variant.Price, nil
+}
@@ -2,14 +2,11 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"reflect"
 	"strings"
 
 	uuid "github.com/satori/go.uuid"
-	"github.com/sirupsen/logrus"
 	"github.com/zeebo/errs"
-	"gopkg.in/spacemonkeygo/dbx.v1/prettyprint"
 
 	"ladybug/database"
 	"ladybug/validate"
@@ -21,7 +18,7 @@ type SignUpRequest struct {
 	Password        string            `json:"password"`
 	Email           string            `json:"email"`
 	BillingAddress  *validate.Address `json:"billingAddress"`
-	ShippingAddress *validate.Address `json"shippingAddress"`
+	ShippingAddress *validate.Address `json:"shippingAddress"`
 }
 
 type SignUpResponse struct {
@@ -31,8 +28,9 @@ type SignUpResponse struct {
 func (u *BuyerServer) BuyerSignUp(ctx context.Context, req *SignUpRequest) (resp *SignUpResponse,
 	err error) {
 
-	fmt.Println("entered sign up server")
-	prettyprint.Println(req)
+	ctx, end := startSpan(ctx, "BuyerServer.BuyerSignUp")
+	defer end(&err)
+
 	err = ValidateBuyerSignUpRequest(req)
 	if err != nil {
 		return nil, err
@@ -47,50 +45,66 @@ func (u *BuyerServer) BuyerSignUp(ctx context.Context, req *SignUpRequest) (resp
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
 
 		buyer, err := tx.Create_Buyer(ctx, database.Buyer_Id(uuid.NewV4().String()),
-			database.Buyer_FirstName(req.FirstName), database.Buyer_LastName(req.LastName))
+			database.Buyer_FirstName(req.FirstName), database.Buyer_LastName(req.LastName),
+			database.Buyer_SaltedHash(hash))
+		if err != nil {
+			return err
+		}
+
+		err = claimBuyerEmail(ctx, tx, strings.ToLower(req.Email))
 		if err != nil {
-			fmt.Println("error is here")
 			return err
 		}
 
-		fmt.Println("BLAH")
 		err = tx.CreateNoReturn_BuyerEmail(ctx,
 			database.BuyerEmail_BuyerPk(buyer.Pk),
 			database.BuyerEmail_Address(strings.ToLower(req.Email)),
-			database.BuyerEmail_SaltedHash(hash),
 			database.BuyerEmail_Id(uuid.NewV4().String()),
+			database.BuyerEmail_IsPrimary(true),
+			database.BuyerEmail_Verified(true),
+			database.BuyerEmail_VerificationHash(""),
 		)
 		if database.IsConstraintViolationError(err) {
-			logrus.Error(err)
 			return errs.New("that email is already in use")
 		}
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("BLAH1")
+		//the billing address is shipped to as well unless a separate shipping address was given
+		has_shipping := !validate.AddressIsEmpty(req.ShippingAddress)
+
 		err = tx.CreateNoReturn_Address(ctx, database.Address_BuyerPk(buyer.Pk),
-			database.Address_StreetAddress(req.BillingAddress.StreetAddress),
+			database.Address_CountryCode(req.BillingAddress.CountryCode),
+			database.Address_Line1(req.BillingAddress.Line1),
+			database.Address_Line2(req.BillingAddress.Line2),
 			database.Address_City(req.BillingAddress.City),
-			database.Address_State(req.BillingAddress.State),
-			database.Address_Zip(req.BillingAddress.Zip),
+			database.Address_Region(req.BillingAddress.Region),
+			database.Address_PostalCode(req.BillingAddress.PostalCode),
 			database.Address_IsBilling(true),
+			database.Address_IsDefaultBilling(true),
+			database.Address_IsDefaultShipping(!has_shipping),
 			database.Address_Id(uuid.NewV4().String()))
 		if err != nil {
 			return err
 		}
-		fmt.Println("BLAH2")
 
-		if !validate.AddressIsEmpty(req.ShippingAddress) {
+		if has_shipping {
 			err = tx.CreateNoReturn_Address(ctx, database.Address_BuyerPk(buyer.Pk),
-				database.Address_StreetAddress(req.ShippingAddress.StreetAddress),
+				database.Address_CountryCode(req.ShippingAddress.CountryCode),
+				database.Address_Line1(req.ShippingAddress.Line1),
+				database.Address_Line2(req.ShippingAddress.Line2),
 				database.Address_City(req.ShippingAddress.City),
-				database.Address_State(req.ShippingAddress.State),
-				database.Address_Zip(req.ShippingAddress.Zip),
+				database.Address_Region(req.ShippingAddress.Region),
+				database.Address_PostalCode(req.ShippingAddress.PostalCode),
 				database.Address_IsBilling(false),
+				database.Address_IsDefaultBilling(false),
+				database.Address_IsDefaultShipping(true),
 				database.Address_Id(uuid.NewV4().String()))
+			if err != nil {
+				return err
+			}
 		}
-		fmt.Println("BLAH3")
 
 		session, err = tx.Create_BuyerSession(ctx, database.BuyerSession_BuyerPk(buyer.Pk),
 			database.BuyerSession_Id(uuid.NewV4().String()))
@@ -98,11 +112,12 @@ func (u *BuyerServer) BuyerSignUp(ctx context.Context, req *SignUpRequest) (resp
 			return err
 		}
 
-		return nil
+		return auditBuyer(ctx, tx, buyer.Pk, AuditBuyerSignUp, nil, nil)
 	})
 	if err != nil {
 		return nil, err
 	}
+	signUps.WithLabelValues("buyer").Inc()
 
 	return &SignUpResponse{Session: session}, nil
 }
@@ -125,6 +140,10 @@ func ValidateBuyerSignUpRequest(sur *SignUpRequest) error {
 		return err
 	}
 
+	//addresses are checked and stored in their normalized form
+	validate.NormalizeAddress(sur.BillingAddress)
+	validate.NormalizeAddress(sur.ShippingAddress)
+
 	if err := validate.CheckAddress(sur.BillingAddress); err != nil {
 		return err
 	}
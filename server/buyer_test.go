@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"strconv"
 	"testing"
+	"time"
 
 	"ladybug/database"
 	"ladybug/validate"
@@ -133,6 +134,285 @@ func TestIncrementConversationCount(t *testing.T) {
 	require.Equal(t, resp.Message.MessageNumber, int64(2))
 }
 
+func TestPostBuyerMessagePublishesEvents(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	buyer_sub := test.BuyerServer.SubscribeBuyerEvents(buyer.Pk)
+	defer buyer_sub.Close()
+	vendor_sub, err := test.VendorServer.SubscribeVendorEvents(ctx, &SubscribeVendorEventsReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	})
+	require.NoError(t, err)
+	defer vendor_sub.Close()
+
+	req := &PostBuyerMessageToConversationReq{
+		BuyerPk:            buyer.Pk,
+		VendorId:           vendor.Id,
+		MessageDescription: "is anybody out there?",
+	}
+	resp, err := test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.NoError(t, err)
+
+	//the buyer's other sessions see their own message
+	event := <-buyer_sub.Events
+	require.Equal(t, event.Type, MessageEvent)
+	require.Equal(t, event.Message.Id, resp.Message.Id)
+
+	//the vendor sees the message and their new unread count
+	event = <-vendor_sub.Events
+	require.Equal(t, event.Type, MessageEvent)
+	require.Equal(t, event.MessageNumber, int64(1))
+	require.Equal(t, event.Message.Description, req.MessageDescription)
+
+	event = <-vendor_sub.Events
+	require.Equal(t, event.Type, UnreadEvent)
+	require.Equal(t, event.UnreadCount, int64(1))
+	require.Len(t, buyer_sub.Events, 0)
+}
+
+func TestBuyerMessagesSince(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	for i := 0; i < 5; i++ {
+		_, err := test.BuyerServer.PostBuyerMessageToConversation(ctx,
+			&PostBuyerMessageToConversationReq{
+				BuyerPk:            buyer.Pk,
+				VendorId:           vendor.Id,
+				MessageDescription: "knock knock",
+			})
+		require.NoError(t, err)
+	}
+	conversation := test.getConversation(ctx, vendor.Pk, buyer.Pk)
+
+	//resume after the third message
+	req := &BuyerMessagesSinceReq{
+		BuyerPk:            buyer.Pk,
+		ConversationId:     conversation.Id,
+		ConversationNumber: 3,
+	}
+	resp, err := test.BuyerServer.BuyerMessagesSince(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Messages, 2)
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(4))
+	require.Equal(t, resp.Messages[1].MessageNumber, int64(5))
+
+	//another buyer cannot resume someone else's conversation
+	req.BuyerPk = test.createBuyer(ctx, &createBuyerInDBOptions{}).Pk
+	_, err = test.BuyerServer.BuyerMessagesSince(ctx, req)
+	require.EqualError(t, err, "conversation not found")
+}
+
+func TestMarkBuyerConversationRead(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	for i := 0; i < 4; i++ {
+		_, err := test.VendorServer.PostVendorMessageToConversation(ctx,
+			&PostVendorMessageToConversationReq{
+				VendorPk:           vendor.Pk,
+				ExecutiveContactPk: owner.Pk,
+				BuyerId:            buyer.Id,
+				MessageDescription: "buy my stuff",
+			})
+		require.NoError(t, err)
+	}
+	conversation := test.getConversation(ctx, vendor.Pk, buyer.Pk)
+	require.True(t, conversation.BuyerUnread)
+
+	//read part of the conversation
+	req := &MarkBuyerConversationReadReq{
+		BuyerPk:            buyer.Pk,
+		ConversationId:     conversation.Id,
+		ConversationNumber: 3,
+	}
+	resp, err := test.BuyerServer.MarkBuyerConversationRead(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, resp.LastRead, int64(3))
+	require.Equal(t, resp.UnreadCount, int64(1))
+	require.True(t, test.getConversation(ctx, vendor.Pk, buyer.Pk).BuyerUnread)
+
+	//the read pointer never moves backwards
+	req.ConversationNumber = 1
+	resp, err = test.BuyerServer.MarkBuyerConversationRead(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, resp.LastRead, int64(3))
+
+	//reading past the end stops at the last message and clears the unread flag
+	req.ConversationNumber = 100
+	resp, err = test.BuyerServer.MarkBuyerConversationRead(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, resp.LastRead, int64(4))
+	require.Equal(t, resp.UnreadCount, int64(0))
+	require.False(t, test.getConversation(ctx, vendor.Pk, buyer.Pk).BuyerUnread)
+
+	//the vendor's messages now show as read
+	paged, err := test.VendorServer.PagedVendorMessagesByConversationId(ctx,
+		&PagedVendorMessagesByConversationIdReq{
+			VendorPk:           vendor.Pk,
+			ExecutiveContactPk: owner.Pk,
+			ConversationId:     conversation.Id,
+		})
+	require.NoError(t, err)
+	for _, m := range paged.Messages {
+		require.True(t, m.Read)
+	}
+}
+
+func TestGetBuyerUnreadCounts(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendors := test.createVendorsInDB(ctx, 3)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	for i, v := range vendors {
+		v_owner := test.createExecutiveContact(ctx, v.Pk, OwnerRole)
+		for j := 0; j <= i; j++ {
+			_, err := test.VendorServer.PostVendorMessageToConversation(ctx,
+				&PostVendorMessageToConversationReq{
+					VendorPk:           v.Pk,
+					ExecutiveContactPk: v_owner.Pk,
+					BuyerId:            buyer.Id,
+					MessageDescription: "hello?",
+				})
+			require.NoError(t, err)
+		}
+	}
+
+	counts_req := &BuyerUnreadCountsReq{BuyerPk: buyer.Pk}
+	resp, err := test.BuyerServer.GetBuyerUnreadCounts(ctx, counts_req)
+	require.NoError(t, err)
+	require.Len(t, resp.Conversations, 3)
+	require.Equal(t, resp.TotalUnread, int64(6))
+
+	//paging through messages with markRead clears the conversation
+	conversation := test.getConversation(ctx, vendors[2].Pk, buyer.Pk)
+	_, err = test.BuyerServer.PagedBuyerMessagesByConversationId(ctx,
+		&PagedBuyerMessagesByConversationIdReq{
+			BuyerPk:        buyer.Pk,
+			ConversationId: conversation.Id,
+			MarkRead:       true,
+		})
+	require.NoError(t, err)
+
+	resp, err = test.BuyerServer.GetBuyerUnreadCounts(ctx, counts_req)
+	require.NoError(t, err)
+	require.Len(t, resp.Conversations, 2)
+	require.Equal(t, resp.TotalUnread, int64(3))
+}
+
+func TestPostBuyerMessageWithAttachmentAndReferences(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	product := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+	order := test.purchaseProduct(ctx, buyer.Pk, vendor.Pk, product)
+	pdf := []byte("%PDF-1.4 the receipt")
+
+	req := &PostBuyerMessageToConversationReq{
+		BuyerPk:            buyer.Pk,
+		VendorId:           vendor.Id,
+		MessageDescription: "this arrived broken, receipt attached",
+		Attachments: []*AttachmentUpload{
+			{Filename: "receipt.pdf", Data: pdf},
+		},
+		References: &MessageReferences{
+			ProductId: product.Id,
+			OrderId:   order.Id,
+		},
+	}
+	resp, err := test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Message.Attachments, 1)
+	require.Equal(t, resp.Message.Attachments[0].ContentType, "application/pdf")
+	require.Len(t, resp.Message.References, 2)
+	require.Equal(t, resp.Message.References[0].Kind, productReference)
+	require.Equal(t, resp.Message.References[1].Kind, orderReference)
+	require.Equal(t, resp.Message.References[1].Price, order.PurchasePrice)
+	require.Equal(t, resp.Message.References[1].ProductId, product.Id)
+
+	//the vendor can download the attachment
+	attachment, err := test.VendorServer.GetVendorMessageAttachment(ctx,
+		&VendorMessageAttachmentReq{
+			VendorPk:           vendor.Pk,
+			ExecutiveContactPk: owner.Pk,
+			AttachmentId:       resp.Message.Attachments[0].Id,
+		})
+	require.NoError(t, err)
+	require.Equal(t, attachment.Data, pdf)
+	require.Equal(t, attachment.Filename, "receipt.pdf")
+
+	//attachments and references come back when paging
+	conversation := test.getConversation(ctx, vendor.Pk, buyer.Pk)
+	paged, err := test.VendorServer.PagedVendorMessagesByConversationId(ctx,
+		&PagedVendorMessagesByConversationIdReq{
+			VendorPk:           vendor.Pk,
+			ExecutiveContactPk: owner.Pk,
+			ConversationId:     conversation.Id,
+		})
+	require.NoError(t, err)
+	require.Len(t, paged.Messages[0].Attachments, 1)
+	require.Len(t, paged.Messages[0].References, 2)
+}
+
+func TestPostBuyerMessageRejectsBadAttachmentsAndReferences(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	other_vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	other_product := test.createActiveAndApprovedProductInStock(ctx, other_vendor.Pk)
+	req := &PostBuyerMessageToConversationReq{
+		BuyerPk:            buyer.Pk,
+		VendorId:           vendor.Id,
+		MessageDescription: "look at this",
+	}
+
+	//html is not an allowed type
+	req.Attachments = []*AttachmentUpload{{Filename: "a.html", Data: []byte("<html></html>")}}
+	_, err := test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, InvalidAttachment.Has(err))
+	require.Contains(t, err.Error(), `attachment "a.html" must be a jpeg, png, gif or pdf`)
+
+	//too large
+	big := append([]byte("%PDF-"), make([]byte, maxAttachmentBytes)...)
+	req.Attachments = []*AttachmentUpload{{Filename: "big.pdf", Data: big}}
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, InvalidAttachment.Has(err))
+
+	//a product from a different vendor cannot be referenced
+	req.Attachments = nil
+	req.References = &MessageReferences{ProductId: other_product.Id}
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, NotFound.Has(err))
+}
+
 func TestGetBuyerMessagesByConversationId(t *testing.T) {
 	test := newTest(t)
 	defer test.tearDown()
@@ -144,34 +424,116 @@ func TestGetBuyerMessagesByConversationId(t *testing.T) {
 	conversation := test.createConversationInDB(buyer, vendor)
 	test.createMessageHistory(ctx, conversation, 210)
 
-	//offset 0
+	//newest page
 	req := &PagedBuyerMessagesByConversationIdReq{
-		Offset:         int64(0),
+		BuyerPk:        buyer.Pk,
 		ConversationId: conversation.Id,
 	}
 	resp, err := test.BuyerServer.PagedBuyerMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, len(resp.Messages), messageRequestLimit)
-	require.Equal(t, resp.Offset, int64(messageRequestLimit))
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(210))
+	require.Equal(t, resp.After, int64(210))
+	require.Equal(t, resp.Before, int64(210-messageRequestLimit+1))
 
-	//subsequent request with new offset
-	req.Offset = resp.Offset
+	//older page picks up where the last one ended even when new messages arrive in between
+	test.createMessageHistory(ctx, conversation, 2)
+	req.Before = resp.Before
 	resp, err = test.BuyerServer.PagedBuyerMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, len(resp.Messages), messageRequestLimit)
-	require.Equal(t, resp.Offset, int64(messageRequestLimit*2))
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(210-messageRequestLimit))
+	require.Equal(t, resp.Before, int64(210-messageRequestLimit*2+1))
 
-	//offset exceeds number of messages in history
-	req.Offset = int64(1000)
+	//oldest page is short and has no before cursor
+	req.Before = 11
 	resp, err = test.BuyerServer.PagedBuyerMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
-	require.Equal(t, len(resp.Messages), 0) //no messages because offest exceeds message history
+	require.Equal(t, len(resp.Messages), 10)
+	require.Equal(t, resp.Before, int64(0))
 
-	//offset result is less than messageRequestLimit
-	req.Offset = 200
+	//newer page returns the messages after the cursor, newest first
+	req.Before = 0
+	req.After = 210
 	resp, err = test.BuyerServer.PagedBuyerMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
-	require.Equal(t, len(resp.Messages), 10)
+	require.Equal(t, len(resp.Messages), 2)
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(212))
+	require.Equal(t, resp.Messages[1].MessageNumber, int64(211))
+	require.Equal(t, resp.After, int64(212))
+
+	//nothing newer
+	req.After = resp.After
+	resp, err = test.BuyerServer.PagedBuyerMessagesByConversationId(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, len(resp.Messages), 0)
+	require.Equal(t, resp.After, int64(212))
+
+	//both cursors
+	req.Before = 5
+	_, err = test.BuyerServer.PagedBuyerMessagesByConversationId(ctx, req)
+	require.True(t, InvalidPage.Has(err))
+}
+
+func TestSearchBuyerMessages(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	vendor := test.createVendorInDB(ctx)
+	conversation := test.createConversationInDB(buyer, vendor)
+	test.createMessageHistory(ctx, conversation, 4)
+	test.createNewMessage(ctx, conversation, &newMessageOptions{
+		Description: "Your Blue Lamp shipped today",
+	})
+	test.createMessageHistory(ctx, conversation, 4)
+
+	other_vendor := test.createVendorInDB(ctx)
+	other_conversation := test.createConversationInDB(buyer, other_vendor)
+	test.createNewMessage(ctx, other_conversation, &newMessageOptions{
+		BuyerSent:   true,
+		Description: "is the blue lamp in stock?",
+	})
+
+	other_buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	strangers := test.createConversationInDB(other_buyer, vendor)
+	test.createNewMessage(ctx, strangers, &newMessageOptions{Description: "blue lamp sale"})
+
+	//matches are case insensitive, newest first and only from the buyer's conversations
+	resp, err := test.BuyerServer.SearchBuyerMessages(ctx, &SearchBuyerMessagesReq{
+		BuyerPk: buyer.Pk,
+		Query:   "BLUE lamp",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Hits, 2)
+	require.Equal(t, resp.Hits[0].ConversationId, other_conversation.Id)
+	require.Equal(t, resp.Hits[0].Message.Description, "is the blue lamp in stock?")
+	require.Len(t, resp.Hits[0].Context, 1)
+
+	//the hit comes with the messages around it
+	hit := resp.Hits[1]
+	require.Equal(t, hit.ConversationId, conversation.Id)
+	require.Equal(t, hit.Message.MessageNumber, int64(5))
+	require.Len(t, hit.Context, 2*searchContextMessages+1)
+	require.Equal(t, hit.Context[0].MessageNumber, int64(3))
+	require.Equal(t, hit.Context[len(hit.Context)-1].MessageNumber, int64(7))
+
+	//words match with anything between them
+	resp, err = test.BuyerServer.SearchBuyerMessages(ctx, &SearchBuyerMessagesReq{
+		BuyerPk: buyer.Pk,
+		Query:   "lamp today",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Hits, 1)
+
+	//like wildcards are not passed through
+	_, err = test.BuyerServer.SearchBuyerMessages(ctx, &SearchBuyerMessagesReq{
+		BuyerPk: buyer.Pk,
+		Query:   " %_ ",
+	})
+	require.True(t, InvalidSearch.Has(err))
 }
 
 func TestGetBuyerConversationsUnread(t *testing.T) {
@@ -447,25 +809,30 @@ func TestBuyerAddress(t *testing.T) {
 	//missing street address
 	req.BillingAddress = &validate.Address{}
 	_, err := test.BuyerServer.BuyerSignUp(ctx, req)
-	require.EqualError(t, err, "city, state, street, or zip fields are blank for billing address")
+	require.EqualError(t, err, "address line 1 is required")
 
 	//missing city
-	req.BillingAddress.StreetAddress = "21 heartbreak ln"
+	req.BillingAddress.Line1 = "21 heartbreak ln"
 	_, err = test.BuyerServer.BuyerSignUp(ctx, req)
-	require.EqualError(t, err, "city, state, street, or zip fields are blank for billing address")
+	require.EqualError(t, err, "city is required")
 
 	//missing State
 	req.BillingAddress.City = "Paris"
 	_, err = test.BuyerServer.BuyerSignUp(ctx, req)
-	require.EqualError(t, err, "city, state, street, or zip fields are blank for billing address")
+	require.EqualError(t, err, "state is required")
 
 	//missing Zip
-	req.BillingAddress.State = "FL"
+	req.BillingAddress.Region = "FL"
+	_, err = test.BuyerServer.BuyerSignUp(ctx, req)
+	require.EqualError(t, err, "zip code is required")
+
+	//zip codes keep their leading zeros
+	req.BillingAddress.PostalCode = "2110"
 	_, err = test.BuyerServer.BuyerSignUp(ctx, req)
-	require.EqualError(t, err, "city, state, street, or zip fields are blank for billing address")
+	require.EqualError(t, err, "2110 is not a valid zip code")
 
 	//valide address Zip
-	req.BillingAddress.Zip = 98563
+	req.BillingAddress.PostalCode = "02110"
 	_, err = test.BuyerServer.BuyerSignUp(ctx, req)
 	require.NoError(t, err)
 
@@ -541,14 +908,16 @@ func (s *serverTest) compareSignUpWithDatabase(ctx context.Context, buyer_pk int
 	require.Equal(s.t, emails[0].Address, req.Email)
 
 	//password matches
-	require.NoError(s.t, comparePasswordHash(req.Password, emails[0].SaltedHash))
+	require.NoError(s.t, comparePasswordHash(req.Password, buyer.SaltedHash))
+	require.True(s.t, emails[0].IsPrimary)
 
 	//Addresses
 	billing_adds, err := s.db.All_Address_By_IsBilling_Equal_True_And_BuyerPk(
 		ctx, database.Address_BuyerPk(buyer_pk))
 	require.NoError(s.t, err)
 	require.Equal(s.t, len(billing_adds), 1)
-	require.Equal(s.t, billing_adds[0].StreetAddress, req.BillingAddress.StreetAddress)
+	require.Equal(s.t, billing_adds[0].Line1, req.BillingAddress.Line1)
+	require.True(s.t, billing_adds[0].IsDefaultBilling)
 
 	var shipping_adds []*database.Address
 	if !validate.AddressIsEmpty(req.ShippingAddress) {
@@ -556,7 +925,8 @@ func (s *serverTest) compareSignUpWithDatabase(ctx context.Context, buyer_pk int
 			ctx, database.Address_BuyerPk(buyer_pk))
 		require.NoError(s.t, err)
 		require.Equal(s.t, len(shipping_adds), 1)
-		require.Equal(s.t, shipping_adds[0].StreetAddress, req.ShippingAddress.StreetAddress)
+		require.Equal(s.t, shipping_adds[0].Line1, req.ShippingAddress.Line1)
+		require.True(s.t, shipping_adds[0].IsDefaultShipping)
 	}
 
 	session, err := s.db.Get_BuyerSession_By_BuyerPk(ctx, database.BuyerSession_BuyerPk(buyer.Pk))
@@ -586,16 +956,18 @@ func getCompleteSignUpRequest() *SignUpRequest {
 		Password:  defaultPassword,
 		Email:     "joey@calzone.com",
 		BillingAddress: &validate.Address{
-			StreetAddress: "21 heartbreak ln",
-			City:          "Paris",
-			State:         "Florida",
-			Zip:           87569,
+			CountryCode: "US",
+			Line1:       "21 heartbreak ln",
+			City:        "Paris",
+			Region:      "Florida",
+			PostalCode:  "87569",
 		},
 		ShippingAddress: &validate.Address{
-			StreetAddress: "P.O. Box 32",
-			City:          "Paris",
-			State:         "Florida",
-			Zip:           87569,
+			CountryCode: "US",
+			Line1:       "P.O. Box 32",
+			City:        "Paris",
+			Region:      "Florida",
+			PostalCode:  "87569",
 		},
 	}
 }
@@ -621,6 +993,10 @@ func (s *serverTest) createConversationInDB(buyer *database.Buyer, vendor *datab
 		database.Conversation_BuyerUnread(false),
 		database.Conversation_VendorUnread(false),
 		database.Conversation_MessageCount(0),
+		database.Conversation_BuyerLastRead(0),
+		database.Conversation_VendorLastRead(0),
+		database.Conversation_BlockedByBuyer(false),
+		database.Conversation_BlockedByVendor(false),
 		database.Conversation_Id(uuid.NewV4().String()),
 	)
 	require.NoError(s.t, err)
@@ -658,9 +1034,13 @@ type newMessageOptions struct {
 
 //createMessageHistory takes context, conversation model and a number. The number inicates the
 //number of messages that should be created between the buyer and vendor. The message history will
-//be back and forth with the buyer initiating the contact
+//be back and forth with the buyer initiating the contact. the history is dated an hour ago so it
+//does not count towards anyone's message rate limit
 func (s *serverTest) createMessageHistory(ctx context.Context, conversation *database.Conversation,
 	num int) {
+	s.db.Hooks.Now = func() time.Time { return time.Now().Add(-time.Hour) }
+	defer func() { s.db.Hooks.Now = time.Now }()
+
 	for i := 0; i < num; i += 2 {
 		s.createDefaultBuyerMessageToVendor(ctx, conversation)
 		s.createDefaultVendorMessageToBuyer(ctx, conversation)
@@ -701,6 +1081,7 @@ func (s *serverTest) createNewMessage(ctx context.Context, conversation *databas
 		database.Message_Id(uuid.NewV4().String()),
 		database.Message_BuyerSent(options.BuyerSent),
 		database.Message_Description(options.Description),
+		database.Message_SearchText(messageSearchText(options.Description)),
 		database.Message_ConversationPk(conversation.Pk),
 		database.Message_ConversationNumber(conversation.MessageCount+1),
 	)
@@ -718,6 +1099,7 @@ func (s *serverTest) createNewMessage(ctx context.Context, conversation *databas
 	err = s.db.UpdateNoReturn_Conversation_By_Pk(ctx,
 		database.Conversation_Pk(conversation.Pk), updates)
 	require.NoError(s.t, err)
+	conversation.MessageCount++
 
 	return message
 }
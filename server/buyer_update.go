@@ -9,14 +9,20 @@ import (
 	"github.com/zeebo/errs"
 )
 
+//InvalidUpdate is returned when a buyer update asks for something UpdateBuyer does not do
+var InvalidUpdate = errs.Class("invalid update")
+
 type UpdateBuyerRequest struct {
 	BuyerPk         int64
 	FirstName       string `json:"firstName"`
 	LastName        string `json:"lastName"`
 	CurrentEmail    string `json:"currentEmail"`
-	NewEmail        string `json:"newEmail"`
 	CurrentPassword string `json:"currentPassword"`
 	NewPassword     string `json:"password"`
+
+	//NewEmail is only read so that clients still changing emails here are told where to go
+	//instead of being sent back an unchanged buyer
+	NewEmail string `json:"newEmail"`
 }
 
 type UpdateBuyerResponse struct {
@@ -31,15 +37,23 @@ type UpdateRequestField struct {
 type BuyerRequestFields struct {
 	FirstName       UpdateRequestField
 	LastName        UpdateRequestField
-	NewEmail        UpdateRequestField
 	CurrentEmail    UpdateRequestField
 	CurrentPassword UpdateRequestField
 	NewPassword     UpdateRequestField
 }
 
+//UpdateBuyer changes the buyer's name and password. emails are changed with AddBuyerEmail,
+//VerifyBuyerEmail and SetPrimaryBuyerEmail so that every address is verified before it is used
 func (u *BuyerServer) UpdateBuyer(ctx context.Context, req *UpdateBuyerRequest) (
 	resp *UpdateBuyerResponse, err error) {
 
+	ctx, end := startSpan(ctx, "BuyerServer.UpdateBuyer")
+	defer end(&err)
+
+	if req.NewEmail != "" {
+		return nil, InvalidUpdate.New("emails are added and verified with /api/buyer/emails")
+	}
+
 	buyer_req_fields := BuyerRequestFieldsFromUpdateRequest(req)
 
 	if err := ValidateUpdateBuyerRequestFields(buyer_req_fields); err != nil {
@@ -50,14 +64,7 @@ func (u *BuyerServer) UpdateBuyer(ctx context.Context, req *UpdateBuyerRequest)
 	has_buyer_updates := buyer_req_fields.hasBuyerUpdates()
 	buyer_updates := &database.Buyer_Update_Fields{}
 	if has_buyer_updates {
-		buyer_updates = buyer_req_fields.makeBuyerUpdateFields()
-	}
-
-	//set buyer email updates
-	has_email_updates := buyer_req_fields.hasEmailUpdates()
-	email_updates := &database.BuyerEmail_Update_Fields{}
-	if has_email_updates {
-		email_updates, err = buyer_req_fields.makeEmailUpdateFields()
+		buyer_updates, err = buyer_req_fields.makeBuyerUpdateFields()
 		if err != nil {
 			return nil, err
 		}
@@ -65,49 +72,38 @@ func (u *BuyerServer) UpdateBuyer(ctx context.Context, req *UpdateBuyerRequest)
 
 	var buyer *database.Buyer
 	var emails []*database.BuyerEmail
-	if has_buyer_updates || has_email_updates {
-		err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
-
-			if has_buyer_updates {
-				buyer, err = tx.Update_Buyer_By_Pk(ctx, database.Buyer_Pk(req.BuyerPk),
-					database.Buyer_Update_Fields(*buyer_updates))
-				if err != nil {
-					return err
-				}
-			}
-
-			if has_email_updates {
-				email, err := tx.Get_BuyerEmail_By_Address(ctx, database.BuyerEmail_Address(
-					*buyer_req_fields.CurrentEmail.Value()))
-				if err != nil {
-					return err
-				}
-
-				err = comparePasswordHash(*buyer_req_fields.CurrentPassword.Value(),
-					email.SaltedHash)
-				if err != nil {
-					return err
-				}
-
-				err = tx.UpdateNoReturn_BuyerEmail_By_Address(ctx,
-					database.BuyerEmail_Address(*buyer_req_fields.CurrentEmail.Value()),
-					database.BuyerEmail_Update_Fields(*email_updates))
-				if err != nil {
-					return err
-				}
-
-				emails, err = tx.All_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(
-					email.BuyerPk))
-				if err != nil {
-					return err
-				}
-			}
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		buyer, err = tx.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
 
+		err = comparePasswordHash(*buyer_req_fields.CurrentPassword.Value(), buyer.SaltedHash)
+		if err != nil {
+			return err
+		}
+
+		emails, err = tx.All_BuyerEmail_By_BuyerPk(ctx, database.BuyerEmail_BuyerPk(req.BuyerPk))
+		if err != nil {
+			return err
+		}
+
+		if !has_buyer_updates {
 			return nil
-		})
+		}
+
+		before, after := buyer_req_fields.auditFields(buyer)
+
+		buyer, err = tx.Update_Buyer_By_Pk(ctx, database.Buyer_Pk(req.BuyerPk),
+			database.Buyer_Update_Fields(*buyer_updates))
 		if err != nil {
-			return nil, err
+			return err
 		}
+
+		return auditBuyer(ctx, tx, req.BuyerPk, AuditBuyerUpdate, before, after)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &UpdateBuyerResponse{Buyer: BuyerFromDB(buyer, emails)}, nil
@@ -129,7 +125,6 @@ func (f BuyerRequestFields) isEmpty() bool {
 	if f.FirstName.set == false &&
 		f.LastName.set == false &&
 		f.CurrentEmail.set == false &&
-		f.NewEmail.set == false &&
 		f.CurrentPassword.set == false &&
 		f.NewPassword.set == false {
 		return true
@@ -138,7 +133,8 @@ func (f BuyerRequestFields) isEmpty() bool {
 	return false
 }
 
-func (f *BuyerRequestFields) makeBuyerUpdateFields() *database.Buyer_Update_Fields {
+func (f *BuyerRequestFields) makeBuyerUpdateFields() (updates *database.Buyer_Update_Fields,
+	err error) {
 
 	buyer_updates := &database.Buyer_Update_Fields{}
 
@@ -150,36 +146,40 @@ func (f *BuyerRequestFields) makeBuyerUpdateFields() *database.Buyer_Update_Fiel
 		buyer_updates.LastName = database.Buyer_LastName(*f.LastName.Value())
 	}
 
-	return buyer_updates
-}
-
-func (f BuyerRequestFields) makeEmailUpdateFields() (updates *database.BuyerEmail_Update_Fields,
-	err error) {
-
-	email_updates := &database.BuyerEmail_Update_Fields{}
-
-	if f.NewEmail.set {
-		email_updates.Address = database.BuyerEmail_Address(*f.NewEmail.Value())
-	}
-
 	if f.NewPassword.set {
 		hash, err := hashPassword(*f.NewPassword.Value())
 		if err != nil {
 			return nil, err
 		}
 
-		email_updates.SaltedHash = database.BuyerEmail_SaltedHash(hash)
+		buyer_updates.SaltedHash = database.Buyer_SaltedHash(hash)
 	}
 
-	return email_updates, nil
+	return buyer_updates, nil
 }
 
-func (f BuyerRequestFields) hasBuyerUpdates() bool {
-	return f.FirstName.set || f.LastName.set
+//auditFields returns what the update changes for the audit log. names and a new password only
+//show up as having been changed
+func (f BuyerRequestFields) auditFields(buyer *database.Buyer) (before, after auditFields) {
+	before, after = auditFields{}, auditFields{}
+
+	if f.FirstName.set && *f.FirstName.Value() != buyer.FirstName {
+		after["firstName"] = redacted
+	}
+
+	if f.LastName.set && *f.LastName.Value() != buyer.LastName {
+		after["lastName"] = redacted
+	}
+
+	if f.NewPassword.set {
+		after["password"] = redacted
+	}
+
+	return before, after
 }
 
-func (f BuyerRequestFields) hasEmailUpdates() bool {
-	return f.NewEmail.set || f.NewPassword.set
+func (f BuyerRequestFields) hasBuyerUpdates() bool {
+	return f.FirstName.set || f.LastName.set || f.NewPassword.set
 }
 
 func BuyerRequestFieldsFromUpdateRequest(req *UpdateBuyerRequest) *BuyerRequestFields {
@@ -194,9 +194,6 @@ func BuyerRequestFieldsFromUpdateRequest(req *UpdateBuyerRequest) *BuyerRequestF
 	if req.CurrentEmail != "" {
 		buyer_req_fields.CurrentEmail = SetRequestField(req.CurrentEmail)
 	}
-	if req.NewEmail != "" {
-		buyer_req_fields.NewEmail = SetRequestField(req.NewEmail)
-	}
 	if req.CurrentPassword != "" {
 		buyer_req_fields.CurrentPassword = SetRequestField(req.CurrentPassword)
 	}
@@ -229,12 +226,6 @@ func ValidateUpdateBuyerRequestFields(req *BuyerRequestFields) error {
 		return errs.New("current email must be set")
 	}
 
-	if req.NewEmail.set {
-		if err := validate.CheckEmail(*req.NewEmail.Value()); err != nil {
-			return err
-		}
-	}
-
 	if !req.CurrentPassword.set {
 		return errs.New("current password must be set")
 	}
@@ -252,7 +243,6 @@ func updateBuyerRequestIsEmpty(req *UpdateBuyerRequest) bool {
 	if req.FirstName == "" &&
 		req.LastName == "" &&
 		req.CurrentEmail == "" &&
-		req.NewEmail == "" &&
 		req.CurrentPassword == "" &&
 		req.NewPassword == "" {
 		return true
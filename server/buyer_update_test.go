@@ -33,10 +33,6 @@ func TestBuyerRequestFieldsFromUpdateRequest(t *testing.T) {
 	require.False(t, req_fields.LastName.set)
 	require.Nil(t, req_fields.LastName.Value())
 
-	//assert new email
-	require.False(t, req_fields.NewEmail.set)
-	require.Nil(t, req_fields.NewEmail.Value())
-
 	//assert current password
 	require.False(t, req_fields.CurrentPassword.set)
 	require.Nil(t, req_fields.CurrentPassword.Value())
@@ -172,7 +168,7 @@ func TestUpdateBuyerFirstAndLastName(t *testing.T) {
 	require.NotEqual(t, req.LastName, buyer.LastName)
 }
 
-func TestUpdateBuyerEmailOnly(t *testing.T) {
+func TestUpdateBuyerNothingToUpdate(t *testing.T) {
 	test := newTest(t)
 	defer test.tearDown()
 
@@ -180,16 +176,16 @@ func TestUpdateBuyerEmailOnly(t *testing.T) {
 	ctx := context.Background()
 	buyer := test.createFullTestBuyer(ctx)
 	req := getFullUpdateBuyerRequest(buyer)
+	req.FirstName, req.LastName = "", ""
 
-	//update email
-	req.NewEmail = "test@testemail.com"
+	//the buyer comes back unchanged along with their emails
 	resp, err := test.BuyerServer.UpdateBuyer(ctx, req)
 	require.NoError(t, err)
-	require.Equal(t, req.NewEmail, resp.Buyer.Emails[0].Address)
-	require.NotEqual(t, req.CurrentEmail, resp.Buyer.Emails[0].Address)
+	require.Equal(t, resp.Buyer.FirstName, buyer.FirstName)
+	require.Equal(t, resp.Buyer.Emails[0].Address, req.CurrentEmail)
 }
 
-func TestUpdateBuyerEmailAndPassword(t *testing.T) {
+func TestUpdateBuyerRefusesNewEmail(t *testing.T) {
 	test := newTest(t)
 	defer test.tearDown()
 
@@ -197,14 +193,17 @@ func TestUpdateBuyerEmailAndPassword(t *testing.T) {
 	ctx := context.Background()
 	buyer := test.createFullTestBuyer(ctx)
 	req := getFullUpdateBuyerRequest(buyer)
+	req.FirstName = "new first name"
+	req.NewEmail = "new@email.com"
 
-	//update email and password
-	req.NewEmail = "newemail@email.com"
-	req.NewPassword = "Password5%"
-	resp, err := test.BuyerServer.UpdateBuyer(ctx, req)
+	//old clients are pointed at the email endpoints and nothing is changed
+	_, err := test.BuyerServer.UpdateBuyer(ctx, req)
+	require.True(t, InvalidUpdate.Has(err))
+	require.Contains(t, err.Error(), "/api/buyer/emails")
+
+	unchanged, err := test.db.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(buyer.Pk))
 	require.NoError(t, err)
-	require.Equal(t, req.NewEmail, resp.Buyer.Emails[0].Address)
-	require.NotEqual(t, req.CurrentEmail, resp.Buyer.Emails[0].Address)
+	require.Equal(t, buyer.FirstName, unchanged.FirstName)
 }
 
 func TestUpdateBuyerAllFields(t *testing.T) {
@@ -218,7 +217,6 @@ func TestUpdateBuyerAllFields(t *testing.T) {
 
 	req.FirstName = "new first name"
 	req.LastName = "new last name"
-	req.NewEmail = "new@email.com"
 	req.NewPassword = "Password1!"
 
 	resp, err := test.BuyerServer.UpdateBuyer(ctx, req)
@@ -232,15 +230,13 @@ func TestUpdateBuyerAllFields(t *testing.T) {
 	require.Equal(t, req.LastName, resp.Buyer.LastName)
 	require.NotEqual(t, req.LastName, buyer.LastName)
 
-	//verify change in email
-	require.Equal(t, req.NewEmail, resp.Buyer.Emails[0].Address)
-	require.NotEqual(t, req.CurrentEmail, resp.Buyer.Emails[0].Address)
+	//emails are not changed here
+	require.Equal(t, req.CurrentEmail, resp.Buyer.Emails[0].Address)
 
 	//verify password change
-	email, err := test.db.Get_BuyerEmail_By_Address(ctx, database.BuyerEmail_Address(
-		resp.Buyer.Emails[0].Address))
+	updated_buyer, err := test.db.Get_Buyer_By_Pk(ctx, database.Buyer_Pk(buyer.Pk))
 	require.NoError(t, err)
-	require.NoError(t, comparePasswordHash(req.NewPassword, email.SaltedHash))
+	require.NoError(t, comparePasswordHash(req.NewPassword, updated_buyer.SaltedHash))
 }
 
 //---------------------------------- helpers -----------------------------------------------//
@@ -251,7 +247,6 @@ func getFullUpdateBuyerRequest(b *FullTestBuyer) *UpdateBuyerRequest {
 		FirstName:       b.FirstName,
 		LastName:        b.LastName,
 		CurrentEmail:    b.emails[0].Address,
-		NewEmail:        "",
 		CurrentPassword: defaultPassword,
 		NewPassword:     "",
 	}
@@ -0,0 +1,758 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	CSVFormat   = "csv"
+	JSONLFormat = "jsonl"
+
+	maxImportRows               = 10000
+	maxImportErrors             = 100
+	importBatchSize             = 100
+	maxSkuLength                = 64
+	maxProductDescriptionLength = 5000
+
+	importRunning = "running"
+	importDone    = "done"
+	importFailed  = "failed"
+)
+
+//InvalidImport is returned when an import file cannot be read at all. problems with individual
+//rows are reported on the import instead
+var InvalidImport = errs.Class("invalid import")
+
+//CatalogRow is one product or variant in an import or export file. csv files use
+//catalogCSVHeader for the column names
+type CatalogRow struct {
+	Sku            string  `json:"sku"`
+	Description    string  `json:"description"`
+	Price          float32 `json:"price"`
+	Discount       float32 `json:"discount"`
+	DiscountActive bool    `json:"discountActive"`
+	NumInStock     int     `json:"numInStock"`
+	ProductActive  bool    `json:"productActive"`
+	GoogleBucketId string  `json:"googleBucketId"`
+}
+
+var catalogCSVHeader = []string{"sku", "description", "price", "discount", "discount_active",
+	"num_in_stock", "product_active", "google_bucket_id"}
+
+//catalogJSONColumns maps the keys of a json lines row to the csv column with the same field
+var catalogJSONColumns = map[string]string{
+	"sku":            "sku",
+	"description":    "description",
+	"price":          "price",
+	"discount":       "discount",
+	"discountActive": "discount_active",
+	"numInStock":     "num_in_stock",
+	"productActive":  "product_active",
+	"googleBucketId": "google_bucket_id",
+}
+
+func catalogRowFromDB(p *database.Product) *CatalogRow {
+	return &CatalogRow{
+		Sku:            p.Sku,
+		Description:    p.Description,
+		Price:          p.Price,
+		Discount:       p.Discount,
+		DiscountActive: p.DiscountActive,
+		NumInStock:     p.NumInStock,
+		ProductActive:  p.ProductActive,
+		GoogleBucketId: p.GoogleBucketId,
+	}
+}
+
+//catalogRowFromVariant exports a variant with its product's description and active flag. an
+//import only takes the variant's own fields back
+func catalogRowFromVariant(p *database.Product, variant *database.ProductVariant) *CatalogRow {
+	return &CatalogRow{
+		Sku:            variant.Sku,
+		Description:    p.Description,
+		Price:          variant.Price,
+		Discount:       variant.Discount,
+		DiscountActive: variant.DiscountActive,
+		NumInStock:     variant.NumInStock,
+		ProductActive:  p.ProductActive,
+		GoogleBucketId: variant.GoogleBucketId,
+	}
+}
+
+func (r *CatalogRow) csvRecord() []string {
+	return []string{
+		r.Sku,
+		r.Description,
+		strconv.FormatFloat(float64(r.Price), 'f', -1, 32),
+		strconv.FormatFloat(float64(r.Discount), 'f', -1, 32),
+		strconv.FormatBool(r.DiscountActive),
+		strconv.Itoa(r.NumInStock),
+		strconv.FormatBool(r.ProductActive),
+		r.GoogleBucketId,
+	}
+}
+
+func (r *CatalogRow) validate() error {
+	switch {
+	case r.Sku == "":
+		return errs.New("sku is required")
+	case len(r.Sku) > maxSkuLength:
+		return errs.New("sku cannot exceed %d characters", maxSkuLength)
+	case r.Price <= 0:
+		return errs.New("price must be greater than 0")
+	case r.Discount < 0 || r.Discount > r.Price:
+		return errs.New("discount must be between 0 and the price")
+	case r.NumInStock < 0:
+		return errs.New("numInStock cannot be negative")
+	case len(r.Description) > maxProductDescriptionLength:
+		return errs.New("description cannot exceed %d characters", maxProductDescriptionLength)
+	}
+
+	return nil
+}
+
+//ImportRowError explains why a row was not imported. Row counts from 1 and does not include the
+//csv header
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type importRow struct {
+	row  int
+	data *CatalogRow
+	//columns holds the csv names of the fields the file gave a value for. only those are written
+	//to a product that already exists
+	columns map[string]bool
+}
+
+func (r *importRow) has(column string) bool {
+	return r.columns[column]
+}
+
+//parseCSVCatalog reads rows by column name so columns may come in any order. only sku and price
+//are required. an empty description or google_bucket_id cell clears the field and any other empty
+//cell leaves it as it is
+func parseCSVCatalog(r io.Reader) (rows []*importRow, row_errors []*ImportRowError, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, InvalidImport.New("unable to read the csv header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, c := range catalogCSVHeader {
+			known = known || c == name
+		}
+		if !known {
+			return nil, nil, InvalidImport.New("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, required := range []string{"sku", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, InvalidImport.New("the %q column is required", required)
+		}
+	}
+
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return nil, nil, InvalidImport.Wrap(err)
+			}
+			row_errors = append(row_errors, &ImportRowError{Row: n, Message: err.Error()})
+			continue
+		}
+
+		row, present, err := csvCatalogRow(columns, record)
+		if err != nil {
+			row_errors = append(row_errors, &ImportRowError{Row: n, Message: err.Error()})
+			continue
+		}
+
+		rows = append(rows, &importRow{row: n, data: row, columns: present})
+	}
+
+	return rows, row_errors, nil
+}
+
+func csvCatalogRow(columns map[string]int, record []string) (row *CatalogRow,
+	present map[string]bool, err error) {
+
+	value := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	present = map[string]bool{}
+	for _, name := range []string{"sku", "description", "google_bucket_id"} {
+		_, present[name] = columns[name]
+	}
+
+	row = &CatalogRow{
+		Sku:            value("sku"),
+		Description:    value("description"),
+		GoogleBucketId: value("google_bucket_id"),
+	}
+
+	parsers := []struct {
+		column string
+		parse  func(s string) error
+	}{
+		{"price", func(s string) error {
+			f, err := strconv.ParseFloat(s, 32)
+			row.Price = float32(f)
+			return err
+		}},
+		{"discount", func(s string) error {
+			f, err := strconv.ParseFloat(s, 32)
+			row.Discount = float32(f)
+			return err
+		}},
+		{"discount_active", func(s string) (err error) {
+			row.DiscountActive, err = strconv.ParseBool(s)
+			return err
+		}},
+		{"num_in_stock", func(s string) (err error) {
+			row.NumInStock, err = strconv.Atoi(s)
+			return err
+		}},
+		{"product_active", func(s string) (err error) {
+			row.ProductActive, err = strconv.ParseBool(s)
+			return err
+		}},
+	}
+
+	for _, p := range parsers {
+		s := value(p.column)
+		if s == "" {
+			continue
+		}
+		if err := p.parse(s); err != nil {
+			return nil, nil, errs.New("invalid %s %q", p.column, s)
+		}
+		present[p.column] = true
+	}
+
+	return row, present, nil
+}
+
+//parseJSONLCatalog reads one json CatalogRow per line. blank lines are skipped and keys left out
+//of a row leave those fields as they are
+func parseJSONLCatalog(r io.Reader) (rows []*importRow, row_errors []*ImportRowError,
+	err error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for n := 0; scanner.Scan(); {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		n++
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		row := &CatalogRow{}
+		if err := decoder.Decode(row); err != nil {
+			row_errors = append(row_errors, &ImportRowError{Row: n, Message: err.Error()})
+			continue
+		}
+
+		keys := map[string]json.RawMessage{}
+		if err := json.Unmarshal(line, &keys); err != nil {
+			row_errors = append(row_errors, &ImportRowError{Row: n, Message: err.Error()})
+			continue
+		}
+
+		present := map[string]bool{}
+		for key := range keys {
+			present[catalogJSONColumns[key]] = true
+		}
+
+		rows = append(rows, &importRow{row: n, data: row, columns: present})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, InvalidImport.Wrap(err)
+	}
+
+	return rows, row_errors, nil
+}
+
+//checkImportRows drops rows that fail validation or repeat a sku from earlier in the file
+func checkImportRows(rows []*importRow, row_errors []*ImportRowError) ([]*importRow,
+	[]*ImportRowError) {
+
+	valid := []*importRow{}
+	skus := map[string]int{}
+	for _, r := range rows {
+		err := r.data.validate()
+		if first, ok := skus[r.data.Sku]; ok && err == nil {
+			err = errs.New("sku %q was already used on row %d", r.data.Sku, first)
+		}
+		if err != nil {
+			row_errors = append(row_errors, &ImportRowError{Row: r.row, Message: err.Error()})
+			continue
+		}
+
+		skus[r.data.Sku] = r.row
+		valid = append(valid, r)
+	}
+
+	return valid, row_errors
+}
+
+type CatalogImport struct {
+	Id            string            `json:"id"`
+	Format        string            `json:"format"`
+	Status        string            `json:"status"`
+	TotalRows     int64             `json:"totalRows"`
+	ProcessedRows int64             `json:"processedRows"`
+	CreatedRows   int64             `json:"createdRows"`
+	UpdatedRows   int64             `json:"updatedRows"`
+	FailedRows    int64             `json:"failedRows"`
+	Errors        []*ImportRowError `json:"errors"`
+	CreatedAt     int64             `json:"createdAt"`
+	UpdatedAt     int64             `json:"updatedAt"`
+}
+
+func CatalogImportFromDB(job *database.CatalogImport) (*CatalogImport, error) {
+	row_errors := []*ImportRowError{}
+	err := json.Unmarshal([]byte(job.Errors), &row_errors)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return &CatalogImport{
+		Id:            job.Id,
+		Format:        job.Format,
+		Status:        job.Status,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		CreatedRows:   job.CreatedRows,
+		UpdatedRows:   job.UpdatedRows,
+		FailedRows:    job.FailedRows,
+		Errors:        row_errors,
+		CreatedAt:     job.CreatedAt.Unix(),
+		UpdatedAt:     job.UpdatedAt.Unix(),
+	}, nil
+}
+
+type ImportCatalogReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Format             string
+	File               io.Reader
+}
+
+type ImportCatalogResp struct {
+	Import *CatalogImport `json:"import"`
+}
+
+//ImportCatalog checks every row of the file up front then upserts the valid rows by sku in the
+//background. poll GetCatalogImport for progress
+func (v *VendorServer) ImportCatalog(ctx context.Context, req *ImportCatalogReq) (
+	resp *ImportCatalogResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ImportCatalog")
+	defer end(&err)
+
+	var contact *database.ExecutiveContact
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		contact, err = permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*importRow
+	var row_errors []*ImportRowError
+	switch req.Format {
+	case CSVFormat:
+		rows, row_errors, err = parseCSVCatalog(req.File)
+	case JSONLFormat:
+		rows, row_errors, err = parseJSONLCatalog(req.File)
+	default:
+		return nil, InvalidImport.New("format must be csv or jsonl")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(rows) + len(row_errors)
+	if total > maxImportRows {
+		return nil, InvalidImport.New("imports are limited to %d rows", maxImportRows)
+	}
+
+	rows, row_errors = checkImportRows(rows, row_errors)
+	sort.Slice(row_errors, func(i, j int) bool { return row_errors[i].Row < row_errors[j].Row })
+	if len(row_errors) > maxImportErrors {
+		row_errors = row_errors[:maxImportErrors]
+	}
+
+	errors_json, err := json.Marshal(row_errors)
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+	failed := int64(total - len(rows))
+
+	job, err := v.db.WithContext(ctx).Create_CatalogImport(ctx,
+		database.CatalogImport_Id(uuid.NewV4().String()),
+		database.CatalogImport_VendorPk(req.VendorPk),
+		database.CatalogImport_Format(req.Format),
+		database.CatalogImport_Status(importRunning),
+		database.CatalogImport_TotalRows(int64(total)),
+		database.CatalogImport_ProcessedRows(failed),
+		database.CatalogImport_CreatedRows(0),
+		database.CatalogImport_UpdatedRows(0),
+		database.CatalogImport_FailedRows(failed),
+		database.CatalogImport_Errors(string(errors_json)))
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := CatalogImportFromDB(job)
+	if err != nil {
+		return nil, err
+	}
+
+	v.imports.Add(1)
+	go func() {
+		defer v.imports.Done()
+		v.runCatalogImport(detachedContext(ctx), job, contact.Id, rows)
+	}()
+
+	return &ImportCatalogResp{
+		Import: out,
+	}, nil
+}
+
+//WaitForImports blocks until the catalog imports running in the background are done or ctx is
+func (v *VendorServer) WaitForImports(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		v.imports.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errs.Wrap(ctx.Err())
+	}
+}
+
+//runCatalogImport applies rows a batch at a time so progress is visible while a large file is
+//imported. it runs after the request that started it has finished. product changes are audited
+//as made by the contact who started the import
+func (v *VendorServer) runCatalogImport(ctx context.Context, job *database.CatalogImport,
+	contact_id string, rows []*importRow) {
+
+	processed, created, updated := job.ProcessedRows, int64(0), int64(0)
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		err := v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+			for _, r := range rows[start:end] {
+				was_created, err := upsertProduct(ctx, tx, job.VendorPk, contact_id, r)
+				if err != nil {
+					return err
+				}
+
+				if was_created {
+					created++
+				} else {
+					updated++
+				}
+			}
+			processed += int64(end - start)
+
+			return tx.UpdateNoReturn_CatalogImport_By_Pk(ctx,
+				database.CatalogImport_Pk(job.Pk),
+				database.CatalogImport_Update_Fields{
+					ProcessedRows: database.CatalogImport_ProcessedRows(processed),
+					CreatedRows:   database.CatalogImport_CreatedRows(created),
+					UpdatedRows:   database.CatalogImport_UpdatedRows(updated),
+				})
+		})
+		if err != nil {
+			Logger(ctx).Errorf("catalog import %s failed: %+v", job.Id, err)
+			v.finishCatalogImport(ctx, job, importFailed)
+			return
+		}
+	}
+
+	v.finishCatalogImport(ctx, job, importDone)
+}
+
+func (v *VendorServer) finishCatalogImport(ctx context.Context, job *database.CatalogImport,
+	status string) {
+
+	err := v.db.WithContext(ctx).UpdateNoReturn_CatalogImport_By_Pk(ctx,
+		database.CatalogImport_Pk(job.Pk), database.CatalogImport_Update_Fields{
+			Status: database.CatalogImport_Status(status),
+		})
+	if err != nil {
+		Logger(ctx).Errorf("unable to finish catalog import %s: %+v", job.Id, err)
+	}
+}
+
+//upsertProduct updates the vendor's product or variant with the row's sku or creates a product
+//if there is neither. new products need ladybug's approval before buyers can see them. updates
+//only write the fields the row has. a variant only takes the row's price, discount, stock and
+//images since the rest belongs to its product
+func upsertProduct(ctx context.Context, tx *database.Tx, vendor_pk int64, contact_id string,
+	r *importRow) (created bool, err error) {
+
+	row := r.data
+
+	variant, err := tx.Find_ProductVariant_By_VendorPk_And_Sku(ctx,
+		database.ProductVariant_VendorPk(vendor_pk), database.ProductVariant_Sku(row.Sku))
+	if err != nil {
+		return false, err
+	}
+
+	if variant != nil {
+		fields := database.ProductVariant_Update_Fields{
+			Price: database.ProductVariant_Price(row.Price),
+		}
+		if r.has("discount") {
+			fields.Discount = database.ProductVariant_Discount(row.Discount)
+		}
+		if r.has("discount_active") {
+			fields.DiscountActive = database.ProductVariant_DiscountActive(row.DiscountActive)
+		}
+		if r.has("num_in_stock") {
+			fields.NumInStock = database.ProductVariant_NumInStock(row.NumInStock)
+		}
+		if r.has("google_bucket_id") {
+			fields.GoogleBucketId = database.ProductVariant_GoogleBucketId(row.GoogleBucketId)
+		}
+
+		updated, err := tx.Update_ProductVariant_By_Pk(ctx, database.ProductVariant_Pk(variant.Pk),
+			fields)
+		if err != nil {
+			return false, err
+		}
+
+		return false, auditContact(ctx, tx, contact_id, AuditVariantUpdate, "product_variant",
+			variant.Id, variantAuditFields(variant), variantAuditFields(updated))
+	}
+
+	product, err := tx.Find_Product_By_VendorPk_And_Sku(ctx,
+		database.Product_VendorPk(vendor_pk), database.Product_Sku(row.Sku))
+	if err != nil {
+		return false, err
+	}
+
+	if product == nil {
+		product, err = tx.Create_Product(ctx,
+			database.Product_Id(uuid.NewV4().String()),
+			database.Product_VendorPk(vendor_pk),
+			database.Product_Price(row.Price),
+			database.Product_Discount(row.Discount),
+			database.Product_DiscountActive(row.DiscountActive),
+			database.Product_Sku(row.Sku),
+			database.Product_GoogleBucketId(row.GoogleBucketId),
+			database.Product_LadybugApproved(false),
+			database.Product_ProductActive(row.ProductActive),
+			database.Product_NumInStock(row.NumInStock),
+			database.Product_Description(row.Description),
+			database.Product_Rating(0),
+			database.Product_Attributes(encodeAttributeNames(nil)))
+		if err != nil {
+			return false, err
+		}
+
+		return true, auditContact(ctx, tx, contact_id, AuditProductCreate, "product", product.Id,
+			nil, productAuditFields(product))
+	}
+
+	fields := database.Product_Update_Fields{
+		Price: database.Product_Price(row.Price),
+	}
+	if r.has("discount") {
+		fields.Discount = database.Product_Discount(row.Discount)
+	}
+	if r.has("discount_active") {
+		fields.DiscountActive = database.Product_DiscountActive(row.DiscountActive)
+	}
+	if r.has("google_bucket_id") {
+		fields.GoogleBucketId = database.Product_GoogleBucketId(row.GoogleBucketId)
+	}
+	if r.has("product_active") {
+		fields.ProductActive = database.Product_ProductActive(row.ProductActive)
+	}
+	if r.has("num_in_stock") {
+		fields.NumInStock = database.Product_NumInStock(row.NumInStock)
+	}
+	if r.has("description") {
+		fields.Description = database.Product_Description(row.Description)
+	}
+
+	updated, err := tx.Update_Product_By_Pk(ctx, database.Product_Pk(product.Pk), fields)
+	if err != nil {
+		return false, err
+	}
+
+	return false, auditContact(ctx, tx, contact_id, AuditProductUpdate, "product", product.Id,
+		productAuditFields(product), productAuditFields(updated))
+}
+
+type GetCatalogImportReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ImportId           string
+}
+
+type GetCatalogImportResp struct {
+	Import *CatalogImport `json:"import"`
+}
+
+func (v *VendorServer) GetCatalogImport(ctx context.Context, req *GetCatalogImportReq) (
+	resp *GetCatalogImportResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.GetCatalogImport")
+	defer end(&err)
+
+	var out *CatalogImport
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		job, err := tx.Find_CatalogImport_By_Id(ctx, database.CatalogImport_Id(req.ImportId))
+		if err != nil {
+			return err
+		}
+
+		if job == nil || job.VendorPk != req.VendorPk {
+			return NotFound.New("import not found")
+		}
+
+		out, err = CatalogImportFromDB(job)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetCatalogImportResp{
+		Import: out,
+	}, nil
+}
+
+type ExportCatalogReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Format             string
+}
+
+//ExportCatalog writes every one of the vendor's products, each followed by its variants, to w in
+//a format ImportCatalog accepts
+func (v *VendorServer) ExportCatalog(ctx context.Context, req *ExportCatalogReq,
+	w io.Writer) (err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ExportCatalog")
+	defer end(&err)
+
+	if req.Format != CSVFormat && req.Format != JSONLFormat {
+		return InvalidImport.New("format must be csv or jsonl")
+	}
+
+	var rows []*CatalogRow
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		products, err := tx.All_Product_By_VendorPk(ctx,
+			database.Product_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		variants, err := tx.All_ProductVariant_By_VendorPk_OrderBy_Asc_Pk(ctx,
+			database.ProductVariant_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		by_product := map[int64][]*database.ProductVariant{}
+		for _, variant := range variants {
+			by_product[variant.ProductPk] = append(by_product[variant.ProductPk], variant)
+		}
+
+		for _, p := range products {
+			rows = append(rows, catalogRowFromDB(p))
+			for _, variant := range by_product[p.Pk] {
+				rows = append(rows, catalogRowFromVariant(p, variant))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if req.Format == JSONLFormat {
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return errs.Wrap(err)
+			}
+		}
+		return nil
+	}
+
+	out := csv.NewWriter(w)
+	err = out.Write(catalogCSVHeader)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	for _, row := range rows {
+		err = out.Write(row.csvRecord())
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	out.Flush()
+	return errs.Wrap(out.Error())
+}
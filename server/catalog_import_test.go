@@ -0,0 +1,240 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+//importCatalog runs an import to completion and returns its final status
+func (h *serverTest) importCatalog(ctx context.Context, vendor_pk, contact_pk int64,
+	format, file string) *CatalogImport {
+
+	resp, err := h.VendorServer.ImportCatalog(ctx, &ImportCatalogReq{
+		VendorPk:           vendor_pk,
+		ExecutiveContactPk: contact_pk,
+		Format:             format,
+		File:               strings.NewReader(file),
+	})
+	require.NoError(h.t, err)
+	h.VendorServer.imports.Wait()
+
+	status, err := h.VendorServer.GetCatalogImport(ctx, &GetCatalogImportReq{
+		VendorPk:           vendor_pk,
+		ExecutiveContactPk: contact_pk,
+		ImportId:           resp.Import.Id,
+	})
+	require.NoError(h.t, err)
+
+	return status.Import
+}
+
+func TestImportCatalog(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	manager := test.createExecutiveContact(ctx, vendor.Pk, CatalogManagerRole)
+
+	//bad rows are reported and the rest are imported
+	job := test.importCatalog(ctx, vendor.Pk, manager.Pk, CSVFormat, strings.Join([]string{
+		"sku,price,num_in_stock,description",
+		"WIDGET-1,9.99,10,a widget",
+		"WIDGET-2,19.99,5,a bigger widget",
+		"WIDGET-3,free,5,a free widget",
+		"WIDGET-1,4.99,1,the same widget",
+		",1.00,1,no sku",
+	}, "\n"))
+	require.Equal(t, job.Status, importDone)
+	require.Equal(t, job.TotalRows, int64(5))
+	require.Equal(t, job.ProcessedRows, int64(5))
+	require.Equal(t, job.CreatedRows, int64(2))
+	require.Equal(t, job.FailedRows, int64(3))
+	require.Len(t, job.Errors, 3)
+	require.Equal(t, job.Errors[0].Row, 3)
+	require.Equal(t, job.Errors[1].Row, 4)
+	require.Equal(t, job.Errors[2].Row, 5)
+
+	product, err := test.db.Find_Product_By_VendorPk_And_Sku(ctx,
+		database.Product_VendorPk(vendor.Pk), database.Product_Sku("WIDGET-1"))
+	require.NoError(t, err)
+	require.Equal(t, product.Price, float32(9.99))
+	require.False(t, product.LadybugApproved)
+
+	//json lines upsert by sku
+	job = test.importCatalog(ctx, vendor.Pk, manager.Pk, JSONLFormat, strings.Join([]string{
+		`{"sku": "WIDGET-1", "price": 8.99, "numInStock": 20}`,
+		``,
+		`{"sku": "WIDGET-4", "price": 1.50, "productActive": true}`,
+	}, "\n"))
+	require.Equal(t, job.Status, importDone)
+	require.Equal(t, job.CreatedRows, int64(1))
+	require.Equal(t, job.UpdatedRows, int64(1))
+
+	product, err = test.db.Find_Product_By_VendorPk_And_Sku(ctx,
+		database.Product_VendorPk(vendor.Pk), database.Product_Sku("WIDGET-1"))
+	require.NoError(t, err)
+	require.Equal(t, product.NumInStock, 20)
+
+	//files that cannot be read are refused outright
+	_, err = test.VendorServer.ImportCatalog(ctx, &ImportCatalogReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		Format:             CSVFormat,
+		File:               strings.NewReader("sku,colour\nWIDGET-1,red"),
+	})
+	require.True(t, InvalidImport.Has(err))
+
+	_, err = test.VendorServer.ImportCatalog(ctx, &ImportCatalogReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		Format:             "xlsx",
+		File:               strings.NewReader(""),
+	})
+	require.True(t, InvalidImport.Has(err))
+
+	//other vendors cannot see the import
+	other_vendor := test.createVendorInDB(ctx)
+	_, err = test.VendorServer.GetCatalogImport(ctx, &GetCatalogImportReq{
+		VendorPk:           other_vendor.Pk,
+		ExecutiveContactPk: test.createExecutiveContact(ctx, other_vendor.Pk, OwnerRole).Pk,
+		ImportId:           job.Id,
+	})
+	require.True(t, NotFound.Has(err))
+}
+
+func TestImportCatalogKeepsMissingFields(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	manager := test.createExecutiveContact(ctx, vendor.Pk, CatalogManagerRole)
+
+	job := test.importCatalog(ctx, vendor.Pk, manager.Pk, CSVFormat, strings.Join([]string{
+		"sku,description,price,discount,discount_active,num_in_stock,product_active," +
+			"google_bucket_id",
+		"WIDGET-1,a widget,9.99,1.00,true,10,true,widget-images",
+	}, "\n"))
+	require.Equal(t, job.CreatedRows, int64(1))
+
+	requireKept := func(price float32) {
+		product, err := test.db.Find_Product_By_VendorPk_And_Sku(ctx,
+			database.Product_VendorPk(vendor.Pk), database.Product_Sku("WIDGET-1"))
+		require.NoError(t, err)
+		require.Equal(t, product.Price, price)
+		require.Equal(t, product.Description, "a widget")
+		require.Equal(t, product.Discount, float32(1.00))
+		require.True(t, product.DiscountActive)
+		require.Equal(t, product.NumInStock, 10)
+		require.True(t, product.ProductActive)
+		require.Equal(t, product.GoogleBucketId, "widget-images")
+	}
+
+	//a price sync only changes the price
+	job = test.importCatalog(ctx, vendor.Pk, manager.Pk, CSVFormat, "sku,price\nWIDGET-1,8.99")
+	require.Equal(t, job.UpdatedRows, int64(1))
+	requireKept(8.99)
+
+	job = test.importCatalog(ctx, vendor.Pk, manager.Pk, JSONLFormat,
+		`{"sku": "WIDGET-1", "price": 7.99}`)
+	require.Equal(t, job.UpdatedRows, int64(1))
+	requireKept(7.99)
+
+	//empty cells are left alone too
+	job = test.importCatalog(ctx, vendor.Pk, manager.Pk, CSVFormat,
+		"sku,price,num_in_stock\nWIDGET-1,6.99,")
+	require.Equal(t, job.UpdatedRows, int64(1))
+	requireKept(6.99)
+}
+
+func TestExportCatalog(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	test.createActiveAndApprovedProductsInStock(ctx, 2, vendor.Pk)
+
+	shirt, err := test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		UnitPrice:          20,
+		SKU:                "SHIRT",
+		Attributes:         []string{"size"},
+	})
+	require.NoError(t, err)
+
+	_, err = test.VendorServer.AddProductVariant(ctx, &AddProductVariantReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ProductId:          shirt.ProductId,
+		Sku:                "SHIRT-S",
+		Attributes:         map[string]string{"size": "S"},
+		Price:              18,
+		NumInStock:         4,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = test.VendorServer.ExportCatalog(ctx, &ExportCatalogReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Format:             CSVFormat,
+	}, &buf)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "SHIRT,") {
+			require.True(t, strings.HasPrefix(lines[i+1], "SHIRT-S,,18,"))
+		}
+	}
+
+	//an export imports cleanly back into the same catalog, variants included
+	job := test.importCatalog(ctx, vendor.Pk, owner.Pk, CSVFormat, buf.String())
+	require.Equal(t, job.CreatedRows, int64(0))
+	require.Equal(t, job.UpdatedRows, int64(4))
+	require.Equal(t, job.FailedRows, int64(0))
+
+	buf.Reset()
+	err = test.VendorServer.ExportCatalog(ctx, &ExportCatalogReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Format:             JSONLFormat,
+	}, &buf)
+	require.NoError(t, err)
+
+	job = test.importCatalog(ctx, vendor.Pk, owner.Pk, JSONLFormat, buf.String())
+	require.Equal(t, job.UpdatedRows, int64(4))
+	require.Equal(t, job.FailedRows, int64(0))
+}
+
+func TestWaitForImports(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	require.NoError(t, test.VendorServer.WaitForImports(ctx))
+
+	//an import that does not finish in time is reported
+	test.VendorServer.imports.Add(1)
+	timeout_ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, test.VendorServer.WaitForImports(timeout_ctx))
+
+	test.VendorServer.imports.Done()
+	require.NoError(t, test.VendorServer.WaitForImports(ctx))
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"time"
 
 	"ladybug/database"
@@ -34,6 +35,10 @@ type Message struct {
 	Description   string `json:"description"`
 	CreatedAt     int64  `json:"createdAt"`
 	MessageNumber int64  `json:"messageNumber"`
+	Read          bool   `json:"read"`
+
+	Attachments []*Attachment    `json:"attachments,omitempty"`
+	References  []*ReferenceCard `json:"references,omitempty"`
 }
 
 func MessageFromDB(message *database.Message) *Message {
@@ -55,6 +60,68 @@ func MessagesFromDB(messages []*database.Message) []*Message {
 	return out
 }
 
+//readByRecipient is true once the participant who did not send the message has read up to it
+func readByRecipient(conversation *database.Conversation, message *database.Message) bool {
+	if message.BuyerSent {
+		return conversation.VendorLastRead >= message.ConversationNumber
+	}
+
+	return conversation.BuyerLastRead >= message.ConversationNumber
+}
+
+func MessagesWithReceiptsFromDB(conversation *database.Conversation,
+	messages []*database.Message) []*Message {
+
+	out := []*Message{}
+	for _, m := range messages {
+		message := MessageFromDB(m)
+		message.Read = readByRecipient(conversation, m)
+		out = append(out, message)
+	}
+
+	return out
+}
+
+//newestConversationNumber is the highest conversation number in a page of messages
+func newestConversationNumber(messages []*database.Message) int64 {
+	var newest int64
+	for _, m := range messages {
+		if m.ConversationNumber > newest {
+			newest = m.ConversationNumber
+		}
+	}
+
+	return newest
+}
+
+//lastReadUpTo moves a read pointer forward to the requested conversation number. the pointer never
+//moves backwards or past the last message in the conversation
+func lastReadUpTo(current, requested, message_count int64) int64 {
+	if requested > message_count {
+		requested = message_count
+	}
+
+	if requested < current {
+		return current
+	}
+
+	return requested
+}
+
+//lockConversation locks the conversation and reads it again so its message count is current. a
+//new message is numbered from that count, so with the lock held the buyer and the vendor posting
+//at the same time are numbered one after the other
+func lockConversation(ctx context.Context, tx *database.Tx, conversation_pk int64) (
+	*database.Conversation, error) {
+
+	err := tx.LockConversation(ctx, conversation_pk)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Get_Conversation_By_Pk(ctx, database.Conversation_Pk(conversation_pk))
+}
+
 type Conversation struct {
 	Id string `json:"id"`
 }
@@ -73,3 +140,11 @@ func ConversationsFromDB(conversations []*database.Conversation) []*Conversation
 
 	return out
 }
+
+type ConversationUnread struct {
+	Id          string `json:"id"`
+	UnreadCount int64  `json:"unreadCount"`
+
+	//LastRead is the conversation number of the last message read
+	LastRead int64 `json:"lastRead"`
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ladybug/database"
+)
+
+const (
+	//number of events a subscriber can fall behind before events are dropped. a subscriber that
+	//misses events can catch up by resuming from the last conversation number it has seen
+	subscriptionBuffer = 64
+
+	MessageEvent = "message"
+	UnreadEvent  = "unread"
+	ReadEvent    = "read"
+)
+
+//Event is pushed to every session subscribed to a buyer or vendor topic
+type Event struct {
+	Type           string   `json:"type"`
+	ConversationId string   `json:"conversationId,omitempty"`
+	MessageNumber  int64    `json:"messageNumber,omitempty"`
+	Message        *Message `json:"message,omitempty"`
+	UnreadCount    int64    `json:"unreadCount"`
+}
+
+func BuyerTopic(buyer_pk int64) string {
+	return fmt.Sprintf("buyer.%d", buyer_pk)
+}
+
+func VendorTopic(vendor_pk int64) string {
+	return fmt.Sprintf("vendor.%d", vendor_pk)
+}
+
+//Hub delivers events to the sessions subscribed to a topic
+type Hub interface {
+	Publish(ctx context.Context, topic string, event *Event) error
+	Subscribe(topic string) *Subscription
+}
+
+type Subscription struct {
+	Events <-chan *Event
+
+	events chan *Event
+	topic  string
+	hub    *LocalHub
+	once   sync.Once
+}
+
+//Close removes the subscription from its hub. it is safe to call more than once
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s)
+	})
+}
+
+//LocalHub is an in process pub/sub hub. it only reaches sessions connected to this instance
+type LocalHub struct {
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func NewLocalHub() *LocalHub {
+	return &LocalHub{topics: map[string]map[*Subscription]struct{}{}}
+}
+
+func (h *LocalHub) Subscribe(topic string) *Subscription {
+	events := make(chan *Event, subscriptionBuffer)
+	s := &Subscription{
+		Events: events,
+		events: events,
+		topic:  topic,
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = map[*Subscription]struct{}{}
+		h.topics[topic] = subs
+	}
+	subs[s] = struct{}{}
+
+	return s
+}
+
+func (h *LocalHub) unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.topics[s.topic]
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.topics, s.topic)
+	}
+	close(s.events)
+}
+
+//Publish never blocks on a slow subscriber, the event is dropped for that subscriber instead
+func (h *LocalHub) Publish(ctx context.Context, topic string, event *Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.topics[topic] {
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+//publishNewMessage tells both participants about a new message and gives the recipient their new
+//unread count. the message is already committed when this is called so failures are only logged
+func publishNewMessage(ctx context.Context, hub Hub, sender_topic, recipient_topic string,
+	conversation *database.Conversation, message *Message, recipient_unread int64) {
+
+	message_event := &Event{
+		Type:           MessageEvent,
+		ConversationId: conversation.Id,
+		MessageNumber:  message.MessageNumber,
+		Message:        message,
+	}
+
+	for _, topic := range []string{sender_topic, recipient_topic} {
+		err := hub.Publish(ctx, topic, message_event)
+		if err != nil {
+			Logger(ctx).Errorf("publish message event: %+v", err)
+		}
+	}
+
+	err := hub.Publish(ctx, recipient_topic, &Event{
+		Type:           UnreadEvent,
+		ConversationId: conversation.Id,
+		UnreadCount:    recipient_unread,
+	})
+	if err != nil {
+		Logger(ctx).Errorf("publish unread event: %+v", err)
+	}
+}
+
+//publishRead sends a read receipt to both participants and gives the reader their new unread count
+func publishRead(ctx context.Context, hub Hub, reader_topic, other_topic string,
+	conversation_id string, last_read, reader_unread int64) {
+
+	read_event := &Event{
+		Type:           ReadEvent,
+		ConversationId: conversation_id,
+		MessageNumber:  last_read,
+	}
+
+	for _, topic := range []string{reader_topic, other_topic} {
+		err := hub.Publish(ctx, topic, read_event)
+		if err != nil {
+			Logger(ctx).Errorf("publish read event: %+v", err)
+		}
+	}
+
+	err := hub.Publish(ctx, reader_topic, &Event{
+		Type:           UnreadEvent,
+		ConversationId: conversation_id,
+		UnreadCount:    reader_unread,
+	})
+	if err != nil {
+		Logger(ctx).Errorf("publish unread event: %+v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	eventsChannel = "ladybug_events"
+
+	//postgres rejects NOTIFY payloads of 8000 bytes or more
+	maxNotifyPayload = 7999
+)
+
+type notification struct {
+	Topic string `json:"topic"`
+	Event *Event `json:"event"`
+}
+
+//PostgresHub fans events out to every ladybug instance with LISTEN/NOTIFY. each instance delivers
+//the notifications it receives to its own sessions through a LocalHub
+type PostgresHub struct {
+	db       *database.DB
+	local    *LocalHub
+	listener *pq.Listener
+}
+
+func NewPostgresHub(db *database.DB, dsn string) (*PostgresHub, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				logrus.Errorf("events listener: %+v", err)
+			}
+		})
+
+	err := listener.Listen(eventsChannel)
+	if err != nil {
+		listener.Close()
+		return nil, errs.Wrap(err)
+	}
+
+	h := &PostgresHub{
+		db:       db,
+		local:    NewLocalHub(),
+		listener: listener,
+	}
+	go h.run()
+
+	return h, nil
+}
+
+func (h *PostgresHub) run() {
+	for n := range h.listener.Notify {
+		//a nil notification means the connection was re-established and notifications may have
+		//been lost. subscribers recover by resuming from their last seen conversation number
+		if n == nil {
+			continue
+		}
+
+		var note notification
+		err := json.Unmarshal([]byte(n.Extra), &note)
+		if err != nil {
+			logrus.Errorf("events listener: bad payload: %+v", err)
+			continue
+		}
+
+		h.local.Publish(context.Background(), note.Topic, note.Event)
+	}
+}
+
+func (h *PostgresHub) Subscribe(topic string) *Subscription {
+	return h.local.Subscribe(topic)
+}
+
+func (h *PostgresHub) Publish(ctx context.Context, topic string, event *Event) error {
+	payload, err := json.Marshal(&notification{Topic: topic, Event: event})
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	//a long message will not fit in a notification. send the event without the message body and
+	//let the client fetch it by resuming from the message number
+	if len(payload) > maxNotifyPayload && event.Message != nil {
+		trimmed := *event
+		trimmed.Message = nil
+		payload, err = json.Marshal(&notification{Topic: topic, Event: &trimmed})
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	_, err = h.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", eventsChannel, string(payload))
+	return errs.Wrap(err)
+}
+
+func (h *PostgresHub) Close() error {
+	return errs.Wrap(h.listener.Close())
+}
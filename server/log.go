@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//sensitiveKeys are matched against lowercased field names with separators removed, so they catch
+//password, currentPassword, salted_hash, SaltedHash, VerificationHash, token and so on
+var sensitiveKeys = []string{"password", "hash", "token", "secret", "authorization", "cookie"}
+
+type loggerKey struct{}
+
+//WithLogger attaches log to ctx. everything logged while working on ctx goes through it so fields
+//such as the request id end up on every line
+func WithLogger(ctx context.Context, log *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+//Logger returns the logger attached to ctx, or the standard logger when there is none
+func Logger(ctx context.Context) *logrus.Entry {
+	if log, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return log
+	}
+
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+//RedactFormatter redacts sensitive fields before Formatter renders an entry. fields whose name is
+//sensitive are dropped outright and structured values are searched for sensitive fields
+type RedactFormatter struct {
+	Formatter logrus.Formatter
+}
+
+func (f *RedactFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if isSensitiveKey(key) {
+			data[key] = redacted
+			continue
+		}
+		data[key] = Redact(value)
+	}
+
+	clean := *entry
+	clean.Data = data
+	return f.Formatter.Format(&clean)
+}
+
+//Redact returns v with the value of every sensitive field replaced. structs and maps are walked in
+//their json form so requests and database rows can be logged as they are. values without fields
+//are returned unchanged
+func Redact(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, error, time.Time, time.Duration:
+		return v
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(v)).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return v
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return v
+	}
+
+	return redactValue(generic)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	//set up
+	var out bytes.Buffer
+	log := logrus.New()
+	log.Out = &out
+	log.Formatter = &RedactFormatter{Formatter: &logrus.JSONFormatter{}}
+
+	ctx := WithLogger(context.Background(), logrus.NewEntry(log).WithField("request_id", "req-1"))
+
+	//the request id survives work that outlives the request
+	Logger(detachedContext(ctx)).WithFields(logrus.Fields{
+		"password": "Password1!",
+		"req": &SignUpRequest{
+			FirstName: "Ada",
+			Password:  "Password1!",
+		},
+		"buyer": &database.Buyer{Pk: 7, SaltedHash: "$2a$10$hash"},
+	}).Info("signed up")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	require.Equal(t, line["request_id"], "req-1")
+	require.Equal(t, line["msg"], "signed up")
+	require.Equal(t, line["password"], redacted)
+
+	req := line["req"].(map[string]interface{})
+	require.Equal(t, req["firstName"], "Ada")
+	require.Equal(t, req["password"], redacted)
+
+	buyer := line["buyer"].(map[string]interface{})
+	require.Equal(t, buyer["Pk"], float64(7))
+	require.Equal(t, buyer["SaltedHash"], redacted)
+	require.NotContains(t, out.String(), "Password1!")
+	require.NotContains(t, out.String(), "$2a$10$hash")
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+//smtpTimeout bounds a whole conversation with the relay, from dialing to QUIT
+const smtpTimeout = 30 * time.Second
+
+//Mailer sends email on behalf of the marketplace
+type Mailer interface {
+	SendMail(ctx context.Context, to, subject, body string) error
+}
+
+//LogMailer logs mail instead of sending it. it is meant for development. bodies hold tokens that
+//log people in so only the recipient and subject are logged
+type LogMailer struct{}
+
+func (LogMailer) SendMail(ctx context.Context, to, subject, body string) error {
+	Logger(ctx).Infof("mail to %s: %s", to, subject)
+	return nil
+}
+
+//SMTPMailer sends plain text mail through an SMTP relay. the connection is upgraded with STARTTLS
+//whenever the relay offers it, and credentials are only sent over TLS unless the relay is on
+//localhost
+type SMTPMailer struct {
+	//Address is the relay's host:port
+	Address string
+	//From is the address mail is sent from. it may have a name, as in
+	//"Ladybug <no-reply@ladybug.example.com>"
+	From string
+	//Username and Password log in to the relay. mail is sent without logging in when Username is
+	//empty
+	Username string
+	Password string
+}
+
+//NewSMTPMailer checks the relay's address and the from address
+func NewSMTPMailer(address, from, username, password string) (*SMTPMailer, error) {
+	_, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errs.New("the smtp address must be host:port: %v", err)
+	}
+
+	_, err = mail.ParseAddress(from)
+	if err != nil {
+		return nil, errs.New("invalid from address %q: %v", from, err)
+	}
+
+	return &SMTPMailer{
+		Address:  address,
+		From:     from,
+		Username: username,
+		Password: password,
+	}, nil
+}
+
+func (m *SMTPMailer) SendMail(ctx context.Context, to, subject, body string) (err error) {
+	ctx, end := startSpan(ctx, "SMTPMailer.SendMail")
+	defer end(&err)
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	host, _, err := net.SplitHostPort(m.Address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errs.Wrap(err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	if m.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.Username, m.Password, host))
+		if err != nil {
+			return errs.Wrap(err)
+		}
+	}
+
+	err = client.Mail(from.Address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	err = client.Rcpt(recipient.Address)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	_, err = w.Write(formatMail(from, recipient, subject, body, time.Now()))
+	if err != nil {
+		w.Close()
+		return errs.Wrap(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	return errs.Wrap(client.Quit())
+}
+
+//formatMail builds a plain text message. the subject is encoded so it cannot add headers of its
+//own
+func formatMail(from, to *mail.Address, subject, body string, date time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return msg.Bytes()
+}
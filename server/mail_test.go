@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//fakeSMTPRelay accepts one message without TLS or logging in and sends what it was given on the
+//returned channel
+func fakeSMTPRelay(t *testing.T) (address string, received chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received = make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var lines []string
+		reply("220 relay ready")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 relay")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lines = append(lines, data)
+				}
+				reply("250 queued")
+			case line == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	//set up
+	ctx := context.Background()
+	address, received := fakeSMTPRelay(t)
+
+	mailer, err := NewSMTPMailer(address, "Ladybug <no-reply@ladybug.test>", "", "")
+	require.NoError(t, err)
+
+	err = mailer.SendMail(ctx, "ada@example.com", "Verify your Ladybug email\r\nBcc: x@y.z",
+		"Use this token:\nabc123")
+	require.NoError(t, err)
+
+	lines := <-received
+	require.Contains(t, lines, "MAIL FROM:<no-reply@ladybug.test>")
+	require.Contains(t, lines, "RCPT TO:<ada@example.com>")
+	require.Contains(t, lines, "To: <ada@example.com>")
+	require.Contains(t, lines, "abc123")
+
+	//the subject cannot add headers
+	for _, line := range lines {
+		require.False(t, strings.HasPrefix(line, "Bcc:"))
+	}
+
+	//bad addresses are refused up front
+	_, err = NewSMTPMailer("smtp.example.com", "no-reply@ladybug.test", "", "")
+	require.Error(t, err)
+
+	_, err = NewSMTPMailer("smtp.example.com:587", "not an address", "", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,341 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	maxAttachmentsPerMessage = 4
+	maxAttachmentBytes       = 5 << 20
+	maxAttachmentNameLength  = 255
+
+	productReference = "product"
+	orderReference   = "order"
+	trialReference   = "trial"
+)
+
+//InvalidAttachment is returned for attachments that are empty, too large or of a type that is not
+//allowed
+var InvalidAttachment = errs.Class("invalid attachment")
+
+//content types are sniffed from the uploaded bytes, the type a client claims is ignored
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"application/pdf": true,
+}
+
+type AttachmentUpload struct {
+	Filename string `json:"filename"`
+	Data     []byte `json:"data"`
+}
+
+type Attachment struct {
+	Id          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+func AttachmentFromDB(attachment *database.MessageAttachment) *Attachment {
+	return &Attachment{
+		Id:          attachment.Id,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+	}
+}
+
+//MessageReferences points a message at things the buyer and vendor have in common. each id is
+//optional
+type MessageReferences struct {
+	ProductId string `json:"productId"`
+	OrderId   string `json:"orderId"`
+	TrialId   string `json:"trialId"`
+}
+
+//ReferenceCard is the summary a client shows in place of a reference
+type ReferenceCard struct {
+	Kind        string  `json:"kind"`
+	Id          string  `json:"id"`
+	ProductId   string  `json:"productId"`
+	Description string  `json:"description"`
+	Price       float32 `json:"price"`
+	CreatedAt   int64   `json:"createdAt"`
+}
+
+func attachmentContentType(upload *AttachmentUpload) string {
+	content_type := http.DetectContentType(upload.Data)
+	//DetectContentType can append parameters, e.g. "text/plain; charset=utf-8"
+	if i := strings.IndexByte(content_type, ';'); i >= 0 {
+		return content_type[:i]
+	}
+
+	return content_type
+}
+
+func ValidateAttachments(uploads []*AttachmentUpload) error {
+	if len(uploads) > maxAttachmentsPerMessage {
+		return InvalidAttachment.New("a message cannot have more than %d attachments",
+			maxAttachmentsPerMessage)
+	}
+
+	for _, upload := range uploads {
+		if upload == nil || len(upload.Data) == 0 {
+			return InvalidAttachment.New("attachment is empty")
+		}
+
+		if upload.Filename == "" || len(upload.Filename) > maxAttachmentNameLength {
+			return InvalidAttachment.New("attachment filename must be between 1 and %d characters",
+				maxAttachmentNameLength)
+		}
+
+		if len(upload.Data) > maxAttachmentBytes {
+			return InvalidAttachment.New("attachment %q is larger than %d bytes", upload.Filename,
+				maxAttachmentBytes)
+		}
+
+		if !allowedAttachmentTypes[attachmentContentType(upload)] {
+			return InvalidAttachment.New("attachment %q must be a jpeg, png, gif or pdf",
+				upload.Filename)
+		}
+	}
+
+	return nil
+}
+
+//attachToMessage stores the uploads and references for a message that was just created. blobs are
+//written before the transaction commits so a failed commit can leave an unreferenced blob behind
+func attachToMessage(ctx context.Context, tx *database.Tx, blobs BlobStore,
+	conversation *database.Conversation, message *database.Message,
+	uploads []*AttachmentUpload, refs *MessageReferences) error {
+
+	for _, upload := range uploads {
+		id := uuid.NewV4().String()
+		blob_key := "attachments/" + id
+
+		err := blobs.Put(ctx, blob_key, upload.Data)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Create_MessageAttachment(ctx,
+			database.MessageAttachment_Id(id),
+			database.MessageAttachment_MessagePk(message.Pk),
+			database.MessageAttachment_BlobKey(blob_key),
+			database.MessageAttachment_Filename(upload.Filename),
+			database.MessageAttachment_ContentType(attachmentContentType(upload)),
+			database.MessageAttachment_Size(int64(len(upload.Data))))
+		if err != nil {
+			return err
+		}
+	}
+
+	if refs == nil {
+		return nil
+	}
+
+	if refs.ProductId != "" {
+		product, err := tx.Find_Product_By_Id(ctx, database.Product_Id(refs.ProductId))
+		if err != nil {
+			return err
+		}
+
+		if product == nil || product.VendorPk != conversation.VendorPk {
+			return NotFound.New("product not found")
+		}
+
+		err = createMessageReference(ctx, tx, message, productReference, product.Pk)
+		if err != nil {
+			return err
+		}
+	}
+
+	if refs.OrderId != "" {
+		order, err := tx.Find_PurchasedProduct_By_Id(ctx,
+			database.PurchasedProduct_Id(refs.OrderId))
+		if err != nil {
+			return err
+		}
+
+		if order == nil || order.BuyerPk != conversation.BuyerPk ||
+			order.VendorPk != conversation.VendorPk {
+			return NotFound.New("order not found")
+		}
+
+		err = createMessageReference(ctx, tx, message, orderReference, order.Pk)
+		if err != nil {
+			return err
+		}
+	}
+
+	if refs.TrialId != "" {
+		trial, err := tx.Find_TrialProduct_By_Id(ctx, database.TrialProduct_Id(refs.TrialId))
+		if err != nil {
+			return err
+		}
+
+		if trial == nil || trial.BuyerPk != conversation.BuyerPk ||
+			trial.VendorPk != conversation.VendorPk {
+			return NotFound.New("trial not found")
+		}
+
+		err = createMessageReference(ctx, tx, message, trialReference, trial.Pk)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createMessageReference(ctx context.Context, tx *database.Tx, message *database.Message,
+	kind string, ref_pk int64) error {
+
+	_, err := tx.Create_MessageReference(ctx,
+		database.MessageReference_MessagePk(message.Pk),
+		database.MessageReference_Kind(kind),
+		database.MessageReference_RefPk(ref_pk))
+	return err
+}
+
+func referenceCard(ctx context.Context, tx *database.Tx, ref *database.MessageReference) (
+	*ReferenceCard, error) {
+
+	switch ref.Kind {
+	case productReference:
+		product, err := tx.Get_Product_By_Pk(ctx, database.Product_Pk(ref.RefPk))
+		if err != nil {
+			return nil, err
+		}
+
+		return &ReferenceCard{
+			Kind:        ref.Kind,
+			Id:          product.Id,
+			ProductId:   product.Id,
+			Description: product.Description,
+			Price:       product.Price,
+			CreatedAt:   product.CreatedAt.Unix(),
+		}, nil
+
+	case orderReference:
+		order, err := tx.Get_PurchasedProduct_By_Pk(ctx, database.PurchasedProduct_Pk(ref.RefPk))
+		if err != nil {
+			return nil, err
+		}
+
+		product, err := tx.Get_Product_By_Pk(ctx, database.Product_Pk(order.ProductPk))
+		if err != nil {
+			return nil, err
+		}
+
+		return &ReferenceCard{
+			Kind:        ref.Kind,
+			Id:          order.Id,
+			ProductId:   product.Id,
+			Description: product.Description,
+			Price:       order.PurchasePrice,
+			CreatedAt:   order.CreatedAt.Unix(),
+		}, nil
+
+	case trialReference:
+		trial, err := tx.Get_TrialProduct_By_Pk(ctx, database.TrialProduct_Pk(ref.RefPk))
+		if err != nil {
+			return nil, err
+		}
+
+		product, err := tx.Get_Product_By_Pk(ctx, database.Product_Pk(trial.ProductPk))
+		if err != nil {
+			return nil, err
+		}
+
+		return &ReferenceCard{
+			Kind:        ref.Kind,
+			Id:          trial.Id,
+			ProductId:   product.Id,
+			Description: product.Description,
+			Price:       trial.TrialPrice,
+			CreatedAt:   trial.CreatedAt.Unix(),
+		}, nil
+	}
+
+	return nil, errs.New("unknown reference kind %q", ref.Kind)
+}
+
+//expandMessages converts messages for the api, filling in read receipts, attachments and
+//reference cards
+func expandMessages(ctx context.Context, tx *database.Tx, conversation *database.Conversation,
+	messages []*database.Message) ([]*Message, error) {
+
+	out := MessagesWithReceiptsFromDB(conversation, messages)
+	for i, m := range messages {
+		attachments, err := tx.All_MessageAttachment_By_MessagePk(ctx,
+			database.MessageAttachment_MessagePk(m.Pk))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range attachments {
+			out[i].Attachments = append(out[i].Attachments, AttachmentFromDB(a))
+		}
+
+		refs, err := tx.All_MessageReference_By_MessagePk(ctx,
+			database.MessageReference_MessagePk(m.Pk))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+			card, err := referenceCard(ctx, tx, ref)
+			if err != nil {
+				return nil, err
+			}
+			out[i].References = append(out[i].References, card)
+		}
+	}
+
+	return out, nil
+}
+
+//loadAttachment finds an attachment and the conversation its message belongs to so the caller can
+//check membership before handing out the contents
+func loadAttachment(ctx context.Context, tx *database.Tx, attachment_id string) (
+	*database.MessageAttachment, *database.Conversation, error) {
+
+	attachment, err := tx.Find_MessageAttachment_By_Id(ctx,
+		database.MessageAttachment_Id(attachment_id))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if attachment == nil {
+		return nil, nil, NotFound.New("attachment not found")
+	}
+
+	message, err := tx.Get_Message_By_Pk(ctx, database.Message_Pk(attachment.MessagePk))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversation, err := tx.Get_Conversation_By_Pk(ctx,
+		database.Conversation_Pk(message.ConversationPk))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attachment, conversation, nil
+}
+
+type MessageAttachmentResp struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
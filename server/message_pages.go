@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+//InvalidPage is returned when a page of messages is requested with both cursors set
+var InvalidPage = errs.Class("invalid page")
+
+//MessagePage is a page of messages newest first along with the cursors for the pages on either
+//side of it. Before is the conversation number to page back from and is zero once the start of
+//the conversation is reached. After is the conversation number to page forward from. an empty
+//page keeps the cursor it was requested with so the client can keep polling from it
+type MessagePage struct {
+	Messages []*Message `json:"messages"`
+	Before   int64      `json:"before"`
+	After    int64      `json:"after"`
+}
+
+//pageMessages loads a page of a conversation keyed on conversation number so that messages
+//posted between requests do not shift the page. before returns messages older than that number
+//and after returns messages newer than it. with neither set the newest messages are returned
+func pageMessages(ctx context.Context, tx *database.Tx, conversation *database.Conversation,
+	before, after int64) ([]*database.Message, error) {
+
+	if before != 0 && after != 0 {
+		return nil, InvalidPage.New("only one of before or after can be set")
+	}
+
+	if after != 0 {
+		messages, err := tx.Limited_Message_By_ConversationPk_And_ConversationNumber_Greater_OrderBy_Asc_ConversationNumber(
+			ctx, database.Message_ConversationPk(conversation.Pk),
+			database.Message_ConversationNumber(after), messageRequestLimit, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		//pages are always newest first regardless of the direction they were loaded in
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+
+		return messages, nil
+	}
+
+	if before == 0 {
+		before = conversation.MessageCount + 1
+	}
+
+	return tx.Limited_Message_By_ConversationPk_And_ConversationNumber_Less_OrderBy_Desc_ConversationNumber(
+		ctx, database.Message_ConversationPk(conversation.Pk),
+		database.Message_ConversationNumber(before), messageRequestLimit, 0)
+}
+
+//messagePageCursors returns the before and after cursors for a page from pageMessages given the
+//cursors it was requested with
+func messagePageCursors(messages []*database.Message, req_before, req_after int64) (
+	before, after int64) {
+
+	if len(messages) == 0 {
+		//nothing is older than req_before, so paging forward picks up from just below it
+		if req_before > 1 {
+			return 0, req_before - 1
+		}
+		return 0, req_after
+	}
+
+	before = messages[len(messages)-1].ConversationNumber
+	if before <= 1 {
+		before = 0
+	}
+
+	return before, messages[0].ConversationNumber
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	//searchContextMessages is how many messages either side of a hit are returned with it
+	searchContextMessages = 2
+	searchRequestLimit    = 20
+)
+
+//InvalidSearch is returned when a search query has nothing to match on
+var InvalidSearch = errs.Class("invalid search")
+
+//MessageSearchHit is a message that matched a search along with the messages around it in its
+//conversation. Context is oldest first and includes the hit itself
+type MessageSearchHit struct {
+	ConversationId string     `json:"conversationId"`
+	Message        *Message   `json:"message"`
+	Context        []*Message `json:"context"`
+}
+
+//messageSearchText is what message search matches against. it is stored alongside the message so
+//that matching is case insensitive on every database
+func messageSearchText(description string) string {
+	return strings.ToLower(description)
+}
+
+//messageSearchWords splits a search query into the words a message has to contain. LIKE
+//wildcards and escapes in the query are treated as spaces since sqlite searches with LIKE
+func messageSearchWords(query string) ([]string, error) {
+	words := strings.FieldsFunc(messageSearchText(query), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '%' || r == '_' || r == '\\'
+	})
+	if len(words) == 0 {
+		return nil, InvalidSearch.New("search query is empty")
+	}
+
+	return words, nil
+}
+
+//searchHits loads the conversation and surrounding messages for each message that matched a
+//search
+func searchHits(ctx context.Context, tx *database.Tx, messages []*database.Message) (
+	[]*MessageSearchHit, error) {
+
+	conversations := map[int64]*database.Conversation{}
+	hits := []*MessageSearchHit{}
+	for _, m := range messages {
+		conversation, ok := conversations[m.ConversationPk]
+		if !ok {
+			var err error
+			conversation, err = tx.Get_Conversation_By_Pk(ctx,
+				database.Conversation_Pk(m.ConversationPk))
+			if err != nil {
+				return nil, err
+			}
+			conversations[m.ConversationPk] = conversation
+		}
+
+		db_context, err := tx.Limited_Message_By_ConversationPk_And_ConversationNumber_Greater_OrderBy_Asc_ConversationNumber(
+			ctx, database.Message_ConversationPk(conversation.Pk),
+			database.Message_ConversationNumber(m.ConversationNumber-searchContextMessages-1),
+			2*searchContextMessages+1, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		surrounding, err := expandMessages(ctx, tx, conversation, db_context)
+		if err != nil {
+			return nil, err
+		}
+
+		hit := &MessageSearchHit{
+			ConversationId: conversation.Id,
+			Context:        surrounding,
+		}
+		for _, c := range surrounding {
+			if c.Id == m.Id {
+				hit.Message = c
+			}
+		}
+
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	signUps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladybug_sign_ups_total",
+		Help: "Accounts created, by buyer or vendor.",
+	}, []string{"kind"})
+
+	buyerLogIns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladybug_buyer_log_ins_total",
+		Help: "Buyer log in attempts, by whether they succeeded.",
+	}, []string{"result"})
+
+	trialsStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ladybug_trials_started_total",
+		Help: "Products buyers started trialing.",
+	})
+
+	messagesPosted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladybug_messages_posted_total",
+		Help: "Messages posted to conversations, by who sent them.",
+	}, []string{"sender"})
+
+	productsRegistered = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ladybug_products_registered_total",
+		Help: "Products vendors registered.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladybug_webhook_deliveries_total",
+		Help: "Webhook delivery attempts, by whether they succeeded, will be retried or failed.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(signUps, buyerLogIns, trialsStarted, messagesPosted,
+		productsRegistered, webhookDeliveries)
+}
+
+//countLogIn records how a log in attempt went. it is deferred so every way out is counted
+func countLogIn(err error) {
+	if err != nil {
+		buyerLogIns.WithLabelValues("failed").Inc()
+		return
+	}
+	buyerLogIns.WithLabelValues("succeeded").Inc()
+}
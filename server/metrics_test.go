@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessMetrics(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	buyer_sign_ups := testutil.ToFloat64(signUps.WithLabelValues("buyer"))
+	succeeded := testutil.ToFloat64(buyerLogIns.WithLabelValues("succeeded"))
+	failed := testutil.ToFloat64(buyerLogIns.WithLabelValues("failed"))
+
+	buyer := test.createFullTestBuyer(ctx)
+	require.Equal(t, testutil.ToFloat64(signUps.WithLabelValues("buyer")), buyer_sign_ups+1)
+
+	_, err := test.BuyerServer.BuyerLogIn(ctx, &LogInRequest{
+		Email:    buyer.emails[0].Address,
+		Password: buyer.emails[0].unsaltedPassword,
+	})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.BuyerLogIn(ctx, &LogInRequest{
+		Email:    buyer.emails[0].Address,
+		Password: "not the password",
+	})
+	require.Error(t, err)
+
+	require.Equal(t, testutil.ToFloat64(buyerLogIns.WithLabelValues("succeeded")), succeeded+1)
+	require.Equal(t, testutil.ToFloat64(buyerLogIns.WithLabelValues("failed")), failed+1)
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	maxMessageLength = 4000
+
+	//a sender can post messageRateLimit messages across all of their conversations in any
+	//messageRateWindow
+	messageRateLimit  = 20
+	messageRateWindow = time.Minute
+
+	maxReportReasonLength = 1000
+
+	buyerReporter  = "buyer"
+	vendorReporter = "vendor"
+	filterReporter = "filter"
+
+	reportOpen     = "open"
+	reportResolved = "resolved"
+)
+
+var (
+	//Blocked is returned when the other participant has blocked the conversation
+	Blocked = errs.Class("blocked")
+
+	//RateLimited is returned when a sender has posted too many messages recently
+	RateLimited = errs.Class("rate limited")
+
+	//InvalidMessage is returned for messages that are empty, too long or rejected by the content
+	//filter
+	InvalidMessage = errs.Class("invalid message")
+)
+
+type FilterVerdict int
+
+const (
+	//AllowMessage stores the message as normal
+	AllowMessage FilterVerdict = iota
+	//FlagMessage stores the message and opens a report for an admin to review
+	FlagMessage
+	//RejectMessage refuses the message before it is stored
+	RejectMessage
+)
+
+//ContentFilter is consulted for every message before it is stored
+type ContentFilter interface {
+	FilterMessage(ctx context.Context, buyer_sent bool, description string) (FilterVerdict, error)
+}
+
+//ContentFilterFunc lets an ordinary function be used as a ContentFilter
+type ContentFilterFunc func(ctx context.Context, buyer_sent bool, description string) (
+	FilterVerdict, error)
+
+func (f ContentFilterFunc) FilterMessage(ctx context.Context, buyer_sent bool,
+	description string) (FilterVerdict, error) {
+
+	return f(ctx, buyer_sent, description)
+}
+
+//checkMessage applies the length limits and the content filter to a message. a nil filter allows
+//everything
+func checkMessage(ctx context.Context, filter ContentFilter, buyer_sent bool,
+	description string) (FilterVerdict, error) {
+
+	if strings.TrimSpace(description) == "" {
+		return RejectMessage, InvalidMessage.New("message is empty")
+	}
+
+	if utf8.RuneCountInString(description) > maxMessageLength {
+		return RejectMessage, InvalidMessage.New("message is longer than %d characters",
+			maxMessageLength)
+	}
+
+	if filter == nil {
+		return AllowMessage, nil
+	}
+
+	verdict, err := filter.FilterMessage(ctx, buyer_sent, description)
+	if err != nil {
+		return RejectMessage, err
+	}
+
+	if verdict == RejectMessage {
+		return RejectMessage, InvalidMessage.New("message was rejected")
+	}
+
+	return verdict, nil
+}
+
+func messageRateCutoff(now time.Time) database.Message_CreatedAt_Field {
+	return database.Message_CreatedAt(now.UTC().Add(-messageRateWindow))
+}
+
+//checkBuyerSendRate locks the buyer so that concurrent posts are counted one after another
+func checkBuyerSendRate(ctx context.Context, tx *database.Tx, buyer_pk int64,
+	now time.Time) error {
+
+	err := tx.LockBuyer(ctx, buyer_pk)
+	if err != nil {
+		return err
+	}
+
+	count, err := tx.Count_Message_By_Conversation_BuyerPk_And_Message_BuyerSent_Equal_True_And_Message_CreatedAt_Greater(
+		ctx, database.Conversation_BuyerPk(buyer_pk), messageRateCutoff(now))
+	if err != nil {
+		return err
+	}
+
+	if count >= messageRateLimit {
+		return RateLimited.New("too many messages, try again later")
+	}
+
+	return nil
+}
+
+//checkVendorSendRate locks the vendor so that concurrent posts are counted one after another
+func checkVendorSendRate(ctx context.Context, tx *database.Tx, vendor_pk int64,
+	now time.Time) error {
+
+	err := tx.LockVendor(ctx, vendor_pk)
+	if err != nil {
+		return err
+	}
+
+	count, err := tx.Count_Message_By_Conversation_VendorPk_And_Message_BuyerSent_Equal_False_And_Message_CreatedAt_Greater(
+		ctx, database.Conversation_VendorPk(vendor_pk), messageRateCutoff(now))
+	if err != nil {
+		return err
+	}
+
+	if count >= messageRateLimit {
+		return RateLimited.New("too many messages, try again later")
+	}
+
+	return nil
+}
+
+//flagConversation reports a conversation on behalf of the content filter
+func flagConversation(ctx context.Context, tx *database.Tx,
+	conversation *database.Conversation) error {
+
+	reason := "flagged by content filter"
+	report, err := reportConversation(ctx, tx, conversation, filterReporter, reason)
+	if err != nil {
+		return err
+	}
+
+	return audit(ctx, tx, &auditEvent{
+		actorKind:  SystemActor,
+		actorId:    filterReporter,
+		action:     AuditConversationReport,
+		targetKind: "conversation",
+		targetId:   conversation.Id,
+		after:      auditFields{"reportId": report.Id, "reason": reason},
+	})
+}
+
+//reportConversation opens a report on a conversation for admin review with a snapshot of its
+//messages
+func reportConversation(ctx context.Context, tx *database.Tx, conversation *database.Conversation,
+	reporter, reason string) (*database.ConversationReport, error) {
+
+	if utf8.RuneCountInString(reason) > maxReportReasonLength {
+		return nil, InvalidMessage.New("reason is longer than %d characters",
+			maxReportReasonLength)
+	}
+
+	messages, err := tx.All_Message_By_ConversationPk_And_ConversationNumber_Greater_OrderBy_Asc_ConversationNumber(
+		ctx, database.Message_ConversationPk(conversation.Pk), database.Message_ConversationNumber(0))
+	if err != nil {
+		return nil, err
+	}
+
+	transcript, err := json.Marshal(MessagesFromDB(messages))
+	if err != nil {
+		return nil, errs.Wrap(err)
+	}
+
+	return tx.Create_ConversationReport(ctx,
+		database.ConversationReport_Id(uuid.NewV4().String()),
+		database.ConversationReport_ConversationPk(conversation.Pk),
+		database.ConversationReport_Reporter(reporter),
+		database.ConversationReport_Reason(reason),
+		database.ConversationReport_Transcript(string(transcript)),
+		database.ConversationReport_Status(reportOpen),
+		database.ConversationReport_Resolution(""))
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+func TestBlockedParticipantCannotPost(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	conversation := test.createConversationInDB(buyer, vendor)
+
+	buyer_req := &PostBuyerMessageToConversationReq{
+		BuyerPk:            buyer.Pk,
+		VendorId:           vendor.Id,
+		MessageDescription: "hello?",
+	}
+	vendor_req := &PostVendorMessageToConversationReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		BuyerId:            buyer.Id,
+		MessageDescription: "hi there",
+	}
+
+	//the vendor blocks the buyer
+	_, err := test.VendorServer.BlockVendorConversation(ctx, &BlockVendorConversationReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ConversationId:     conversation.Id,
+		Blocked:            true,
+	})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, buyer_req)
+	require.True(t, Blocked.Has(err))
+
+	//the vendor can still post
+	_, err = test.VendorServer.PostVendorMessageToConversation(ctx, vendor_req)
+	require.NoError(t, err)
+
+	//unblocking lets the buyer post again
+	_, err = test.VendorServer.BlockVendorConversation(ctx, &BlockVendorConversationReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ConversationId:     conversation.Id,
+		Blocked:            false,
+	})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, buyer_req)
+	require.NoError(t, err)
+
+	//only participants can block
+	_, err = test.BuyerServer.BlockBuyerConversation(ctx, &BlockBuyerConversationReq{
+		BuyerPk:        test.createBuyer(ctx, &createBuyerInDBOptions{}).Pk,
+		ConversationId: conversation.Id,
+		Blocked:        true,
+	})
+	require.True(t, NotFound.Has(err))
+}
+
+func TestMessageLimits(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	req := &PostBuyerMessageToConversationReq{
+		BuyerPk:  buyer.Pk,
+		VendorId: vendor.Id,
+	}
+
+	//empty and overlong messages
+	req.MessageDescription = "  "
+	_, err := test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, InvalidMessage.Has(err))
+
+	req.MessageDescription = strings.Repeat("a", maxMessageLength+1)
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, InvalidMessage.Has(err))
+
+	//the rate limit covers every conversation the buyer is in
+	req.MessageDescription = "ping"
+	for i := 0; i < messageRateLimit-1; i++ {
+		_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+		require.NoError(t, err)
+	}
+
+	req.VendorId = test.createVendorInDB(ctx).Id
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, RateLimited.Has(err))
+
+	//the window follows the database clock
+	test.db.Hooks.Now = func() time.Time { return time.Now().Add(messageRateWindow) }
+	defer func() { test.db.Hooks.Now = time.Now }()
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.NoError(t, err)
+}
+
+func TestContentFilter(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	test.BuyerServer.filter = ContentFilterFunc(func(ctx context.Context, buyer_sent bool,
+		description string) (FilterVerdict, error) {
+
+		switch {
+		case strings.Contains(description, "spam"):
+			return RejectMessage, nil
+		case strings.Contains(description, "suspicious"):
+			return FlagMessage, nil
+		}
+		return AllowMessage, nil
+	})
+	admin := NewAdminServer(test.db)
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	req := &PostBuyerMessageToConversationReq{
+		BuyerPk:            buyer.Pk,
+		VendorId:           vendor.Id,
+		MessageDescription: "buy cheap spam",
+	}
+
+	//rejected messages are not stored
+	_, err := test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.True(t, InvalidMessage.Has(err))
+	conversation, err := test.db.Find_Conversation_By_VendorPk_And_BuyerPk(ctx,
+		database.Conversation_VendorPk(vendor.Pk), database.Conversation_BuyerPk(buyer.Pk))
+	require.NoError(t, err)
+	require.Nil(t, conversation)
+
+	//flagged messages are stored and reported
+	req.MessageDescription = "a suspicious offer"
+	resp, err := test.BuyerServer.PostBuyerMessageToConversation(ctx, req)
+	require.NoError(t, err)
+
+	reports, err := admin.PagedOpenConversationReports(ctx, &PagedOpenConversationReportsReq{})
+	require.NoError(t, err)
+	require.Len(t, reports.Reports, 1)
+	require.Equal(t, reports.Reports[0].Reporter, filterReporter)
+	require.Len(t, reports.Reports[0].Transcript, 1)
+	require.Equal(t, reports.Reports[0].Transcript[0].Id, resp.Message.Id)
+}
+
+func TestReportConversation(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	admin := NewAdminServer(test.db)
+	vendor := test.createVendorInDB(ctx)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	conversation := test.createConversationInDB(buyer, vendor)
+	test.createMessageHistory(ctx, conversation, 6)
+
+	resp, err := test.BuyerServer.ReportBuyerConversation(ctx, &ReportBuyerConversationReq{
+		BuyerPk:        buyer.Pk,
+		ConversationId: conversation.Id,
+		Reason:         "rude",
+	})
+	require.NoError(t, err)
+
+	//the transcript is a snapshot taken when the report was made
+	test.createMessageHistory(ctx, conversation, 2)
+
+	reports, err := admin.PagedOpenConversationReports(ctx, &PagedOpenConversationReportsReq{})
+	require.NoError(t, err)
+	require.Len(t, reports.Reports, 1)
+	report := reports.Reports[0]
+	require.Equal(t, report.Id, resp.ReportId)
+	require.Equal(t, report.ConversationId, conversation.Id)
+	require.Equal(t, report.Reporter, buyerReporter)
+	require.Equal(t, report.Reason, "rude")
+	require.Len(t, report.Transcript, 6)
+	require.Equal(t, report.Transcript[5].MessageNumber, int64(6))
+
+	//resolving takes it off the queue
+	resolved, err := admin.ResolveConversationReport(ctx, &ResolveConversationReportReq{
+		ReportId:   resp.ReportId,
+		Resolution: "warned the vendor",
+	})
+	require.NoError(t, err)
+	require.Equal(t, resolved.Report.Status, reportResolved)
+
+	reports, err = admin.PagedOpenConversationReports(ctx, &PagedOpenConversationReportsReq{})
+	require.NoError(t, err)
+	require.Len(t, reports.Reports, 0)
+
+	_, err = admin.ResolveConversationReport(ctx, &ResolveConversationReportReq{
+		ReportId: "not-a-report",
+	})
+	require.True(t, NotFound.Has(err))
+
+	//only participants can report
+	other_vendor := test.createVendorInDB(ctx)
+	_, err = test.VendorServer.ReportVendorConversation(ctx, &ReportVendorConversationReq{
+		VendorPk:           other_vendor.Pk,
+		ExecutiveContactPk: test.createExecutiveContact(ctx, other_vendor.Pk, OwnerRole).Pk,
+		ConversationId:     conversation.Id,
+	})
+	require.True(t, NotFound.Has(err))
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	maxProductAttributes = 3
+	maxAttributeLength   = 50
+)
+
+//InvalidVariant is returned when a product with variants is tried without picking one
+var InvalidVariant = errs.Class("invalid variant")
+
+//skuTaken reports whether any of the vendor's products or variants already use sku
+func skuTaken(ctx context.Context, tx *database.Tx, vendor_pk int64, sku string) (bool, error) {
+	taken, err := tx.Has_Product_By_VendorPk_And_Sku(ctx,
+		database.Product_VendorPk(vendor_pk), database.Product_Sku(sku))
+	if err != nil || taken {
+		return taken, err
+	}
+
+	return tx.Has_ProductVariant_By_VendorPk_And_Sku(ctx,
+		database.ProductVariant_VendorPk(vendor_pk), database.ProductVariant_Sku(sku))
+}
+
+//checkAttributeNames validates the attributes a product's variants differ by, e.g. size and color
+func checkAttributeNames(names []string) error {
+	if len(names) > maxProductAttributes {
+		return errs.New("a product can have at most %d attributes", maxProductAttributes)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" || len(name) > maxAttributeLength {
+			return errs.New("attribute names must be 1 to %d characters", maxAttributeLength)
+		}
+		if seen[name] {
+			return errs.New("attribute %q is listed twice", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+func encodeAttributeNames(names []string) string {
+	if names == nil {
+		names = []string{}
+	}
+	b, _ := json.Marshal(names)
+	return string(b)
+}
+
+//decodeAttributeNames reads product.attributes. products created before variants existed have
+//no attributes
+func decodeAttributeNames(s string) []string {
+	names := []string{}
+	_ = json.Unmarshal([]byte(s), &names)
+	return names
+}
+
+//checkVariantAttributes makes sure a variant gives a value for exactly the product's attributes
+func checkVariantAttributes(names []string, values map[string]string) error {
+	if len(values) != len(names) {
+		return errs.New("a variant needs a value for each of %s", strings.Join(names, ", "))
+	}
+
+	for _, name := range names {
+		value, ok := values[name]
+		if !ok {
+			return errs.New("a variant needs a value for each of %s", strings.Join(names, ", "))
+		}
+		if strings.TrimSpace(value) == "" || len(value) > maxAttributeLength {
+			return errs.New("attribute values must be 1 to %d characters", maxAttributeLength)
+		}
+	}
+
+	return nil
+}
+
+type ProductVariant struct {
+	Id             string            `json:"id"`
+	ProductId      string            `json:"productId"`
+	Sku            string            `json:"sku"`
+	Attributes     map[string]string `json:"attributes"`
+	Price          float32           `json:"price"`
+	Discount       float32           `json:"discount"`
+	DiscountActive bool              `json:"discountActive"`
+	NumInStock     int               `json:"numInStock"`
+	GoogleBucketId string            `json:"googleBucketId"`
+}
+
+func ProductVariantFromDB(product *database.Product,
+	variant *database.ProductVariant) *ProductVariant {
+
+	attributes := map[string]string{}
+	_ = json.Unmarshal([]byte(variant.Attributes), &attributes)
+
+	return &ProductVariant{
+		Id:             variant.Id,
+		ProductId:      product.Id,
+		Sku:            variant.Sku,
+		Attributes:     attributes,
+		Price:          variant.Price,
+		Discount:       variant.Discount,
+		DiscountActive: variant.DiscountActive,
+		NumInStock:     variant.NumInStock,
+		GoogleBucketId: variant.GoogleBucketId,
+	}
+}
+
+//findVendorProduct loads one of the vendor's products by id
+func findVendorProduct(ctx context.Context, tx *database.Tx, vendor_pk int64, product_id string) (
+	*database.Product, error) {
+
+	product, err := tx.Find_Product_By_Id(ctx, database.Product_Id(product_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if product == nil || product.VendorPk != vendor_pk {
+		return nil, NotFound.New("product not found")
+	}
+
+	return product, nil
+}
+
+//findProductVariant loads a variant and the product it belongs to
+func findProductVariant(ctx context.Context, tx *database.Tx, variant_id string) (
+	*database.ProductVariant, *database.Product, error) {
+
+	variant, err := tx.Find_ProductVariant_By_Id(ctx, database.ProductVariant_Id(variant_id))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if variant == nil {
+		return nil, nil, NotFound.New("variant not found")
+	}
+
+	product, err := tx.Get_Product_By_Pk(ctx, database.Product_Pk(variant.ProductPk))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return variant, product, nil
+}
+
+type AddProductVariantReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ProductId          string            `json:"productId"`
+	Sku                string            `json:"sku"`
+	Attributes         map[string]string `json:"attributes"`
+	Price              float32           `json:"price"`
+	Discount           float32           `json:"discount"`
+	DiscountActive     bool              `json:"discountActive"`
+	NumInStock         int               `json:"numInStock"`
+	GoogleBucketId     string            `json:"googleBucketId"`
+}
+
+type AddProductVariantResp struct {
+	Variant *ProductVariant `json:"variant"`
+}
+
+//AddProductVariant adds a version of a product that buyers can pick. it must give a value for
+//each of the product's attributes and no other variant of the product may have the same values
+func (v *VendorServer) AddProductVariant(ctx context.Context, req *AddProductVariantReq) (
+	resp *AddProductVariantResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.AddProductVariant")
+	defer end(&err)
+
+	row := &CatalogRow{Sku: req.Sku, Price: req.Price, Discount: req.Discount,
+		NumInStock: req.NumInStock}
+	if err := row.validate(); err != nil {
+		return nil, err
+	}
+
+	var variant *ProductVariant
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		product, err := findVendorProduct(ctx, tx, req.VendorPk, req.ProductId)
+		if err != nil {
+			return err
+		}
+
+		names := decodeAttributeNames(product.Attributes)
+		if len(names) == 0 {
+			return errs.New("the product has no attributes to vary by")
+		}
+
+		err = checkVariantAttributes(names, req.Attributes)
+		if err != nil {
+			return err
+		}
+
+		//json.Marshal sorts map keys so equal attributes always encode the same way
+		attributes, err := json.Marshal(req.Attributes)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+
+		siblings, err := tx.All_ProductVariant_By_ProductPk_OrderBy_Asc_Pk(ctx,
+			database.ProductVariant_ProductPk(product.Pk))
+		if err != nil {
+			return err
+		}
+
+		for _, s := range siblings {
+			if s.Attributes == string(attributes) {
+				return errs.New("the product already has a variant with these attributes")
+			}
+		}
+
+		taken, err := skuTaken(ctx, tx, req.VendorPk, req.Sku)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return errs.New("sku %q is already used by another of your products", req.Sku)
+		}
+
+		db_variant, err := tx.Create_ProductVariant(ctx,
+			database.ProductVariant_Id(uuid.NewV4().String()),
+			database.ProductVariant_ProductPk(product.Pk),
+			database.ProductVariant_VendorPk(req.VendorPk),
+			database.ProductVariant_Sku(req.Sku),
+			database.ProductVariant_Attributes(string(attributes)),
+			database.ProductVariant_Price(req.Price),
+			database.ProductVariant_Discount(req.Discount),
+			database.ProductVariant_DiscountActive(req.DiscountActive),
+			database.ProductVariant_NumInStock(req.NumInStock),
+			database.ProductVariant_GoogleBucketId(req.GoogleBucketId))
+		if err != nil {
+			return err
+		}
+
+		err = auditContact(ctx, tx, actor.Id, AuditVariantCreate, "product_variant",
+			db_variant.Id, nil, variantAuditFields(db_variant))
+		if err != nil {
+			return err
+		}
+
+		variant = ProductVariantFromDB(product, db_variant)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddProductVariantResp{
+		Variant: variant,
+	}, nil
+}
+
+type UpdateProductVariantReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	VariantId          string  `json:"variantId"`
+	Price              float32 `json:"price"`
+	Discount           float32 `json:"discount"`
+	DiscountActive     bool    `json:"discountActive"`
+	NumInStock         int     `json:"numInStock"`
+	GoogleBucketId     string  `json:"googleBucketId"`
+}
+
+type UpdateProductVariantResp struct {
+	Variant *ProductVariant `json:"variant"`
+}
+
+//UpdateProductVariant changes a variant's price, stock and images. its sku and attributes are
+//fixed once it exists
+func (v *VendorServer) UpdateProductVariant(ctx context.Context,
+	req *UpdateProductVariantReq) (resp *UpdateProductVariantResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.UpdateProductVariant")
+	defer end(&err)
+
+	var variant *ProductVariant
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		db_variant, product, err := findProductVariant(ctx, tx, req.VariantId)
+		if err != nil {
+			return err
+		}
+
+		if db_variant.VendorPk != req.VendorPk {
+			return NotFound.New("variant not found")
+		}
+
+		row := &CatalogRow{Sku: db_variant.Sku, Price: req.Price, Discount: req.Discount,
+			NumInStock: req.NumInStock}
+		if err := row.validate(); err != nil {
+			return err
+		}
+
+		before := variantAuditFields(db_variant)
+		db_variant, err = tx.Update_ProductVariant_By_Pk(ctx,
+			database.ProductVariant_Pk(db_variant.Pk),
+			database.ProductVariant_Update_Fields{
+				Price:          database.ProductVariant_Price(req.Price),
+				Discount:       database.ProductVariant_Discount(req.Discount),
+				DiscountActive: database.ProductVariant_DiscountActive(req.DiscountActive),
+				NumInStock:     database.ProductVariant_NumInStock(req.NumInStock),
+				GoogleBucketId: database.ProductVariant_GoogleBucketId(req.GoogleBucketId),
+			})
+		if err != nil {
+			return err
+		}
+
+		err = auditContact(ctx, tx, actor.Id, AuditVariantUpdate, "product_variant",
+			db_variant.Id, before, variantAuditFields(db_variant))
+		if err != nil {
+			return err
+		}
+
+		variant = ProductVariantFromDB(product, db_variant)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateProductVariantResp{
+		Variant: variant,
+	}, nil
+}
+
+type ProductVariantsReq struct {
+	ProductId string
+}
+
+type ProductVariantsResp struct {
+	Attributes []string          `json:"attributes"`
+	Variants   []*ProductVariant `json:"variants"`
+}
+
+//ProductVariants lists the versions of a product buyers can choose between. it is public
+func (u *BuyerServer) ProductVariants(ctx context.Context, req *ProductVariantsReq) (
+	resp *ProductVariantsResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.ProductVariants")
+	defer end(&err)
+
+	resp = &ProductVariantsResp{Variants: []*ProductVariant{}}
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		product, err := tx.Find_Product_By_Id(ctx, database.Product_Id(req.ProductId))
+		if err != nil {
+			return err
+		}
+
+		if product == nil || !product.ProductActive || !product.LadybugApproved {
+			return NotFound.New("product not found")
+		}
+
+		variants, err := tx.All_ProductVariant_By_ProductPk_OrderBy_Asc_Pk(ctx,
+			database.ProductVariant_ProductPk(product.Pk))
+		if err != nil {
+			return err
+		}
+
+		resp.Attributes = decodeAttributeNames(product.Attributes)
+		for _, variant := range variants {
+			resp.Variants = append(resp.Variants, ProductVariantFromDB(product, variant))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+//updateProductRating recomputes a product's rating from all of its reviews. reviews are left on
+//the product rather than a variant so every variant shares them
+func updateProductRating(ctx context.Context, tx *database.Tx, product_pk int64) error {
+	reviews, err := tx.All_ProductReview_By_ProductPk(ctx,
+		database.ProductReview_ProductPk(product_pk))
+	if err != nil {
+		return err
+	}
+
+	var rating float32
+	if len(reviews) > 0 {
+		total := 0
+		for _, r := range reviews {
+			total += r.Rating
+		}
+		rating = float32(total) / float32(len(reviews))
+	}
+
+	_, err = tx.Update_Product_By_Pk(ctx, database.Product_Pk(product_pk),
+		database.Product_Update_Fields{
+			Rating: database.Product_Rating(rating),
+		})
+	return err
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+func TestProductVariants(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	manager := test.createExecutiveContact(ctx, vendor.Pk, CatalogManagerRole)
+
+	resp, err := test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		UnitPrice:          20,
+		SKU:                "SHIRT",
+		ProductActive:      true,
+		NumberInStock:      1,
+		Attributes:         []string{"size", "color"},
+	})
+	require.NoError(t, err)
+
+	add := &AddProductVariantReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		ProductId:          resp.ProductId,
+		Sku:                "SHIRT-S-RED",
+		Attributes:         map[string]string{"size": "S", "color": "red"},
+		Price:              18,
+		NumInStock:         4,
+	}
+	small, err := test.VendorServer.AddProductVariant(ctx, add)
+	require.NoError(t, err)
+	require.Equal(t, small.Variant.Attributes["size"], "S")
+
+	//skus are unique across the vendor's products and variants
+	_, err = test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		UnitPrice:          5,
+		SKU:                "SHIRT-S-RED",
+	})
+	require.Error(t, err)
+
+	add.Attributes = map[string]string{"size": "M", "color": "red"}
+	_, err = test.VendorServer.AddProductVariant(ctx, add)
+	require.Error(t, err)
+
+	//another vendor can reuse the sku
+	other_vendor := test.createVendorInDB(ctx)
+	_, err = test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           other_vendor.Pk,
+		ExecutiveContactPk: test.createExecutiveContact(ctx, other_vendor.Pk, OwnerRole).Pk,
+		UnitPrice:          5,
+		SKU:                "SHIRT-S-RED",
+	})
+	require.NoError(t, err)
+
+	//the same attributes cannot be added twice and every attribute needs a value
+	add.Sku = "SHIRT-S-RED-2"
+	add.Attributes = map[string]string{"size": "S", "color": "red"}
+	_, err = test.VendorServer.AddProductVariant(ctx, add)
+	require.Error(t, err)
+
+	add.Attributes = map[string]string{"size": "M"}
+	_, err = test.VendorServer.AddProductVariant(ctx, add)
+	require.Error(t, err)
+
+	add.Sku = "SHIRT-M-RED"
+	add.Attributes = map[string]string{"size": "M", "color": "red"}
+	_, err = test.VendorServer.AddProductVariant(ctx, add)
+	require.NoError(t, err)
+
+	//variants keep their own price and stock
+	updated, err := test.VendorServer.UpdateProductVariant(ctx, &UpdateProductVariantReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: manager.Pk,
+		VariantId:          small.Variant.Id,
+		Price:              15,
+		NumInStock:         9,
+	})
+	require.NoError(t, err)
+	require.Equal(t, updated.Variant.Price, float32(15))
+	require.Equal(t, updated.Variant.Sku, "SHIRT-S-RED")
+
+	//buyers only see variants of approved products
+	_, err = test.BuyerServer.ProductVariants(ctx, &ProductVariantsReq{
+		ProductId: resp.ProductId,
+	})
+	require.True(t, NotFound.Has(err))
+
+	_, err = test.db.Update_Product_By_Pk(ctx, database.Product_Pk(test.productPk(ctx,
+		resp.ProductId)), database.Product_Update_Fields{
+		LadybugApproved: database.Product_LadybugApproved(true),
+	})
+	require.NoError(t, err)
+
+	variants, err := test.BuyerServer.ProductVariants(ctx, &ProductVariantsReq{
+		ProductId: resp.ProductId,
+	})
+	require.NoError(t, err)
+	require.Equal(t, variants.Attributes, []string{"size", "color"})
+	require.Len(t, variants.Variants, 2)
+	require.Equal(t, variants.Variants[0].NumInStock, 9)
+}
+
+func (h *serverTest) productPk(ctx context.Context, product_id string) int64 {
+	product, err := h.db.Get_Product_By_Id(ctx, database.Product_Id(product_id))
+	require.NoError(h.t, err)
+
+	return product.Pk
+}
+
+func TestStartVariantTrial(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	shirt := test.createProductInDB(ctx, vendor.Pk, &productOptions{ProductActive: true,
+		LadybugApproved: true, Attributes: []string{"size"}})
+	mug := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+
+	large, err := test.VendorServer.AddProductVariant(ctx, &AddProductVariantReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ProductId:          shirt.Id,
+		Sku:                "SHIRT-L",
+		Attributes:         map[string]string{"size": "L"},
+		Price:              42,
+		NumInStock:         1,
+	})
+	require.NoError(t, err)
+
+	req := &StartProductTrialReq{
+		BuyerPk:   buyer.Pk,
+		VendorId:  vendor.Id,
+		ProductId: shirt.Id,
+	}
+
+	//a product with variants needs one picked
+	_, err = test.BuyerServer.StartProductTrial(ctx, req)
+	require.True(t, InvalidVariant.Has(err))
+
+	req.VariantId = large.Variant.Id
+	resp, err := test.BuyerServer.StartProductTrial(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, resp.TrialProduct.TrialPrice, float32(42))
+
+	trial, err := test.db.Find_TrialProduct_By_Id(ctx,
+		database.TrialProduct_Id(resp.TrialProduct.Id))
+	require.NoError(t, err)
+	require.Equal(t, trial.ProductPk, shirt.Pk)
+	require.NotEqual(t, trial.VariantPk, int64(0))
+
+	//variants of other products are refused
+	req.ProductId = mug.Id
+	_, err = test.BuyerServer.StartProductTrial(ctx, req)
+	require.True(t, NotFound.Has(err))
+}
+
+func TestProductRatingRollup(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	buyers := test.createDefaultBuyers(ctx, 2)
+	product := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+
+	for i, stars := range []int{5, 2} {
+		test.purchaseProduct(ctx, buyers[i].Pk, vendor.Pk, product)
+		_, err := test.BuyerServer.ReviewProduct(ctx, &ProductReviewReq{
+			BuyerPk:   buyers[i].Pk,
+			ProductId: product.Id,
+			Stars:     stars,
+		})
+		require.NoError(t, err)
+	}
+
+	rated, err := test.db.Get_Product_By_Id(ctx, database.Product_Id(product.Id))
+	require.NoError(t, err)
+	require.Equal(t, rated.Rating, float32(3.5))
+
+	_, err = test.BuyerServer.UpdateProductReview(ctx, &UpdateProductReviewReq{
+		BuyerPk:   buyers[1].Pk,
+		ProductId: product.Id,
+		Stars:     4,
+	})
+	require.NoError(t, err)
+
+	rated, err = test.db.Get_Product_By_Id(ctx, database.Product_Id(product.Id))
+	require.NoError(t, err)
+	require.Equal(t, rated.Rating, float32(4.5))
+}
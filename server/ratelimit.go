@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+//RateLimit is a token bucket that holds Requests tokens and refills completely over Per. a client
+//can burst through the whole bucket and then gets one request every Per/Requests
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+//refill is the tokens a bucket regains per second
+func (l RateLimit) refill() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+//RateLimitResult is what taking a token left the bucket at
+type RateLimitResult struct {
+	Allowed bool
+
+	//Remaining is the number of whole tokens left
+	Remaining int
+
+	//RetryAfter is how long until the next token. it is zero when one is available
+	RetryAfter time.Duration
+
+	//Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+//rateLimitResult works out the result for a bucket that has tokens left after a take
+func rateLimitResult(limit RateLimit, tokens float64, allowed bool) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsDuration((float64(limit.Requests) - tokens) / limit.refill()),
+	}
+	if tokens < 1 {
+		result.RetryAfter = secondsDuration((1 - tokens) / limit.refill())
+	}
+
+	return result
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+//RateLimitStore keeps the token buckets of rate limited clients
+type RateLimitStore interface {
+	//Take refills the bucket named name for the time passed since it was last used and takes a
+	//token from it if there is one
+	Take(ctx context.Context, name string, limit RateLimit, now time.Time) (
+		RateLimitResult, error)
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+
+	//fullAt is when the bucket will have refilled, after which it can be forgotten
+	fullAt time.Time
+}
+
+//MemoryRateLimitStore keeps buckets in process. each instance limits on its own, so clients get
+//the limit once per instance behind the load balancer
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, name string, limit RateLimit,
+	now time.Time) (RateLimitResult, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[name]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updatedAt: now}
+		s.buckets[name] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Requests),
+		b.tokens+now.Sub(b.updatedAt).Seconds()*limit.refill())
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	result := rateLimitResult(limit, b.tokens, allowed)
+	b.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+//sweep drops full buckets every so often so clients that went away do not pile up. a full bucket
+//is the same as a new one, so dropping it changes nothing for the client
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for name, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, name)
+		}
+	}
+}
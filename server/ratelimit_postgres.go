@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+//bucketRetention is how long unused buckets are kept. limits are expected to refill well within it
+const bucketRetention = 24 * time.Hour
+
+//refilledTokens is the bucket's tokens after refilling for the time since it was last used. $2 is
+//the bucket size, $3 the tokens regained per second and $4 the time now
+const refilledTokens = `LEAST($2::float8, b.tokens +
+	GREATEST(0, EXTRACT(EPOCH FROM ($4 - b.updated_at))::float8) * $3::float8)`
+
+//takeToken refills and takes from a bucket in one statement so instances racing on the same bucket
+//cannot both spend its last token
+var takeToken = fmt.Sprintf(`
+INSERT INTO rate_limit_buckets AS b ( name, tokens, allowed, updated_at )
+VALUES ( $1, $2::float8 - 1, true, $4 )
+ON CONFLICT ( name ) DO UPDATE SET
+	allowed = %[1]s >= 1,
+	tokens = %[1]s - CASE WHEN %[1]s >= 1 THEN 1 ELSE 0 END,
+	updated_at = GREATEST(b.updated_at, $4)
+RETURNING tokens, allowed`, refilledTokens)
+
+//PostgresRateLimitStore keeps buckets in the database so every instance enforces the same limit
+type PostgresRateLimitStore struct {
+	db *database.DB
+}
+
+func NewPostgresRateLimitStore(db *database.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+func (s *PostgresRateLimitStore) Take(ctx context.Context, name string, limit RateLimit,
+	now time.Time) (RateLimitResult, error) {
+
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, takeToken, name, float64(limit.Requests), limit.refill(),
+		now).Scan(&tokens, &allowed)
+	if err != nil {
+		return RateLimitResult{}, errs.Wrap(err)
+	}
+
+	return rateLimitResult(limit, tokens, allowed), nil
+}
+
+//RunPrune deletes buckets unused for bucketRetention every interval until ctx is done
+func (s *PostgresRateLimitStore) RunPrune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.db.WithContext(ctx).Delete_RateLimitBucket_By_UpdatedAt_Less(ctx,
+			database.RateLimitBucket_UpdatedAt(time.Now().Add(-bucketRetention)))
+		if err != nil {
+			Logger(ctx).Errorf("pruning rate limit buckets failed: %+v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -13,27 +13,53 @@ import (
 type serverTest struct {
 	t            *testing.T
 	db           *database.DB
+	hub          *LocalHub
+	mailer       *testMailer
 	BuyerServer  *BuyerServer
 	VendorServer *VendorServer
 }
 
+type sentMail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+//testMailer keeps the mail it is asked to send so tests can read it
+type testMailer struct {
+	sent []sentMail
+}
+
+func (m *testMailer) SendMail(ctx context.Context, to, subject, body string) error {
+	m.sent = append(m.sent, sentMail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
 //NOTE: just as a reminder while you are going through your tests create convience funtions that do
 //things like create buyers etc
 func newTest(t *testing.T) *serverTest {
 
-	db, err := database.Open("sqlite3", "file:memdb1?mode=memory&cache=shared")
+	//opened the way main opens it so statements go through the tracing driver
+	db, err := database.OpenTraced("sqlite3", "file:memdb1?mode=memory&cache=shared")
 	require.NoError(t, err)
 
 	//initialize database with schema
 	_, err = db.Exec(db.Schema())
 	require.NoError(t, err)
 
-	buyer_server := NewBuyerServer(db)
-	vendor_server := NewVendorServer(db)
+	blobs, err := NewDirBlobStore(t.TempDir())
+	require.NoError(t, err)
+
+	hub := NewLocalHub()
+	mailer := &testMailer{}
+	buyer_server := NewBuyerServer(db, hub, blobs, nil, mailer, 0)
+	vendor_server := NewVendorServer(db, hub, blobs, nil, mailer, 0)
 
 	return &serverTest{
 		t:            t,
 		db:           db,
+		hub:          hub,
+		mailer:       mailer,
 		BuyerServer:  buyer_server,
 		VendorServer: vendor_server,
 	}
@@ -55,6 +81,22 @@ func (h *serverTest) createVendorInDB(ctx context.Context) *database.Vendor {
 	return vendor
 }
 
+//createExecutiveContact adds a member with the given role to a vendor's team
+func (h *serverTest) createExecutiveContact(ctx context.Context, vendor_pk int64,
+	role string) *database.ExecutiveContact {
+
+	contact, err := h.db.Create_ExecutiveContact(ctx,
+		database.ExecutiveContact_Id(uuid.NewV4().String()),
+		database.ExecutiveContact_VendorPk(vendor_pk),
+		database.ExecutiveContact_FirstName("some_firstName"),
+		database.ExecutiveContact_LastName("some_last_name"),
+		database.ExecutiveContact_Role(role),
+	)
+	require.NoError(h.t, err)
+
+	return contact
+}
+
 type createBuyerInDBOptions struct {
 	firstName string
 	lastName  string
@@ -77,6 +119,7 @@ func (h *serverTest) createBuyer(ctx context.Context, options *createBuyerInDBOp
 		database.Buyer_Id(uuid.NewV4().String()),
 		database.Buyer_FirstName(options.firstName),
 		database.Buyer_LastName(options.lastName),
+		database.Buyer_SaltedHash(""),
 	)
 	require.NoError(h.t, err)
 
@@ -143,6 +186,7 @@ type productOptions struct {
 	NumInStock      int
 	Description     string
 	Rating          float32
+	Attributes      []string
 }
 
 //setDefaultOptions allows the caller of the function to pass in variables
@@ -198,6 +242,7 @@ func (h *serverTest) createProductInDB(ctx context.Context, vendor_pk int64,
 		database.Product_NumInStock(options.NumInStock),
 		database.Product_Description(options.Description),
 		database.Product_Rating(options.Rating),
+		database.Product_Attributes(encodeAttributeNames(options.Attributes)),
 	)
 	require.NoError(h.t, err)
 
@@ -211,6 +256,7 @@ func (h *serverTest) purchaseProduct(ctx context.Context, buyer_pk, vendor_pk in
 		database.PurchasedProduct_VendorPk(vendor_pk),
 		database.PurchasedProduct_BuyerPk(buyer_pk),
 		database.PurchasedProduct_ProductPk(product.Pk),
+		database.PurchasedProduct_VariantPk(0),
 		database.PurchasedProduct_PurchasePrice(product.Price),
 	)
 	require.NoError(h.t, err)
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+
+	"ladybug/database"
+)
+
+type StorefrontReq struct {
+	Slug string
+}
+
+type StorefrontResp struct {
+	Profile *VendorProfile `json:"profile"`
+	//Rating is the average of every review left on the vendor's products. it is 0 when there are
+	//no reviews
+	Rating      float32 `json:"rating"`
+	ReviewCount int     `json:"reviewCount"`
+}
+
+//findStorefront loads the vendor behind a storefront slug. vendors that have not set up a profile
+//do not have a storefront
+func findStorefront(ctx context.Context, tx *database.Tx, slug string) (
+	*database.Vendor, *database.VendorProfile, error) {
+
+	profile, err := tx.Find_VendorProfile_By_Slug(ctx, database.VendorProfile_Slug(slug))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if profile == nil {
+		return nil, nil, NotFound.New("storefront not found")
+	}
+
+	vendor, err := tx.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(profile.VendorPk))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return vendor, profile, nil
+}
+
+//GetStorefront is public. it does not need a buyer session
+func (u *BuyerServer) GetStorefront(ctx context.Context, req *StorefrontReq) (
+	resp *StorefrontResp, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.GetStorefront")
+	defer end(&err)
+
+	resp = &StorefrontResp{}
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		vendor, profile, err := findStorefront(ctx, tx, req.Slug)
+		if err != nil {
+			return err
+		}
+
+		reviews, err := tx.All_ProductReview_By_Product_VendorPk(ctx,
+			database.Product_VendorPk(vendor.Pk))
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		for _, r := range reviews {
+			total += r.Rating
+		}
+
+		resp.Profile = VendorProfileFromDB(vendor, profile)
+		resp.ReviewCount = len(reviews)
+		if len(reviews) > 0 {
+			resp.Rating = float32(total) / float32(len(reviews))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type StorefrontProductsReq struct {
+	Slug      string
+	PageToken string `json:"pageToken"`
+}
+
+//StorefrontProducts pages through the vendor's active products that ladybug has approved
+func (u *BuyerServer) StorefrontProducts(ctx context.Context, req *StorefrontProductsReq) (
+	resp *ProductResponse, err error) {
+
+	ctx, end := startSpan(ctx, "BuyerServer.StorefrontProducts")
+	defer end(&err)
+
+	product_response := &ProductResponse{}
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		vendor, _, err := findStorefront(ctx, tx, req.Slug)
+		if err != nil {
+			return err
+		}
+
+		db_products, ctoken, err := tx.Paged_Product_By_VendorPk_And_ProductActive_Equal_True_And_LadybugApproved_Equal_True(
+			ctx, database.Product_VendorPk(vendor.Pk), productRequestLimit, req.PageToken)
+		if err != nil {
+			return err
+		}
+
+		product_response.Products = ProductsFromDB(db_products)
+		product_response.PageToken = ctoken
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return product_response, nil
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+//tracer comes from the global provider, which does nothing until main installs an exporter
+var tracer = otel.Tracer("ladybug/server")
+
+//startSpan starts the span for a server method. the returned func ends it and records the error
+//the method returned, so methods open with
+//
+//	ctx, end := startSpan(ctx, "BuyerServer.BuyerSignUp")
+//	defer end(&err)
+func startSpan(ctx context.Context, name string) (context.Context, func(*error)) {
+	ctx, span := tracer.Start(ctx, name)
+
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
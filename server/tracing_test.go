@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanRecorder     *tracetest.SpanRecorder
+	spanRecorderOnce sync.Once
+)
+
+//recordSpans returns a func listing the spans ended since it was called. the package tracers only
+//ever delegate to the first provider installed, so one recorder is shared by every test
+func recordSpans() func() []sdktrace.ReadOnlySpan {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(
+			sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+
+	start := len(spanRecorder.Ended())
+	return func() []sdktrace.ReadOnlySpan {
+		return spanRecorder.Ended()[start:]
+	}
+}
+
+func TestSignUpSpans(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ended := recordSpans()
+
+	ctx := context.Background()
+	_, err := test.BuyerServer.BuyerSignUp(ctx, getCompleteSignUpRequest())
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	var inserts []sdktrace.ReadOnlySpan
+	for _, span := range ended() {
+		spans[span.Name()] = span
+		if span.Name() == "sql INSERT" {
+			inserts = append(inserts, span)
+		}
+	}
+
+	//the method holds the transaction which holds every statement
+	method := spans["BuyerServer.BuyerSignUp"]
+	tx := spans["database.WithTx"]
+	require.NotNil(t, method)
+	require.NotNil(t, tx)
+	require.Equal(t, tx.Parent().SpanID(), method.SpanContext().SpanID())
+
+	require.NotEmpty(t, inserts)
+	for _, insert := range inserts {
+		require.Equal(t, insert.Parent().SpanID(), tx.SpanContext().SpanID())
+	}
+}
+
+func TestSpansOutsideTransactions(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ended := recordSpans()
+
+	ctx := context.Background()
+	_, err := test.BuyerServer.DeleteDueBuyers(ctx, time.Now())
+	require.NoError(t, err)
+
+	var method sdktrace.ReadOnlySpan
+	var selects []sdktrace.ReadOnlySpan
+	for _, span := range ended() {
+		switch span.Name() {
+		case "BuyerServer.DeleteDueBuyers":
+			method = span
+		case "sql SELECT":
+			selects = append(selects, span)
+		}
+	}
+
+	//the due query runs on the DB rather than in a transaction and is still traced
+	require.NotNil(t, method)
+	require.Len(t, selects, 1)
+	require.Equal(t, selects[0].Parent().SpanID(), method.SpanContext().SpanID())
+}
@@ -2,41 +2,92 @@ package server
 
 import (
 	"context"
-	"ladybug/database"
+	"sync"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
 )
 
 type VendorServer struct {
-	db *database.DB
+	db     *database.DB
+	hub    Hub
+	blobs  BlobStore
+	filter ContentFilter
+	mailer Mailer
+
+	//deletionGrace is how long accounts wait between being scheduled for deletion and deleted
+	deletionGrace time.Duration
+
+	//imports tracks catalog imports still running in the background
+	imports sync.WaitGroup
 }
 
-func NewVendorServer(db *database.DB) *VendorServer {
-	return &VendorServer{db: db}
+//NewVendorServer returns a VendorServer. DefaultDeletionGracePeriod is used when deletion_grace
+//is not positive
+func NewVendorServer(db *database.DB, hub Hub, blobs BlobStore, filter ContentFilter,
+	mailer Mailer, deletion_grace time.Duration) *VendorServer {
+
+	if deletion_grace <= 0 {
+		deletion_grace = DefaultDeletionGracePeriod
+	}
+
+	return &VendorServer{db: db, hub: hub, blobs: blobs, filter: filter, mailer: mailer,
+		deletionGrace: deletion_grace}
 }
 
 type RegisterProductRequest struct {
-	VendorPk       int64
-	UnitPrice      float32 `json:"unitPrice"`
-	Discount       float32 `json:"discountPrice"`
-	DiscountActive bool    `json:"discountActive"`
-	SKU            string  `json:"sku"`
-	GoogleBucketId string  `json:"googleBucketId"`
-	ProductActive  bool    `json:"productActive"`
-	NumberInStock  int     `json:"numberInStock"`
-	Description    string  `json:"description"`
+	VendorPk           int64
+	ExecutiveContactPk int64
+	UnitPrice          float32 `json:"unitPrice"`
+	Discount           float32 `json:"discountPrice"`
+	DiscountActive     bool    `json:"discountActive"`
+	SKU                string  `json:"sku"`
+	GoogleBucketId     string  `json:"googleBucketId"`
+	ProductActive      bool    `json:"productActive"`
+	NumberInStock      int     `json:"numberInStock"`
+	Description        string  `json:"description"`
+
+	//Attributes names what the product's variants differ by, e.g. size and color. products
+	//without attributes are sold as they are
+	Attributes []string `json:"attributes"`
 }
 
 type RegisterProductResponse struct {
-	Response string `json:"response"`
+	Response  string `json:"response"`
+	ProductId string `json:"productId"`
 }
 
 func (v *VendorServer) RegisterProduct(ctx context.Context, req *RegisterProductRequest) (
 	resp *RegisterProductResponse, err error) {
 
+	ctx, end := startSpan(ctx, "VendorServer.RegisterProduct")
+	defer end(&err)
+
+	err = checkAttributeNames(req.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var product *database.Product
 	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		taken, err := skuTaken(ctx, tx, req.VendorPk, req.SKU)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return errs.New("sku %q is already used by another of your products", req.SKU)
+		}
 
-		err = tx.CreateNoReturn_Product(ctx,
+		product, err = tx.Create_Product(ctx,
 			database.Product_Id(uuid.NewV4().String()),
 			database.Product_VendorPk(req.VendorPk),
 			database.Product_Price(req.UnitPrice),
@@ -48,16 +99,22 @@ func (v *VendorServer) RegisterProduct(ctx context.Context, req *RegisterProduct
 			database.Product_ProductActive(req.ProductActive),
 			database.Product_NumInStock(req.NumberInStock),
 			database.Product_Description(req.Description),
-			database.Product_Rating(0))
+			database.Product_Rating(0),
+			database.Product_Attributes(encodeAttributeNames(req.Attributes)))
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return auditContact(ctx, tx, actor.Id, AuditProductCreate, "product", product.Id, nil,
+			productAuditFields(product))
 	})
 	if err != nil {
 		return nil, err
 	}
+	productsRegistered.Inc()
 
-	return &RegisterProductResponse{Response: "Product has been succesfully registered"}, nil
+	return &RegisterProductResponse{
+		Response:  "Product has been succesfully registered",
+		ProductId: product.Id,
+	}, nil
 }
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+//scopes limit what an api key can reach on top of what the role of the contact who created it
+//allows
+const (
+	ReadCatalogScope  = "catalog:read"
+	WriteCatalogScope = "catalog:write"
+	ReadOrdersScope   = "orders:read"
+	MessagingScope    = "messaging"
+)
+
+const (
+	maxVendorApiKeys  = 25
+	maxApiKeyNameSize = 64
+
+	//apiKeyPrefix starts every key so leaked keys are easy to recognize and search for
+	apiKeyPrefix = "lb_"
+
+	//apiKeyUseResolution is how stale last_used_at may get. keys used by busy integrations would
+	//otherwise write to the database on every request
+	apiKeyUseResolution = time.Minute
+)
+
+var apiKeyScopes = map[string]bool{
+	ReadCatalogScope:  true,
+	WriteCatalogScope: true,
+	ReadOrdersScope:   true,
+	MessagingScope:    true,
+}
+
+//Unauthorized is returned when an api key does not exist or was revoked
+var Unauthorized = errs.Class("unauthorized")
+
+type VendorApiKey struct {
+	Id     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Prefix string   `json:"prefix"`
+
+	//LastUsedAt is zero until the key is used. it may be behind by up to apiKeyUseResolution
+	LastUsedAt int64 `json:"lastUsedAt"`
+	CreatedAt  int64 `json:"createdAt"`
+}
+
+func vendorApiKeyFromDB(key *database.VendorApiKey) *VendorApiKey {
+	api_key := &VendorApiKey{
+		Id:        key.Id,
+		Name:      key.Name,
+		Scopes:    strings.Fields(key.Scopes),
+		Prefix:    key.Prefix,
+		CreatedAt: key.CreatedAt.Unix(),
+	}
+	if !key.LastUsedAt.IsZero() {
+		api_key.LastUsedAt = key.LastUsedAt.Unix()
+	}
+
+	return api_key
+}
+
+//newApiKey makes a key along with the prefix that is shown for it once only its hash is kept
+func newApiKey() (key, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errs.Wrap(err)
+	}
+
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+//checkApiKeyScopes makes sure scopes are known and returns them sorted without duplicates
+func checkApiKeyScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errs.New("an api key needs at least one scope")
+	}
+
+	seen := map[string]bool{}
+	var checked []string
+	for _, scope := range scopes {
+		if !apiKeyScopes[scope] {
+			return nil, errs.New("unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			checked = append(checked, scope)
+		}
+	}
+	sort.Strings(checked)
+
+	return checked, nil
+}
+
+type CreateVendorApiKeyReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`
+}
+
+type CreateVendorApiKeyResp struct {
+	ApiKey *VendorApiKey `json:"apiKey"`
+
+	//Key is only ever returned here. it goes in the Authorization header as a bearer token
+	Key string `json:"key"`
+}
+
+//CreateVendorApiKey makes a key the vendor's own systems can use in place of a session. the key
+//acts as the contact creating it, limited to its scopes
+func (v *VendorServer) CreateVendorApiKey(ctx context.Context,
+	req *CreateVendorApiKeyReq) (resp *CreateVendorApiKeyResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.CreateVendorApiKey")
+	defer end(&err)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxApiKeyNameSize {
+		return nil, errs.New("an api key name has to be 1 to %d characters", maxApiKeyNameSize)
+	}
+
+	scopes, err := checkApiKeyScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, prefix, err := newApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var api_key *database.VendorApiKey
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageApiKeys)
+		if err != nil {
+			return err
+		}
+
+		count, err := tx.Count_VendorApiKey_By_VendorPk(ctx,
+			database.VendorApiKey_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		if count >= maxVendorApiKeys {
+			return errs.New("only a max of %d api keys are allowed", maxVendorApiKeys)
+		}
+
+		api_key, err = tx.Create_VendorApiKey(ctx,
+			database.VendorApiKey_Id(uuid.NewV4().String()),
+			database.VendorApiKey_VendorPk(req.VendorPk),
+			database.VendorApiKey_CreatedByPk(actor.Pk),
+			database.VendorApiKey_Name(name),
+			database.VendorApiKey_Scopes(strings.Join(scopes, " ")),
+			database.VendorApiKey_Prefix(prefix),
+			database.VendorApiKey_KeyHash(hashToken(key)),
+			database.VendorApiKey_LastUsedAt(time.Time{}))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditApiKeyCreate, "vendor_api_key",
+			api_key.Id, nil, auditFields{"name": name, "scopes": scopes})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateVendorApiKeyResp{
+		ApiKey: vendorApiKeyFromDB(api_key),
+		Key:    key,
+	}, nil
+}
+
+type ListVendorApiKeysReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type ListVendorApiKeysResp struct {
+	ApiKeys []*VendorApiKey `json:"apiKeys"`
+}
+
+//ListVendorApiKeys returns the vendor's keys, oldest first. the keys themselves cannot be shown
+//again, only their prefixes
+func (v *VendorServer) ListVendorApiKeys(ctx context.Context,
+	req *ListVendorApiKeysReq) (resp *ListVendorApiKeysResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ListVendorApiKeys")
+	defer end(&err)
+
+	api_keys := []*VendorApiKey{}
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageApiKeys)
+		if err != nil {
+			return err
+		}
+
+		keys, err := tx.All_VendorApiKey_By_VendorPk_OrderBy_Asc_CreatedAt(ctx,
+			database.VendorApiKey_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			api_keys = append(api_keys, vendorApiKeyFromDB(key))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListVendorApiKeysResp{
+		ApiKeys: api_keys,
+	}, nil
+}
+
+type RevokeVendorApiKeyReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ApiKeyId           string `json:"apiKeyId"`
+}
+
+type RevokeVendorApiKeyResp struct {
+	ApiKeyId string `json:"apiKeyId"`
+}
+
+//RevokeVendorApiKey deletes a key. requests made with it are refused from then on
+func (v *VendorServer) RevokeVendorApiKey(ctx context.Context,
+	req *RevokeVendorApiKeyReq) (resp *RevokeVendorApiKeyResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.RevokeVendorApiKey")
+	defer end(&err)
+
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageApiKeys)
+		if err != nil {
+			return err
+		}
+
+		api_key, err := tx.Find_VendorApiKey_By_Id(ctx, database.VendorApiKey_Id(req.ApiKeyId))
+		if err != nil {
+			return err
+		}
+
+		if api_key == nil || api_key.VendorPk != req.VendorPk {
+			return NotFound.New("api key not found")
+		}
+
+		_, err = tx.Delete_VendorApiKey_By_Pk(ctx, database.VendorApiKey_Pk(api_key.Pk))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditApiKeyRevoke, "vendor_api_key",
+			api_key.Id, auditFields{"name": api_key.Name}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RevokeVendorApiKeyResp{
+		ApiKeyId: req.ApiKeyId,
+	}, nil
+}
+
+//VendorApiKeyAuth is who a request made with an api key acts as
+type VendorApiKeyAuth struct {
+	ApiKeyId           string
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Scopes             []string
+}
+
+//AuthenticateVendorApiKey resolves a key to the vendor and contact it acts as and records that it
+//was used
+func (v *VendorServer) AuthenticateVendorApiKey(ctx context.Context,
+	key string) (auth *VendorApiKeyAuth, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.AuthenticateVendorApiKey")
+	defer end(&err)
+
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, Unauthorized.New("invalid api key")
+	}
+
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		api_key, err := tx.Find_VendorApiKey_By_KeyHash(ctx,
+			database.VendorApiKey_KeyHash(hashToken(key)))
+		if err != nil {
+			return err
+		}
+
+		if api_key == nil {
+			return Unauthorized.New("invalid api key")
+		}
+
+		now := v.db.Hooks.Now().UTC()
+		if now.Sub(api_key.LastUsedAt) >= apiKeyUseResolution {
+			err = tx.UpdateNoReturn_VendorApiKey_By_Pk(ctx,
+				database.VendorApiKey_Pk(api_key.Pk),
+				database.VendorApiKey_Update_Fields{
+					LastUsedAt: database.VendorApiKey_LastUsedAt(now),
+				})
+			if err != nil {
+				return err
+			}
+		}
+
+		auth = &VendorApiKeyAuth{
+			ApiKeyId:           api_key.Id,
+			VendorPk:           api_key.VendorPk,
+			ExecutiveContactPk: api_key.CreatedByPk,
+			Scopes:             strings.Fields(api_key.Scopes),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVendorApiKeys(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	other_vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	agent := test.createExecutiveContact(ctx, vendor.Pk, SupportAgentRole)
+
+	//only owners manage keys
+	_, err := test.VendorServer.CreateVendorApiKey(ctx, &CreateVendorApiKeyReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: agent.Pk,
+		Name:               "erp",
+		Scopes:             []string{ReadCatalogScope},
+	})
+	require.True(t, Forbidden.Has(err))
+
+	_, err = test.VendorServer.CreateVendorApiKey(ctx, &CreateVendorApiKeyReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Name:               "erp",
+		Scopes:             []string{"everything"},
+	})
+	require.Error(t, err)
+
+	created, err := test.VendorServer.CreateVendorApiKey(ctx, &CreateVendorApiKeyReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Name:               " erp ",
+		Scopes:             []string{WriteCatalogScope, ReadCatalogScope, WriteCatalogScope},
+	})
+	require.NoError(t, err)
+	require.Equal(t, created.ApiKey.Name, "erp")
+	require.Equal(t, created.ApiKey.Scopes, []string{ReadCatalogScope, WriteCatalogScope})
+	require.Zero(t, created.ApiKey.LastUsedAt)
+	require.Contains(t, created.Key, created.ApiKey.Prefix)
+
+	//the key acts as the owner that made it, limited to its scopes
+	used_at := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	var auth *VendorApiKeyAuth
+	test.at(used_at, func() {
+		auth, err = test.VendorServer.AuthenticateVendorApiKey(ctx, created.Key)
+	})
+	require.NoError(t, err)
+	require.Equal(t, auth.ApiKeyId, created.ApiKey.Id)
+	require.Equal(t, auth.VendorPk, vendor.Pk)
+	require.Equal(t, auth.ExecutiveContactPk, owner.Pk)
+	require.Equal(t, auth.Scopes, []string{ReadCatalogScope, WriteCatalogScope})
+
+	_, err = test.VendorServer.AuthenticateVendorApiKey(ctx, created.Key+"x")
+	require.True(t, Unauthorized.Has(err))
+
+	list, err := test.VendorServer.ListVendorApiKeys(ctx, &ListVendorApiKeysReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	})
+	require.NoError(t, err)
+	require.Len(t, list.ApiKeys, 1)
+	require.Equal(t, list.ApiKeys[0].LastUsedAt, used_at.Unix())
+
+	//other vendors cannot see the key
+	_, err = test.VendorServer.RevokeVendorApiKey(ctx, &RevokeVendorApiKeyReq{
+		VendorPk:           other_vendor.Pk,
+		ExecutiveContactPk: test.createExecutiveContact(ctx, other_vendor.Pk, OwnerRole).Pk,
+		ApiKeyId:           created.ApiKey.Id,
+	})
+	require.True(t, NotFound.Has(err))
+
+	_, err = test.VendorServer.RevokeVendorApiKey(ctx, &RevokeVendorApiKeyReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ApiKeyId:           created.ApiKey.Id,
+	})
+	require.NoError(t, err)
+
+	_, err = test.VendorServer.AuthenticateVendorApiKey(ctx, created.Key)
+	require.True(t, Unauthorized.Has(err))
+}
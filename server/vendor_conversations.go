@@ -7,7 +7,8 @@ import (
 )
 
 type VendorConversationsUnreadReq struct {
-	VendorPk int64
+	VendorPk           int64
+	ExecutiveContactPk int64
 }
 
 type VendorConversationsUnreadResp struct {
@@ -17,8 +18,16 @@ type VendorConversationsUnreadResp struct {
 func (u *VendorServer) GetVendorCoversationsUnread(ctx context.Context,
 	req *VendorConversationsUnreadReq) (resp *VendorConversationsUnreadResp, err error) {
 
+	ctx, end := startSpan(ctx, "VendorServer.GetVendorCoversationsUnread")
+	defer end(&err)
+
 	var conversations []*database.Conversation
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
 		conversations, err = tx.All_Conversation_By_VendorPk_And_VendorUnread_Equal_True(ctx,
 			database.Conversation_VendorPk(req.VendorPk))
 		if err != nil {
@@ -37,8 +46,9 @@ func (u *VendorServer) GetVendorCoversationsUnread(ctx context.Context,
 }
 
 type PagedVendorConversationReq struct {
-	VendorPk  int64
-	PageToken string `json:"pageToken"`
+	VendorPk           int64
+	ExecutiveContactPk int64
+	PageToken          string `json:"pageToken"`
 }
 
 type PagedVendorConversationResp struct {
@@ -49,9 +59,17 @@ type PagedVendorConversationResp struct {
 func (u *VendorServer) GetPagedVendorConversations(ctx context.Context,
 	req *PagedVendorConversationReq) (resp *PagedVendorConversationResp, err error) {
 
+	ctx, end := startSpan(ctx, "VendorServer.GetPagedVendorConversations")
+	defer end(&err)
+
 	var conversations []*database.Conversation
 	var ctoken string
 	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
 		conversations, ctoken, err = tx.Paged_Conversation_By_VendorPk(ctx,
 			database.Conversation_VendorPk(req.VendorPk), conversationRequestLimit, req.PageToken)
 		if err != nil {
@@ -69,3 +87,235 @@ func (u *VendorServer) GetPagedVendorConversations(ctx context.Context,
 		Conversations: ConversationsFromDB(conversations),
 	}, nil
 }
+
+//markVendorConversationRead advances the vendor's read pointer inside an open transaction and
+//returns the conversation as it is after the update
+func markVendorConversationRead(ctx context.Context, tx *database.Tx,
+	conversation *database.Conversation, conversation_number int64) (
+	*database.Conversation, error) {
+
+	last_read := lastReadUpTo(conversation.VendorLastRead, conversation_number,
+		conversation.MessageCount)
+	unread := last_read < conversation.MessageCount
+	if last_read == conversation.VendorLastRead && unread == conversation.VendorUnread {
+		return conversation, nil
+	}
+
+	return tx.Update_Conversation_By_Pk(ctx,
+		database.Conversation_Pk(conversation.Pk),
+		database.Conversation_Update_Fields{
+			VendorLastRead: database.Conversation_VendorLastRead(last_read),
+			VendorUnread:   database.Conversation_VendorUnread(unread),
+		})
+}
+
+type MarkVendorConversationReadReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ConversationId     string `json:"conversationId"`
+	ConversationNumber int64  `json:"conversationNumber"`
+}
+
+type MarkVendorConversationReadResp struct {
+	LastRead    int64 `json:"lastRead"`
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+//MarkVendorConversationRead marks the conversation read up to and including conversation number
+func (u *VendorServer) MarkVendorConversationRead(ctx context.Context,
+	req *MarkVendorConversationReadReq) (resp *MarkVendorConversationReadResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.MarkVendorConversationRead")
+	defer end(&err)
+
+	var before, conversation *database.Conversation
+	var unread_count int64
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		before, err = authorizeVendorConversation(ctx, tx, req.VendorPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		conversation, err = markVendorConversationRead(ctx, tx, before, req.ConversationNumber)
+		if err != nil {
+			return err
+		}
+
+		unread_count, err = tx.Count_Conversation_By_VendorPk_And_VendorUnread_Equal_True(ctx,
+			database.Conversation_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.VendorLastRead != before.VendorLastRead {
+		publishRead(ctx, u.hub, VendorTopic(conversation.VendorPk),
+			BuyerTopic(conversation.BuyerPk), conversation.Id, conversation.VendorLastRead,
+			unread_count)
+	}
+
+	return &MarkVendorConversationReadResp{
+		LastRead:    conversation.VendorLastRead,
+		UnreadCount: conversation.MessageCount - conversation.VendorLastRead,
+	}, nil
+}
+
+type VendorUnreadCountsReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type VendorUnreadCountsResp struct {
+	TotalUnread   int64                 `json:"totalUnread"`
+	Conversations []*ConversationUnread `json:"conversations"`
+}
+
+//GetVendorUnreadCounts returns the number of unread messages in each unread conversation and the
+//total across all of them
+func (u *VendorServer) GetVendorUnreadCounts(ctx context.Context, req *VendorUnreadCountsReq) (
+	resp *VendorUnreadCountsResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.GetVendorUnreadCounts")
+	defer end(&err)
+
+	var conversations []*database.Conversation
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		conversations, err = tx.All_Conversation_By_VendorPk_And_VendorUnread_Equal_True(ctx,
+			database.Conversation_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &VendorUnreadCountsResp{Conversations: []*ConversationUnread{}}
+	for _, c := range conversations {
+		unread := c.MessageCount - c.VendorLastRead
+		resp.TotalUnread += unread
+		resp.Conversations = append(resp.Conversations, &ConversationUnread{
+			Id:          c.Id,
+			UnreadCount: unread,
+			LastRead:    c.VendorLastRead,
+		})
+	}
+
+	return resp, nil
+}
+
+type BlockVendorConversationReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ConversationId     string `json:"conversationId"`
+	Blocked            bool   `json:"blocked"`
+}
+
+type BlockVendorConversationResp struct {
+	Blocked bool `json:"blocked"`
+}
+
+//BlockVendorConversation blocks or unblocks the buyer in a conversation. while it is blocked any
+//message the buyer posts to it is rejected
+func (u *VendorServer) BlockVendorConversation(ctx context.Context,
+	req *BlockVendorConversationReq) (resp *BlockVendorConversationResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.BlockVendorConversation")
+	defer end(&err)
+
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		conversation, err := authorizeVendorConversation(ctx, tx, req.VendorPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		err = tx.UpdateNoReturn_Conversation_By_Pk(ctx,
+			database.Conversation_Pk(conversation.Pk),
+			database.Conversation_Update_Fields{
+				BlockedByVendor: database.Conversation_BlockedByVendor(req.Blocked),
+			})
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditConversationBlock, "conversation",
+			conversation.Id, auditFields{"blockedByVendor": conversation.BlockedByVendor},
+			auditFields{"blockedByVendor": req.Blocked})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockVendorConversationResp{
+		Blocked: req.Blocked,
+	}, nil
+}
+
+type ReportVendorConversationReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ConversationId     string `json:"conversationId"`
+	Reason             string `json:"reason"`
+}
+
+type ReportVendorConversationResp struct {
+	ReportId string `json:"reportId"`
+}
+
+//ReportVendorConversation sends a conversation to the admin review queue
+func (u *VendorServer) ReportVendorConversation(ctx context.Context,
+	req *ReportVendorConversationReq) (resp *ReportVendorConversationResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ReportVendorConversation")
+	defer end(&err)
+
+	var report *database.ConversationReport
+	err = u.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		conversation, err := authorizeVendorConversation(ctx, tx, req.VendorPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		report, err = reportConversation(ctx, tx, conversation, vendorReporter, req.Reason)
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditConversationReport, "conversation",
+			conversation.Id, nil, auditFields{"reportId": report.Id, "reason": req.Reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReportVendorConversationResp{
+		ReportId: report.Id,
+	}, nil
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ladybug/database"
+)
+
+type VendorDeletion struct {
+	RequestedAt int64 `json:"requestedAt"`
+	DeleteAfter int64 `json:"deleteAfter"`
+}
+
+func VendorDeletionFromDB(deletion *database.VendorDeletion) *VendorDeletion {
+	if deletion == nil {
+		return nil
+	}
+
+	return &VendorDeletion{
+		RequestedAt: deletion.CreatedAt.Unix(),
+		DeleteAfter: deletion.DeleteAfter.Unix(),
+	}
+}
+
+type GetVendorDeletionReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type GetVendorDeletionResp struct {
+	//Deletion is nil unless an owner has asked for the vendor's account to be deleted
+	Deletion *VendorDeletion `json:"deletion"`
+}
+
+func (v *VendorServer) GetVendorDeletion(ctx context.Context, req *GetVendorDeletionReq) (
+	resp *GetVendorDeletionResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.GetVendorDeletion")
+	defer end(&err)
+
+	var deletion *database.VendorDeletion
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, CloseAccount)
+		if err != nil {
+			return err
+		}
+
+		deletion, err = tx.Find_VendorDeletion_By_VendorPk(ctx,
+			database.VendorDeletion_VendorPk(req.VendorPk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetVendorDeletionResp{
+		Deletion: VendorDeletionFromDB(deletion),
+	}, nil
+}
+
+type RequestVendorDeletionReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type RequestVendorDeletionResp struct {
+	Deletion *VendorDeletion `json:"deletion"`
+}
+
+//RequestVendorDeletion schedules the vendor's account to be deleted once the grace period is
+//over. the team keeps working as usual until then and any owner can cancel
+func (v *VendorServer) RequestVendorDeletion(ctx context.Context,
+	req *RequestVendorDeletionReq) (resp *RequestVendorDeletionResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.RequestVendorDeletion")
+	defer end(&err)
+
+	var deletion *database.VendorDeletion
+	var emails []*database.VendorEmail
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		contact, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, CloseAccount)
+		if err != nil {
+			return err
+		}
+
+		existing, err := tx.Find_VendorDeletion_By_VendorPk(ctx,
+			database.VendorDeletion_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			return InvalidDeletion.New("your account is already scheduled to be deleted")
+		}
+
+		deletion, err = tx.Create_VendorDeletion(ctx,
+			database.VendorDeletion_VendorPk(req.VendorPk),
+			database.VendorDeletion_DeleteAfter(v.db.Hooks.Now().UTC().Add(v.deletionGrace)))
+		if err != nil {
+			return err
+		}
+
+		vendor_id, err := vendorId(ctx, tx, req.VendorPk)
+		if err != nil {
+			return err
+		}
+
+		err = auditContact(ctx, tx, contact.Id, AuditVendorDeletionRequest, "vendor", vendor_id,
+			nil, auditFields{"deleteAfter": deletion.DeleteAfter.Unix()})
+		if err != nil {
+			return err
+		}
+
+		emails, err = tx.All_VendorEmail_By_ExecutiveContactPk(ctx,
+			database.VendorEmail_ExecutiveContactPk(contact.Pk))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, email := range emails {
+		err = v.mailer.SendMail(ctx, email.Address, "Your Ladybug vendor account will be deleted",
+			fmt.Sprintf("Your vendor account, team and catalog will be deleted on %s. An owner "+
+				"can cancel before then.", deletion.DeleteAfter.Format("January 2, 2006")))
+		if err != nil {
+			Logger(ctx).Errorf("unable to mail deletion notice to vendor %d: %+v", req.VendorPk,
+				err)
+		}
+	}
+
+	return &RequestVendorDeletionResp{
+		Deletion: VendorDeletionFromDB(deletion),
+	}, nil
+}
+
+type CancelVendorDeletionReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type CancelVendorDeletionResp struct{}
+
+func (v *VendorServer) CancelVendorDeletion(ctx context.Context, req *CancelVendorDeletionReq) (
+	resp *CancelVendorDeletionResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.CancelVendorDeletion")
+	defer end(&err)
+
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		contact, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, CloseAccount)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := tx.Delete_VendorDeletion_By_VendorPk(ctx,
+			database.VendorDeletion_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		if !deleted {
+			return NotFound.New("your account is not scheduled to be deleted")
+		}
+
+		vendor_id, err := vendorId(ctx, tx, req.VendorPk)
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, contact.Id, AuditVendorDeletionCancel, "vendor", vendor_id,
+			nil, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CancelVendorDeletionResp{}, nil
+}
+
+//eraseVendor removes a vendor's team and everything they set up. the vendor itself is kept, with
+//its fein cleared, because conversations, trials and purchases still point at it for the buyers
+//involved. its products are taken off sale and its conversations are closed to new messages
+func eraseVendor(ctx context.Context, tx *database.Tx, vendor_pk int64) error {
+	id, err := vendorId(ctx, tx, vendor_pk)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := tx.Delete_VendorSession_By_VendorPk(ctx,
+		database.VendorSession_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	err = audit(ctx, tx, &auditEvent{
+		actorKind:  SystemActor,
+		action:     AuditSessionRevoke,
+		targetKind: "vendor",
+		targetId:   id,
+		after:      auditFields{"sessions": revoked},
+	})
+	if err != nil {
+		return err
+	}
+
+	contacts, err := tx.All_ExecutiveContact_By_VendorPk(ctx,
+		database.ExecutiveContact_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	for _, contact := range contacts {
+		_, err = tx.Delete_VendorEmail_By_ExecutiveContactPk(ctx,
+			database.VendorEmail_ExecutiveContactPk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_VendorPhone_By_ExecutiveContactPk(ctx,
+			database.VendorPhone_ExecutiveContactPk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_ExecutiveContact_By_Pk(ctx, database.ExecutiveContact_Pk(contact.Pk))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Delete_VendorInvite_By_VendorPk(ctx, database.VendorInvite_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Delete_VendorApiKey_By_VendorPk(ctx, database.VendorApiKey_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := tx.All_WebhookEndpoint_By_VendorPk_OrderBy_Asc_CreatedAt(ctx,
+		database.WebhookEndpoint_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		_, err = tx.Delete_WebhookDelivery_By_EndpointPk(ctx,
+			database.WebhookDelivery_EndpointPk(endpoint.Pk))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_WebhookEndpoint_By_Pk(ctx, database.WebhookEndpoint_Pk(endpoint.Pk))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Delete_VendorAddress_By_VendorPk(ctx, database.VendorAddress_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Delete_VendorProfile_By_VendorPk(ctx, database.VendorProfile_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	products, err := tx.All_Product_By_VendorPk(ctx, database.Product_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		if !product.ProductActive {
+			continue
+		}
+
+		_, err = tx.Update_Product_By_Pk(ctx, database.Product_Pk(product.Pk),
+			database.Product_Update_Fields{
+				ProductActive: database.Product_ProductActive(false),
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	conversations, err := tx.All_Conversation_By_VendorPk(ctx,
+		database.Conversation_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	for _, conversation := range conversations {
+		err = tx.UpdateNoReturn_Conversation_By_Pk(ctx, database.Conversation_Pk(conversation.Pk),
+			database.Conversation_Update_Fields{
+				BlockedByVendor: database.Conversation_BlockedByVendor(true),
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.UpdateNoReturn_Vendor_By_Pk(ctx, database.Vendor_Pk(vendor_pk),
+		database.Vendor_Update_Fields{
+			Fein: database.Vendor_Fein(""),
+		})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Delete_VendorDeletion_By_VendorPk(ctx,
+		database.VendorDeletion_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	return audit(ctx, tx, &auditEvent{
+		actorKind:  SystemActor,
+		action:     AuditVendorDelete,
+		targetKind: "vendor",
+		targetId:   id,
+	})
+}
+
+//DeleteDueVendors erases every vendor whose grace period ended before now. each vendor is erased
+//in its own transaction so one failure is logged and does not hold up the rest
+func (v *VendorServer) DeleteDueVendors(ctx context.Context, now time.Time) (
+	deleted int, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.DeleteDueVendors")
+	defer end(&err)
+
+	due, err := v.db.WithContext(ctx).All_VendorDeletion_By_DeleteAfter_LessOrEqual(ctx,
+		database.VendorDeletion_DeleteAfter(now.UTC()))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, deletion := range due {
+		err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+			return eraseVendor(ctx, tx, deletion.VendorPk)
+		})
+		if err != nil {
+			Logger(ctx).Errorf("unable to delete vendor %d: %+v", deletion.VendorPk, err)
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+//RunVendorDeletions calls DeleteDueVendors every interval until ctx is done
+func (v *VendorServer) RunVendorDeletions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := v.DeleteDueVendors(ctx, v.db.Hooks.Now())
+		if err != nil {
+			Logger(ctx).Errorf("deleting vendors failed: %+v", err)
+		}
+		if deleted > 0 {
+			Logger(ctx).Infof("deleted %d vendor accounts", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+func TestVendorDeletion(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	catalog_manager := test.createExecutiveContact(ctx, vendor.Pk, CatalogManagerRole)
+	buyer := test.createFullTestBuyer(ctx)
+	product := test.createActiveAndApprovedProductInStock(ctx, vendor.Pk)
+	purchase := test.purchaseProduct(ctx, buyer.Pk, vendor.Pk, product)
+	_, err := test.VendorServer.UpdateVendorProfile(ctx, &UpdateVendorProfileReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		DisplayName:        "Some Vendor",
+		Slug:               "some-vendor",
+	})
+	require.NoError(t, err)
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "where is my order?",
+		})
+	require.NoError(t, err)
+
+	//only owners can close the account
+	_, err = test.VendorServer.RequestVendorDeletion(ctx, &RequestVendorDeletionReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: catalog_manager.Pk,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	deletion_req := &RequestVendorDeletionReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	}
+	cancel_req := &CancelVendorDeletionReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	}
+
+	//the grace period starts from the database clock
+	requested_at := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	var resp *RequestVendorDeletionResp
+	test.at(requested_at, func() {
+		resp, err = test.VendorServer.RequestVendorDeletion(ctx, deletion_req)
+	})
+	require.NoError(t, err)
+	require.Equal(t, resp.Deletion.DeleteAfter, requested_at.Add(DefaultDeletionGracePeriod).Unix())
+
+	grace_over := time.Unix(resp.Deletion.DeleteAfter, 0).Add(time.Second)
+
+	//cancelling keeps the account
+	_, err = test.VendorServer.CancelVendorDeletion(ctx, cancel_req)
+	require.NoError(t, err)
+
+	deleted, err := test.VendorServer.DeleteDueVendors(ctx, grace_over)
+	require.NoError(t, err)
+	require.Equal(t, deleted, 0)
+
+	_, err = test.VendorServer.CancelVendorDeletion(ctx, cancel_req)
+	require.True(t, NotFound.Has(err))
+
+	//nothing happens during the grace period
+	resp, err = test.VendorServer.RequestVendorDeletion(ctx, deletion_req)
+	require.NoError(t, err)
+
+	_, err = test.VendorServer.RequestVendorDeletion(ctx, deletion_req)
+	require.True(t, InvalidDeletion.Has(err))
+
+	deleted, err = test.VendorServer.DeleteDueVendors(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, deleted, 0)
+
+	deleted, err = test.VendorServer.DeleteDueVendors(ctx,
+		time.Unix(resp.Deletion.DeleteAfter, 0).Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, deleted, 1)
+
+	//the team and everything they set up are gone
+	contacts, err := test.db.All_ExecutiveContact_By_VendorPk(ctx,
+		database.ExecutiveContact_VendorPk(vendor.Pk))
+	require.NoError(t, err)
+	require.Empty(t, contacts)
+
+	profile, err := test.db.Find_VendorProfile_By_VendorPk(ctx,
+		database.VendorProfile_VendorPk(vendor.Pk))
+	require.NoError(t, err)
+	require.Nil(t, profile)
+
+	erased, err := test.db.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(vendor.Pk))
+	require.NoError(t, err)
+	require.Equal(t, erased.Fein, "")
+
+	//products come off sale and buyers can no longer message the vendor
+	off_sale, err := test.db.Get_Product_By_Pk(ctx, database.Product_Pk(product.Pk))
+	require.NoError(t, err)
+	require.False(t, off_sale.ProductActive)
+
+	conversations, err := test.db.All_Conversation_By_VendorPk(ctx,
+		database.Conversation_VendorPk(vendor.Pk))
+	require.NoError(t, err)
+	require.Len(t, conversations, 1)
+	require.True(t, conversations[0].BlockedByVendor)
+
+	//buyers keep their purchases
+	_, err = test.db.Get_PurchasedProduct_By_Pk(ctx, database.PurchasedProduct_Pk(purchase.Pk))
+	require.NoError(t, err)
+}
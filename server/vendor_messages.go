@@ -9,8 +9,12 @@ import (
 
 type PostVendorMessageToConversationReq struct {
 	VendorPk           int64
-	BuyerId            string `json:"vendorId"`
+	ExecutiveContactPk int64
+	BuyerId            string `json:"buyerId"`
 	MessageDescription string `json:"messageDescription"`
+
+	Attachments []*AttachmentUpload `json:"attachments"`
+	References  *MessageReferences  `json:"references"`
 }
 
 type PostVendorMessageToConversationResp struct {
@@ -20,8 +24,27 @@ type PostVendorMessageToConversationResp struct {
 func (v *VendorServer) PostVendorMessageToConversation(ctx context.Context,
 	req *PostVendorMessageToConversationReq) (resp *PostVendorMessageToConversationResp, err error) {
 
-	var message *database.Message
+	ctx, end := startSpan(ctx, "VendorServer.PostVendorMessageToConversation")
+	defer end(&err)
+
+	err = ValidateAttachments(req.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict, err := checkMessage(ctx, v.filter, false, req.MessageDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversation *database.Conversation
+	var message *Message
+	var unread_count int64
 	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
 
 		buyer_pk_field, err := tx.Get_Buyer_Pk_By_Id(ctx,
 			database.Buyer_Id(req.BuyerId))
@@ -29,13 +52,29 @@ func (v *VendorServer) PostVendorMessageToConversation(ctx context.Context,
 			return err
 		}
 
-		conversation, err := tx.Find_Conversation_By_VendorPk_And_BuyerPk(ctx,
+		conversation, err = tx.Find_Conversation_By_VendorPk_And_BuyerPk(ctx,
 			database.Conversation_VendorPk(req.VendorPk),
 			database.Conversation_BuyerPk(buyer_pk_field.Pk))
 		if err != nil {
 			return err
 		}
 
+		if conversation != nil {
+			conversation, err = lockConversation(ctx, tx, conversation.Pk)
+			if err != nil {
+				return err
+			}
+		}
+
+		if conversation != nil && conversation.BlockedByBuyer {
+			return Blocked.New("the buyer has blocked this conversation")
+		}
+
+		err = checkVendorSendRate(ctx, tx, req.VendorPk, v.db.Hooks.Now())
+		if err != nil {
+			return err
+		}
+
 		//if buyer and vendor have not had a conversation before create new conversation
 		if conversation == nil {
 			conversation, err = tx.Create_Conversation(ctx,
@@ -44,14 +83,19 @@ func (v *VendorServer) PostVendorMessageToConversation(ctx context.Context,
 				database.Conversation_BuyerUnread(true),
 				database.Conversation_VendorUnread(false),
 				database.Conversation_MessageCount(1),
+				database.Conversation_BuyerLastRead(0),
+				database.Conversation_VendorLastRead(1),
+				database.Conversation_BlockedByBuyer(false),
+				database.Conversation_BlockedByVendor(false),
 				database.Conversation_Id(uuid.NewV4().String()))
 			if err != nil {
 				return err
 			}
 		} else {
 			conversation_updates := database.Conversation_Update_Fields{
-				MessageCount: database.Conversation_MessageCount(conversation.MessageCount + 1),
-				BuyerUnread:  database.Conversation_BuyerUnread(true),
+				MessageCount:   database.Conversation_MessageCount(conversation.MessageCount + 1),
+				BuyerUnread:    database.Conversation_BuyerUnread(true),
+				VendorLastRead: database.Conversation_VendorLastRead(conversation.MessageCount + 1),
 			}
 
 			conversation, err = tx.Update_Conversation_By_Pk(ctx,
@@ -62,49 +106,113 @@ func (v *VendorServer) PostVendorMessageToConversation(ctx context.Context,
 			}
 		}
 
-		message, err = tx.Create_Message(ctx,
+		db_message, err := tx.Create_Message(ctx,
 			database.Message_Id(uuid.NewV4().String()),
 			database.Message_BuyerSent(false),
 			database.Message_Description(req.MessageDescription),
+			database.Message_SearchText(messageSearchText(req.MessageDescription)),
 			database.Message_ConversationPk(conversation.Pk),
 			database.Message_ConversationNumber(conversation.MessageCount))
 		if err != nil {
 			return err
 		}
 
+		err = attachToMessage(ctx, tx, v.blobs, conversation, db_message, req.Attachments,
+			req.References)
+		if err != nil {
+			return err
+		}
+
+		if verdict == FlagMessage {
+			err = flagConversation(ctx, tx, conversation)
+			if err != nil {
+				return err
+			}
+		}
+
+		messages, err := expandMessages(ctx, tx, conversation, []*database.Message{db_message})
+		if err != nil {
+			return err
+		}
+		message = messages[0]
+
+		unread_count, err = tx.Count_Conversation_By_BuyerPk_And_BuyerUnread_Equal_True(ctx,
+			database.Conversation_BuyerPk(conversation.BuyerPk))
+		if err != nil {
+			return err
+		}
+
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	messagesPosted.WithLabelValues("vendor").Inc()
+	publishNewMessage(ctx, v.hub,
+		VendorTopic(conversation.VendorPk), BuyerTopic(conversation.BuyerPk),
+		conversation, message, unread_count)
+
 	return &PostVendorMessageToConversationResp{
-		Message: MessageFromDB(message),
+		Message: message,
 	}, nil
 }
 
 type PagedVendorMessagesByConversationIdReq struct {
-	Offset         int64  `json:"offset"`
-	ConversationId string `json:"conversationId"`
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ConversationId     string `json:"conversationId"`
+	Before             int64  `json:"before"`
+	After              int64  `json:"after"`
+	MarkRead           bool   `json:"markRead"`
 }
 
 type PagedVendorMessagesByConversationIdResp struct {
-	Offset   int64      `json:"offset"`
-	Messages []*Message `json:"messages"`
+	MessagePage
 }
 
 func (v *VendorServer) PagedVendorMessagesByConversationId(ctx context.Context,
 	req *PagedVendorMessagesByConversationIdReq) (resp *PagedVendorMessagesByConversationIdResp, err error) {
 
-	var messages []*database.Message
+	ctx, end := startSpan(ctx, "VendorServer.PagedVendorMessagesByConversationId")
+	defer end(&err)
+
+	var before, conversation *database.Conversation
+	var db_messages []*database.Message
+	var messages []*Message
+	var unread_count int64
 	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
-		conversation, err := tx.Get_Conversation_By_Id(ctx, database.Conversation_Id(req.ConversationId))
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		before, err = authorizeVendorConversation(ctx, tx, req.VendorPk, req.ConversationId)
 		if err != nil {
 			return err
 		}
+		conversation = before
 
-		messages, err = tx.Limited_Message_By_ConversationPk_OrderBy_Desc_CreatedAt(ctx,
-			database.Message_ConversationPk(conversation.Pk), messageRequestLimit, req.Offset)
+		db_messages, err = pageMessages(ctx, tx, conversation, req.Before, req.After)
+		if err != nil {
+			return err
+		}
+
+		if req.MarkRead && len(db_messages) > 0 {
+			conversation, err = markVendorConversationRead(ctx, tx, conversation,
+				newestConversationNumber(db_messages))
+			if err != nil {
+				return err
+			}
+
+			unread_count, err = tx.Count_Conversation_By_VendorPk_And_VendorUnread_Equal_True(ctx,
+				database.Conversation_VendorPk(conversation.VendorPk))
+			if err != nil {
+				return err
+			}
+		}
+
+		messages, err = expandMessages(ctx, tx, conversation, db_messages)
 		if err != nil {
 			return err
 		}
@@ -115,10 +223,192 @@ func (v *VendorServer) PagedVendorMessagesByConversationId(ctx context.Context,
 		return nil, err
 	}
 
-	offset := req.Offset + messageRequestLimit
+	if conversation.VendorLastRead != before.VendorLastRead {
+		publishRead(ctx, v.hub, VendorTopic(conversation.VendorPk),
+			BuyerTopic(conversation.BuyerPk), conversation.Id, conversation.VendorLastRead,
+			unread_count)
+	}
+
+	page := MessagePage{Messages: messages}
+	page.Before, page.After = messagePageCursors(db_messages, req.Before, req.After)
 
 	return &PagedVendorMessagesByConversationIdResp{
-		Messages: MessagesFromDB(messages),
-		Offset:   offset,
+		MessagePage: page,
+	}, nil
+}
+
+type SubscribeVendorEventsReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+//SubscribeVendorEvents subscribes to new messages and unread counts for a vendor. the caller must
+//close the subscription when the session ends
+func (v *VendorServer) SubscribeVendorEvents(ctx context.Context, req *SubscribeVendorEventsReq) (
+	*Subscription, error) {
+
+	err := v.permit(ctx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.hub.Subscribe(VendorTopic(req.VendorPk)), nil
+}
+
+type VendorMessagesSinceReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ConversationId     string `json:"conversationId"`
+	ConversationNumber int64  `json:"conversationNumber"`
+}
+
+type VendorMessagesSinceResp struct {
+	Messages []*Message `json:"messages"`
+}
+
+//VendorMessagesSince returns the messages in a conversation after the last conversation number a
+//client has seen. it is used to catch up after reconnecting to the event stream
+func (v *VendorServer) VendorMessagesSince(ctx context.Context, req *VendorMessagesSinceReq) (
+	resp *VendorMessagesSinceResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.VendorMessagesSince")
+	defer end(&err)
+
+	var messages []*Message
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		conversation, err := authorizeVendorConversation(ctx, tx, req.VendorPk, req.ConversationId)
+		if err != nil {
+			return err
+		}
+
+		db_messages, err := tx.All_Message_By_ConversationPk_And_ConversationNumber_Greater_OrderBy_Asc_ConversationNumber(
+			ctx, database.Message_ConversationPk(conversation.Pk),
+			database.Message_ConversationNumber(req.ConversationNumber))
+		if err != nil {
+			return err
+		}
+
+		messages, err = expandMessages(ctx, tx, conversation, db_messages)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &VendorMessagesSinceResp{
+		Messages: messages,
+	}, nil
+}
+
+type SearchVendorMessagesReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Query              string `json:"query"`
+	Offset             int64  `json:"offset"`
+}
+
+type SearchVendorMessagesResp struct {
+	Hits   []*MessageSearchHit `json:"hits"`
+	Offset int64               `json:"offset"`
+}
+
+//SearchVendorMessages finds messages containing the query across all of the vendor's conversations,
+//newest first
+func (v *VendorServer) SearchVendorMessages(ctx context.Context, req *SearchVendorMessagesReq) (
+	resp *SearchVendorMessagesResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.SearchVendorMessages")
+	defer end(&err)
+
+	words, err := messageSearchWords(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []*MessageSearchHit
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		db_messages, err := tx.SearchVendorMessages(ctx, req.VendorPk, words, searchRequestLimit,
+			req.Offset)
+		if err != nil {
+			return err
+		}
+
+		hits, err = searchHits(ctx, tx, db_messages)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchVendorMessagesResp{
+		Hits:   hits,
+		Offset: req.Offset + searchRequestLimit,
+	}, nil
+}
+
+type VendorMessageAttachmentReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	AttachmentId       string `json:"attachmentId"`
+}
+
+//GetVendorMessageAttachment returns the contents of an attachment in one of the vendor's
+//conversations
+func (v *VendorServer) GetVendorMessageAttachment(ctx context.Context,
+	req *VendorMessageAttachmentReq) (resp *MessageAttachmentResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.GetVendorMessageAttachment")
+	defer end(&err)
+
+	var attachment *database.MessageAttachment
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageConversations)
+		if err != nil {
+			return err
+		}
+
+		var conversation *database.Conversation
+		attachment, conversation, err = loadAttachment(ctx, tx, req.AttachmentId)
+		if err != nil {
+			return err
+		}
+
+		if conversation.VendorPk != req.VendorPk {
+			return NotFound.New("attachment not found")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := v.blobs.Get(ctx, attachment.BlobKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageAttachmentResp{
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Data:        data,
 	}, nil
 }
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	OwnerRole          = "owner"
+	CatalogManagerRole = "catalog_manager"
+	SupportAgentRole   = "support_agent"
+	FinanceRole        = "finance"
+)
+
+type Permission int
+
+const (
+	//ManageTeam covers inviting and removing contacts and changing their roles
+	ManageTeam Permission = iota
+	//ManageCatalog covers creating and changing products and the storefront profile
+	ManageCatalog
+	//ManageConversations covers reading and answering buyer messages
+	ManageConversations
+	//ViewFinances covers sales and payout information
+	ViewFinances
+	//ManageApiKeys covers creating and revoking the vendor's api keys
+	ManageApiKeys
+	//ManageWebhooks covers the vendor's webhook endpoints and their deliveries
+	ManageWebhooks
+	//CloseAccount covers scheduling and cancelling the deletion of the vendor's account
+	CloseAccount
+)
+
+//Forbidden is returned when an executive contact's role does not allow what they tried to do
+var Forbidden = errs.Class("forbidden")
+
+var rolePermissions = map[string]map[Permission]bool{
+	OwnerRole: {
+		ManageTeam:          true,
+		ManageCatalog:       true,
+		ManageConversations: true,
+		ViewFinances:        true,
+		ManageApiKeys:       true,
+		ManageWebhooks:      true,
+		CloseAccount:        true,
+	},
+	CatalogManagerRole: {
+		ManageCatalog: true,
+	},
+	SupportAgentRole: {
+		ManageConversations: true,
+	},
+	FinanceRole: {
+		ViewFinances: true,
+	},
+}
+
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+//permit checks that the executive contact acting for a vendor belongs to that vendor and that
+//their role grants the permission. every VendorServer method acting for a signed in contact calls
+//it before doing anything else
+func permit(ctx context.Context, tx *database.Tx, vendor_pk, contact_pk int64,
+	permission Permission) (*database.ExecutiveContact, error) {
+
+	contact, err := tx.Find_ExecutiveContact_By_Pk(ctx, database.ExecutiveContact_Pk(contact_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	if contact == nil || contact.VendorPk != vendor_pk {
+		return nil, Forbidden.New("not a member of this vendor")
+	}
+
+	if !rolePermissions[contact.Role][permission] {
+		return nil, Forbidden.New("your role does not allow this")
+	}
+
+	return contact, nil
+}
+
+//permit is for VendorServer methods that do not otherwise open a transaction
+func (v *VendorServer) permit(ctx context.Context, vendor_pk, contact_pk int64,
+	permission Permission) error {
+
+	return v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, vendor_pk, contact_pk, permission)
+		return err
+	})
+}
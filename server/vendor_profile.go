@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"unicode/utf8"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+	"ladybug/validate"
+)
+
+const (
+	maxProfileTextLength = 2000
+)
+
+//VendorProfile is how a vendor presents itself to buyers on its storefront
+type VendorProfile struct {
+	VendorId     string `json:"vendorId"`
+	DisplayName  string `json:"displayName"`
+	Slug         string `json:"slug"`
+	LogoUrl      string `json:"logoUrl"`
+	Description  string `json:"description"`
+	ReturnPolicy string `json:"returnPolicy"`
+	SupportHours string `json:"supportHours"`
+}
+
+func VendorProfileFromDB(vendor *database.Vendor, profile *database.VendorProfile) *VendorProfile {
+	return &VendorProfile{
+		VendorId:     vendor.Id,
+		DisplayName:  profile.DisplayName,
+		Slug:         profile.Slug,
+		LogoUrl:      profile.LogoUrl,
+		Description:  profile.Description,
+		ReturnPolicy: profile.ReturnPolicy,
+		SupportHours: profile.SupportHours,
+	}
+}
+
+type GetVendorProfileReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type GetVendorProfileResp struct {
+	//Profile is nil until the vendor saves one
+	Profile *VendorProfile `json:"profile"`
+}
+
+func (v *VendorServer) GetVendorProfile(ctx context.Context, req *GetVendorProfileReq) (
+	resp *GetVendorProfileResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.GetVendorProfile")
+	defer end(&err)
+
+	var profile *VendorProfile
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		vendor, err := tx.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		db_profile, err := tx.Find_VendorProfile_By_VendorPk(ctx,
+			database.VendorProfile_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		if db_profile != nil {
+			profile = VendorProfileFromDB(vendor, db_profile)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetVendorProfileResp{
+		Profile: profile,
+	}, nil
+}
+
+type UpdateVendorProfileReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	DisplayName        string `json:"displayName"`
+	Slug               string `json:"slug"`
+	LogoUrl            string `json:"logoUrl"`
+	Description        string `json:"description"`
+	ReturnPolicy       string `json:"returnPolicy"`
+	SupportHours       string `json:"supportHours"`
+}
+
+type UpdateVendorProfileResp struct {
+	Profile *VendorProfile `json:"profile"`
+}
+
+func (req *UpdateVendorProfileReq) validate() error {
+	if err := validate.CheckName(req.DisplayName); err != nil {
+		return err
+	}
+
+	if err := validate.CheckSlug(req.Slug); err != nil {
+		return err
+	}
+
+	if err := checkLogoUrl(req.LogoUrl); err != nil {
+		return err
+	}
+
+	for _, text := range []string{req.LogoUrl, req.Description, req.ReturnPolicy,
+		req.SupportHours} {
+
+		if utf8.RuneCountInString(text) > maxProfileTextLength {
+			return errs.New("profile fields cannot exceed %d characters", maxProfileTextLength)
+		}
+	}
+
+	return nil
+}
+
+//checkLogoUrl allows no logo or an https one. buyers' browsers load the logo, so anything else
+//would be mixed content or a script url on the storefront
+func checkLogoUrl(raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return errs.New("a logo url has to be an https url")
+	}
+
+	return nil
+}
+
+//UpdateVendorProfile saves the whole storefront profile, creating it the first time
+func (v *VendorServer) UpdateVendorProfile(ctx context.Context, req *UpdateVendorProfileReq) (
+	resp *UpdateVendorProfileResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.UpdateVendorProfile")
+	defer end(&err)
+
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	var profile *VendorProfile
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		vendor, err := tx.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		taken, err := tx.Find_VendorProfile_By_Slug(ctx, database.VendorProfile_Slug(req.Slug))
+		if err != nil {
+			return err
+		}
+
+		if taken != nil && taken.VendorPk != req.VendorPk {
+			return errs.New("the slug %q is already taken", req.Slug)
+		}
+
+		db_profile, err := tx.Find_VendorProfile_By_VendorPk(ctx,
+			database.VendorProfile_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		if db_profile == nil {
+			db_profile, err = tx.Create_VendorProfile(ctx,
+				database.VendorProfile_Id(uuid.NewV4().String()),
+				database.VendorProfile_VendorPk(req.VendorPk),
+				database.VendorProfile_DisplayName(req.DisplayName),
+				database.VendorProfile_Slug(req.Slug),
+				database.VendorProfile_LogoUrl(req.LogoUrl),
+				database.VendorProfile_Description(req.Description),
+				database.VendorProfile_ReturnPolicy(req.ReturnPolicy),
+				database.VendorProfile_SupportHours(req.SupportHours))
+		} else {
+			db_profile, err = tx.Update_VendorProfile_By_Pk(ctx,
+				database.VendorProfile_Pk(db_profile.Pk),
+				database.VendorProfile_Update_Fields{
+					DisplayName:  database.VendorProfile_DisplayName(req.DisplayName),
+					Slug:         database.VendorProfile_Slug(req.Slug),
+					LogoUrl:      database.VendorProfile_LogoUrl(req.LogoUrl),
+					Description:  database.VendorProfile_Description(req.Description),
+					ReturnPolicy: database.VendorProfile_ReturnPolicy(req.ReturnPolicy),
+					SupportHours: database.VendorProfile_SupportHours(req.SupportHours),
+				})
+		}
+		if err != nil {
+			return err
+		}
+
+		profile = VendorProfileFromDB(vendor, db_profile)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateVendorProfileResp{
+		Profile: profile,
+	}, nil
+}
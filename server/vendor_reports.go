@@ -0,0 +1,535 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	DayInterval   = "day"
+	WeekInterval  = "week"
+	MonthInterval = "month"
+
+	maxReportRange    = 366 * 24 * time.Hour
+	lowStockThreshold = 5
+)
+
+//InvalidReport is returned when a report is asked for over a range or interval we do not support
+var InvalidReport = errs.Class("invalid report")
+
+//SalesTotals are the figures reported for the whole range, for each period and for each product.
+//a trial counts as converted when the same buyer buys the product before the end of the range
+type SalesTotals struct {
+	Revenue         float64 `json:"revenue"`
+	Units           int64   `json:"units"`
+	Trials          int64   `json:"trials"`
+	TrialsConverted int64   `json:"trialsConverted"`
+	TrialsReturned  int64   `json:"trialsReturned"`
+	ConversionRate  float64 `json:"conversionRate"`
+	ReturnRate      float64 `json:"returnRate"`
+}
+
+func (t *SalesTotals) setRates() {
+	if t.Trials == 0 {
+		return
+	}
+	t.ConversionRate = float64(t.TrialsConverted) / float64(t.Trials)
+	t.ReturnRate = float64(t.TrialsReturned) / float64(t.Trials)
+}
+
+type PeriodSales struct {
+	Start int64 `json:"start"`
+	SalesTotals
+}
+
+type ProductSales struct {
+	ProductId string `json:"productId"`
+	Sku       string `json:"sku"`
+	SalesTotals
+}
+
+//periodStart truncates t to the start of the day, monday of the week or first of the month it
+//falls in. reports are always in UTC
+func periodStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case WeekInterval:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case MonthInterval:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+type salesReport struct {
+	totals   SalesTotals
+	periods  map[int64]*SalesTotals
+	products map[int64]*SalesTotals
+}
+
+func (r *salesReport) add(period, product_pk int64, fn func(t *SalesTotals)) {
+	if r.periods[period] == nil {
+		r.periods[period] = &SalesTotals{}
+	}
+	if r.products[product_pk] == nil {
+		r.products[product_pk] = &SalesTotals{}
+	}
+
+	fn(&r.totals)
+	fn(r.periods[period])
+	fn(r.products[product_pk])
+}
+
+func newSalesReport() *salesReport {
+	return &salesReport{
+		periods:  map[int64]*SalesTotals{},
+		products: map[int64]*SalesTotals{},
+	}
+}
+
+type salesData struct {
+	report   *salesReport
+	products map[int64]*database.Product
+}
+
+type buyerProduct struct{ buyer_pk, product_pk int64 }
+
+//recordFirstPurchases notes the first time each buyer bought each product, which decides whether
+//their trials converted. purchases must be oldest first. purchases by erased buyers have no buyer
+//left and are skipped so they cannot convert the trials of other erased buyers
+func recordFirstPurchases(bought map[buyerProduct]time.Time,
+	purchases []*database.PurchasedProduct) {
+
+	for _, p := range purchases {
+		if p.BuyerPk == 0 {
+			continue
+		}
+
+		key := buyerProduct{p.BuyerPk, p.ProductPk}
+		if _, ok := bought[key]; !ok {
+			bought[key] = p.CreatedAt
+		}
+	}
+}
+
+func (r *salesReport) addPurchases(purchases []*database.PurchasedProduct, interval string) {
+	for _, p := range purchases {
+		period := periodStart(p.CreatedAt, interval).Unix()
+		r.add(period, p.ProductPk, func(t *SalesTotals) {
+			t.Revenue += float64(p.PurchasePrice)
+			t.Units++
+		})
+	}
+}
+
+func (r *salesReport) addTrials(trials []*database.TrialProduct,
+	bought map[buyerProduct]time.Time, interval string) {
+
+	for _, trial := range trials {
+		bought_at, ok := bought[buyerProduct{trial.BuyerPk, trial.ProductPk}]
+		converted := ok && !bought_at.Before(trial.CreatedAt)
+
+		period := periodStart(trial.CreatedAt, interval).Unix()
+		r.add(period, trial.ProductPk, func(t *SalesTotals) {
+			t.Trials++
+			if converted {
+				t.TrialsConverted++
+			}
+			if trial.IsReturned {
+				t.TrialsReturned++
+			}
+		})
+	}
+}
+
+func loadSalesRange(ctx context.Context, tx *database.Tx, vendor_pk int64, from, to time.Time) (
+	[]*database.PurchasedProduct, []*database.TrialProduct, error) {
+
+	purchases, err := tx.All_PurchasedProduct_By_VendorPk_And_CreatedAt_GreaterOrEqual_And_CreatedAt_Less_OrderBy_Asc_CreatedAt(
+		ctx, database.PurchasedProduct_VendorPk(vendor_pk),
+		database.PurchasedProduct_CreatedAt(from), database.PurchasedProduct_CreatedAt(to))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trials, err := tx.All_TrialProduct_By_VendorPk_And_CreatedAt_GreaterOrEqual_And_CreatedAt_Less_OrderBy_Asc_CreatedAt(
+		ctx, database.TrialProduct_VendorPk(vendor_pk),
+		database.TrialProduct_CreatedAt(from), database.TrialProduct_CreatedAt(to))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return purchases, trials, nil
+}
+
+func loadProducts(ctx context.Context, tx *database.Tx, vendor_pk int64) (
+	map[int64]*database.Product, error) {
+
+	db_products, err := tx.All_Product_By_VendorPk(ctx, database.Product_VendorPk(vendor_pk))
+	if err != nil {
+		return nil, err
+	}
+
+	products := map[int64]*database.Product{}
+	for _, p := range db_products {
+		products[p.Pk] = p
+	}
+	return products, nil
+}
+
+func loadSales(ctx context.Context, tx *database.Tx, vendor_pk int64, from, to time.Time,
+	interval string) (*salesData, error) {
+
+	purchases, trials, err := loadSalesRange(ctx, tx, vendor_pk, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := loadProducts(ctx, tx, vendor_pk)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &salesData{
+		report:   newSalesReport(),
+		products: products,
+	}
+
+	bought := map[buyerProduct]time.Time{}
+	recordFirstPurchases(bought, purchases)
+	data.report.addPurchases(purchases, interval)
+	data.report.addTrials(trials, bought, interval)
+
+	return data, nil
+}
+
+//nextPeriod is the start of the period after the one starting at start
+func nextPeriod(start time.Time, interval string) time.Time {
+	switch interval {
+	case WeekInterval:
+		return start.AddDate(0, 0, 7)
+	case MonthInterval:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+//forEachPeriod calls fn with the part of each period that falls between from and to, in order
+func forEachPeriod(from, to time.Time, interval string,
+	fn func(period_from, period_to time.Time) error) error {
+
+	for start := periodStart(from, interval); start.Before(to); start = nextPeriod(start,
+		interval) {
+
+		period_from, period_to := start, nextPeriod(start, interval)
+		if period_from.Before(from) {
+			period_from = from
+		}
+		if period_to.After(to) {
+			period_to = to
+		}
+
+		if err := fn(period_from, period_to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *salesData) product(pk int64) (id, sku string) {
+	if p := d.products[pk]; p != nil {
+		return p.Id, p.Sku
+	}
+	return "", ""
+}
+
+func sortedKeys(m map[int64]*SalesTotals) []int64 {
+	keys := make([]int64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+type VendorSalesReportReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	From               int64  `json:"from"`
+	To                 int64  `json:"to"`
+	Interval           string `json:"interval"`
+}
+
+func (req *VendorSalesReportReq) validate() (from, to time.Time, err error) {
+	switch req.Interval {
+	case "":
+		req.Interval = DayInterval
+	case DayInterval, WeekInterval, MonthInterval:
+	default:
+		return from, to, InvalidReport.New("interval must be one of day, week or month")
+	}
+
+	from, to = time.Unix(req.From, 0).UTC(), time.Unix(req.To, 0).UTC()
+	if !from.Before(to) {
+		return from, to, InvalidReport.New("the start of the range must be before the end")
+	}
+
+	if to.Sub(from) > maxReportRange {
+		return from, to, InvalidReport.New("reports can cover at most %d days",
+			int(maxReportRange.Hours()/24))
+	}
+
+	return from, to, nil
+}
+
+type VendorSalesReportResp struct {
+	Totals   SalesTotals     `json:"totals"`
+	Periods  []*PeriodSales  `json:"periods"`
+	Products []*ProductSales `json:"products"`
+}
+
+//VendorSalesReport reports revenue, units and trial outcomes over a range broken down by period
+//and by product
+func (v *VendorServer) VendorSalesReport(ctx context.Context, req *VendorSalesReportReq) (
+	resp *VendorSalesReportResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.VendorSalesReport")
+	defer end(&err)
+
+	from, to, err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var data *salesData
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ViewFinances)
+		if err != nil {
+			return err
+		}
+
+		data, err = loadSales(ctx, tx, req.VendorPk, from, to, req.Interval)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := data.report
+	resp = &VendorSalesReportResp{
+		Totals:   report.totals,
+		Periods:  []*PeriodSales{},
+		Products: []*ProductSales{},
+	}
+	resp.Totals.setRates()
+
+	for _, start := range sortedKeys(report.periods) {
+		period := &PeriodSales{Start: start, SalesTotals: *report.periods[start]}
+		period.setRates()
+		resp.Periods = append(resp.Periods, period)
+	}
+
+	for _, pk := range sortedKeys(report.products) {
+		product := &ProductSales{SalesTotals: *report.products[pk]}
+		product.ProductId, product.Sku = data.product(pk)
+		product.setRates()
+		resp.Products = append(resp.Products, product)
+	}
+
+	return resp, nil
+}
+
+var salesCSVHeader = []string{"period_start", "product_id", "sku", "units", "revenue", "trials",
+	"trials_converted", "trials_returned"}
+
+//ExportVendorSales writes the same figures as VendorSalesReport to w as csv, one row for each
+//product sold or trialed in each period. the range is read twice: once to find when each buyer
+//first bought each product, which is kept for the whole range, then a period at a time as the
+//rows for that period are written. nothing is written if the request is refused
+func (v *VendorServer) ExportVendorSales(ctx context.Context, req *VendorSalesReportReq,
+	w io.Writer) (err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ExportVendorSales")
+	defer end(&err)
+
+	from, to, err := req.validate()
+	if err != nil {
+		return err
+	}
+
+	return v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ViewFinances)
+		if err != nil {
+			return err
+		}
+
+		products, err := loadProducts(ctx, tx, req.VendorPk)
+		if err != nil {
+			return err
+		}
+
+		//a trial converts if the buyer buys later in the range, possibly in a later period, so the
+		//first purchases are found before any period is written
+		bought := map[buyerProduct]time.Time{}
+		err = forEachPeriod(from, to, req.Interval, func(period_from, period_to time.Time) error {
+			purchases, _, err := loadSalesRange(ctx, tx, req.VendorPk, period_from, period_to)
+			if err != nil {
+				return err
+			}
+			recordFirstPurchases(bought, purchases)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		data := &salesData{products: products}
+		out := csv.NewWriter(w)
+		err = out.Write(salesCSVHeader)
+		if err != nil {
+			return errs.Wrap(err)
+		}
+
+		return forEachPeriod(from, to, req.Interval, func(period_from, period_to time.Time) error {
+			purchases, trials, err := loadSalesRange(ctx, tx, req.VendorPk, period_from, period_to)
+			if err != nil {
+				return err
+			}
+
+			report := newSalesReport()
+			report.addPurchases(purchases, req.Interval)
+			report.addTrials(trials, bought, req.Interval)
+
+			period := periodStart(period_from, req.Interval)
+			for _, product_pk := range sortedKeys(report.products) {
+				t := report.products[product_pk]
+				id, sku := data.product(product_pk)
+				err = out.Write([]string{
+					period.Format("2006-01-02"),
+					id,
+					sku,
+					strconv.FormatInt(t.Units, 10),
+					strconv.FormatFloat(t.Revenue, 'f', 2, 64),
+					strconv.FormatInt(t.Trials, 10),
+					strconv.FormatInt(t.TrialsConverted, 10),
+					strconv.FormatInt(t.TrialsReturned, 10),
+				})
+				if err != nil {
+					return errs.Wrap(err)
+				}
+			}
+
+			out.Flush()
+			return errs.Wrap(out.Error())
+		})
+	})
+}
+
+type LowStockReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	//Threshold defaults to lowStockThreshold
+	Threshold int `json:"threshold"`
+}
+
+type LowStockProduct struct {
+	ProductId string `json:"productId"`
+	//VariantId is set when the stock is the variant's rather than the product's
+	VariantId  string `json:"variantId,omitempty"`
+	Sku        string `json:"sku"`
+	NumInStock int    `json:"numInStock"`
+}
+
+type LowStockResp struct {
+	Threshold int                `json:"threshold"`
+	Products  []*LowStockProduct `json:"products"`
+}
+
+//LowStock lists the vendor's active products and their variants with Threshold or fewer left,
+//lowest first
+func (v *VendorServer) LowStock(ctx context.Context, req *LowStockReq) (
+	resp *LowStockResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.LowStock")
+	defer end(&err)
+
+	if req.Threshold < 0 {
+		return nil, InvalidReport.New("threshold cannot be negative")
+	}
+	if req.Threshold == 0 {
+		req.Threshold = lowStockThreshold
+	}
+
+	resp = &LowStockResp{
+		Threshold: req.Threshold,
+		Products:  []*LowStockProduct{},
+	}
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageCatalog)
+		if err != nil {
+			return err
+		}
+
+		products, err := tx.All_Product_By_VendorPk_And_ProductActive_Equal_True_And_NumInStock_LessOrEqual_OrderBy_Asc_NumInStock(
+			ctx, database.Product_VendorPk(req.VendorPk),
+			database.Product_NumInStock(req.Threshold))
+		if err != nil {
+			return err
+		}
+
+		for _, p := range products {
+			resp.Products = append(resp.Products, &LowStockProduct{
+				ProductId:  p.Id,
+				Sku:        p.Sku,
+				NumInStock: p.NumInStock,
+			})
+		}
+
+		variants, err := tx.All_ProductVariant_By_ProductVariant_VendorPk_And_Product_ProductActive_Equal_True_And_ProductVariant_NumInStock_LessOrEqual_OrderBy_Asc_ProductVariant_NumInStock(
+			ctx, database.ProductVariant_VendorPk(req.VendorPk),
+			database.ProductVariant_NumInStock(req.Threshold))
+		if err != nil {
+			return err
+		}
+
+		product_ids := map[int64]string{}
+		for _, variant := range variants {
+			if _, ok := product_ids[variant.ProductPk]; !ok {
+				product, err := tx.Get_Product_By_Pk(ctx, database.Product_Pk(variant.ProductPk))
+				if err != nil {
+					return err
+				}
+				product_ids[variant.ProductPk] = product.Id
+			}
+
+			resp.Products = append(resp.Products, &LowStockProduct{
+				ProductId:  product_ids[variant.ProductPk],
+				VariantId:  variant.Id,
+				Sku:        variant.Sku,
+				NumInStock: variant.NumInStock,
+			})
+		}
+
+		sort.SliceStable(resp.Products, func(i, j int) bool {
+			return resp.Products[i].NumInStock < resp.Products[j].NumInStock
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
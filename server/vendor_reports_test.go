@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+//at runs fn with the database clock set to t
+func (h *serverTest) at(t time.Time, fn func()) {
+	h.db.Hooks.Now = func() time.Time { return t }
+	defer func() { h.db.Hooks.Now = time.Now }()
+	fn()
+}
+
+func (h *serverTest) createTrial(ctx context.Context, buyer_pk int64, product *database.Product,
+	returned bool) *database.TrialProduct {
+
+	trial, err := h.db.Create_TrialProduct(ctx,
+		database.TrialProduct_Id(uuid.NewV4().String()),
+		database.TrialProduct_VendorPk(product.VendorPk),
+		database.TrialProduct_BuyerPk(buyer_pk),
+		database.TrialProduct_ProductPk(product.Pk),
+		database.TrialProduct_VariantPk(0),
+		database.TrialProduct_TrialPrice(product.Price),
+		database.TrialProduct_IsReturned(returned),
+		database.TrialProduct_ShippingAddressPk(0),
+	)
+	require.NoError(h.t, err)
+
+	return trial
+}
+
+func TestVendorSalesReport(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	finance := test.createExecutiveContact(ctx, vendor.Pk, FinanceRole)
+	support_agent := test.createExecutiveContact(ctx, vendor.Pk, SupportAgentRole)
+	buyers := test.createDefaultBuyers(ctx, 3)
+	product_a := test.createProductInDB(ctx, vendor.Pk, &productOptions{Price: 10, Discount: 1})
+	product_b := test.createProductInDB(ctx, vendor.Pk, &productOptions{Price: 20, Discount: 1})
+
+	monday := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	test.at(monday, func() {
+		test.createTrial(ctx, buyers[0].Pk, product_a, false)
+		test.purchaseProduct(ctx, buyers[0].Pk, vendor.Pk, product_a)
+		test.createTrial(ctx, buyers[1].Pk, product_a, true)
+	})
+	test.at(monday.AddDate(0, 0, 2), func() {
+		test.purchaseProduct(ctx, buyers[1].Pk, vendor.Pk, product_b)
+		test.purchaseProduct(ctx, buyers[1].Pk, vendor.Pk, product_b)
+	})
+	test.at(monday.AddDate(0, 0, 8), func() {
+		test.purchaseProduct(ctx, buyers[2].Pk, vendor.Pk, product_a)
+	})
+
+	req := &VendorSalesReportReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: finance.Pk,
+		From:               monday.AddDate(0, 0, -1).Unix(),
+		To:                 monday.AddDate(0, 0, 13).Unix(),
+	}
+
+	//daily
+	resp, err := test.VendorServer.VendorSalesReport(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, resp.Totals.Revenue, float64(60))
+	require.Equal(t, resp.Totals.Units, int64(4))
+	require.Equal(t, resp.Totals.Trials, int64(2))
+	require.Equal(t, resp.Totals.ConversionRate, 0.5)
+	require.Equal(t, resp.Totals.ReturnRate, 0.5)
+	require.Len(t, resp.Periods, 3)
+	require.Len(t, resp.Products, 2)
+	require.Equal(t, resp.Products[0].ProductId, product_a.Id)
+	require.Equal(t, resp.Products[0].Units, int64(2))
+	require.Equal(t, resp.Products[1].Revenue, float64(40))
+
+	//weekly periods start on monday
+	req.Interval = WeekInterval
+	resp, err = test.VendorServer.VendorSalesReport(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Periods, 2)
+	require.Equal(t, resp.Periods[0].Start, time.Date(2026, time.March, 2, 0, 0, 0, 0,
+		time.UTC).Unix())
+	require.Equal(t, resp.Periods[0].Revenue, float64(50))
+
+	//the csv has a row for each product in each period
+	var buf bytes.Buffer
+	err = test.VendorServer.ExportVendorSales(ctx, req, &buf)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, lines[0], strings.Join(salesCSVHeader, ","))
+	require.True(t, strings.HasPrefix(lines[1], "2026-03-02,"+product_a.Id+","))
+	require.True(t, strings.HasSuffix(lines[1], ",1,10.00,2,1,1"))
+
+	//bad ranges and intervals
+	req.Interval = "year"
+	_, err = test.VendorServer.VendorSalesReport(ctx, req)
+	require.True(t, InvalidReport.Has(err))
+
+	req.Interval = MonthInterval
+	req.From, req.To = req.To, req.From
+	_, err = test.VendorServer.VendorSalesReport(ctx, req)
+	require.True(t, InvalidReport.Has(err))
+
+	//support agents cannot see sales
+	req.From, req.To = req.To, req.From
+	req.ExecutiveContactPk = support_agent.Pk
+	_, err = test.VendorServer.VendorSalesReport(ctx, req)
+	require.True(t, Forbidden.Has(err))
+}
+
+func TestVendorSalesReportErasedBuyers(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	finance := test.createExecutiveContact(ctx, vendor.Pk, FinanceRole)
+	product := test.createProductInDB(ctx, vendor.Pk, &productOptions{Price: 10, Discount: 1})
+
+	//erased buyers are detached from what they trialed and bought
+	monday := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	test.at(monday, func() {
+		test.createTrial(ctx, 0, product, false)
+		test.purchaseProduct(ctx, 0, vendor.Pk, product)
+	})
+
+	//so one erased buyer's purchase does not convert another's trial
+	resp, err := test.VendorServer.VendorSalesReport(ctx, &VendorSalesReportReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: finance.Pk,
+		From:               monday.AddDate(0, 0, -1).Unix(),
+		To:                 monday.AddDate(0, 0, 1).Unix(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, resp.Totals.Trials, int64(1))
+	require.Equal(t, resp.Totals.Units, int64(1))
+	require.Equal(t, resp.Totals.ConversionRate, float64(0))
+}
+
+func TestLowStock(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	low := test.createProductInDB(ctx, vendor.Pk, &productOptions{ProductActive: true,
+		NumInStock: 2})
+	test.createProductInDB(ctx, vendor.Pk, &productOptions{ProductActive: true, NumInStock: 50})
+	test.createProductInDB(ctx, vendor.Pk, &productOptions{NumInStock: 0})
+
+	//variants are listed with their own stock
+	shirt, err := test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		UnitPrice:          20,
+		SKU:                "SHIRT",
+		ProductActive:      true,
+		NumberInStock:      50,
+		Attributes:         []string{"size"},
+	})
+	require.NoError(t, err)
+
+	small, err := test.VendorServer.AddProductVariant(ctx, &AddProductVariantReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ProductId:          shirt.ProductId,
+		Sku:                "SHIRT-S",
+		Attributes:         map[string]string{"size": "S"},
+		Price:              20,
+		NumInStock:         1,
+	})
+	require.NoError(t, err)
+
+	resp, err := test.VendorServer.LowStock(ctx, &LowStockReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resp.Threshold, lowStockThreshold)
+	require.Len(t, resp.Products, 2)
+	require.Equal(t, resp.Products[0].ProductId, shirt.ProductId)
+	require.Equal(t, resp.Products[0].VariantId, small.Variant.Id)
+	require.Equal(t, resp.Products[0].NumInStock, 1)
+	require.Equal(t, resp.Products[1].ProductId, low.Id)
+
+	resp, err = test.VendorServer.LowStock(ctx, &LowStockReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Threshold:          100,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Products, 4)
+}
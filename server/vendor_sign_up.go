@@ -14,7 +14,8 @@ const (
 	maxExecutiveContacts = 12
 )
 
-//NOTE: Executive contacts have full access to a vendor's portal. there are presently no roles set up
+//NOTE: Executive contacts created at sign up are owners of the vendor. more contacts with other
+//roles can be invited afterwards
 type ExecutiveContact struct {
 	FirstName   string `json:"firstName"`
 	LastName    string `json:"lastName"`
@@ -28,8 +29,8 @@ type ExecutiveContact struct {
 type VendorSignUpRequest struct {
 	Fein              string              `json:"fein"`
 	BillingAddress    *validate.Address   `json:"billingAddress"`
-	ShippingAddress   *validate.Address   `json"shippingAddress"`
-	ExecutiveContacts []*ExecutiveContact `json"executiveContact"`
+	ShippingAddress   *validate.Address   `json:"shippingAddress"`
+	ExecutiveContacts []*ExecutiveContact `json:"executiveContacts"`
 }
 
 type VendorSignUpResponse struct {
@@ -40,6 +41,9 @@ type VendorSignUpResponse struct {
 func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpRequest) (
 	resp *VendorSignUpResponse, err error) {
 
+	ctx, end := startSpan(ctx, "VendorServer.VendorSignUp")
+	defer end(&err)
+
 	err = ValidateVendorSignUpRequest(req)
 	if err != nil {
 		return nil, err
@@ -68,10 +72,12 @@ func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpReques
 
 		err = tx.CreateNoReturn_VendorAddress(ctx,
 			database.VendorAddress_VendorPk(vendor.Pk),
-			database.VendorAddress_StreetAddress(req.BillingAddress.StreetAddress),
+			database.VendorAddress_CountryCode(req.BillingAddress.CountryCode),
+			database.VendorAddress_Line1(req.BillingAddress.Line1),
+			database.VendorAddress_Line2(req.BillingAddress.Line2),
 			database.VendorAddress_City(req.BillingAddress.City),
-			database.VendorAddress_State(req.BillingAddress.State),
-			database.VendorAddress_Zip(req.BillingAddress.Zip),
+			database.VendorAddress_Region(req.BillingAddress.Region),
+			database.VendorAddress_PostalCode(req.BillingAddress.PostalCode),
 			database.VendorAddress_IsBilling(true),
 			database.VendorAddress_Id(uuid.NewV4().String()))
 		if err != nil {
@@ -81,10 +87,12 @@ func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpReques
 		if !ship_addr_is_empty {
 			err = tx.CreateNoReturn_VendorAddress(ctx,
 				database.VendorAddress_VendorPk(vendor.Pk),
-				database.VendorAddress_StreetAddress(req.ShippingAddress.StreetAddress),
+				database.VendorAddress_CountryCode(req.ShippingAddress.CountryCode),
+				database.VendorAddress_Line1(req.ShippingAddress.Line1),
+				database.VendorAddress_Line2(req.ShippingAddress.Line2),
 				database.VendorAddress_City(req.ShippingAddress.City),
-				database.VendorAddress_State(req.ShippingAddress.State),
-				database.VendorAddress_Zip(req.ShippingAddress.Zip),
+				database.VendorAddress_Region(req.ShippingAddress.Region),
+				database.VendorAddress_PostalCode(req.ShippingAddress.PostalCode),
 				database.VendorAddress_IsBilling(false),
 				database.VendorAddress_Id(uuid.NewV4().String()))
 			if err != nil {
@@ -92,6 +100,7 @@ func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpReques
 			}
 		}
 
+		var first_contact *database.ExecutiveContact
 		for _, e := range req.ExecutiveContacts {
 			hash, err := hashPassword(e.Password)
 			if err != nil {
@@ -102,11 +111,16 @@ func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpReques
 				database.ExecutiveContact_Id(uuid.NewV4().String()),
 				database.ExecutiveContact_VendorPk(vendor.Pk),
 				database.ExecutiveContact_FirstName(e.FirstName),
-				database.ExecutiveContact_LastName(e.LastName))
+				database.ExecutiveContact_LastName(e.LastName),
+				database.ExecutiveContact_Role(OwnerRole))
 			if err != nil {
 				return err
 			}
 
+			if first_contact == nil {
+				first_contact = exec
+			}
+
 			err = tx.CreateNoReturn_VendorEmail(ctx,
 				database.VendorEmail_Id(uuid.NewV4().String()),
 				database.VendorEmail_ExecutiveContactPk(exec.Pk),
@@ -129,17 +143,20 @@ func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpReques
 
 		vendor_session, err = tx.Create_VendorSession(ctx,
 			database.VendorSession_VendorPk(vendor.Pk),
+			database.VendorSession_ExecutiveContactPk(first_contact.Pk),
 			database.VendorSession_Id(uuid.NewV4().String()))
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return auditContact(ctx, tx, first_contact.Id, AuditVendorSignUp, "vendor", vendor.Id,
+			nil, auditFields{"fein": req.Fein, "executiveContacts": len(req.ExecutiveContacts)})
 
 	})
 	if err != nil {
 		return nil, err
 	}
+	signUps.WithLabelValues("vendor").Inc()
 
 	return &VendorSignUpResponse{Session: vendor_session, VendorId: vendor_id}, nil
 }
@@ -148,6 +165,10 @@ func (v *VendorServer) VendorSignUp(ctx context.Context, req *VendorSignUpReques
 //error is there is a problem or incoming data does not match the requirement
 func ValidateVendorSignUpRequest(vsr *VendorSignUpRequest) error {
 
+	if len(vsr.ExecutiveContacts) == 0 {
+		return errs.New("at least one executive contact is required")
+	}
+
 	if len(vsr.ExecutiveContacts) > maxExecutiveContacts {
 		return errs.New("only a max of %d contacts are allowed", maxExecutiveContacts)
 	}
@@ -166,6 +187,10 @@ func ValidateVendorSignUpRequest(vsr *VendorSignUpRequest) error {
 		}
 	}
 
+	//addresses are checked and stored in their normalized form
+	validate.NormalizeAddress(vsr.BillingAddress)
+	validate.NormalizeAddress(vsr.ShippingAddress)
+
 	if err := validate.CheckAddress(vsr.BillingAddress); err != nil {
 		return err
 	}
@@ -0,0 +1,517 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+	"ladybug/validate"
+)
+
+const (
+	inviteExpiry = 7 * 24 * time.Hour
+)
+
+type TeamMember struct {
+	Id        string   `json:"id"`
+	FirstName string   `json:"firstName"`
+	LastName  string   `json:"lastName"`
+	Role      string   `json:"role"`
+	Emails    []string `json:"emails"`
+}
+
+func teamMemberFromDB(ctx context.Context, tx *database.Tx,
+	contact *database.ExecutiveContact) (*TeamMember, error) {
+
+	emails, err := tx.All_VendorEmail_By_ExecutiveContactPk(ctx,
+		database.VendorEmail_ExecutiveContactPk(contact.Pk))
+	if err != nil {
+		return nil, err
+	}
+
+	member := &TeamMember{
+		Id:        contact.Id,
+		FirstName: contact.FirstName,
+		LastName:  contact.LastName,
+		Role:      contact.Role,
+		Emails:    []string{},
+	}
+	for _, e := range emails {
+		member.Emails = append(member.Emails, e.Address)
+	}
+
+	return member, nil
+}
+
+//hashToken is what is stored for tokens we mail out, like invite acceptance and email
+//verification tokens, so a database leak does not let anyone use them
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+//findTeamMember loads one of the vendor's executive contacts by id
+func findTeamMember(ctx context.Context, tx *database.Tx, vendor_pk int64, contact_id string) (
+	*database.ExecutiveContact, error) {
+
+	contact, err := tx.Find_ExecutiveContact_By_Id(ctx, database.ExecutiveContact_Id(contact_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if contact == nil || contact.VendorPk != vendor_pk {
+		return nil, NotFound.New("contact not found")
+	}
+
+	return contact, nil
+}
+
+//checkKeepsOwner makes sure a change to contact does not leave the vendor without an owner. the
+//caller has to hold the vendor's lock, or two owners removing each other at the same time would
+//both see the other one left
+func checkKeepsOwner(ctx context.Context, tx *database.Tx,
+	contact *database.ExecutiveContact) error {
+
+	if contact.Role != OwnerRole {
+		return nil
+	}
+
+	owners, err := tx.Count_ExecutiveContact_By_VendorPk_And_Role(ctx,
+		database.ExecutiveContact_VendorPk(contact.VendorPk),
+		database.ExecutiveContact_Role(OwnerRole))
+	if err != nil {
+		return err
+	}
+
+	if owners <= 1 {
+		return Forbidden.New("a vendor must keep at least one owner")
+	}
+
+	return nil
+}
+
+type GetVendorTeamReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type GetVendorTeamResp struct {
+	Members []*TeamMember `json:"members"`
+}
+
+func (v *VendorServer) GetVendorTeam(ctx context.Context, req *GetVendorTeamReq) (
+	resp *GetVendorTeamResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.GetVendorTeam")
+	defer end(&err)
+
+	members := []*TeamMember{}
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageTeam)
+		if err != nil {
+			return err
+		}
+
+		contacts, err := tx.All_ExecutiveContact_By_VendorPk(ctx,
+			database.ExecutiveContact_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		for _, c := range contacts {
+			member, err := teamMemberFromDB(ctx, tx, c)
+			if err != nil {
+				return err
+			}
+			members = append(members, member)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetVendorTeamResp{
+		Members: members,
+	}, nil
+}
+
+type InviteExecutiveContactReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Email              string `json:"email"`
+	Role               string `json:"role"`
+}
+
+type InviteExecutiveContactResp struct {
+	InviteId string `json:"inviteId"`
+}
+
+//InviteExecutiveContact emails an acceptance token to someone who should join the vendor's team.
+//pending invites count towards maxExecutiveContacts
+func (v *VendorServer) InviteExecutiveContact(ctx context.Context,
+	req *InviteExecutiveContactReq) (resp *InviteExecutiveContactResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.InviteExecutiveContact")
+	defer end(&err)
+
+	if err := validate.CheckEmail(req.Email); err != nil {
+		return nil, err
+	}
+
+	if !validRole(req.Role) {
+		return nil, errs.New("unknown role %q", req.Role)
+	}
+
+	token := uuid.NewV4().String()
+	var invite *database.VendorInvite
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageTeam)
+		if err != nil {
+			return err
+		}
+
+		taken, err := tx.Has_VendorEmail_By_Address(ctx, database.VendorEmail_Address(req.Email))
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return errs.New("%s already belongs to a vendor", req.Email)
+		}
+
+		contacts, err := tx.Count_ExecutiveContact_By_VendorPk(ctx,
+			database.ExecutiveContact_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		invites, err := tx.Count_VendorInvite_By_VendorPk_And_CreatedAt_Greater(ctx,
+			database.VendorInvite_VendorPk(req.VendorPk),
+			database.VendorInvite_CreatedAt(v.db.Hooks.Now().UTC().Add(-inviteExpiry)))
+		if err != nil {
+			return err
+		}
+
+		if contacts+invites >= maxExecutiveContacts {
+			return errs.New("only a max of %d contacts are allowed", maxExecutiveContacts)
+		}
+
+		invite, err = tx.Create_VendorInvite(ctx,
+			database.VendorInvite_Id(uuid.NewV4().String()),
+			database.VendorInvite_VendorPk(req.VendorPk),
+			database.VendorInvite_InvitedByPk(req.ExecutiveContactPk),
+			database.VendorInvite_Email(req.Email),
+			database.VendorInvite_Role(req.Role),
+			database.VendorInvite_TokenHash(hashToken(token)))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.mailer.SendMail(ctx, req.Email, "You have been invited to a Ladybug vendor team",
+		fmt.Sprintf("Use this token to accept the invite within %d days: %s",
+			int(inviteExpiry.Hours()/24), token))
+	if err != nil {
+		return nil, err
+	}
+
+	return &InviteExecutiveContactResp{
+		InviteId: invite.Id,
+	}, nil
+}
+
+type AcceptVendorInviteReq struct {
+	Token       string `json:"token"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	CountryCode int    `json:"countryCode"`
+	AreaCode    int    `json:"areaCode"`
+	PhoneNumber int    `json:"phoneNumber"`
+	Password    string `json:"password"`
+}
+
+type AcceptVendorInviteResp struct {
+	Session  *database.VendorSession `json:"-"`
+	VendorId string                  `json:"vendorId"`
+}
+
+//AcceptVendorInvite adds the invited person to the vendor's team with the role they were invited
+//as and signs them in
+func (v *VendorServer) AcceptVendorInvite(ctx context.Context, req *AcceptVendorInviteReq) (
+	resp *AcceptVendorInviteResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.AcceptVendorInvite")
+	defer end(&err)
+
+	if err := validate.CheckFullName(req.FirstName, req.LastName); err != nil {
+		return nil, err
+	}
+
+	if err := validate.CheckPassword(req.Password); err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var vendor_session *database.VendorSession
+	var vendor *database.Vendor
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		invite, err := tx.Find_VendorInvite_By_TokenHash(ctx,
+			database.VendorInvite_TokenHash(hashToken(req.Token)))
+		if err != nil {
+			return err
+		}
+
+		if invite == nil || v.db.Hooks.Now().Sub(invite.CreatedAt) > inviteExpiry {
+			return NotFound.New("invite not found")
+		}
+
+		vendor, err = tx.Get_Vendor_By_Pk(ctx, database.Vendor_Pk(invite.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		contact, err := tx.Create_ExecutiveContact(ctx,
+			database.ExecutiveContact_Id(uuid.NewV4().String()),
+			database.ExecutiveContact_VendorPk(invite.VendorPk),
+			database.ExecutiveContact_FirstName(req.FirstName),
+			database.ExecutiveContact_LastName(req.LastName),
+			database.ExecutiveContact_Role(invite.Role))
+		if err != nil {
+			return err
+		}
+
+		err = tx.CreateNoReturn_VendorEmail(ctx,
+			database.VendorEmail_Id(uuid.NewV4().String()),
+			database.VendorEmail_ExecutiveContactPk(contact.Pk),
+			database.VendorEmail_Address(invite.Email),
+			database.VendorEmail_SaltedHash(hash))
+		if err != nil {
+			return err
+		}
+
+		err = tx.CreateNoReturn_VendorPhone(ctx,
+			database.VendorPhone_Id(uuid.NewV4().String()),
+			database.VendorPhone_ExecutiveContactPk(contact.Pk),
+			database.VendorPhone_PhoneNumber(req.PhoneNumber),
+			database.VendorPhone_CountryCode(req.CountryCode),
+			database.VendorPhone_AreaCode(req.AreaCode))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_VendorInvite_By_Pk(ctx, database.VendorInvite_Pk(invite.Pk))
+		if err != nil {
+			return err
+		}
+
+		vendor_session, err = tx.Create_VendorSession(ctx,
+			database.VendorSession_VendorPk(invite.VendorPk),
+			database.VendorSession_ExecutiveContactPk(contact.Pk),
+			database.VendorSession_Id(uuid.NewV4().String()))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, contact.Id, AuditInviteAccept, "vendor", vendor.Id, nil,
+			auditFields{"role": invite.Role})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AcceptVendorInviteResp{
+		Session:  vendor_session,
+		VendorId: vendor.Id,
+	}, nil
+}
+
+type RemoveExecutiveContactReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ContactId          string `json:"contactId"`
+}
+
+type RemoveExecutiveContactResp struct {
+	ContactId string `json:"contactId"`
+}
+
+//RemoveExecutiveContact takes someone off the vendor's team, signs them out everywhere and revokes
+//the api keys they created. the last owner cannot be removed
+func (v *VendorServer) RemoveExecutiveContact(ctx context.Context,
+	req *RemoveExecutiveContactReq) (resp *RemoveExecutiveContactResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.RemoveExecutiveContact")
+	defer end(&err)
+
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		err := tx.LockVendor(ctx, req.VendorPk)
+		if err != nil {
+			return err
+		}
+
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageTeam)
+		if err != nil {
+			return err
+		}
+
+		contact, err := findTeamMember(ctx, tx, req.VendorPk, req.ContactId)
+		if err != nil {
+			return err
+		}
+
+		err = checkKeepsOwner(ctx, tx, contact)
+		if err != nil {
+			return err
+		}
+
+		revoked, err := tx.Delete_VendorSession_By_ExecutiveContactPk(ctx,
+			database.VendorSession_ExecutiveContactPk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		err = auditContact(ctx, tx, actor.Id, AuditSessionRevoke, "executive_contact",
+			contact.Id, nil, auditFields{"sessions": revoked})
+		if err != nil {
+			return err
+		}
+
+		//keys act as the contact that created them so they go with them
+		revoked_keys, err := tx.Delete_VendorApiKey_By_CreatedByPk(ctx,
+			database.VendorApiKey_CreatedByPk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		if revoked_keys > 0 {
+			err = auditContact(ctx, tx, actor.Id, AuditApiKeyRevoke, "executive_contact",
+				contact.Id, nil, auditFields{"api_keys": revoked_keys})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Delete_VendorEmail_By_ExecutiveContactPk(ctx,
+			database.VendorEmail_ExecutiveContactPk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_VendorPhone_By_ExecutiveContactPk(ctx,
+			database.VendorPhone_ExecutiveContactPk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_ExecutiveContact_By_Pk(ctx, database.ExecutiveContact_Pk(contact.Pk))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditContactRemove, "executive_contact",
+			contact.Id, auditFields{"role": contact.Role}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoveExecutiveContactResp{
+		ContactId: req.ContactId,
+	}, nil
+}
+
+type SetExecutiveContactRoleReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	ContactId          string `json:"contactId"`
+	Role               string `json:"role"`
+}
+
+type SetExecutiveContactRoleResp struct {
+	Member *TeamMember `json:"member"`
+}
+
+//SetExecutiveContactRole changes what a member of the vendor's team is allowed to do. the last
+//owner cannot be given another role
+func (v *VendorServer) SetExecutiveContactRole(ctx context.Context,
+	req *SetExecutiveContactRoleReq) (resp *SetExecutiveContactRoleResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.SetExecutiveContactRole")
+	defer end(&err)
+
+	if !validRole(req.Role) {
+		return nil, errs.New("unknown role %q", req.Role)
+	}
+
+	var member *TeamMember
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		err := tx.LockVendor(ctx, req.VendorPk)
+		if err != nil {
+			return err
+		}
+
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageTeam)
+		if err != nil {
+			return err
+		}
+
+		contact, err := findTeamMember(ctx, tx, req.VendorPk, req.ContactId)
+		if err != nil {
+			return err
+		}
+
+		if req.Role != OwnerRole {
+			err = checkKeepsOwner(ctx, tx, contact)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.UpdateNoReturn_ExecutiveContact_By_Pk(ctx,
+			database.ExecutiveContact_Pk(contact.Pk),
+			database.ExecutiveContact_Update_Fields{
+				Role: database.ExecutiveContact_Role(req.Role),
+			})
+		if err != nil {
+			return err
+		}
+
+		err = auditContact(ctx, tx, actor.Id, AuditContactRole, "executive_contact", contact.Id,
+			auditFields{"role": contact.Role}, auditFields{"role": req.Role})
+		if err != nil {
+			return err
+		}
+		contact.Role = req.Role
+
+		member, err = teamMemberFromDB(ctx, tx, contact)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetExecutiveContactRoleResp{
+		Member: member,
+	}, nil
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//mailedToken pulls the token out of the last mail that was sent, e.g. an invite or verification
+func (h *serverTest) mailedToken() string {
+	require.NotEmpty(h.t, h.mailer.sent)
+	body := h.mailer.sent[len(h.mailer.sent)-1].Body
+	return body[strings.LastIndex(body, " ")+1:]
+}
+
+func TestInviteExecutiveContact(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+
+	_, err := test.VendorServer.InviteExecutiveContact(ctx, &InviteExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Email:              "agent@email.com",
+		Role:               SupportAgentRole,
+	})
+	require.NoError(t, err)
+	require.Len(t, test.mailer.sent, 1)
+	require.Equal(t, test.mailer.sent[0].To, "agent@email.com")
+
+	//a bad token does not get anyone in
+	accept_req := &AcceptVendorInviteReq{
+		Token:     "not-a-token",
+		FirstName: "Sam",
+		LastName:  "Agent",
+		Password:  defaultPassword,
+	}
+	_, err = test.VendorServer.AcceptVendorInvite(ctx, accept_req)
+	require.True(t, NotFound.Has(err))
+
+	accept_req.Token = test.mailedToken()
+	resp, err := test.VendorServer.AcceptVendorInvite(ctx, accept_req)
+	require.NoError(t, err)
+	require.Equal(t, resp.VendorId, vendor.Id)
+	require.Equal(t, resp.Session.VendorPk, vendor.Pk)
+
+	team, err := test.VendorServer.GetVendorTeam(ctx, &GetVendorTeamReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	})
+	require.NoError(t, err)
+	require.Len(t, team.Members, 2)
+	require.Equal(t, team.Members[1].Role, SupportAgentRole)
+	require.Equal(t, team.Members[1].Emails, []string{"agent@email.com"})
+
+	//invites are single use
+	_, err = test.VendorServer.AcceptVendorInvite(ctx, accept_req)
+	require.True(t, NotFound.Has(err))
+
+	//the email now belongs to a contact
+	_, err = test.VendorServer.InviteExecutiveContact(ctx, &InviteExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Email:              "agent@email.com",
+		Role:               FinanceRole,
+	})
+	require.Error(t, err)
+}
+
+func TestVendorRolePermissions(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	catalog_manager := test.createExecutiveContact(ctx, vendor.Pk, CatalogManagerRole)
+	support_agent := test.createExecutiveContact(ctx, vendor.Pk, SupportAgentRole)
+	outsider := test.createExecutiveContact(ctx, test.createVendorInDB(ctx).Pk, OwnerRole)
+
+	//catalog managers cannot read buyer messages
+	_, err := test.VendorServer.GetPagedVendorConversations(ctx, &PagedVendorConversationReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: catalog_manager.Pk,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	//support agents can
+	_, err = test.VendorServer.GetPagedVendorConversations(ctx, &PagedVendorConversationReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: support_agent.Pk,
+	})
+	require.NoError(t, err)
+
+	//but they cannot change the catalog or the team
+	_, err = test.VendorServer.RegisterProduct(ctx, &RegisterProductRequest{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: support_agent.Pk,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	_, err = test.VendorServer.InviteExecutiveContact(ctx, &InviteExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: support_agent.Pk,
+		Email:              "someone@email.com",
+		Role:               OwnerRole,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	//contacts of another vendor get nothing
+	_, err = test.VendorServer.GetVendorTeam(ctx, &GetVendorTeamReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: outsider.Pk,
+	})
+	require.True(t, Forbidden.Has(err))
+}
+
+func TestRemoveExecutiveContact(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	finance := test.createExecutiveContact(ctx, vendor.Pk, FinanceRole)
+
+	//the last owner can neither be removed nor demoted
+	_, err := test.VendorServer.RemoveExecutiveContact(ctx, &RemoveExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ContactId:          owner.Id,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	_, err = test.VendorServer.SetExecutiveContactRole(ctx, &SetExecutiveContactRoleReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ContactId:          owner.Id,
+		Role:               FinanceRole,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	//once there is a second owner the first can step down
+	role_resp, err := test.VendorServer.SetExecutiveContactRole(ctx, &SetExecutiveContactRoleReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ContactId:          finance.Id,
+		Role:               OwnerRole,
+	})
+	require.NoError(t, err)
+	require.Equal(t, role_resp.Member.Role, OwnerRole)
+
+	_, err = test.VendorServer.RemoveExecutiveContact(ctx, &RemoveExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: finance.Pk,
+		ContactId:          owner.Id,
+	})
+	require.NoError(t, err)
+
+	//removed contacts lose access
+	_, err = test.VendorServer.GetVendorTeam(ctx, &GetVendorTeamReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	})
+	require.True(t, Forbidden.Has(err))
+
+	_, err = test.VendorServer.RemoveExecutiveContact(ctx, &RemoveExecutiveContactReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: finance.Pk,
+		ContactId:          "not-a-contact",
+	})
+	require.True(t, NotFound.Has(err))
+}
@@ -15,12 +15,14 @@ func TestPostVendorMessagesToConversations(t *testing.T) {
 	//set up
 	ctx := context.Background()
 	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
 	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
 	conversation := test.createConversationInDB(buyer, vendor)
 	test.createMessageHistory(ctx, conversation, 210)
 
 	req := &PostVendorMessageToConversationReq{
 		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
 		BuyerId:            buyer.Id,
 		MessageDescription: "stop! can't touch this.",
 	}
@@ -38,38 +40,61 @@ func TestGetVendorMessagesByConversationId(t *testing.T) {
 	//set up
 	ctx := context.Background()
 	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
 	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
 	conversation := test.createConversationInDB(buyer, vendor)
 	test.createMessageHistory(ctx, conversation, 210)
 
-	//offset 0
+	//newest page
 	req := &PagedVendorMessagesByConversationIdReq{
-		Offset:         int64(0),
-		ConversationId: conversation.Id,
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ConversationId:     conversation.Id,
 	}
 	resp, err := test.VendorServer.PagedVendorMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, len(resp.Messages), messageRequestLimit)
-	require.Equal(t, resp.Offset, int64(messageRequestLimit))
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(210))
+	require.Equal(t, resp.After, int64(210))
+	require.Equal(t, resp.Before, int64(210-messageRequestLimit+1))
 
-	//subsequent request with new offset
-	req.Offset = resp.Offset
+	//older page picks up where the last one ended even when new messages arrive in between
+	test.createMessageHistory(ctx, conversation, 2)
+	req.Before = resp.Before
 	resp, err = test.VendorServer.PagedVendorMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, len(resp.Messages), messageRequestLimit)
-	require.Equal(t, resp.Offset, int64(messageRequestLimit*2))
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(210-messageRequestLimit))
+	require.Equal(t, resp.Before, int64(210-messageRequestLimit*2+1))
 
-	//offset exceeds number of messages in history
-	req.Offset = int64(1000)
+	//oldest page is short and has no before cursor
+	req.Before = 11
 	resp, err = test.VendorServer.PagedVendorMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
-	require.Equal(t, len(resp.Messages), 0) //no messages because offest exceeds message history
+	require.Equal(t, len(resp.Messages), 10)
+	require.Equal(t, resp.Before, int64(0))
 
-	//offset result is less than messageRequestLimit
-	req.Offset = 200
+	//newer page returns the messages after the cursor, newest first
+	req.Before = 0
+	req.After = 210
 	resp, err = test.VendorServer.PagedVendorMessagesByConversationId(ctx, req)
 	require.NoError(t, err)
-	require.Equal(t, len(resp.Messages), 10)
+	require.Equal(t, len(resp.Messages), 2)
+	require.Equal(t, resp.Messages[0].MessageNumber, int64(212))
+	require.Equal(t, resp.Messages[1].MessageNumber, int64(211))
+	require.Equal(t, resp.After, int64(212))
+
+	//nothing newer
+	req.After = resp.After
+	resp, err = test.VendorServer.PagedVendorMessagesByConversationId(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, len(resp.Messages), 0)
+	require.Equal(t, resp.After, int64(212))
+
+	//both cursors
+	req.Before = 5
+	_, err = test.VendorServer.PagedVendorMessagesByConversationId(ctx, req)
+	require.True(t, InvalidPage.Has(err))
 }
 
 func TestGetPagedVendorConversations(t *testing.T) {
@@ -79,11 +104,12 @@ func TestGetPagedVendorConversations(t *testing.T) {
 	//set up
 	ctx := context.Background()
 	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
 	buyers := test.createDefaultBuyers(ctx, 53)
 	test.createConversationsWithBuyers(vendor, buyers)
 
 	//get first set of paged results
-	req := &PagedVendorConversationReq{VendorPk: vendor.Pk}
+	req := &PagedVendorConversationReq{VendorPk: vendor.Pk, ExecutiveContactPk: owner.Pk}
 	resp, err := test.VendorServer.GetPagedVendorConversations(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, resp.PageToken, strconv.Itoa(conversationRequestLimit))
@@ -110,13 +136,61 @@ func TestGetVendorConversationsUnread(t *testing.T) {
 	//set up
 	ctx := context.Background()
 	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
 	buyers := test.createDefaultBuyers(ctx, 53)
 	conversations := test.createConversationsWithBuyers(vendor, buyers)
 	test.createDefaultMessagesFromBuyer(ctx, conversations[:20])
 
 	//get unread conversations
-	req := &VendorConversationsUnreadReq{VendorPk: vendor.Pk}
+	req := &VendorConversationsUnreadReq{VendorPk: vendor.Pk, ExecutiveContactPk: owner.Pk}
 	resp, err := test.VendorServer.GetVendorCoversationsUnread(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, len(resp.Conversations), 20)
 }
+
+func TestMarkVendorConversationRead(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+	_, err := test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "is this thing on?",
+		})
+	require.NoError(t, err)
+	conversation := test.getConversation(ctx, vendor.Pk, buyer.Pk)
+	buyer_sub := test.BuyerServer.SubscribeBuyerEvents(buyer.Pk)
+	defer buyer_sub.Close()
+
+	//vendor reads the message
+	resp, err := test.VendorServer.MarkVendorConversationRead(ctx, &MarkVendorConversationReadReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		ConversationId:     conversation.Id,
+		ConversationNumber: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resp.UnreadCount, int64(0))
+	require.False(t, test.getConversation(ctx, vendor.Pk, buyer.Pk).VendorUnread)
+
+	//buyer gets a read receipt
+	event := <-buyer_sub.Events
+	require.Equal(t, event.Type, ReadEvent)
+	require.Equal(t, event.MessageNumber, int64(1))
+
+	//some other vendor cannot mark it read
+	other_vendor := test.createVendorInDB(ctx)
+	_, err = test.VendorServer.MarkVendorConversationRead(ctx, &MarkVendorConversationReadReq{
+		VendorPk:           other_vendor.Pk,
+		ExecutiveContactPk: test.createExecutiveContact(ctx, other_vendor.Pk, OwnerRole).Pk,
+		ConversationId:     conversation.Id,
+		ConversationNumber: 1,
+	})
+	require.EqualError(t, err, "conversation not found")
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+const (
+	//maxWebhookAttempts is how many times a delivery is tried before it is given up on. with the
+	//backoff below the last attempt comes about eight and a half hours after the first
+	maxWebhookAttempts = 10
+	webhookBaseBackoff = time.Minute
+	webhookMaxBackoff  = 6 * time.Hour
+
+	//webhookDisableAfter is how long an endpoint can fail every delivery before it is disabled
+	webhookDisableAfter = 3 * 24 * time.Hour
+
+	webhookTimeout   = 10 * time.Second
+	webhookBatchSize = 100
+
+	//webhookConcurrency is how many endpoints are sent to at once. each endpoint gets its
+	//deliveries in order, one at a time
+	webhookConcurrency = 10
+
+	//webhookEndpointBudget bounds how long one run spends on an endpoint, so a slow endpoint holds
+	//up its own deliveries rather than everyone's. what is left over waits for the next run
+	webhookEndpointBudget = 3 * webhookTimeout
+
+	//webhookLease keeps other instances from sending a delivery while it is being sent
+	webhookLease = 2 * webhookTimeout
+
+	maxWebhookErrorSize = 512
+)
+
+//headers sent with every delivery. the signature is hex(hmac_sha256(secret, t + "." + body))
+//where t is the unix time in the header, so receivers can reject old deliveries being replayed
+const (
+	WebhookSignatureHeader = "Ladybug-Signature"
+	WebhookEventHeader     = "Ladybug-Event"
+	WebhookDeliveryHeader  = "Ladybug-Delivery"
+)
+
+//WebhookDispatcher sends queued webhook deliveries. deliveries are sent at least once, so a
+//receiver can see one again if an instance stops after sending it but before recording that
+type WebhookDispatcher struct {
+	db     *database.DB
+	client *http.Client
+}
+
+//NewWebhookDispatcher sends deliveries with client. when it is nil a client that refuses to
+//connect to loopback and private addresses is used, so vendors cannot reach inside our network
+func NewWebhookDispatcher(db *database.DB, client *http.Client) *WebhookDispatcher {
+	if client == nil {
+		client = newWebhookClient()
+	}
+
+	return &WebhookDispatcher{db: db, client: client}
+}
+
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return errs.New("webhooks cannot be sent to %s", host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		//a redirect would be a way around the address check and the signature is not for its url
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+//signWebhook is the value of WebhookSignatureHeader for body sent at t
+func signWebhook(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+//webhookBackoff is how long to wait before trying a delivery again after attempts failed ones
+func webhookBackoff(attempts int64) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := int64(1); i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+
+	return backoff
+}
+
+//DeliverDue sends the deliveries that are due at now and records how they went. endpoints are
+//sent to concurrently. each delivery is leased, signed and recorded at the time it is sent rather
+//than at now, since a run can take longer than a lease. it returns how many were sent
+//successfully
+func (d *WebhookDispatcher) DeliverDue(ctx context.Context, now time.Time) (
+	delivered int, err error) {
+
+	ctx, end := startSpan(ctx, "WebhookDispatcher.DeliverDue")
+	defer end(&err)
+
+	now = now.UTC()
+	due, err := d.db.WithContext(ctx).Limited_WebhookDelivery_By_Status_And_NextAttemptAt_LessOrEqual_OrderBy_Asc_NextAttemptAt(
+		ctx, database.WebhookDelivery_Status(webhookPending),
+		database.WebhookDelivery_NextAttemptAt(now), webhookBatchSize, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var endpoint_pks []int64
+	by_endpoint := map[int64][]*database.WebhookDelivery{}
+	for _, delivery := range due {
+		if _, ok := by_endpoint[delivery.EndpointPk]; !ok {
+			endpoint_pks = append(endpoint_pks, delivery.EndpointPk)
+		}
+		by_endpoint[delivery.EndpointPk] = append(by_endpoint[delivery.EndpointPk], delivery)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, webhookConcurrency)
+	for _, endpoint_pk := range endpoint_pks {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(deliveries []*database.WebhookDelivery) {
+			defer func() { <-slots }()
+			defer wg.Done()
+
+			sent := d.deliverToEndpoint(ctx, deliveries)
+
+			mu.Lock()
+			delivered += sent
+			mu.Unlock()
+		}(by_endpoint[endpoint_pk])
+	}
+	wg.Wait()
+
+	return delivered, nil
+}
+
+//deliverToEndpoint sends one endpoint's deliveries in order. it stops at the first one that is not
+//sent, since the rest would most likely go the same way, or once webhookEndpointBudget is spent
+func (d *WebhookDispatcher) deliverToEndpoint(ctx context.Context,
+	deliveries []*database.WebhookDelivery) (delivered int) {
+
+	started := time.Now()
+	for _, delivery := range deliveries {
+		if time.Since(started) >= webhookEndpointBudget {
+			break
+		}
+
+		ok, err := d.deliver(ctx, delivery)
+		if err != nil {
+			Logger(ctx).Errorf("unable to deliver webhook %s: %+v", delivery.Id, err)
+			break
+		}
+		if !ok {
+			break
+		}
+		delivered++
+	}
+
+	return delivered
+}
+
+//deliver sends one delivery to its endpoint, unless another dispatcher leased it first or the
+//endpoint went away or was disabled in the meantime
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *database.WebhookDelivery) (
+	ok bool, err error) {
+
+	now := d.db.Hooks.Now().UTC()
+
+	var leased bool
+	var endpoint *database.WebhookEndpoint
+	err = d.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		leased, err = tx.LeaseWebhookDelivery(ctx, delivery.Pk, webhookPending, now,
+			now.Add(webhookLease))
+		if err != nil || !leased {
+			return err
+		}
+
+		endpoint, err = tx.Find_WebhookEndpoint_By_Pk(ctx,
+			database.WebhookEndpoint_Pk(delivery.EndpointPk))
+		if err != nil {
+			return err
+		}
+
+		if endpoint == nil || !endpoint.Enabled {
+			return tx.UpdateNoReturn_WebhookDelivery_By_Pk(ctx,
+				database.WebhookDelivery_Pk(delivery.Pk),
+				database.WebhookDelivery_Update_Fields{
+					Status: database.WebhookDelivery_Status(webhookFailed),
+					Error:  database.WebhookDelivery_Error("the endpoint is disabled"),
+				})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if !leased {
+		return false, nil
+	}
+	if endpoint == nil || !endpoint.Enabled {
+		webhookDeliveries.WithLabelValues(webhookFailed).Inc()
+		return false, nil
+	}
+
+	status, send_err := d.send(ctx, endpoint, delivery, now)
+
+	err = d.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		return recordWebhookAttempt(ctx, tx, endpoint, delivery, now, status, send_err)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return send_err == nil, nil
+}
+
+//send posts a delivery and returns the status it got back. any status other than 2xx is an error
+func (d *WebhookDispatcher) send(ctx context.Context, endpoint *database.WebhookEndpoint,
+	delivery *database.WebhookDelivery, now time.Time) (status int, err error) {
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url,
+		bytes.NewReader(body))
+	if err != nil {
+		return 0, errs.Wrap(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Ladybug-Webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.Id)
+	req.Header.Set(WebhookSignatureHeader, signWebhook(endpoint.Secret, now, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, errs.Wrap(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errs.New("the endpoint responded %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+//recordWebhookAttempt updates a delivery and the health of its endpoint after an attempt. failed
+//deliveries are retried with exponential backoff until maxWebhookAttempts and endpoints that have
+//failed for webhookDisableAfter are disabled
+func recordWebhookAttempt(ctx context.Context, tx *database.Tx,
+	endpoint *database.WebhookEndpoint, delivery *database.WebhookDelivery, now time.Time,
+	status int, send_err error) error {
+
+	attempts := delivery.Attempts + 1
+	update := database.WebhookDelivery_Update_Fields{
+		Attempts:       database.WebhookDelivery_Attempts(attempts),
+		ResponseStatus: database.WebhookDelivery_ResponseStatus(int64(status)),
+		Error:          database.WebhookDelivery_Error(""),
+	}
+
+	var endpoint_update database.WebhookEndpoint_Update_Fields
+	var endpoint_changed, disabled bool
+	switch {
+	case send_err == nil:
+		update.Status = database.WebhookDelivery_Status(webhookSucceeded)
+		webhookDeliveries.WithLabelValues(webhookSucceeded).Inc()
+
+		if !endpoint.FailingSince.IsZero() {
+			endpoint_update.FailingSince = database.WebhookEndpoint_FailingSince(time.Time{})
+			endpoint_changed = true
+		}
+	default:
+		message := send_err.Error()
+		if len(message) > maxWebhookErrorSize {
+			message = message[:maxWebhookErrorSize]
+		}
+		update.Error = database.WebhookDelivery_Error(message)
+
+		if attempts >= maxWebhookAttempts {
+			update.Status = database.WebhookDelivery_Status(webhookFailed)
+			webhookDeliveries.WithLabelValues(webhookFailed).Inc()
+		} else {
+			update.NextAttemptAt = database.WebhookDelivery_NextAttemptAt(
+				now.Add(webhookBackoff(attempts)))
+			webhookDeliveries.WithLabelValues("retrying").Inc()
+		}
+
+		switch {
+		case endpoint.FailingSince.IsZero():
+			endpoint_update.FailingSince = database.WebhookEndpoint_FailingSince(now)
+			endpoint_changed = true
+		case now.Sub(endpoint.FailingSince) >= webhookDisableAfter:
+			endpoint_update.Enabled = database.WebhookEndpoint_Enabled(false)
+			endpoint_changed, disabled = true, true
+		}
+	}
+
+	err := tx.UpdateNoReturn_WebhookDelivery_By_Pk(ctx, database.WebhookDelivery_Pk(delivery.Pk),
+		update)
+	if err != nil {
+		return err
+	}
+
+	if !endpoint_changed {
+		return nil
+	}
+
+	_, err = tx.Update_WebhookEndpoint_By_Pk(ctx, database.WebhookEndpoint_Pk(endpoint.Pk),
+		endpoint_update)
+	if err != nil {
+		return err
+	}
+
+	if !disabled {
+		return nil
+	}
+
+	Logger(ctx).Warnf("disabled webhook endpoint %s after failing since %s", endpoint.Id,
+		endpoint.FailingSince.Format(time.RFC3339))
+
+	return audit(ctx, tx, &auditEvent{
+		actorKind:  SystemActor,
+		action:     AuditWebhookDisable,
+		targetKind: "webhook_endpoint",
+		targetId:   endpoint.Id,
+		before:     auditFields{"enabled": true},
+		after:      auditFields{"enabled": false},
+	})
+}
+
+//RunWebhookDeliveries calls DeliverDue every interval until ctx is done
+func (d *WebhookDispatcher) RunWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DeliverDue(ctx, d.db.Hooks.Now())
+		if err != nil {
+			Logger(ctx).Errorf("delivering webhooks failed: %+v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
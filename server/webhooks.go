@@ -0,0 +1,628 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zeebo/errs"
+
+	"ladybug/database"
+)
+
+//events vendors can subscribe their webhook endpoints to
+const (
+	MessageCreatedEvent = "message.created"
+	TrialStartedEvent   = "trial.started"
+)
+
+const (
+	maxWebhookEndpoints = 10
+	maxWebhookUrlSize   = 2048
+	webhookRequestLimit = 50
+
+	webhookPending   = "pending"
+	webhookSucceeded = "succeeded"
+	webhookFailed    = "failed"
+)
+
+//webhookEvents are the events endpoints can subscribe to. an event is only listed once something
+//sends it
+var webhookEvents = map[string]bool{
+	MessageCreatedEvent: true,
+	TrialStartedEvent:   true,
+}
+
+//MessageCreatedWebhook is the data of a message.created event, sent when a buyer messages the
+//vendor
+type MessageCreatedWebhook struct {
+	ConversationId string   `json:"conversationId"`
+	Message        *Message `json:"message"`
+}
+
+//TrialStartedWebhook is the data of a trial.started event
+type TrialStartedWebhook struct {
+	ProductId string        `json:"productId"`
+	VariantId string        `json:"variantId,omitempty"`
+	Trial     *TrialProduct `json:"trial"`
+}
+
+//webhookEvent is the body of every delivery. Id stays the same when a delivery is retried or
+//replayed so receivers can tell repeats apart
+type webhookEvent struct {
+	Id        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt int64       `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+//enqueueWebhook queues a delivery of event to each of the vendor's endpoints subscribed to it. it
+//runs in the transaction of whatever the event is about so events are queued exactly when that
+//commits
+func enqueueWebhook(ctx context.Context, tx *database.Tx, vendor_pk int64, event string,
+	data interface{}) error {
+
+	endpoints, err := tx.All_WebhookEndpoint_By_VendorPk_And_Enabled_Equal_True(ctx,
+		database.WebhookEndpoint_VendorPk(vendor_pk))
+	if err != nil {
+		return err
+	}
+
+	var subscribed []*database.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		for _, e := range strings.Fields(endpoint.Events) {
+			if e == event {
+				subscribed = append(subscribed, endpoint)
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	event_id := uuid.NewV4().String()
+	payload, err := json.Marshal(&webhookEvent{
+		Id:        event_id,
+		Type:      event,
+		CreatedAt: now.Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	for _, endpoint := range subscribed {
+		_, err = tx.Create_WebhookDelivery(ctx,
+			database.WebhookDelivery_Id(uuid.NewV4().String()),
+			database.WebhookDelivery_EndpointPk(endpoint.Pk),
+			database.WebhookDelivery_EventId(event_id),
+			database.WebhookDelivery_Event(event),
+			database.WebhookDelivery_Payload(string(payload)),
+			database.WebhookDelivery_Status(webhookPending),
+			database.WebhookDelivery_Attempts(0),
+			database.WebhookDelivery_NextAttemptAt(now),
+			database.WebhookDelivery_ResponseStatus(0),
+			database.WebhookDelivery_Error(""))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type WebhookEndpoint struct {
+	Id      string   `json:"id"`
+	Url     string   `json:"url"`
+	Events  []string `json:"events"`
+	Enabled bool     `json:"enabled"`
+
+	//FailingSince is when deliveries to the endpoint started failing, or zero. endpoints that keep
+	//failing for webhookDisableAfter are disabled
+	FailingSince int64 `json:"failingSince"`
+	CreatedAt    int64 `json:"createdAt"`
+}
+
+func webhookEndpointFromDB(endpoint *database.WebhookEndpoint) *WebhookEndpoint {
+	e := &WebhookEndpoint{
+		Id:        endpoint.Id,
+		Url:       endpoint.Url,
+		Events:    strings.Fields(endpoint.Events),
+		Enabled:   endpoint.Enabled,
+		CreatedAt: endpoint.CreatedAt.Unix(),
+	}
+	if !endpoint.FailingSince.IsZero() {
+		e.FailingSince = endpoint.FailingSince.Unix()
+	}
+
+	return e
+}
+
+//checkWebhookUrl only allows https so deliveries and their signatures cannot be read on the way.
+//addresses inside our network are refused when delivering since they only resolve then
+func checkWebhookUrl(raw string) error {
+	if len(raw) > maxWebhookUrlSize {
+		return errs.New("a webhook url can be at most %d characters", maxWebhookUrlSize)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return errs.New("a webhook url has to be an https url")
+	}
+
+	return nil
+}
+
+//checkWebhookEvents makes sure events are known and returns them sorted without duplicates
+func checkWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, errs.New("a webhook endpoint needs at least one event")
+	}
+
+	seen := map[string]bool{}
+	var checked []string
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return nil, errs.New("unknown event %q", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			checked = append(checked, event)
+		}
+	}
+	sort.Strings(checked)
+
+	return checked, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errs.Wrap(err)
+	}
+
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+//findWebhookEndpoint loads one of the vendor's endpoints by id
+func findWebhookEndpoint(ctx context.Context, tx *database.Tx, vendor_pk int64,
+	endpoint_id string) (*database.WebhookEndpoint, error) {
+
+	endpoint, err := tx.Find_WebhookEndpoint_By_Id(ctx, database.WebhookEndpoint_Id(endpoint_id))
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint == nil || endpoint.VendorPk != vendor_pk {
+		return nil, NotFound.New("webhook endpoint not found")
+	}
+
+	return endpoint, nil
+}
+
+type CreateWebhookEndpointReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	Url                string   `json:"url"`
+	Events             []string `json:"events"`
+}
+
+type CreateWebhookEndpointResp struct {
+	Endpoint *WebhookEndpoint `json:"endpoint"`
+
+	//Secret signs the endpoint's deliveries. it is only ever returned here
+	Secret string `json:"secret"`
+}
+
+//CreateWebhookEndpoint registers a url to post the vendor's events to
+func (v *VendorServer) CreateWebhookEndpoint(ctx context.Context,
+	req *CreateWebhookEndpointReq) (resp *CreateWebhookEndpointResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.CreateWebhookEndpoint")
+	defer end(&err)
+
+	if err := checkWebhookUrl(req.Url); err != nil {
+		return nil, err
+	}
+
+	events, err := checkWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoint *database.WebhookEndpoint
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageWebhooks)
+		if err != nil {
+			return err
+		}
+
+		count, err := tx.Count_WebhookEndpoint_By_VendorPk(ctx,
+			database.WebhookEndpoint_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		if count >= maxWebhookEndpoints {
+			return errs.New("only a max of %d webhook endpoints are allowed",
+				maxWebhookEndpoints)
+		}
+
+		endpoint, err = tx.Create_WebhookEndpoint(ctx,
+			database.WebhookEndpoint_Id(uuid.NewV4().String()),
+			database.WebhookEndpoint_VendorPk(req.VendorPk),
+			database.WebhookEndpoint_Url(req.Url),
+			database.WebhookEndpoint_Secret(secret),
+			database.WebhookEndpoint_Events(strings.Join(events, " ")),
+			database.WebhookEndpoint_Enabled(true),
+			database.WebhookEndpoint_FailingSince(time.Time{}))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditWebhookCreate, "webhook_endpoint",
+			endpoint.Id, nil, auditFields{"url": req.Url, "events": events})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateWebhookEndpointResp{
+		Endpoint: webhookEndpointFromDB(endpoint),
+		Secret:   secret,
+	}, nil
+}
+
+type ListWebhookEndpointsReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+}
+
+type ListWebhookEndpointsResp struct {
+	Endpoints []*WebhookEndpoint `json:"endpoints"`
+}
+
+func (v *VendorServer) ListWebhookEndpoints(ctx context.Context,
+	req *ListWebhookEndpointsReq) (resp *ListWebhookEndpointsResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ListWebhookEndpoints")
+	defer end(&err)
+
+	endpoints := []*WebhookEndpoint{}
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageWebhooks)
+		if err != nil {
+			return err
+		}
+
+		db_endpoints, err := tx.All_WebhookEndpoint_By_VendorPk_OrderBy_Asc_CreatedAt(ctx,
+			database.WebhookEndpoint_VendorPk(req.VendorPk))
+		if err != nil {
+			return err
+		}
+
+		for _, endpoint := range db_endpoints {
+			endpoints = append(endpoints, webhookEndpointFromDB(endpoint))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListWebhookEndpointsResp{
+		Endpoints: endpoints,
+	}, nil
+}
+
+type UpdateWebhookEndpointReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	EndpointId         string   `json:"endpointId"`
+	Url                string   `json:"url"`
+	Events             []string `json:"events"`
+	Enabled            bool     `json:"enabled"`
+}
+
+type UpdateWebhookEndpointResp struct {
+	Endpoint *WebhookEndpoint `json:"endpoint"`
+}
+
+//UpdateWebhookEndpoint replaces an endpoint's url and events and enables or disables it. enabling
+//an endpoint that was disabled for failing gives it a fresh start
+func (v *VendorServer) UpdateWebhookEndpoint(ctx context.Context,
+	req *UpdateWebhookEndpointReq) (resp *UpdateWebhookEndpointResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.UpdateWebhookEndpoint")
+	defer end(&err)
+
+	if err := checkWebhookUrl(req.Url); err != nil {
+		return nil, err
+	}
+
+	events, err := checkWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoint *database.WebhookEndpoint
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageWebhooks)
+		if err != nil {
+			return err
+		}
+
+		before, err := findWebhookEndpoint(ctx, tx, req.VendorPk, req.EndpointId)
+		if err != nil {
+			return err
+		}
+
+		update := database.WebhookEndpoint_Update_Fields{
+			Url:     database.WebhookEndpoint_Url(req.Url),
+			Events:  database.WebhookEndpoint_Events(strings.Join(events, " ")),
+			Enabled: database.WebhookEndpoint_Enabled(req.Enabled),
+		}
+		if req.Enabled && !before.Enabled {
+			update.FailingSince = database.WebhookEndpoint_FailingSince(time.Time{})
+		}
+
+		endpoint, err = tx.Update_WebhookEndpoint_By_Pk(ctx,
+			database.WebhookEndpoint_Pk(before.Pk), update)
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditWebhookUpdate, "webhook_endpoint",
+			endpoint.Id, webhookAuditFields(before), webhookAuditFields(endpoint))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateWebhookEndpointResp{
+		Endpoint: webhookEndpointFromDB(endpoint),
+	}, nil
+}
+
+func webhookAuditFields(endpoint *database.WebhookEndpoint) auditFields {
+	return auditFields{
+		"url":     endpoint.Url,
+		"events":  strings.Fields(endpoint.Events),
+		"enabled": endpoint.Enabled,
+	}
+}
+
+type DeleteWebhookEndpointReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	EndpointId         string `json:"endpointId"`
+}
+
+type DeleteWebhookEndpointResp struct {
+	EndpointId string `json:"endpointId"`
+}
+
+//DeleteWebhookEndpoint removes an endpoint along with its delivery log and anything still queued
+//for it
+func (v *VendorServer) DeleteWebhookEndpoint(ctx context.Context,
+	req *DeleteWebhookEndpointReq) (resp *DeleteWebhookEndpointResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.DeleteWebhookEndpoint")
+	defer end(&err)
+
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageWebhooks)
+		if err != nil {
+			return err
+		}
+
+		endpoint, err := findWebhookEndpoint(ctx, tx, req.VendorPk, req.EndpointId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_WebhookDelivery_By_EndpointPk(ctx,
+			database.WebhookDelivery_EndpointPk(endpoint.Pk))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Delete_WebhookEndpoint_By_Pk(ctx, database.WebhookEndpoint_Pk(endpoint.Pk))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditWebhookDelete, "webhook_endpoint",
+			endpoint.Id, webhookAuditFields(endpoint), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteWebhookEndpointResp{
+		EndpointId: req.EndpointId,
+	}, nil
+}
+
+type WebhookDelivery struct {
+	Id      string          `json:"id"`
+	EventId string          `json:"eventId"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"`
+
+	Attempts int64 `json:"attempts"`
+
+	//NextAttemptAt is when a pending delivery is tried next. it is zero once the delivery is done
+	NextAttemptAt int64 `json:"nextAttemptAt"`
+
+	//ResponseStatus and Error describe the last attempt
+	ResponseStatus int64  `json:"responseStatus"`
+	Error          string `json:"error"`
+
+	CreatedAt int64 `json:"createdAt"`
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+func webhookDeliveryFromDB(delivery *database.WebhookDelivery) *WebhookDelivery {
+	d := &WebhookDelivery{
+		Id:             delivery.Id,
+		EventId:        delivery.EventId,
+		Event:          delivery.Event,
+		Payload:        json.RawMessage(delivery.Payload),
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		Error:          delivery.Error,
+		CreatedAt:      delivery.CreatedAt.Unix(),
+		UpdatedAt:      delivery.UpdatedAt.Unix(),
+	}
+	if delivery.Status == webhookPending {
+		d.NextAttemptAt = delivery.NextAttemptAt.Unix()
+	}
+
+	return d
+}
+
+type PagedWebhookDeliveriesReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	EndpointId         string `json:"endpointId"`
+	Offset             int64  `json:"offset"`
+}
+
+type PagedWebhookDeliveriesResp struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Offset     int64              `json:"offset"`
+}
+
+//PagedWebhookDeliveries is the delivery log of an endpoint, newest first
+func (v *VendorServer) PagedWebhookDeliveries(ctx context.Context,
+	req *PagedWebhookDeliveriesReq) (resp *PagedWebhookDeliveriesResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.PagedWebhookDeliveries")
+	defer end(&err)
+
+	deliveries := []*WebhookDelivery{}
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		_, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageWebhooks)
+		if err != nil {
+			return err
+		}
+
+		endpoint, err := findWebhookEndpoint(ctx, tx, req.VendorPk, req.EndpointId)
+		if err != nil {
+			return err
+		}
+
+		db_deliveries, err := tx.Limited_WebhookDelivery_By_EndpointPk_OrderBy_Desc_CreatedAt(ctx,
+			database.WebhookDelivery_EndpointPk(endpoint.Pk), webhookRequestLimit, req.Offset)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range db_deliveries {
+			deliveries = append(deliveries, webhookDeliveryFromDB(delivery))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PagedWebhookDeliveriesResp{
+		Deliveries: deliveries,
+		Offset:     req.Offset + webhookRequestLimit,
+	}, nil
+}
+
+type ReplayWebhookDeliveryReq struct {
+	VendorPk           int64
+	ExecutiveContactPk int64
+	DeliveryId         string `json:"deliveryId"`
+}
+
+type ReplayWebhookDeliveryResp struct {
+	Delivery *WebhookDelivery `json:"delivery"`
+}
+
+//ReplayWebhookDelivery queues the event of a past delivery to be sent to its endpoint again. the
+//replay is a delivery of its own with the same event id and payload
+func (v *VendorServer) ReplayWebhookDelivery(ctx context.Context,
+	req *ReplayWebhookDeliveryReq) (resp *ReplayWebhookDeliveryResp, err error) {
+
+	ctx, end := startSpan(ctx, "VendorServer.ReplayWebhookDelivery")
+	defer end(&err)
+
+	var replay *database.WebhookDelivery
+	err = v.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		actor, err := permit(ctx, tx, req.VendorPk, req.ExecutiveContactPk, ManageWebhooks)
+		if err != nil {
+			return err
+		}
+
+		delivery, err := tx.Find_WebhookDelivery_By_Id(ctx,
+			database.WebhookDelivery_Id(req.DeliveryId))
+		if err != nil {
+			return err
+		}
+
+		if delivery == nil {
+			return NotFound.New("webhook delivery not found")
+		}
+
+		endpoint, err := tx.Find_WebhookEndpoint_By_Pk(ctx,
+			database.WebhookEndpoint_Pk(delivery.EndpointPk))
+		if err != nil {
+			return err
+		}
+
+		if endpoint == nil || endpoint.VendorPk != req.VendorPk {
+			return NotFound.New("webhook delivery not found")
+		}
+
+		if !endpoint.Enabled {
+			return errs.New("enable the webhook endpoint before replaying its deliveries")
+		}
+
+		replay, err = tx.Create_WebhookDelivery(ctx,
+			database.WebhookDelivery_Id(uuid.NewV4().String()),
+			database.WebhookDelivery_EndpointPk(endpoint.Pk),
+			database.WebhookDelivery_EventId(delivery.EventId),
+			database.WebhookDelivery_Event(delivery.Event),
+			database.WebhookDelivery_Payload(delivery.Payload),
+			database.WebhookDelivery_Status(webhookPending),
+			database.WebhookDelivery_Attempts(0),
+			database.WebhookDelivery_NextAttemptAt(v.db.Hooks.Now().UTC()),
+			database.WebhookDelivery_ResponseStatus(0),
+			database.WebhookDelivery_Error(""))
+		if err != nil {
+			return err
+		}
+
+		return auditContact(ctx, tx, actor.Id, AuditWebhookReplay, "webhook_delivery",
+			delivery.Id, nil, auditFields{"replayId": replay.Id, "event": replay.Event})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplayWebhookDeliveryResp{
+		Delivery: webhookDeliveryFromDB(replay),
+	}, nil
+}
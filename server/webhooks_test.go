@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ladybug/database"
+)
+
+//webhookReceiver records the deliveries it is sent and answers them with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+//deliverAt runs the dispatcher with the clock set to at
+func (h *serverTest) deliverAt(ctx context.Context, dispatcher *WebhookDispatcher,
+	at time.Time) (delivered int) {
+
+	h.at(at, func() {
+		var err error
+		delivered, err = dispatcher.DeliverDue(ctx, at)
+		require.NoError(h.t, err)
+	})
+
+	return delivered
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	receiver := &webhookReceiver{status: http.StatusOK}
+	ts := httptest.NewTLSServer(receiver)
+	defer ts.Close()
+	dispatcher := NewWebhookDispatcher(test.db, ts.Client())
+
+	_, err := test.VendorServer.CreateWebhookEndpoint(ctx, &CreateWebhookEndpointReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Url:                "http://example.com/hooks",
+		Events:             []string{MessageCreatedEvent},
+	})
+	require.Error(t, err)
+
+	//events nothing sends cannot be subscribed to
+	_, err = test.VendorServer.CreateWebhookEndpoint(ctx, &CreateWebhookEndpointReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Url:                ts.URL,
+		Events:             []string{"order.paid"},
+	})
+	require.Error(t, err)
+
+	created, err := test.VendorServer.CreateWebhookEndpoint(ctx, &CreateWebhookEndpointReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Url:                ts.URL,
+		Events:             []string{MessageCreatedEvent},
+	})
+	require.NoError(t, err)
+	require.True(t, created.Endpoint.Enabled)
+
+	//posting a message queues a delivery that is signed with the endpoint's secret
+	message, err := test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "is this in stock?",
+		})
+	require.NoError(t, err)
+
+	now := time.Now()
+	delivered := test.deliverAt(ctx, dispatcher, now)
+	require.Equal(t, delivered, 1)
+	require.Len(t, receiver.received, 1)
+
+	req, body := receiver.received[0], receiver.bodies[0]
+	require.Equal(t, req.Header.Get(WebhookEventHeader), MessageCreatedEvent)
+	require.Equal(t, req.Header.Get(WebhookSignatureHeader),
+		signWebhook(created.Secret, now, body))
+
+	var event struct {
+		Type string                `json:"type"`
+		Data MessageCreatedWebhook `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &event))
+	require.Equal(t, event.Type, MessageCreatedEvent)
+	require.Equal(t, event.Data.Message.Id, message.Message.Id)
+
+	//failed deliveries are retried with backoff
+	receiver.status = http.StatusInternalServerError
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "hello?",
+		})
+	require.NoError(t, err)
+
+	now = time.Now()
+	delivered = test.deliverAt(ctx, dispatcher, now)
+	require.Equal(t, delivered, 0)
+	require.Len(t, receiver.received, 2)
+
+	log, err := test.VendorServer.PagedWebhookDeliveries(ctx, &PagedWebhookDeliveriesReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		EndpointId:         created.Endpoint.Id,
+	})
+	require.NoError(t, err)
+	require.Len(t, log.Deliveries, 2)
+	var failing *WebhookDelivery
+	for _, delivery := range log.Deliveries {
+		if delivery.Status == webhookPending {
+			failing = delivery
+		}
+	}
+	require.NotNil(t, failing)
+	require.Equal(t, failing.Attempts, int64(1))
+	require.Equal(t, failing.ResponseStatus, int64(http.StatusInternalServerError))
+	require.Equal(t, failing.NextAttemptAt, now.Add(webhookBackoff(1)).Unix())
+
+	test.deliverAt(ctx, dispatcher, now)
+	require.Len(t, receiver.received, 2)
+
+	test.deliverAt(ctx, dispatcher, now.Add(webhookBackoff(1)))
+	require.Len(t, receiver.received, 3)
+
+	//endpoints that keep failing are disabled
+	test.deliverAt(ctx, dispatcher, now.Add(webhookDisableAfter))
+
+	endpoints, err := test.VendorServer.ListWebhookEndpoints(ctx, &ListWebhookEndpointsReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+	})
+	require.NoError(t, err)
+	require.False(t, endpoints.Endpoints[0].Enabled)
+
+	//once enabled again a delivery can be replayed
+	_, err = test.VendorServer.UpdateWebhookEndpoint(ctx, &UpdateWebhookEndpointReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		EndpointId:         created.Endpoint.Id,
+		Url:                ts.URL,
+		Events:             []string{MessageCreatedEvent},
+		Enabled:            true,
+	})
+	require.NoError(t, err)
+
+	receiver.status = http.StatusNoContent
+	replay, err := test.VendorServer.ReplayWebhookDelivery(ctx, &ReplayWebhookDeliveryReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		DeliveryId:         failing.Id,
+	})
+	require.NoError(t, err)
+	require.Equal(t, replay.Delivery.EventId, failing.EventId)
+
+	audited, err := NewAdminServer(test.db).PagedAuditEvents(ctx, &PagedAuditEventsReq{
+		TargetId: failing.Id,
+	})
+	require.NoError(t, err)
+	require.Len(t, audited.Events, 1)
+	require.Equal(t, audited.Events[0].Action, AuditWebhookReplay)
+	require.Equal(t, audited.Events[0].ActorId, owner.Id)
+
+	delivered = test.deliverAt(ctx, dispatcher, time.Now())
+	require.Equal(t, delivered, 1)
+}
+
+func TestWebhookDeliveryLease(t *testing.T) {
+	test := newTest(t)
+	defer test.tearDown()
+
+	//set up
+	ctx := context.Background()
+	vendor := test.createVendorInDB(ctx)
+	owner := test.createExecutiveContact(ctx, vendor.Pk, OwnerRole)
+	buyer := test.createBuyer(ctx, &createBuyerInDBOptions{})
+
+	receiver := &webhookReceiver{status: http.StatusOK}
+	ts := httptest.NewTLSServer(receiver)
+	defer ts.Close()
+	dispatcher := NewWebhookDispatcher(test.db, ts.Client())
+
+	_, err := test.VendorServer.CreateWebhookEndpoint(ctx, &CreateWebhookEndpointReq{
+		VendorPk:           vendor.Pk,
+		ExecutiveContactPk: owner.Pk,
+		Url:                ts.URL,
+		Events:             []string{MessageCreatedEvent},
+	})
+	require.NoError(t, err)
+
+	_, err = test.BuyerServer.PostBuyerMessageToConversation(ctx,
+		&PostBuyerMessageToConversationReq{
+			BuyerPk:            buyer.Pk,
+			VendorId:           vendor.Id,
+			MessageDescription: "is this in stock?",
+		})
+	require.NoError(t, err)
+
+	now := time.Now()
+	due, err := test.db.Limited_WebhookDelivery_By_Status_And_NextAttemptAt_LessOrEqual_OrderBy_Asc_NextAttemptAt(
+		ctx, database.WebhookDelivery_Status(webhookPending),
+		database.WebhookDelivery_NextAttemptAt(now), webhookBatchSize, 0)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	//only one dispatcher gets the lease
+	var first, second bool
+	err = test.db.WithTx(ctx, func(ctx context.Context, tx *database.Tx) error {
+		first, err = tx.LeaseWebhookDelivery(ctx, due[0].Pk, webhookPending, now,
+			now.Add(webhookLease))
+		if err != nil {
+			return err
+		}
+
+		second, err = tx.LeaseWebhookDelivery(ctx, due[0].Pk, webhookPending, now,
+			now.Add(webhookLease))
+		return err
+	})
+	require.NoError(t, err)
+	require.True(t, first)
+	require.False(t, second)
+
+	//a dispatcher that read the delivery before it was leased leaves it alone
+	var ok bool
+	test.at(now, func() {
+		ok, err = dispatcher.deliver(ctx, due[0])
+	})
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Empty(t, receiver.received)
+
+	//and it is sent again if the lease runs out
+	delivered := test.deliverAt(ctx, dispatcher, now.Add(webhookLease))
+	require.Equal(t, delivered, 1)
+	require.Len(t, receiver.received, 1)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	require.Equal(t, webhookBackoff(1), time.Minute)
+	require.Equal(t, webhookBackoff(2), 2*time.Minute)
+	require.Equal(t, webhookBackoff(5), 16*time.Minute)
+	require.Equal(t, webhookBackoff(20), webhookMaxBackoff)
+}
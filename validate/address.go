@@ -0,0 +1,226 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zeebo/errs"
+)
+
+//DefaultCountryCode is used for addresses submitted without a country. every address was a US one
+//before ladybug accepted others
+const DefaultCountryCode = "US"
+
+const (
+	maxAddressLineLength = 100
+	maxPostalCodeLength  = 12
+)
+
+type Address struct {
+	CountryCode string `json:"countryCode"`
+	Line1       string `json:"line1"`
+	Line2       string `json:"line2"`
+	City        string `json:"city"`
+	Region      string `json:"region"`
+	PostalCode  string `json:"postalCode"`
+}
+
+//legacyAddress has the keys addresses were sent with before they were international. they are
+//still read for one release so that older clients keep working. zip was a number, so zips that
+//started with 0 lost it
+type legacyAddress struct {
+	StreetAddress string          `json:"streetAddress"`
+	State         string          `json:"state"`
+	Zip           json.RawMessage `json:"zip"`
+}
+
+//UnmarshalJSON reads an address, filling in fields left empty from their legacy keys
+func (a *Address) UnmarshalJSON(b []byte) error {
+	type address Address
+	var current address
+	err := json.Unmarshal(b, &current)
+	if err != nil {
+		return err
+	}
+
+	var legacy legacyAddress
+	err = json.Unmarshal(b, &legacy)
+	if err != nil {
+		return err
+	}
+
+	if current.Line1 == "" {
+		current.Line1 = legacy.StreetAddress
+	}
+	if current.Region == "" {
+		current.Region = legacy.State
+	}
+	if current.PostalCode == "" && len(legacy.Zip) > 0 && string(legacy.Zip) != "null" {
+		var zip int64
+		if json.Unmarshal(legacy.Zip, &zip) == nil {
+			current.PostalCode = fmt.Sprintf("%05d", zip)
+		} else if err := json.Unmarshal(legacy.Zip, &current.PostalCode); err != nil {
+			return errs.New("zip must be a number or a string")
+		}
+	}
+
+	*a = Address(current)
+	return nil
+}
+
+//countryRules describes what a complete address looks like in one country
+type countryRules struct {
+	//regionName is what the country calls its regions, e.g. state or province
+	regionName     string
+	regionRequired bool
+
+	//postalName is what the country calls its postal codes. postalRegex is matched against the
+	//upper cased code
+	postalName     string
+	postalRegex    *regexp.Regexp
+	postalRequired bool
+}
+
+var (
+	countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+	//countries without rules of their own use these
+	defaultCountryRules = &countryRules{
+		regionName:  "region",
+		postalName:  "postal code",
+		postalRegex: regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]*$`),
+	}
+
+	countries = map[string]*countryRules{
+		"US": {
+			regionName:     "state",
+			regionRequired: true,
+			postalName:     "zip code",
+			postalRegex:    regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+			postalRequired: true,
+		},
+		"CA": {
+			regionName:     "province",
+			regionRequired: true,
+			postalName:     "postal code",
+			postalRegex:    regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+			postalRequired: true,
+		},
+		"GB": {
+			regionName:     "county",
+			postalName:     "postcode",
+			postalRegex:    regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+			postalRequired: true,
+		},
+		"IE": {
+			regionName:  "county",
+			postalName:  "eircode",
+			postalRegex: regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`),
+		},
+		"AU": {
+			regionName:     "state",
+			regionRequired: true,
+			postalName:     "postcode",
+			postalRegex:    regexp.MustCompile(`^\d{4}$`),
+			postalRequired: true,
+		},
+		"DE": {
+			regionName:     "state",
+			postalName:     "postal code",
+			postalRegex:    regexp.MustCompile(`^\d{5}$`),
+			postalRequired: true,
+		},
+		"FR": {
+			regionName:     "region",
+			postalName:     "postal code",
+			postalRegex:    regexp.MustCompile(`^\d{5}$`),
+			postalRequired: true,
+		},
+		"JP": {
+			regionName:     "prefecture",
+			regionRequired: true,
+			postalName:     "postal code",
+			postalRegex:    regexp.MustCompile(`^\d{3}-?\d{4}$`),
+			postalRequired: true,
+		},
+	}
+)
+
+func rulesFor(country_code string) *countryRules {
+	if rules, ok := countries[country_code]; ok {
+		return rules
+	}
+	return defaultCountryRules
+}
+
+//NormalizeAddress trims each field and upper cases the country and postal codes so they can be
+//checked and stored consistently
+func NormalizeAddress(a *Address) {
+	if a == nil {
+		return
+	}
+
+	a.CountryCode = strings.ToUpper(strings.TrimSpace(a.CountryCode))
+	if a.CountryCode == "" {
+		a.CountryCode = DefaultCountryCode
+	}
+
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.Region = strings.TrimSpace(a.Region)
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+}
+
+//AddressIsEmpty reports whether nothing but perhaps a country was filled in
+func AddressIsEmpty(a *Address) bool {
+	if a == nil {
+		return true
+	}
+
+	return a.Line1 == "" &&
+		a.Line2 == "" &&
+		a.City == "" &&
+		a.Region == "" &&
+		a.PostalCode == ""
+}
+
+//CheckAddress makes sure an address has what its country needs for a parcel to arrive. the
+//address should be normalized first
+func CheckAddress(a *Address) error {
+	if a == nil {
+		return errs.New("no address was submitted")
+	}
+
+	if !countryCodeRegex.MatchString(a.CountryCode) {
+		return errs.New("country code must be a two letter ISO 3166 code")
+	}
+
+	rules := rulesFor(a.CountryCode)
+
+	switch {
+	case a.Line1 == "":
+		return errs.New("address line 1 is required")
+	case a.City == "":
+		return errs.New("city is required")
+	case a.Region == "" && rules.regionRequired:
+		return errs.New("%s is required", rules.regionName)
+	case a.PostalCode == "" && rules.postalRequired:
+		return errs.New("%s is required", rules.postalName)
+	case len(a.Line1) > maxAddressLineLength || len(a.Line2) > maxAddressLineLength ||
+		len(a.City) > maxAddressLineLength || len(a.Region) > maxAddressLineLength:
+		return errs.New("address fields cannot exceed %d characters", maxAddressLineLength)
+	}
+
+	if a.PostalCode == "" {
+		return nil
+	}
+
+	if len(a.PostalCode) > maxPostalCodeLength || !rules.postalRegex.MatchString(a.PostalCode) {
+		return errs.New("%s is not a valid %s", a.PostalCode, rules.postalName)
+	}
+
+	return nil
+}
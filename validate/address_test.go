@@ -0,0 +1,118 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+)
+
+func TestValidateAddress(t *testing.T) {
+	address_tests := []struct {
+		input    Address
+		expected error
+	}{
+		{Address{CountryCode: "US", Line1: "1 Post Office Sq", City: "Boston", Region: "MA",
+			PostalCode: "02110"}, nil},
+		{Address{CountryCode: "US", Line1: "1 Post Office Sq", City: "Boston", Region: "MA",
+			PostalCode: "02110-1234"}, nil},
+		{Address{CountryCode: "US", Line1: "1 Post Office Sq", City: "Boston", Region: "MA",
+			PostalCode: "2110"}, errs.New("2110 is not a valid zip code")},
+		{Address{CountryCode: "US", Line1: "1 Post Office Sq", City: "Boston",
+			PostalCode: "02110"}, errs.New("state is required")},
+		{Address{CountryCode: "CA", Line1: "290 Bremner Blvd", City: "Toronto", Region: "ON",
+			PostalCode: "M5V 3L9"}, nil},
+		{Address{CountryCode: "CA", Line1: "290 Bremner Blvd", City: "Toronto",
+			PostalCode: "M5V 3L9"}, errs.New("province is required")},
+		{Address{CountryCode: "GB", Line1: "10 Downing St", City: "London",
+			PostalCode: "SW1A 2AA"}, nil},
+		{Address{CountryCode: "GB", Line1: "10 Downing St", City: "London",
+			PostalCode: "12345"}, errs.New("12345 is not a valid postcode")},
+		{Address{CountryCode: "IE", Line1: "1 Main St", City: "Cork"}, nil},
+		{Address{CountryCode: "NZ", Line1: "1 Queen St", City: "Auckland"}, nil},
+		{Address{CountryCode: "USA", Line1: "1 Post Office Sq", City: "Boston", Region: "MA",
+			PostalCode: "02110"}, errs.New("country code must be a two letter ISO 3166 code")},
+		{Address{CountryCode: "US", City: "Boston", Region: "MA", PostalCode: "02110"},
+			errs.New("address line 1 is required")},
+		{Address{CountryCode: "US", Line1: "1 Post Office Sq", Region: "MA",
+			PostalCode: "02110"}, errs.New("city is required")},
+		{Address{CountryCode: "US", Line1: "1 Post Office Sq", City: "Boston", Region: "MA"},
+			errs.New("zip code is required")},
+	}
+
+	for _, tt := range address_tests {
+		actual := CheckAddress(&tt.input)
+
+		if tt.expected == nil {
+			require.NoError(t, actual, fmt.Sprintf("CheckAddress(%+v)", tt.input))
+		} else {
+			require.EqualError(t, actual, tt.expected.Error(),
+				fmt.Sprintf("CheckAddress(%+v)", tt.input))
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	address := &Address{
+		Line1:       " 290 Bremner Blvd ",
+		City:        "Toronto",
+		Region:      "ON",
+		CountryCode: "ca",
+		PostalCode:  "m5v 3l9",
+	}
+
+	NormalizeAddress(address)
+	require.Equal(t, address.CountryCode, "CA")
+	require.Equal(t, address.Line1, "290 Bremner Blvd")
+	require.Equal(t, address.PostalCode, "M5V 3L9")
+	require.NoError(t, CheckAddress(address))
+
+	//addresses without a country are us ones
+	address = &Address{Line1: "1 Post Office Sq"}
+	NormalizeAddress(address)
+	require.Equal(t, address.CountryCode, DefaultCountryCode)
+}
+
+func TestAddressIsEmpty(t *testing.T) {
+	address := &Address{}
+
+	actual := AddressIsEmpty(address)
+	expected := true
+	require.Equal(t, actual, expected,
+		fmt.Sprintf("shippingAddress(sur)  actual:%t expected:%t", actual, expected))
+
+	//a country on its own is still empty
+	require.True(t, AddressIsEmpty(&Address{CountryCode: "US"}))
+}
+
+func TestAddressIsNotEmpty(t *testing.T) {
+	address := &Address{
+		Line1: "not empty"}
+
+	actual := AddressIsEmpty(address)
+	expected := false
+	require.Equal(t, actual, expected,
+		fmt.Sprintf("shippingAddress(sur)  actual:%t expected:%t", actual, expected))
+}
+
+func TestAddressLegacyKeys(t *testing.T) {
+	var address Address
+	err := json.Unmarshal([]byte(`{"streetAddress": "1 Post Office Sq", "city": "Boston",
+		"state": "MA", "zip": 2110}`), &address)
+	require.NoError(t, err)
+	require.Equal(t, address, Address{Line1: "1 Post Office Sq", City: "Boston", Region: "MA",
+		PostalCode: "02110"})
+
+	//zips can be strings too, and the current keys win when both are sent
+	address = Address{}
+	err = json.Unmarshal([]byte(`{"line1": "1 Post Office Sq", "streetAddress": "old",
+		"city": "Boston", "region": "MA", "zip": "02110-1234"}`), &address)
+	require.NoError(t, err)
+	require.Equal(t, address, Address{Line1: "1 Post Office Sq", City: "Boston", Region: "MA",
+		PostalCode: "02110-1234"})
+
+	err = json.Unmarshal([]byte(`{"zip": true}`), &address)
+	require.Error(t, err)
+}
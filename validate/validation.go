@@ -8,13 +8,6 @@ import (
 	"github.com/zeebo/errs"
 )
 
-type Address struct {
-	StreetAddress string `json:"streetAddress"`
-	City          string `json:"city"`
-	State         string `json:"state"`
-	Zip           int    `json"zip"`
-}
-
 type validateFunc func(r rune) bool
 
 type passwordPolicy struct {
@@ -63,6 +56,9 @@ var (
 
 	//taken from https://stackoverflow.com/questions/201323/how-to-validate-an-email-address-using-a-regular-expression
 	emailRegex = regexp.MustCompile(`(?:[a-z0-9!#$%&'*+/=?^_{|}~-]+(?:\.[a-z0-9!#$%&'*+/=?^_{|}~-]+)*|"(?:[\x01-\x08\x0b\x0c\x0e-\x1f\x21\x23-\x5b\x5d-\x7f]|\\[\x01-\x09\x0b\x0c\x0e-\x7f])*")@(?:(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z0-9](?:[a-z0-9-]*[a-z0-9])?|\[(?:(?:(2(5[0-5]|[0-4][0-9])|1[0-9][0-9]|[1-9]?[0-9]))\.){3}(?:(2(5[0-5]|[0-4][0-9])|1[0-9][0-9]|[1-9]?[0-9])|[a-z0-9-]*[a-z0-9]:(?:[\x01-\x08\x0b\x0c\x0e-\x1f\x21-\x5a\x53-\x7f]|\\[\x01-\x09\x0b\x0c\x0e-\x7f])+)\])`)
+
+	//slugs are lower case words joined by single hyphens so they read well in a url
+	slugRegex = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
 )
 
 func checkMinPasswordPolicy(pw string) bool {
@@ -73,36 +69,6 @@ func checkMaxPasswordPolicy(pw string) bool {
 	return len(pw) <= MaxPasswordLen
 }
 
-func AddressIsEmpty(a *Address) bool {
-	if a == nil {
-		return true
-	}
-
-	if a.StreetAddress == "" &&
-		a.City == "" &&
-		a.State == "" &&
-		a.Zip == 0 {
-		return true
-	}
-
-	return false
-}
-
-func CheckAddress(a *Address) error {
-	if a == nil {
-		return errs.New("no address was submitted")
-	}
-
-	if a.StreetAddress == "" ||
-		a.City == "" ||
-		a.State == "" ||
-		a.Zip == 0 {
-		return errs.New("city, state, street, or zip fields are blank for billing address")
-	}
-
-	return nil
-}
-
 func CheckEmail(email string) error {
 	if len(email) <= 0 {
 		return errs.New("email address cannot be empty")
@@ -147,6 +113,19 @@ func CheckName(name string) error {
 	}
 }
 
+func CheckSlug(slug string) error {
+	switch {
+	case len(slug) < 3:
+		return errs.New("slug must be at least 3 characters")
+	case len(slug) > 50:
+		return errs.New("slug cannot exceed 50 characters")
+	case !slugRegex.MatchString(slug):
+		return errs.New("slug may only contain lower case letters, numbers and single hyphens")
+	default:
+		return nil
+	}
+}
+
 func checkForRune(fn func(r rune) bool) func(s string) bool {
 	return func(s string) bool {
 		for _, r := range s {
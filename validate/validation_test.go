@@ -72,6 +72,37 @@ func TestValidateName(t *testing.T) {
 	}
 }
 
+func TestValidateSlug(t *testing.T) {
+	slug_tests := []struct {
+		input    string
+		expected error
+	}{
+		{"ab", errs.New("slug must be at least 3 characters")},
+		{"longer-than-fifty-characters-should-not-be-allowed-1",
+			errs.New("slug cannot exceed 50 characters"),
+		},
+		{"Upper-Case", errs.New("slug may only contain lower case letters, numbers and single " +
+			"hyphens")},
+		{"double--hyphen", errs.New("slug may only contain lower case letters, numbers and " +
+			"single hyphens")},
+		{"-leading", errs.New("slug may only contain lower case letters, numbers and single " +
+			"hyphens")},
+		{"acme-widgets", nil},
+		{"shop42", nil},
+	}
+
+	for _, tt := range slug_tests {
+		actual := CheckSlug(tt.input)
+
+		if tt.expected == nil {
+			require.NoError(t, actual, fmt.Sprintf("CheckSlug(%s)", tt.input))
+		} else {
+			require.EqualError(t, actual, tt.expected.Error(),
+				fmt.Sprintf("CheckSlug(%s)", tt.input))
+		}
+	}
+}
+
 func TestValidatePassword(t *testing.T) {
 	password_tests := []struct {
 		input    string
@@ -110,61 +141,3 @@ func TestValidatePassword(t *testing.T) {
 		}
 	}
 }
-
-func TestValidateIncompleteAddress(t *testing.T) {
-	address := &Address{
-		StreetAddress: "",
-		City:          "Orlando",
-		State:         "FL",
-		Zip:           98074,
-	}
-
-	actual := CheckAddress(address)
-	expected := errs.New("city, state, street, or zip fields are blank for billing address")
-	require.EqualError(t, actual, expected.Error())
-}
-
-func TestValidateZipCodeIsZero(t *testing.T) {
-	address := &Address{
-		StreetAddress: "florida ln",
-		City:          "Orlando",
-		State:         "FL",
-		Zip:           0,
-	}
-
-	actual := CheckAddress(address)
-	expected := errs.New("city, state, street, or zip fields are blank for billing address")
-	require.EqualError(t, actual, expected.Error())
-}
-
-func TestValidateStreetMissing(t *testing.T) {
-	address := &Address{
-		StreetAddress: "florida ln",
-		City:          "Orlando",
-		State:         "",
-		Zip:           0,
-	}
-
-	actual := CheckAddress(address)
-	expected := errs.New("city, state, street, or zip fields are blank for billing address")
-	require.EqualError(t, actual, expected.Error())
-}
-
-func TestAddressIsEmpty(t *testing.T) {
-	address := &Address{}
-
-	actual := AddressIsEmpty(address)
-	expected := true
-	require.Equal(t, actual, expected,
-		fmt.Sprintf("shippingAddress(sur)  actual:%t expected:%t", actual, expected))
-}
-
-func TestAddressIsNotEmpty(t *testing.T) {
-	address := &Address{
-		StreetAddress: "not empty"}
-
-	actual := AddressIsEmpty(address)
-	expected := false
-	require.Equal(t, actual, expected,
-		fmt.Sprintf("shippingAddress(sur)  actual:%t expected:%t", actual, expected))
-}